@@ -0,0 +1,57 @@
+package auth
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "strings"
+)
+
+// API keys look like ct_<prefix>_<secret>. The prefix is stored in the clear
+// to find the key and to tell keys apart in listings; the secret carries 256
+// random bits, so a single unsalted SHA-256 is enough to store the key safely.
+const (
+    apiKeyTag       = "ct_"
+    apiKeyPrefixLen = 6
+    apiKeySecretLen = 32
+)
+
+// NewAPIKey generates an API key and returns it with its prefix and the hash
+// to store. The key itself must only be shown once.
+func NewAPIKey() (key, prefix, hash string, err error) {
+    buf := make([]byte, apiKeyPrefixLen+apiKeySecretLen)
+    if _, err := rand.Read(buf); err != nil {
+        return "", "", "", fmt.Errorf("generate api key: %w", err)
+    }
+    prefix = hex.EncodeToString(buf[:apiKeyPrefixLen])
+    key = apiKeyTag + prefix + "_" + base64.RawURLEncoding.EncodeToString(buf[apiKeyPrefixLen:])
+    return key, prefix, HashAPIKey(key), nil
+}
+
+// ParseAPIKeyPrefix returns the prefix of a key in the format produced by
+// NewAPIKey.
+func ParseAPIKeyPrefix(key string) (string, bool) {
+    rest, ok := strings.CutPrefix(key, apiKeyTag)
+    if !ok {
+        return "", false
+    }
+    prefix, secret, ok := strings.Cut(rest, "_")
+    if !ok || len(prefix) != 2*apiKeyPrefixLen || secret == "" {
+        return "", false
+    }
+    return prefix, true
+}
+
+// HashAPIKey returns the hex SHA-256 digest under which a key is stored.
+func HashAPIKey(key string) string {
+    sum := sha256.Sum256([]byte(key))
+    return hex.EncodeToString(sum[:])
+}
+
+// VerifyAPIKey reports whether key matches a stored hash in constant time.
+func VerifyAPIKey(hash, key string) bool {
+    return subtle.ConstantTimeCompare([]byte(hash), []byte(HashAPIKey(key))) == 1
+}
@@ -0,0 +1,70 @@
+// Package auth provides the credential primitives behind authentication:
+// password hashing, signed session tokens, API keys and TOTP second factors.
+package auth
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "strings"
+
+    "golang.org/x/crypto/argon2"
+)
+
+// Argon2id cost parameters, following the OWASP minimum recommendation.
+const (
+    argonTime    = 2
+    argonMemory  = 19 * 1024
+    argonThreads = 1
+    argonSaltLen = 16
+    argonKeyLen  = 32
+)
+
+// ErrMalformedHash is returned when a stored password hash cannot be decoded.
+var ErrMalformedHash = errors.New("malformed password hash")
+
+// HashPassword hashes password with argon2id and a random salt, returning it
+// in the PHC string format ($argon2id$v=19$m=...,t=...,p=...$salt$key) so
+// that the parameters travel with the hash.
+func HashPassword(password string) (string, error) {
+    salt := make([]byte, argonSaltLen)
+    if _, err := rand.Read(salt); err != nil {
+        return "", fmt.Errorf("generate salt: %w", err)
+    }
+    key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+    return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argonMemory, argonTime, argonThreads,
+        base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword reports whether password matches a hash produced by
+// HashPassword, using the parameters recorded in the hash.
+func VerifyPassword(encoded, password string) (bool, error) {
+    parts := strings.Split(encoded, "$")
+    if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+        return false, ErrMalformedHash
+    }
+    var version int
+    if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+        return false, ErrMalformedHash
+    }
+    var (
+        memory, time uint32
+        threads      uint8
+    )
+    if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil || time == 0 || threads == 0 {
+        return false, ErrMalformedHash
+    }
+    salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+    if err != nil {
+        return false, ErrMalformedHash
+    }
+    key, err := base64.RawStdEncoding.DecodeString(parts[5])
+    if err != nil || len(key) == 0 {
+        return false, ErrMalformedHash
+    }
+
+    candidate := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+    return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
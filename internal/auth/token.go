@@ -0,0 +1,112 @@
+package auth
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Token types carried in the typ claim, so that a refresh token cannot be
+// presented as an access token or the other way round.
+const (
+    TokenAccess  = "access"
+    TokenRefresh = "refresh"
+    // TokenMFA is issued after a correct password to users with two-factor
+    // authentication, and only allows completing the login.
+    TokenMFA = "mfa"
+    // TokenVerifyEmail and TokenResetPassword are mailed to users and work
+    // once each.
+    TokenVerifyEmail   = "verify_email"
+    TokenResetPassword = "reset_password"
+)
+
+var (
+    // ErrInvalidToken is returned for tokens that are malformed, carry a bad
+    // signature or use an unexpected algorithm.
+    ErrInvalidToken = errors.New("invalid token")
+    // ErrTokenExpired is returned for well-formed tokens past their expiry.
+    ErrTokenExpired = errors.New("token expired")
+)
+
+// Claims are the JWT claims the API issues.
+type Claims struct {
+    Subject string `json:"sub"`
+    Type    string `json:"typ"`
+    // ID identifies a refresh token so that it can be rotated and revoked,
+    // or a mailed token so that it works only once.
+    ID        string `json:"jti,omitempty"`
+    IssuedAt  int64  `json:"iat"`
+    ExpiresAt int64  `json:"exp"`
+}
+
+// Signer issues and verifies JWTs signed with HMAC-SHA256.
+type Signer struct {
+    key []byte
+}
+
+// NewSigner creates a Signer with the given secret key.
+func NewSigner(key []byte) *Signer {
+    return &Signer{key: key}
+}
+
+// tokenHeader is the fixed JOSE header of every issued token.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Sign encodes and signs claims as a compact JWT.
+func (s *Signer) Sign(claims Claims) (string, error) {
+    payload, err := json.Marshal(claims)
+    if err != nil {
+        return "", fmt.Errorf("encode claims: %w", err)
+    }
+    signingInput := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+    return signingInput + "." + base64.RawURLEncoding.EncodeToString(s.mac(signingInput)), nil
+}
+
+// Verify checks the token's signature and expiry at now and returns its
+// claims. Only HS256 tokens are accepted, whatever their header claims.
+func (s *Signer) Verify(token string, now time.Time) (Claims, error) {
+    parts := strings.Split(token, ".")
+    if len(parts) != 3 {
+        return Claims{}, ErrInvalidToken
+    }
+
+    header, err := base64.RawURLEncoding.DecodeString(parts[0])
+    if err != nil {
+        return Claims{}, ErrInvalidToken
+    }
+    var h struct {
+        Alg string `json:"alg"`
+    }
+    if err := json.Unmarshal(header, &h); err != nil || h.Alg != "HS256" {
+        return Claims{}, ErrInvalidToken
+    }
+
+    signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+    if err != nil || !hmac.Equal(signature, s.mac(parts[0]+"."+parts[1])) {
+        return Claims{}, ErrInvalidToken
+    }
+
+    payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+    if err != nil {
+        return Claims{}, ErrInvalidToken
+    }
+    var claims Claims
+    if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" {
+        return Claims{}, ErrInvalidToken
+    }
+    if now.Unix() >= claims.ExpiresAt {
+        return Claims{}, ErrTokenExpired
+    }
+    return claims, nil
+}
+
+func (s *Signer) mac(signingInput string) []byte {
+    m := hmac.New(sha256.New, s.key)
+    m.Write([]byte(signingInput))
+    return m.Sum(nil)
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base32"
+    "encoding/binary"
+    "encoding/hex"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// TOTP parameters (RFC 6238). SHA-1, six digits and a 30 second step are
+// the defaults every authenticator app supports.
+const (
+    totpPeriod    = 30
+    totpDigits    = 6
+    totpModulus   = 1_000_000 // 10^totpDigits
+    totpSecretLen = 20
+    // totpSkew is how many steps either side of the current one are
+    // accepted, to allow for clock drift and slow typing.
+    totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret generates a random TOTP secret, base32 encoded as
+// authenticator apps expect it.
+func NewTOTPSecret() (string, error) {
+    secret := make([]byte, totpSecretLen)
+    if _, err := rand.Read(secret); err != nil {
+        return "", fmt.Errorf("generate totp secret: %w", err)
+    }
+    return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI that authenticator apps
+// import, usually from a QR code.
+func TOTPProvisioningURI(issuer, account, secret string) string {
+    query := url.Values{}
+    query.Set("secret", secret)
+    query.Set("issuer", issuer)
+    query.Set("algorithm", "SHA1")
+    query.Set("digits", fmt.Sprint(totpDigits))
+    query.Set("period", fmt.Sprint(totpPeriod))
+    label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+    return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TOTPStep returns the time step t falls in.
+func TOTPStep(t time.Time) int64 {
+    return t.Unix() / totpPeriod
+}
+
+// TOTPCode returns the code for a time step.
+func TOTPCode(secret string, step int64) (string, error) {
+    key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+    if err != nil {
+        return "", fmt.Errorf("decode totp secret: %w", err)
+    }
+    var counter [8]byte
+    binary.BigEndian.PutUint64(counter[:], uint64(step))
+    m := hmac.New(sha1.New, key)
+    m.Write(counter[:])
+    sum := m.Sum(nil)
+
+    // Dynamic truncation (RFC 4226 section 5.3).
+    offset := sum[len(sum)-1] & 0x0f
+    value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+    return fmt.Sprintf("%0*d", totpDigits, value%totpModulus), nil
+}
+
+// MatchTOTP checks code against the steps around now and returns the step
+// it belongs to, so that callers can refuse a code from a step that was
+// already used.
+func MatchTOTP(secret, code string, now time.Time) (int64, bool, error) {
+    code = strings.TrimSpace(code)
+    if len(code) != totpDigits {
+        return 0, false, nil
+    }
+    current := TOTPStep(now)
+    for step := current - totpSkew; step <= current+totpSkew; step++ {
+        expected, err := TOTPCode(secret, step)
+        if err != nil {
+            return 0, false, err
+        }
+        if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+            return step, true, nil
+        }
+    }
+    return 0, false, nil
+}
+
+// Recovery codes are 80 random bits written as four groups of four base32
+// characters. That is too much entropy to guess, so like API keys they are
+// stored as a plain SHA-256 hash.
+const recoveryCodeLen = 10
+
+// NewRecoveryCodes generates n recovery codes.
+func NewRecoveryCodes(n int) ([]string, error) {
+    codes := make([]string, n)
+    buf := make([]byte, recoveryCodeLen)
+    for i := range codes {
+        if _, err := rand.Read(buf); err != nil {
+            return nil, fmt.Errorf("generate recovery code: %w", err)
+        }
+        encoded := totpEncoding.EncodeToString(buf)
+        codes[i] = encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16]
+    }
+    return codes, nil
+}
+
+// HashRecoveryCode returns the hex SHA-256 digest under which a recovery code
+// is stored. Case, spaces and dashes are ignored so that codes can be typed
+// back loosely.
+func HashRecoveryCode(code string) string {
+    normalized := strings.Map(func(r rune) rune {
+        if r == '-' || r == ' ' {
+            return -1
+        }
+        return r
+    }, strings.ToUpper(code))
+    sum := sha256.Sum256([]byte(normalized))
+    return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+    "testing"
+    "time"
+)
+
+// rfc6238Secret is the SHA-1 key from RFC 6238 appendix B, "12345678901234567890",
+// base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC's SHA-1 vectors are eight digits; six-digit codes are their last
+// six digits.
+var rfc6238Vectors = []struct {
+    unix int64
+    code string
+}{
+    {59, "287082"},
+    {1111111109, "081804"},
+    {1111111111, "050471"},
+    {1234567890, "005924"},
+    {2000000000, "279037"},
+    {20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+    for _, tt := range rfc6238Vectors {
+        step := TOTPStep(time.Unix(tt.unix, 0))
+        got, err := TOTPCode(rfc6238Secret, step)
+        if err != nil {
+            t.Fatalf("TOTPCode at %d: %v", tt.unix, err)
+        }
+        if got != tt.code {
+            t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.code)
+        }
+    }
+}
+
+func TestTOTPCodeLowercaseSecret(t *testing.T) {
+    got, err := TOTPCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", TOTPStep(time.Unix(59, 0)))
+    if err != nil || got != "287082" {
+        t.Errorf("TOTPCode with a lowercase secret = %s, %v; want 287082", got, err)
+    }
+}
+
+func TestMatchTOTP(t *testing.T) {
+    now := time.Unix(1111111111, 0)
+    current := TOTPStep(now)
+    code := func(step int64) string {
+        c, err := TOTPCode(rfc6238Secret, step)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return c
+    }
+
+    tests := []struct {
+        name     string
+        code     string
+        wantStep int64
+        wantOK   bool
+    }{
+        {"current step", code(current), current, true},
+        {"previous step", code(current - 1), current - 1, true},
+        {"next step", code(current + 1), current + 1, true},
+        {"padded with spaces", " " + code(current) + " ", current, true},
+        {"two steps behind", code(current - 2), 0, false},
+        {"two steps ahead", code(current + 2), 0, false},
+        {"too short", code(current)[:5], 0, false},
+        {"empty", "", 0, false},
+    }
+    for _, tt := range tests {
+        step, ok, err := MatchTOTP(rfc6238Secret, tt.code, now)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if ok != tt.wantOK || step != tt.wantStep {
+            t.Errorf("%s: MatchTOTP = %d, %t; want %d, %t", tt.name, step, ok, tt.wantStep, tt.wantOK)
+        }
+    }
+
+    if _, _, err := MatchTOTP("not base32!", "123456", now); err == nil {
+        t.Error("MatchTOTP with an invalid secret succeeded")
+    }
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+    want := HashRecoveryCode("ABCD-EFGH-IJKL-MNOP")
+    for _, typed := range []string{"abcd-efgh-ijkl-mnop", "ABCDEFGHIJKLMNOP", "abcd efgh ijkl mnop"} {
+        if got := HashRecoveryCode(typed); got != want {
+            t.Errorf("HashRecoveryCode(%q) differs from the canonical form", typed)
+        }
+    }
+    if HashRecoveryCode("ABCD-EFGH-IJKL-MNOQ") == want {
+        t.Error("different recovery codes share a hash")
+    }
+}
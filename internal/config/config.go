@@ -3,12 +3,72 @@ package config
 import (
     "fmt"
     "os"
+    "strconv"
+    "time"
 )
 
 // Config contains runtime configuration for the API server.
 type Config struct {
     Environment string
-    ServerPort string
+    ServerPort  string
+    // StorageDriver selects the repository backend ("memory" or "sqlite").
+    StorageDriver string
+    // SQLitePath is the database file used when StorageDriver is "sqlite".
+    SQLitePath string
+    // ExchangeRatesFile points at a JSON rate table; when empty a built-in static table is used.
+    ExchangeRatesFile string
+    // BitcoinXPub and EthereumXPub are BIP44 account-level extended public keys
+    // used to derive invoice deposit addresses; a coin is not offered when unset.
+    BitcoinXPub  string
+    EthereumXPub string
+    // InvoiceTTL is how long an invoice stays payable.
+    InvoiceTTL time.Duration
+    // BitcoinEsploraURL is the Esplora API the payment watcher polls for BTC
+    // transactions; the watcher does not run when no chain client is configured.
+    BitcoinEsploraURL string
+    // EthereumExplorerURL is the Etherscan-compatible API the payment watcher
+    // polls for ETH transactions, called with EthereumExplorerAPIKey.
+    EthereumExplorerURL    string
+    EthereumExplorerAPIKey string
+    // BitcoinConfirmations and EthereumConfirmations are the confirmations a
+    // payment needs before it settles an invoice.
+    BitcoinConfirmations  int
+    EthereumConfirmations int
+    // PaymentPollInterval is how often the payment watcher checks pending invoices.
+    PaymentPollInterval time.Duration
+    // LightningNode selects the Lightning node invoices are issued through;
+    // "mock" runs an in-process node and empty disables Lightning payments.
+    LightningNode string
+    // ReservationTTL is how long stock is held for an unpaid order.
+    ReservationTTL time.Duration
+    // ReservationSweepInterval is how often expired stock holds are released.
+    ReservationSweepInterval time.Duration
+    // AllocationStrategy picks the warehouses that ship each order item:
+    // "priority", "nearest" or "fewest_splits".
+    AllocationStrategy string
+    // ShippingFee is the flat fee added to every order, written as an amount
+    // and a currency such as "4.99 USD"; orders ship free when it is empty.
+    ShippingFee string
+    // JWTSecret signs access and refresh tokens. It is required in production;
+    // elsewhere a random key is generated at startup when it is empty.
+    JWTSecret string
+    // AccessTokenTTL and RefreshTokenTTL are the lifetimes of issued tokens.
+    AccessTokenTTL  time.Duration
+    RefreshTokenTTL time.Duration
+    // AdminEmail names the account that is given the admin role, whether it
+    // registers later or already exists at startup.
+    AdminEmail string
+    // PublicURL is the storefront address that links in emails point at.
+    PublicURL string
+    // SMTPHost is the relay emails are sent through; when it is empty they
+    // are written to MailDropDir instead.
+    SMTPHost     string
+    SMTPPort     int
+    SMTPUsername string
+    SMTPPassword string
+    // MailFrom is the sender of every email.
+    MailFrom    string
+    MailDropDir string
 }
 
 // Load reads configuration values from the environment and applies sensible defaults.
@@ -23,8 +83,74 @@ func Load() Config {
         port = "8080"
     }
 
+    driver := os.Getenv("STORAGE_DRIVER")
+    if driver == "" {
+        driver = "memory"
+    }
+
+    sqlitePath := os.Getenv("SQLITE_PATH")
+    if sqlitePath == "" {
+        sqlitePath = "cryptotrade.db"
+    }
+
     return Config{
-        Environment: env,
-        ServerPort:  fmt.Sprintf(":%s", port),
+        Environment:              env,
+        ServerPort:               fmt.Sprintf(":%s", port),
+        StorageDriver:            driver,
+        SQLitePath:               sqlitePath,
+        ExchangeRatesFile:        os.Getenv("EXCHANGE_RATES_FILE"),
+        BitcoinXPub:              os.Getenv("BTC_XPUB"),
+        EthereumXPub:             os.Getenv("ETH_XPUB"),
+        InvoiceTTL:               durationEnv("INVOICE_TTL", 30*time.Minute),
+        BitcoinEsploraURL:        os.Getenv("BTC_ESPLORA_URL"),
+        EthereumExplorerURL:      os.Getenv("ETH_EXPLORER_URL"),
+        EthereumExplorerAPIKey:   os.Getenv("ETH_EXPLORER_API_KEY"),
+        BitcoinConfirmations:     intEnv("BTC_CONFIRMATIONS", 2),
+        EthereumConfirmations:    intEnv("ETH_CONFIRMATIONS", 12),
+        PaymentPollInterval:      durationEnv("PAYMENT_POLL_INTERVAL", 30*time.Second),
+        LightningNode:            os.Getenv("LIGHTNING_NODE"),
+        ReservationTTL:           durationEnv("RESERVATION_TTL", 30*time.Minute),
+        ReservationSweepInterval: durationEnv("RESERVATION_SWEEP_INTERVAL", time.Minute),
+        AllocationStrategy:       os.Getenv("ALLOCATION_STRATEGY"),
+        ShippingFee:              os.Getenv("SHIPPING_FEE"),
+        JWTSecret:                os.Getenv("JWT_SECRET"),
+        AccessTokenTTL:           durationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+        RefreshTokenTTL:          durationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+        AdminEmail:               os.Getenv("ADMIN_EMAIL"),
+        PublicURL:                stringEnv("PUBLIC_URL", "http://localhost:8080"),
+        SMTPHost:                 os.Getenv("SMTP_HOST"),
+        SMTPPort:                 intEnv("SMTP_PORT", 587),
+        SMTPUsername:             os.Getenv("SMTP_USERNAME"),
+        SMTPPassword:             os.Getenv("SMTP_PASSWORD"),
+        MailFrom:                 stringEnv("MAIL_FROM", "Cryptotrade <no-reply@localhost>"),
+        MailDropDir:              stringEnv("MAIL_DROP_DIR", "mail"),
+    }
+}
+
+// stringEnv returns the value of key, or def when the variable is unset.
+func stringEnv(key, def string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return def
+}
+
+// intEnv parses a positive integer from key, falling back to def when the
+// variable is unset or malformed.
+func intEnv(key string, def int) int {
+    n, err := strconv.Atoi(os.Getenv(key))
+    if err != nil || n <= 0 {
+        return def
+    }
+    return n
+}
+
+// durationEnv parses a Go duration from key, falling back to def when the
+// variable is unset or malformed.
+func durationEnv(key string, def time.Duration) time.Duration {
+    d, err := time.ParseDuration(os.Getenv(key))
+    if err != nil || d <= 0 {
+        return def
     }
+    return d
 }
@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// AccountTokenPurpose says what a one-time account token may be used for.
+type AccountTokenPurpose string
+
+const (
+    // PurposeVerifyEmail proves that the user receives mail at an address.
+    PurposeVerifyEmail AccountTokenPurpose = "verify_email"
+    // PurposeResetPassword lets a user who forgot their password set a new one.
+    PurposeResetPassword AccountTokenPurpose = "reset_password"
+)
+
+// AccountToken records a one-time token mailed to a user. The token itself
+// is a signed JWT naming this record, which is kept so that the token works
+// only once.
+type AccountToken struct {
+    ID      string              `json:"id"`
+    UserID  string              `json:"user_id"`
+    Purpose AccountTokenPurpose `json:"purpose"`
+    // Email is the address the token was sent to.
+    Email     string    `json:"email"`
+    ExpiresAt time.Time `json:"expires_at"`
+    CreatedAt time.Time `json:"created_at"`
+    // UsedAt is set once the token, or another of the user's tokens for the
+    // same purpose, has been used.
+    UsedAt *time.Time `json:"used_at,omitempty"`
+}
+
+// Usable reports whether the token can still be used at now.
+func (t AccountToken) Usable(now time.Time) bool {
+    return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,165 @@
+package domain
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "time"
+    "unicode/utf8"
+)
+
+// AddressType says what an address in a user's address book is used for.
+type AddressType string
+
+const (
+    AddressShipping AddressType = "shipping"
+    AddressBilling  AddressType = "billing"
+)
+
+// Valid reports whether t is a known address type.
+func (t AddressType) Valid() bool {
+    return t == AddressShipping || t == AddressBilling
+}
+
+// PostalAddress is an address as written on a parcel or an invoice.
+// Country is an ISO 3166-1 alpha-2 code.
+type PostalAddress struct {
+    Name       string `json:"name"`
+    Company    string `json:"company,omitempty"`
+    Line1      string `json:"line1"`
+    Line2      string `json:"line2,omitempty"`
+    City       string `json:"city"`
+    Region     string `json:"region,omitempty"`
+    PostalCode string `json:"postal_code,omitempty"`
+    Country    string `json:"country"`
+    Phone      string `json:"phone,omitempty"`
+    // Location is used in place of an order's ship_to for nearest-warehouse
+    // allocation.
+    Location *GeoPoint `json:"location,omitempty"`
+}
+
+// Address is an entry in a user's address book.
+type Address struct {
+    ID     string      `json:"id"`
+    UserID string      `json:"user_id"`
+    Type   AddressType `json:"type"`
+    PostalAddress
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate ensures the address is well formed.
+func (a Address) Validate() error {
+    if !a.Type.Valid() {
+        return fmt.Errorf("type must be %q or %q", AddressShipping, AddressBilling)
+    }
+    return a.PostalAddress.Validate()
+}
+
+// maxAddressFieldLength bounds each free-text field of an address.
+const maxAddressFieldLength = 200
+
+// addressFormat holds the rules of a country's postal addresses.
+type addressFormat struct {
+    // postalCode matches the country's postal codes; nil means the country
+    // does not use them.
+    postalCode *regexp.Regexp
+    // postalCodeOptional allows an address without a postal code in a
+    // country that has them.
+    postalCodeOptional bool
+    // regionRequired means addresses must name a state or province.
+    regionRequired bool
+}
+
+// addressFormats lists the countries whose rules are known. Addresses in
+// other countries only get the generic checks.
+var addressFormats = map[string]addressFormat{
+    "AT": {postalCode: regexp.MustCompile(`^\d{4}$`)},
+    "AU": {postalCode: regexp.MustCompile(`^\d{4}$`), regionRequired: true},
+    "BE": {postalCode: regexp.MustCompile(`^\d{4}$`)},
+    "BR": {postalCode: regexp.MustCompile(`^\d{5}-?\d{3}$`), regionRequired: true},
+    "CA": {postalCode: regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] ?\d[ABCEGHJ-NPRSTV-Z]\d$`), regionRequired: true},
+    "CH": {postalCode: regexp.MustCompile(`^\d{4}$`)},
+    "DE": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+    "DK": {postalCode: regexp.MustCompile(`^\d{4}$`)},
+    "ES": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+    "FR": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+    "GB": {postalCode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`)},
+    "HK": {},
+    "IE": {postalCode: regexp.MustCompile(`^(?:[AC-FHKNPRTV-Y]\d{2}|D6W) ?[0-9AC-FHKNPRTV-Y]{4}$`), postalCodeOptional: true},
+    "IN": {postalCode: regexp.MustCompile(`^\d{6}$`), regionRequired: true},
+    "IT": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+    "JP": {postalCode: regexp.MustCompile(`^\d{3}-?\d{4}$`), regionRequired: true},
+    "MX": {postalCode: regexp.MustCompile(`^\d{5}$`), regionRequired: true},
+    "NL": {postalCode: regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`)},
+    "NO": {postalCode: regexp.MustCompile(`^\d{4}$`)},
+    "NZ": {postalCode: regexp.MustCompile(`^\d{4}$`)},
+    "PL": {postalCode: regexp.MustCompile(`^\d{2}-\d{3}$`)},
+    "PT": {postalCode: regexp.MustCompile(`^\d{4}-\d{3}$`)},
+    "SE": {postalCode: regexp.MustCompile(`^\d{3} ?\d{2}$`)},
+    "SG": {postalCode: regexp.MustCompile(`^\d{6}$`)},
+    "US": {postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), regionRequired: true},
+}
+
+var (
+    countryCodeRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+    // postalCodeRegex is the generic shape of a postal code, for countries
+    // without known rules.
+    postalCodeRegex = regexp.MustCompile(`^[A-Z\d][A-Z\d -]{1,10}[A-Z\d]$`)
+    phoneRegex      = regexp.MustCompile(`^\+?[\d ()-]{6,20}$`)
+)
+
+// Validate ensures the address is complete for its country. Country and
+// PostalCode are expected in upper case.
+func (a PostalAddress) Validate() error {
+    switch {
+    case a.Name == "":
+        return errors.New("name is required")
+    case a.Line1 == "":
+        return errors.New("line1 is required")
+    case a.City == "":
+        return errors.New("city is required")
+    case !countryCodeRegex.MatchString(a.Country):
+        return errors.New("country must be an ISO 3166-1 alpha-2 code")
+    }
+    fields := []struct{ name, value string }{
+        {"name", a.Name}, {"company", a.Company}, {"line1", a.Line1}, {"line2", a.Line2}, {"city", a.City}, {"region", a.Region},
+    }
+    for _, field := range fields {
+        if utf8.RuneCountInString(field.value) > maxAddressFieldLength {
+            return fmt.Errorf("%s must be at most %d characters", field.name, maxAddressFieldLength)
+        }
+    }
+    if a.Phone != "" && !phoneRegex.MatchString(a.Phone) {
+        return errors.New("phone is invalid")
+    }
+    if a.Location != nil {
+        if err := a.Location.Validate(); err != nil {
+            return fmt.Errorf("location: %w", err)
+        }
+    }
+
+    format, known := addressFormats[a.Country]
+    if !known {
+        if a.PostalCode != "" && !postalCodeRegex.MatchString(a.PostalCode) {
+            return errors.New("postal_code is invalid")
+        }
+        return nil
+    }
+    if format.regionRequired && a.Region == "" {
+        return fmt.Errorf("region is required for addresses in %s", a.Country)
+    }
+    switch {
+    case format.postalCode == nil:
+        if a.PostalCode != "" {
+            return fmt.Errorf("addresses in %s have no postal_code", a.Country)
+        }
+    case a.PostalCode == "":
+        if !format.postalCodeOptional {
+            return fmt.Errorf("postal_code is required for addresses in %s", a.Country)
+        }
+    case !format.postalCode.MatchString(a.PostalCode):
+        return fmt.Errorf("postal_code is not valid for %s", a.Country)
+    }
+    return nil
+}
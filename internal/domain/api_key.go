@@ -0,0 +1,64 @@
+package domain
+
+import (
+    "errors"
+    "fmt"
+    "slices"
+    "time"
+)
+
+// APIKey lets another system call the API without a user session. The key
+// acts with the permissions in its scopes only.
+type APIKey struct {
+    ID   string `json:"id"`
+    Name string `json:"name"`
+    // Prefix is the public start of the key, which identifies it in
+    // listings without revealing it.
+    Prefix string `json:"prefix"`
+    // Hash is the digest the key is checked against; the key itself is
+    // never stored.
+    Hash   string       `json:"-"`
+    Scopes []Permission `json:"scopes"`
+    // CreatedBy is the ID of the user who issued the key.
+    CreatedBy  string     `json:"created_by"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+    LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+    RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+}
+
+// credentialPermissions cannot be granted to API keys, so that a leaked key
+// can neither issue further keys nor promote users.
+var credentialPermissions = []Permission{PermissionUsersManage, PermissionAPIKeysManage}
+
+// Validate ensures the key is well formed.
+func (k APIKey) Validate() error {
+    if k.Name == "" {
+        return errors.New("name is required")
+    }
+    if len(k.Scopes) == 0 {
+        return errors.New("at least one scope is required")
+    }
+    for _, scope := range k.Scopes {
+        if !scope.Valid() {
+            return fmt.Errorf("unknown scope %q", scope)
+        }
+        if slices.Contains(credentialPermissions, scope) {
+            return fmt.Errorf("scope %q cannot be granted to an API key", scope)
+        }
+    }
+    if k.ExpiresAt != nil && !k.ExpiresAt.After(k.CreatedAt) {
+        return errors.New("expires_at must be in the future")
+    }
+    return nil
+}
+
+// Active reports whether the key can authenticate at now.
+func (k APIKey) Active(now time.Time) bool {
+    return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Can reports whether the key's scopes include p.
+func (k APIKey) Can(p Permission) bool {
+    return slices.Contains(k.Scopes, p)
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+    "testing"
+    "time"
+)
+
+func TestAPIKeyValidate(t *testing.T) {
+    created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+    past, future := created.Add(-time.Second), created.Add(time.Hour)
+    tests := []struct {
+        name      string
+        key       APIKey
+        wantValid bool
+    }{
+        {"read scopes", APIKey{Name: "erp", Scopes: []Permission{PermissionCatalogWrite, PermissionOrdersRead}}, true},
+        {"expires later", APIKey{Name: "erp", Scopes: []Permission{PermissionOrdersRead}, ExpiresAt: &future}, true},
+        {"no name", APIKey{Scopes: []Permission{PermissionOrdersRead}}, false},
+        {"no scopes", APIKey{Name: "erp"}, false},
+        {"unknown scope", APIKey{Name: "erp", Scopes: []Permission{"orders:delete"}}, false},
+        {"users:manage", APIKey{Name: "erp", Scopes: []Permission{PermissionOrdersRead, PermissionUsersManage}}, false},
+        {"api_keys:manage", APIKey{Name: "erp", Scopes: []Permission{PermissionAPIKeysManage}}, false},
+        {"expired already", APIKey{Name: "erp", Scopes: []Permission{PermissionOrdersRead}, ExpiresAt: &past}, false},
+        {"expires as created", APIKey{Name: "erp", Scopes: []Permission{PermissionOrdersRead}, ExpiresAt: &created}, false},
+    }
+    for _, tt := range tests {
+        tt.key.CreatedAt = created
+        if err := tt.key.Validate(); (err == nil) != tt.wantValid {
+            t.Errorf("%s: Validate() = %v, want valid %t", tt.name, err, tt.wantValid)
+        }
+    }
+}
+
+func TestAPIKeyActive(t *testing.T) {
+    now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+    later := now.Add(time.Minute)
+    tests := []struct {
+        name string
+        key  APIKey
+        want bool
+    }{
+        {"no expiry", APIKey{}, true},
+        {"expires later", APIKey{ExpiresAt: &later}, true},
+        {"expires now", APIKey{ExpiresAt: &now}, false},
+        {"revoked", APIKey{RevokedAt: &now, ExpiresAt: &later}, false},
+    }
+    for _, tt := range tests {
+        if got := tt.key.Active(now); got != tt.want {
+            t.Errorf("%s: Active() = %t, want %t", tt.name, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+    "errors"
+    "time"
+)
+
+// CartItem is a product the user intends to buy.
+type CartItem struct {
+    ProductID string `json:"product_id"`
+    // SKU selects the variant of a product sold as variants.
+    SKU      string `json:"sku,omitempty"`
+    Quantity int    `json:"quantity"`
+}
+
+// Cart collects the items a user is about to check out. Each product, or
+// each variant of a product, appears at most once.
+type Cart struct {
+    UserID    string     `json:"user_id"`
+    Items     []CartItem `json:"items"`
+    UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Quantity returns how many of productID, or of its variant sku, the cart holds.
+func (c Cart) Quantity(productID, sku string) int {
+    for _, item := range c.Items {
+        if item.ProductID == productID && item.SKU == sku {
+            return item.Quantity
+        }
+    }
+    return 0
+}
+
+// SetQuantity sets the quantity of productID, or of its variant sku, adding
+// the line if it is new and removing it when quantity is zero.
+func (c *Cart) SetQuantity(productID, sku string, quantity int) error {
+    if productID == "" {
+        return errors.New("product_id is required")
+    }
+    if quantity < 0 {
+        return errors.New("quantity must not be negative")
+    }
+
+    for i, item := range c.Items {
+        if item.ProductID != productID || item.SKU != sku {
+            continue
+        }
+        if quantity == 0 {
+            c.Items = append(c.Items[:i], c.Items[i+1:]...)
+        } else {
+            c.Items[i].Quantity = quantity
+        }
+        return nil
+    }
+    if quantity > 0 {
+        c.Items = append(c.Items, CartItem{ProductID: productID, SKU: sku, Quantity: quantity})
+    }
+    return nil
+}
+
+// OrderItems converts the cart lines into order items.
+func (c Cart) OrderItems() []OrderItem {
+    items := make([]OrderItem, 0, len(c.Items))
+    for _, item := range c.Items {
+        items = append(items, OrderItem{ProductID: item.ProductID, SKU: item.SKU, Quantity: item.Quantity})
+    }
+    return items
+}
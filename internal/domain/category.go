@@ -0,0 +1,27 @@
+package domain
+
+import (
+    "errors"
+    "time"
+)
+
+// Category groups products in a hierarchical taxonomy. A product may belong
+// to any number of categories.
+type Category struct {
+    ID   string `json:"id"`
+    Name string `json:"name"`
+    // ParentID is empty for top-level categories.
+    ParentID  string    `json:"parent_id,omitempty"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate ensures the category is well formed before persistence.
+func (c Category) Validate() error {
+    if c.Name == "" {
+        return errors.New("name is required")
+    }
+    if c.ParentID != "" && c.ParentID == c.ID {
+        return errors.New("a category cannot be its own parent")
+    }
+    return nil
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+    "errors"
+    "fmt"
+    "math/big"
+    "strings"
+    "time"
+)
+
+// rateDecimals bounds the precision kept when a rate is recorded, so the
+// stored decimal string is exactly the ratio used for conversion.
+const rateDecimals = 18
+
+// ExchangeRate records how many units of To one unit of From was worth at AsOf.
+type ExchangeRate struct {
+    From string    `json:"from"`
+    To   string    `json:"to"`
+    Rate string    `json:"rate"`
+    AsOf time.Time `json:"as_of"`
+}
+
+// NewExchangeRate builds an ExchangeRate, normalising rate to a decimal string.
+func NewExchangeRate(from, to string, rate *big.Rat, asOf time.Time) (ExchangeRate, error) {
+    if rate == nil || rate.Sign() <= 0 {
+        return ExchangeRate{}, errors.New("exchange rate must be positive")
+    }
+
+    decimal := rate.FloatString(rateDecimals)
+    decimal = strings.TrimRight(decimal, "0")
+    decimal = strings.TrimSuffix(decimal, ".")
+    if decimal == "0" {
+        return ExchangeRate{}, fmt.Errorf("exchange rate %s/%s is below the supported precision", from, to)
+    }
+
+    return ExchangeRate{From: from, To: to, Rate: decimal, AsOf: asOf}, nil
+}
+
+// Ratio returns the rate as an exact rational number.
+func (r ExchangeRate) Ratio() (*big.Rat, error) {
+    ratio, ok := new(big.Rat).SetString(r.Rate)
+    if !ok || ratio.Sign() <= 0 {
+        return nil, fmt.Errorf("invalid exchange rate %q", r.Rate)
+    }
+    return ratio, nil
+}
+
+// Convert expresses m, which must be denominated in r.From, in r.To.
+func (r ExchangeRate) Convert(m Money, mode RoundingMode) (Money, error) {
+    if m.Currency != r.From {
+        return Money{}, fmt.Errorf("%w: rate is for %s, amount is in %s", ErrCurrencyMismatch, r.From, m.Currency)
+    }
+    fromExp, ok := CurrencyExponent(r.From)
+    if !ok {
+        return Money{}, fmt.Errorf("unsupported currency %q", r.From)
+    }
+    toExp, ok := CurrencyExponent(r.To)
+    if !ok {
+        return Money{}, fmt.Errorf("unsupported currency %q", r.To)
+    }
+
+    factor, err := r.Ratio()
+    if err != nil {
+        return Money{}, err
+    }
+    // Rescale from the source minor unit to the target minor unit.
+    scale := new(big.Rat).SetFrac(
+        new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(toExp)), nil),
+        new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(fromExp)), nil),
+    )
+    factor.Mul(factor, scale)
+
+    converted, err := m.MulRat(factor, mode)
+    if err != nil {
+        return Money{}, err
+    }
+    converted.Currency = r.To
+    return converted, nil
+}
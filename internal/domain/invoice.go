@@ -0,0 +1,95 @@
+package domain
+
+import "time"
+
+// InvoiceStatus describes the payment state of an invoice.
+type InvoiceStatus string
+
+const (
+    InvoiceStatusPending InvoiceStatus = "pending"
+    InvoiceStatusPaid    InvoiceStatus = "paid"
+    // InvoiceStatusOverpaid means the invoice was settled with more than was asked.
+    InvoiceStatusOverpaid InvoiceStatus = "overpaid"
+    // InvoiceStatusUnderpaid means the invoice expired holding only part of the amount.
+    InvoiceStatusUnderpaid InvoiceStatus = "underpaid"
+    // InvoiceStatusLate means funds arrived only after expiry and need manual review.
+    InvoiceStatusLate    InvoiceStatus = "late"
+    InvoiceStatusExpired InvoiceStatus = "expired"
+    // InvoiceStatusVoid means the invoice was closed unpaid because its order
+    // was settled some other way, such as being marked paid by staff.
+    InvoiceStatusVoid InvoiceStatus = "void"
+)
+
+// PaymentMethod selects how an invoice is paid.
+type PaymentMethod string
+
+const (
+    // PaymentMethodOnChain pays to a derived deposit address.
+    PaymentMethodOnChain PaymentMethod = "onchain"
+    // PaymentMethodLightning pays a BOLT11 payment request.
+    PaymentMethodLightning PaymentMethod = "lightning"
+)
+
+// Valid reports whether m is a known payment method.
+func (m PaymentMethod) Valid() bool {
+    return m == PaymentMethodOnChain || m == PaymentMethodLightning
+}
+
+// InvoicePayment is a transaction observed paying an invoice. Lightning
+// settlements record the payment hash as TxHash.
+type InvoicePayment struct {
+    TxHash        string    `json:"tx_hash"`
+    Amount        Money     `json:"amount"`
+    Confirmations int       `json:"confirmations"`
+    SeenAt        time.Time `json:"seen_at"`
+}
+
+// Invoice requests payment for an order, either to a dedicated deposit
+// address or through a Lightning payment request.
+type Invoice struct {
+    ID      string        `json:"id"`
+    OrderID string        `json:"order_id"`
+    Method  PaymentMethod `json:"method"`
+    // Amount is the sum expected, denominated in the payment coin.
+    Amount  Money  `json:"amount"`
+    Address string `json:"address,omitempty"`
+    // DerivationPath locates Address relative to the configured account key.
+    DerivationPath  string `json:"derivation_path,omitempty"`
+    DerivationIndex uint32 `json:"-"`
+    // PaymentRequest is the BOLT11 string for Lightning invoices, and
+    // PaymentHash the hex hash its preimage must match.
+    PaymentRequest string `json:"payment_request,omitempty"`
+    PaymentHash    string `json:"payment_hash,omitempty"`
+    // Rate converted the order total into Amount; nil when no conversion was needed.
+    Rate   *ExchangeRate `json:"rate,omitempty"`
+    Status InvoiceStatus `json:"status"`
+    // Received sums the payments that reached the confirmation threshold.
+    Received  Money            `json:"received"`
+    Payments  []InvoicePayment `json:"payments,omitempty"`
+    PaidAt    *time.Time       `json:"paid_at,omitempty"`
+    ExpiresAt time.Time        `json:"expires_at"`
+    CreatedAt time.Time        `json:"created_at"`
+}
+
+// AwaitingPayment reports whether a pending invoice may still be paid at now:
+// it has not expired yet, or a payment seen before it expired is still
+// gathering confirmations.
+func (i Invoice) AwaitingPayment(now time.Time) bool {
+    if i.Status != InvoiceStatusPending {
+        return false
+    }
+    if now.Before(i.ExpiresAt) {
+        return true
+    }
+    for _, p := range i.Payments {
+        if p.SeenAt.Before(i.ExpiresAt) {
+            return true
+        }
+    }
+    return false
+}
+
+// Expired reports whether a still-pending invoice has passed its expiry at now.
+func (i Invoice) Expired(now time.Time) bool {
+    return i.Status == InvoiceStatusPending && !now.Before(i.ExpiresAt)
+}
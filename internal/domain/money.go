@@ -0,0 +1,239 @@
+package domain
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math"
+    "math/big"
+    "strings"
+)
+
+// ErrCurrencyMismatch is returned when arithmetic mixes two currencies.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// ErrMoneyOverflow is returned when an amount no longer fits in int64 minor units.
+var ErrMoneyOverflow = errors.New("money amount overflow")
+
+// currencyExponents maps supported currency codes to the number of decimal
+// places held by their minor unit. ETH is tracked in gwei rather than wei so
+// that realistic balances fit in an int64.
+var currencyExponents = map[string]int{
+    "USD":  2,
+    "EUR":  2,
+    "GBP":  2,
+    "JPY":  0,
+    "BTC":  8,
+    "ETH":  9,
+    "USDT": 6,
+}
+
+// CurrencyExponent returns the number of minor-unit decimal places for a
+// currency and whether the currency is supported.
+func CurrencyExponent(currency string) (int, bool) {
+    exp, ok := currencyExponents[currency]
+    return exp, ok
+}
+
+// RoundingMode selects how fractional minor units are resolved.
+type RoundingMode int
+
+const (
+    // RoundHalfEven rounds to the nearest minor unit, ties to even (banker's rounding).
+    RoundHalfEven RoundingMode = iota
+    // RoundHalfUp rounds to the nearest minor unit, ties away from zero.
+    RoundHalfUp
+    // RoundDown truncates toward zero.
+    RoundDown
+    // RoundUp rounds away from zero.
+    RoundUp
+)
+
+// Money is an exact monetary amount expressed in the minor unit of its currency.
+type Money struct {
+    Amount   int64
+    Currency string
+}
+
+// NewMoney constructs a Money value from an amount in minor units.
+func NewMoney(amount int64, currency string) Money {
+    return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "19.99" into Money. More
+// fractional digits than the currency's minor unit allows is an error rather
+// than a silent rounding.
+func ParseMoney(amount, currency string) (Money, error) {
+    exp, ok := CurrencyExponent(currency)
+    if !ok {
+        return Money{}, fmt.Errorf("unsupported currency %q", currency)
+    }
+
+    s := strings.TrimSpace(amount)
+    negative := strings.HasPrefix(s, "-")
+    s = strings.TrimPrefix(s, "-")
+
+    whole, frac, _ := strings.Cut(s, ".")
+    if whole == "" || strings.Trim(whole+frac, "0123456789") != "" {
+        return Money{}, fmt.Errorf("invalid amount %q", amount)
+    }
+    if len(frac) > exp {
+        return Money{}, fmt.Errorf("amount %q has more than %d decimal places for %s", amount, exp, currency)
+    }
+
+    digits := whole + frac + strings.Repeat("0", exp-len(frac))
+    minor, ok := new(big.Int).SetString(digits, 10)
+    if !ok || !minor.IsInt64() {
+        return Money{}, ErrMoneyOverflow
+    }
+
+    value := minor.Int64()
+    if negative {
+        value = -value
+    }
+    return Money{Amount: value, Currency: currency}, nil
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool { return m.Amount == 0 }
+
+// IsPositive reports whether the amount is greater than zero.
+func (m Money) IsPositive() bool { return m.Amount > 0 }
+
+// IsNegative reports whether the amount is less than zero.
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// Add returns m + other. Both values must share a currency.
+func (m Money) Add(other Money) (Money, error) {
+    if err := m.sameCurrency(other); err != nil {
+        return Money{}, err
+    }
+    sum := m.Amount + other.Amount
+    if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+        return Money{}, ErrMoneyOverflow
+    }
+    return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other. Both values must share a currency.
+func (m Money) Sub(other Money) (Money, error) {
+    if other.Amount == math.MinInt64 {
+        return Money{}, ErrMoneyOverflow
+    }
+    return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+// Mul returns m multiplied by an integer factor such as a quantity.
+func (m Money) Mul(n int64) (Money, error) {
+    product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(n))
+    if !product.IsInt64() {
+        return Money{}, ErrMoneyOverflow
+    }
+    return Money{Amount: product.Int64(), Currency: m.Currency}, nil
+}
+
+// MulRat returns m multiplied by an exact ratio, resolving any fractional
+// minor unit with the given rounding mode. It is the building block for
+// percentages and conversions.
+func (m Money) MulRat(r *big.Rat, mode RoundingMode) (Money, error) {
+    product := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), r)
+    amount, err := roundRat(product, mode)
+    if err != nil {
+        return Money{}, err
+    }
+    return Money{Amount: amount, Currency: m.Currency}, nil
+}
+
+// Decimal formats the amount in major units, e.g. "19.99".
+func (m Money) Decimal() string {
+    exp, ok := CurrencyExponent(m.Currency)
+    if !ok {
+        exp = 0
+    }
+
+    digits := new(big.Int).Abs(big.NewInt(m.Amount)).String()
+    if len(digits) <= exp {
+        digits = strings.Repeat("0", exp-len(digits)+1) + digits
+    }
+
+    out := digits
+    if exp > 0 {
+        out = digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+    }
+    if m.Amount < 0 {
+        out = "-" + out
+    }
+    return out
+}
+
+// String formats the value as "<amount> <currency>".
+func (m Money) String() string {
+    return m.Decimal() + " " + m.Currency
+}
+
+type moneyJSON struct {
+    Amount   string `json:"amount"`
+    Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as a decimal string so no precision is lost
+// to JSON number handling in clients.
+func (m Money) MarshalJSON() ([]byte, error) {
+    return json.Marshal(moneyJSON{Amount: m.Decimal(), Currency: m.Currency})
+}
+
+// UnmarshalJSON decodes {"amount":"19.99","currency":"USD"}.
+func (m *Money) UnmarshalJSON(data []byte) error {
+    var raw moneyJSON
+    if err := json.Unmarshal(data, &raw); err != nil {
+        return errors.New(`money must be an object like {"amount":"19.99","currency":"USD"}`)
+    }
+    parsed, err := ParseMoney(raw.Amount, raw.Currency)
+    if err != nil {
+        return err
+    }
+    *m = parsed
+    return nil
+}
+
+func (m Money) sameCurrency(other Money) error {
+    if m.Currency != other.Currency {
+        return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+    }
+    return nil
+}
+
+// roundRat resolves r to an integer using mode.
+func roundRat(r *big.Rat, mode RoundingMode) (int64, error) {
+    num, den := r.Num(), r.Denom()
+    quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+
+    if rem.Sign() != 0 {
+        // away is +1 or -1: the direction of rounding away from zero.
+        away := int64(r.Sign())
+        twice := new(big.Int).Abs(new(big.Int).Mul(rem, big.NewInt(2)))
+        cmp := twice.Cmp(den)
+
+        roundAway := false
+        switch mode {
+        case RoundUp:
+            roundAway = true
+        case RoundDown:
+            roundAway = false
+        case RoundHalfUp:
+            roundAway = cmp >= 0
+        case RoundHalfEven:
+            roundAway = cmp > 0 || (cmp == 0 && quo.Bit(0) == 1)
+        default:
+            return 0, fmt.Errorf("unknown rounding mode %d", mode)
+        }
+        if roundAway {
+            quo.Add(quo, big.NewInt(away))
+        }
+    }
+
+    if !quo.IsInt64() {
+        return 0, ErrMoneyOverflow
+    }
+    return quo.Int64(), nil
+}
@@ -0,0 +1,194 @@
+package domain
+
+import (
+    "errors"
+    "math"
+    "math/big"
+    "testing"
+)
+
+func TestParseMoney(t *testing.T) {
+    tests := []struct {
+        amount, currency string
+        want             int64
+        wantErr          bool
+    }{
+        {"19.99", "USD", 1999, false},
+        {"19.9", "USD", 1990, false},
+        {"19", "USD", 1900, false},
+        {"19.", "USD", 1900, false},
+        {" 0.01 ", "USD", 1, false},
+        {"-5.25", "EUR", -525, false},
+        {"1500", "JPY", 1500, false},
+        {"0.00000001", "BTC", 1, false},
+        {"1.5", "ETH", 1_500_000_000, false},
+        {"92233720368547758.07", "USD", math.MaxInt64, false},
+        {"92233720368547758.08", "USD", 0, true},
+        {"19.999", "USD", 0, true},
+        {"1.5", "JPY", 0, true},
+        {".5", "USD", 0, true},
+        {"", "USD", 0, true},
+        {"-", "USD", 0, true},
+        {"--1", "USD", 0, true},
+        {"1,000", "USD", 0, true},
+        {"1e3", "USD", 0, true},
+        {"1", "XYZ", 0, true},
+    }
+    for _, tt := range tests {
+        got, err := ParseMoney(tt.amount, tt.currency)
+        if tt.wantErr {
+            if err == nil {
+                t.Errorf("ParseMoney(%q, %q) = %v, want an error", tt.amount, tt.currency, got)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("ParseMoney(%q, %q): %v", tt.amount, tt.currency, err)
+            continue
+        }
+        if got != NewMoney(tt.want, tt.currency) {
+            t.Errorf("ParseMoney(%q, %q) = %v, want %d minor units", tt.amount, tt.currency, got, tt.want)
+        }
+    }
+}
+
+func TestParseMoneyRoundTrip(t *testing.T) {
+    for _, s := range []string{"0.00", "0.01", "-0.01", "19.99", "-1234.50"} {
+        m, err := ParseMoney(s, "USD")
+        if err != nil {
+            t.Fatalf("ParseMoney(%q): %v", s, err)
+        }
+        if got := m.Decimal(); got != s {
+            t.Errorf("ParseMoney(%q).Decimal() = %q", s, got)
+        }
+    }
+}
+
+func TestMoneyAddSub(t *testing.T) {
+    tests := []struct {
+        name    string
+        op      func(Money, Money) (Money, error)
+        a, b    int64
+        want    int64
+        wantErr error
+    }{
+        {"add", Money.Add, 150, 250, 400, nil},
+        {"add negative", Money.Add, 150, -250, -100, nil},
+        {"add to max", Money.Add, math.MaxInt64 - 1, 1, math.MaxInt64, nil},
+        {"add past max", Money.Add, math.MaxInt64, 1, 0, ErrMoneyOverflow},
+        {"add past min", Money.Add, math.MinInt64, -1, 0, ErrMoneyOverflow},
+        {"sub", Money.Sub, 400, 150, 250, nil},
+        {"sub to min", Money.Sub, math.MinInt64 + 1, 1, math.MinInt64, nil},
+        {"sub past min", Money.Sub, math.MinInt64, 1, 0, ErrMoneyOverflow},
+        {"sub past max", Money.Sub, math.MaxInt64, -1, 0, ErrMoneyOverflow},
+        {"sub min", Money.Sub, 0, math.MinInt64, 0, ErrMoneyOverflow},
+        {"sub min from negative", Money.Sub, -1, math.MinInt64, 0, ErrMoneyOverflow},
+    }
+    for _, tt := range tests {
+        got, err := tt.op(NewMoney(tt.a, "USD"), NewMoney(tt.b, "USD"))
+        if tt.wantErr != nil {
+            if !errors.Is(err, tt.wantErr) {
+                t.Errorf("%s(%d, %d) = %v, %v; want %v", tt.name, tt.a, tt.b, got, err, tt.wantErr)
+            }
+            continue
+        }
+        if err != nil || got != NewMoney(tt.want, "USD") {
+            t.Errorf("%s(%d, %d) = %v, %v; want %d", tt.name, tt.a, tt.b, got, err, tt.want)
+        }
+    }
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+    usd, eur := NewMoney(100, "USD"), NewMoney(100, "EUR")
+    if _, err := usd.Add(eur); !errors.Is(err, ErrCurrencyMismatch) {
+        t.Errorf("Add across currencies: got %v, want ErrCurrencyMismatch", err)
+    }
+    if _, err := usd.Sub(eur); !errors.Is(err, ErrCurrencyMismatch) {
+        t.Errorf("Sub across currencies: got %v, want ErrCurrencyMismatch", err)
+    }
+}
+
+func TestRoundRat(t *testing.T) {
+    modes := []struct {
+        name string
+        mode RoundingMode
+    }{
+        {"half even", RoundHalfEven},
+        {"half up", RoundHalfUp},
+        {"down", RoundDown},
+        {"up", RoundUp},
+    }
+    tests := []struct {
+        value string
+        // want holds the result for each mode, in the order of modes.
+        want [4]int64
+    }{
+        {"0", [4]int64{0, 0, 0, 0}},
+        {"7", [4]int64{7, 7, 7, 7}},
+        {"-7", [4]int64{-7, -7, -7, -7}},
+        {"1/4", [4]int64{0, 0, 0, 1}},
+        {"3/4", [4]int64{1, 1, 0, 1}},
+        {"-1/4", [4]int64{0, 0, 0, -1}},
+        {"-3/4", [4]int64{-1, -1, 0, -1}},
+        {"1/2", [4]int64{0, 1, 0, 1}},
+        {"3/2", [4]int64{2, 2, 1, 2}},
+        {"5/2", [4]int64{2, 3, 2, 3}},
+        {"-1/2", [4]int64{0, -1, 0, -1}},
+        {"-3/2", [4]int64{-2, -2, -1, -2}},
+        {"-5/2", [4]int64{-2, -3, -2, -3}},
+        {"-7/2", [4]int64{-4, -4, -3, -4}},
+        {"1001/1000", [4]int64{1, 1, 1, 2}},
+        {"-1001/1000", [4]int64{-1, -1, -1, -2}},
+    }
+    for _, tt := range tests {
+        r, ok := new(big.Rat).SetString(tt.value)
+        if !ok {
+            t.Fatalf("bad test value %q", tt.value)
+        }
+        for i, m := range modes {
+            got, err := roundRat(r, m.mode)
+            if err != nil {
+                t.Errorf("roundRat(%s, %s): %v", tt.value, m.name, err)
+                continue
+            }
+            if got != tt.want[i] {
+                t.Errorf("roundRat(%s, %s) = %d, want %d", tt.value, m.name, got, tt.want[i])
+            }
+        }
+    }
+}
+
+func TestRoundRatErrors(t *testing.T) {
+    if _, err := roundRat(big.NewRat(1, 2), RoundingMode(99)); err == nil {
+        t.Error("roundRat with an unknown mode succeeded")
+    }
+
+    // MaxInt64 + 1/2 rounds away from zero past the largest amount.
+    r := new(big.Rat).Add(new(big.Rat).SetInt64(math.MaxInt64), big.NewRat(1, 2))
+    if _, err := roundRat(r, RoundHalfUp); !errors.Is(err, ErrMoneyOverflow) {
+        t.Errorf("roundRat past MaxInt64: got %v, want ErrMoneyOverflow", err)
+    }
+    if got, err := roundRat(r, RoundDown); err != nil || got != math.MaxInt64 {
+        t.Errorf("roundRat(MaxInt64 + 1/2, down) = %d, %v; want MaxInt64", got, err)
+    }
+}
+
+func TestMoneyMulRat(t *testing.T) {
+    // 10% of 19.95 is 1.995, a tie in cents.
+    price := NewMoney(1995, "USD")
+    tests := []struct {
+        mode RoundingMode
+        want int64
+    }{
+        {RoundHalfEven, 200},
+        {RoundHalfUp, 200},
+        {RoundDown, 199},
+        {RoundUp, 200},
+    }
+    for _, tt := range tests {
+        got, err := price.MulRat(big.NewRat(1, 10), tt.mode)
+        if err != nil || got != NewMoney(tt.want, "USD") {
+            t.Errorf("MulRat(1/10, mode %d) = %v, %v; want %d", tt.mode, got, err, tt.want)
+        }
+    }
+}
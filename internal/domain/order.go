@@ -8,16 +8,79 @@ import (
 // OrderItem represents a product purchase entry within an order.
 type OrderItem struct {
     ProductID string `json:"product_id"`
-    Quantity  int    `json:"quantity"`
+    // SKU selects the variant bought of a product sold as variants. An item
+    // may give only the SKU; the product is then looked up from it.
+    SKU      string `json:"sku,omitempty"`
+    Quantity int    `json:"quantity"`
+    // UnitPrice, Subtotal, Discounts and Total price the line in the order's
+    // currency when the order is placed; Total is Subtotal less Discounts.
+    // Orders placed before lines were priced do not carry them.
+    UnitPrice *Money     `json:"unit_price,omitempty"`
+    Subtotal  *Money     `json:"subtotal,omitempty"`
+    Discounts []Discount `json:"discounts,omitempty"`
+    Total     *Money     `json:"total,omitempty"`
+}
+
+// Discount is the share of a promotion taken off one order line.
+type Discount struct {
+    PromotionID string `json:"promotion_id"`
+    Code        string `json:"code,omitempty"`
+    Amount      Money  `json:"amount"`
+}
+
+// AppliedPromotion sums up what a promotion took off an order, including
+// any shipping it waived.
+type AppliedPromotion struct {
+    PromotionID string        `json:"promotion_id"`
+    Code        string        `json:"code,omitempty"`
+    Name        string        `json:"name"`
+    Type        PromotionType `json:"type"`
+    Amount      Money         `json:"amount"`
 }
 
 // Order represents a customer's purchase order.
 type Order struct {
-    ID        string      `json:"id"`
-    UserID    string      `json:"user_id"`
-    Items     []OrderItem `json:"items"`
-    Total     float64     `json:"total"`
-    CreatedAt time.Time   `json:"created_at"`
+    ID          string       `json:"id"`
+    UserID      string       `json:"user_id"`
+    Items       []OrderItem  `json:"items"`
+    Allocations []Allocation `json:"allocations,omitempty"`
+    // Subtotal is the sum of the line subtotals, Discount everything the
+    // promotions took off and Shipping the fee charged before any discount,
+    // so that Total is Subtotal - Discount + Shipping. Orders placed before
+    // promotions carry only the Total.
+    Subtotal      *Money              `json:"subtotal,omitempty"`
+    Discount      *Money              `json:"discount,omitempty"`
+    Shipping      *Money              `json:"shipping,omitempty"`
+    Promotions    []AppliedPromotion  `json:"promotions,omitempty"`
+    Total         Money               `json:"total"`
+    ExchangeRates []ExchangeRate      `json:"exchange_rates,omitempty"`
+    Status        OrderStatus         `json:"status"`
+    StatusHistory []OrderStatusChange `json:"status_history"`
+    Cancellation  *OrderCancellation  `json:"cancellation,omitempty"`
+    InvoiceID     string              `json:"invoice_id,omitempty"`
+    // RefundDue is set when a payment settled for an order that could no
+    // longer be fulfilled, so that staff refund it.
+    RefundDue *OrderRefundDue `json:"refund_due,omitempty"`
+    // ShippingAddress and BillingAddress are copied from the user's address
+    // book when the order is placed, so later edits leave the order as it was.
+    ShippingAddress *PostalAddress `json:"shipping_address,omitempty"`
+    BillingAddress  *PostalAddress `json:"billing_address,omitempty"`
+    CreatedAt       time.Time      `json:"created_at"`
+}
+
+// OrderCancellation records who cancelled an order and why.
+type OrderCancellation struct {
+    By     string    `json:"by"`
+    Reason string    `json:"reason"`
+    At     time.Time `json:"at"`
+}
+
+// OrderRefundDue records a payment that has to be refunded because its order
+// was cancelled, or its stock sold elsewhere, before the payment settled.
+type OrderRefundDue struct {
+    InvoiceID string    `json:"invoice_id"`
+    Reason    string    `json:"reason"`
+    At        time.Time `json:"at"`
 }
 
 // Validate ensures the order is well formed.
@@ -29,8 +92,8 @@ func (o Order) Validate() error {
         return errors.New("order must contain at least one item")
     }
     for _, item := range o.Items {
-        if item.ProductID == "" {
-            return errors.New("product_id is required for each item")
+        if item.ProductID == "" && item.SKU == "" {
+            return errors.New("product_id or sku is required for each item")
         }
         if item.Quantity <= 0 {
             return errors.New("item quantity must be positive")
@@ -0,0 +1,76 @@
+package domain
+
+import (
+    "fmt"
+    "time"
+)
+
+// OrderStatus describes where an order is in its lifecycle.
+type OrderStatus string
+
+const (
+    OrderStatusPending   OrderStatus = "pending"
+    OrderStatusPaid      OrderStatus = "paid"
+    OrderStatusFulfilled OrderStatus = "fulfilled"
+    OrderStatusShipped   OrderStatus = "shipped"
+    OrderStatusDelivered OrderStatus = "delivered"
+    OrderStatusCancelled OrderStatus = "cancelled"
+    OrderStatusRefunded  OrderStatus = "refunded"
+)
+
+// orderTransitions lists the statuses reachable from each status.
+// Cancelled and refunded are terminal.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+    OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+    OrderStatusPaid:      {OrderStatusFulfilled, OrderStatusCancelled, OrderStatusRefunded},
+    OrderStatusFulfilled: {OrderStatusShipped, OrderStatusCancelled, OrderStatusRefunded},
+    OrderStatusShipped:   {OrderStatusDelivered, OrderStatusRefunded},
+    OrderStatusDelivered: {OrderStatusRefunded},
+    OrderStatusCancelled: nil,
+    OrderStatusRefunded:  nil,
+}
+
+// Valid reports whether s is a known order status.
+func (s OrderStatus) Valid() bool {
+    _, ok := orderTransitions[s]
+    return ok
+}
+
+// CanTransitionTo reports whether an order in status s may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+    for _, allowed := range orderTransitions[s] {
+        if allowed == next {
+            return true
+        }
+    }
+    return false
+}
+
+// OrderStatusChange records a single step in an order's lifecycle.
+type OrderStatusChange struct {
+    From OrderStatus `json:"from,omitempty"`
+    To   OrderStatus `json:"to"`
+    At   time.Time   `json:"at"`
+}
+
+// TransitionError reports an attempt to move an order along an edge the
+// lifecycle does not allow.
+type TransitionError struct {
+    From OrderStatus
+    To   OrderStatus
+}
+
+func (e *TransitionError) Error() string {
+    return fmt.Sprintf("cannot move order from %s to %s", e.From, e.To)
+}
+
+// TransitionTo moves the order to next and appends the change to its history.
+// It returns a *TransitionError when the lifecycle forbids the move.
+func (o *Order) TransitionTo(next OrderStatus, at time.Time) error {
+    if !o.Status.CanTransitionTo(next) {
+        return &TransitionError{From: o.Status, To: next}
+    }
+    o.StatusHistory = append(o.StatusHistory, OrderStatusChange{From: o.Status, To: next, At: at})
+    o.Status = next
+    return nil
+}
@@ -1,14 +1,42 @@
 package domain
 
-import "errors"
+import (
+    "encoding/json"
+    "errors"
+    "time"
+)
 
 // Product represents a product that can be purchased.
 type Product struct {
-    ID          string  `json:"id"`
-    Name        string  `json:"name"`
-    Description string  `json:"description"`
-    Price       float64 `json:"price"`
-    Stock       int     `json:"stock"`
+    ID          string `json:"id"`
+    Name        string `json:"name"`
+    Description string `json:"description"`
+    Price       Money  `json:"price"`
+    // Stock is the physical quantity on hand, including units held for
+    // unpaid orders.
+    Stock int `json:"stock"`
+    // Reserved is the part of Stock held by active reservations.
+    Reserved int `json:"reserved"`
+    // Options and Variants are set for products sold in several versions.
+    // Each variant has its own price and stock; the product's Stock and
+    // Reserved are then the totals over its variants.
+    Options   []ProductOption `json:"options,omitempty"`
+    Variants  []Variant       `json:"variants,omitempty"`
+    CreatedAt time.Time       `json:"created_at"`
+}
+
+// Available returns the quantity that can still be ordered.
+func (p Product) Available() int {
+    return p.Stock - p.Reserved
+}
+
+// MarshalJSON adds the derived available quantity to the encoded product.
+func (p Product) MarshalJSON() ([]byte, error) {
+    type product Product
+    return json.Marshal(struct {
+        product
+        Available int `json:"available"`
+    }{product(p), p.Available()})
 }
 
 // Validate ensures the product is well formed before persistence.
@@ -16,11 +44,20 @@ func (p Product) Validate() error {
     if p.Name == "" {
         return errors.New("name is required")
     }
-    if p.Price <= 0 {
+    if p.Price.Currency == "" {
+        return errors.New("price is required")
+    }
+    if _, ok := CurrencyExponent(p.Price.Currency); !ok {
+        return errors.New("price currency is not supported")
+    }
+    if !p.Price.IsPositive() {
         return errors.New("price must be positive")
     }
     if p.Stock < 0 {
         return errors.New("stock cannot be negative")
     }
-    return nil
+    if p.Stock < p.Reserved {
+        return errors.New("stock cannot be less than the quantity reserved for open orders")
+    }
+    return p.validateVariants()
 }
@@ -0,0 +1,148 @@
+package domain
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// PromotionType selects how a promotion discounts an order.
+type PromotionType string
+
+const (
+    // PromotionPercentage takes a percentage off the eligible lines.
+    PromotionPercentage PromotionType = "percentage"
+    // PromotionFixedAmount takes a fixed amount off the eligible lines.
+    PromotionFixedAmount PromotionType = "fixed_amount"
+    // PromotionBuyXGetY gives away units of the eligible products for every
+    // few bought.
+    PromotionBuyXGetY PromotionType = "buy_x_get_y"
+    // PromotionFreeShipping waives the shipping fee.
+    PromotionFreeShipping PromotionType = "free_shipping"
+)
+
+// Valid reports whether t is a known promotion type.
+func (t PromotionType) Valid() bool {
+    switch t {
+    case PromotionPercentage, PromotionFixedAmount, PromotionBuyXGetY, PromotionFreeShipping:
+        return true
+    }
+    return false
+}
+
+// couponCodeRegex is the shape of a normalised coupon code.
+var couponCodeRegex = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{2,31}$`)
+
+// NormalizeCouponCode returns code in the form it is stored and looked up
+// in, so that customers may type it in any case.
+func NormalizeCouponCode(code string) string {
+    return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Promotion is a discount campaign. Promotions with a Code are coupons that
+// customers enter at checkout; those without one apply to every order they
+// are eligible for.
+type Promotion struct {
+    ID          string        `json:"id"`
+    Code        string        `json:"code,omitempty"`
+    Name        string        `json:"name"`
+    Description string        `json:"description,omitempty"`
+    Type        PromotionType `json:"type"`
+    // PercentOff is the percentage a percentage promotion takes off, from 1 to 100.
+    PercentOff int `json:"percent_off,omitempty"`
+    // AmountOff is what a fixed amount promotion takes off the eligible
+    // lines, spread over them in proportion to their value.
+    AmountOff *Money `json:"amount_off,omitempty"`
+    // BuyQuantity and GetQuantity describe a buy-X-get-Y promotion: for every
+    // BuyQuantity eligible units bought, GetQuantity more are free. The
+    // cheapest units are the ones given away.
+    BuyQuantity int `json:"buy_quantity,omitempty"`
+    GetQuantity int `json:"get_quantity,omitempty"`
+    // MinOrderValue is the subtotal an order needs, before any discount, for
+    // the promotion to apply.
+    MinOrderValue *Money `json:"min_order_value,omitempty"`
+    // ProductIDs and CategoryIDs restrict the promotion to those products and
+    // to the products in those categories or their subcategories. A
+    // promotion without either covers every product.
+    ProductIDs  []string `json:"product_ids,omitempty"`
+    CategoryIDs []string `json:"category_ids,omitempty"`
+    // UsageLimit caps how many orders may use the promotion and PerUserLimit
+    // how many orders of each customer; zero means no limit.
+    UsageLimit   int `json:"usage_limit,omitempty"`
+    PerUserLimit int `json:"per_user_limit,omitempty"`
+    // Redemptions counts the orders the promotion was applied to. Cancelling
+    // an order gives its redemption back.
+    Redemptions int `json:"redemptions"`
+    // StartsAt and EndsAt bound when the promotion can be used; either may
+    // be left open.
+    StartsAt *time.Time `json:"starts_at,omitempty"`
+    EndsAt   *time.Time `json:"ends_at,omitempty"`
+    // Stackable promotions combine with each other. A promotion that is not
+    // stackable is only ever applied on its own.
+    Stackable bool `json:"stackable"`
+    // Active is cleared to withdraw a promotion without deleting it.
+    Active    bool      `json:"active"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate ensures the promotion is well formed.
+func (p Promotion) Validate() error {
+    if p.Name == "" {
+        return errors.New("name is required")
+    }
+    if p.Code != "" && !couponCodeRegex.MatchString(p.Code) {
+        return errors.New("code must be 3 to 32 letters, digits, hyphens or underscores")
+    }
+    if !p.Type.Valid() {
+        return fmt.Errorf("unknown promotion type %q", p.Type)
+    }
+
+    if p.Type == PromotionPercentage {
+        if p.PercentOff < 1 || p.PercentOff > 100 {
+            return errors.New("percent_off must be between 1 and 100")
+        }
+    } else if p.PercentOff != 0 {
+        return fmt.Errorf("percent_off only applies to %s promotions", PromotionPercentage)
+    }
+    if p.Type == PromotionFixedAmount {
+        if p.AmountOff == nil || !p.AmountOff.IsPositive() {
+            return errors.New("amount_off must be positive")
+        }
+    } else if p.AmountOff != nil {
+        return fmt.Errorf("amount_off only applies to %s promotions", PromotionFixedAmount)
+    }
+    if p.Type == PromotionBuyXGetY {
+        if p.BuyQuantity < 1 || p.GetQuantity < 1 {
+            return errors.New("buy_quantity and get_quantity must be positive")
+        }
+    } else if p.BuyQuantity != 0 || p.GetQuantity != 0 {
+        return fmt.Errorf("buy_quantity and get_quantity only apply to %s promotions", PromotionBuyXGetY)
+    }
+
+    if p.MinOrderValue != nil && p.MinOrderValue.IsNegative() {
+        return errors.New("min_order_value cannot be negative")
+    }
+    if p.UsageLimit < 0 || p.PerUserLimit < 0 {
+        return errors.New("usage limits cannot be negative")
+    }
+    if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+        return errors.New("ends_at must be after starts_at")
+    }
+    return nil
+}
+
+// Targeted reports whether the promotion is restricted to some products.
+func (p Promotion) Targeted() bool {
+    return len(p.ProductIDs) > 0 || len(p.CategoryIDs) > 0
+}
+
+// PromotionRedemption records that a promotion was applied to an order.
+type PromotionRedemption struct {
+    PromotionID string    `json:"promotion_id"`
+    UserID      string    `json:"user_id"`
+    OrderID     string    `json:"order_id"`
+    CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// RefreshToken records an issued refresh token. Each refresh exchanges the
+// token for a new one in the same family; presenting a token that was
+// already exchanged or revoked revokes the whole family, since it means the
+// token was stolen or replayed.
+type RefreshToken struct {
+    ID     string `json:"id"`
+    UserID string `json:"user_id"`
+    // FamilyID is shared by every token descended from the same login.
+    FamilyID  string    `json:"family_id"`
+    ExpiresAt time.Time `json:"expires_at"`
+    CreatedAt time.Time `json:"created_at"`
+    // RevokedAt is set once the token is exchanged or revoked.
+    RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the token can still be exchanged at now.
+func (t RefreshToken) Active(now time.Time) bool {
+    return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// ReservationStatus describes the state of a stock reservation.
+type ReservationStatus string
+
+const (
+    // ReservationStatusHeld counts against available stock until it expires.
+    ReservationStatusHeld ReservationStatus = "held"
+    // ReservationStatusCommitted means the order was paid and the units left stock.
+    ReservationStatusCommitted ReservationStatus = "committed"
+    // ReservationStatusReleased means the hold expired or its order was cancelled.
+    ReservationStatusReleased ReservationStatus = "released"
+)
+
+// Reservation is a time-limited hold on product stock for an unpaid order.
+type Reservation struct {
+    ID        string `json:"id"`
+    OrderID   string `json:"order_id"`
+    ProductID string `json:"product_id"`
+    // SKU is the variant held, for products sold as variants.
+    SKU string `json:"sku,omitempty"`
+    // WarehouseID is the warehouse the units are held at; empty for stock
+    // not assigned to any warehouse.
+    WarehouseID string            `json:"warehouse_id,omitempty"`
+    Quantity    int               `json:"quantity"`
+    Status      ReservationStatus `json:"status"`
+    ExpiresAt   time.Time         `json:"expires_at"`
+    CreatedAt   time.Time         `json:"created_at"`
+    // ResolvedAt is when the hold was committed or released.
+    ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
@@ -0,0 +1,95 @@
+package domain
+
+import "slices"
+
+// Role determines what a user may do beyond managing their own account,
+// carts and orders.
+type Role string
+
+const (
+    RoleCustomer       Role = "customer"
+    RoleSupport        Role = "support"
+    RoleCatalogManager Role = "catalog_manager"
+    RoleAdmin          Role = "admin"
+)
+
+// Permission names an operation restricted to staff.
+type Permission string
+
+const (
+    // PermissionCatalogWrite covers creating, changing and deleting products,
+    // their variants and categories.
+    PermissionCatalogWrite Permission = "catalog:write"
+    // PermissionInventoryRead covers stock ledgers, reconciliations and
+    // warehouse stock levels.
+    PermissionInventoryRead Permission = "inventory:read"
+    // PermissionInventoryWrite covers warehouses, stock adjustments and transfers.
+    PermissionInventoryWrite Permission = "inventory:write"
+    // PermissionPromotionsManage covers creating, changing and withdrawing
+    // promotions and coupons.
+    PermissionPromotionsManage Permission = "promotions:manage"
+    // PermissionOrdersRead covers reading every user's orders and invoices.
+    PermissionOrdersRead Permission = "orders:read"
+    // PermissionOrdersManage covers moving any order through its lifecycle,
+    // including cancelling orders on a customer's behalf.
+    PermissionOrdersManage Permission = "orders:manage"
+    // PermissionUsersRead covers listing users and reading any user's profile.
+    PermissionUsersRead Permission = "users:read"
+    // PermissionUsersManage covers changing users' roles.
+    PermissionUsersManage Permission = "users:manage"
+    // PermissionAPIKeysManage covers issuing, listing and revoking API keys.
+    PermissionAPIKeysManage Permission = "api_keys:manage"
+)
+
+// permissions lists every permission.
+var permissions = []Permission{
+    PermissionCatalogWrite,
+    PermissionInventoryRead,
+    PermissionInventoryWrite,
+    PermissionPromotionsManage,
+    PermissionOrdersRead,
+    PermissionOrdersManage,
+    PermissionUsersRead,
+    PermissionUsersManage,
+    PermissionAPIKeysManage,
+}
+
+// Valid reports whether p is a known permission.
+func (p Permission) Valid() bool {
+    return slices.Contains(permissions, p)
+}
+
+// rolePermissions is the permission matrix. Customers hold no permissions:
+// everything they may do is limited to their own resources.
+var rolePermissions = map[Role][]Permission{
+    RoleCustomer: nil,
+    RoleSupport: {
+        PermissionInventoryRead,
+        PermissionOrdersRead,
+        PermissionOrdersManage,
+        PermissionUsersRead,
+    },
+    RoleCatalogManager: {
+        PermissionCatalogWrite,
+        PermissionInventoryRead,
+        PermissionInventoryWrite,
+        PermissionPromotionsManage,
+    },
+    RoleAdmin: permissions,
+}
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+    _, ok := rolePermissions[r]
+    return ok
+}
+
+// Can reports whether the role grants p.
+func (r Role) Can(p Permission) bool {
+    return slices.Contains(rolePermissions[r], p)
+}
+
+// Permissions lists the permissions the role grants.
+func (r Role) Permissions() []Permission {
+    return slices.Clone(rolePermissions[r])
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+    "errors"
+    "time"
+)
+
+// StockMovementReason explains why physical stock changed.
+type StockMovementReason string
+
+const (
+    // StockMovementSale records units leaving stock for a paid order.
+    StockMovementSale StockMovementReason = "sale"
+    // StockMovementRestock records goods received.
+    StockMovementRestock StockMovementReason = "restock"
+    // StockMovementAdjustment records a manual correction, such as after a count.
+    StockMovementAdjustment StockMovementReason = "adjustment"
+    // StockMovementCancel records units returned to stock by a cancelled order.
+    StockMovementCancel StockMovementReason = "cancel"
+    // StockMovementReturn records goods sent back by a customer.
+    StockMovementReturn StockMovementReason = "return"
+    // StockMovementTransfer records units moved between warehouses; a
+    // transfer is a pair of movements that cancel out in the product total.
+    StockMovementTransfer StockMovementReason = "transfer"
+)
+
+// Valid reports whether r is a known reason.
+func (r StockMovementReason) Valid() bool {
+    switch r {
+    case StockMovementSale, StockMovementRestock, StockMovementAdjustment,
+        StockMovementCancel, StockMovementReturn, StockMovementTransfer:
+        return true
+    }
+    return false
+}
+
+// StockMovement is an entry in the append-only stock ledger. Summing a
+// product's movements gives its stock, and summing those of one warehouse
+// gives the warehouse's quantity on hand. Reservations do not move physical
+// stock and are not recorded.
+type StockMovement struct {
+    ID        string `json:"id"`
+    ProductID string `json:"product_id"`
+    // SKU is the variant whose stock changed, for products sold as variants.
+    SKU string `json:"sku,omitempty"`
+    // WarehouseID is empty for stock not assigned to any warehouse.
+    WarehouseID string `json:"warehouse_id,omitempty"`
+    // Quantity is the signed change in physical stock.
+    Quantity int                 `json:"quantity"`
+    Reason   StockMovementReason `json:"reason"`
+    // ReferenceID links the movement to its cause, such as an order ID.
+    ReferenceID string    `json:"reference_id,omitempty"`
+    Actor       string    `json:"actor"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+// Validate ensures the movement is well formed before it is recorded.
+func (m StockMovement) Validate() error {
+    if m.ProductID == "" {
+        return errors.New("product_id is required")
+    }
+    if m.Quantity == 0 {
+        return errors.New("quantity must not be zero")
+    }
+    if !m.Reason.Valid() {
+        return errors.New("reason is not supported")
+    }
+    if m.Actor == "" {
+        return errors.New("actor is required")
+    }
+    return nil
+}
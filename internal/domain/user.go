@@ -2,7 +2,9 @@ package domain
 
 import (
     "errors"
+    "fmt"
     "regexp"
+    "time"
 )
 
 var emailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
@@ -12,6 +14,34 @@ type User struct {
     ID    string `json:"id"`
     Name  string `json:"name"`
     Email string `json:"email"`
+    Role  Role   `json:"role"`
+    // EmailVerified is set once the user has proved they receive mail at
+    // Email. It is cleared when the email changes.
+    EmailVerified bool `json:"email_verified"`
+    // PasswordHash is the argon2id hash of the user's password. It is never
+    // encoded into responses.
+    PasswordHash string `json:"-"`
+    // TOTPEnabled is set once the user has confirmed a TOTP enrollment;
+    // logging in then takes a code as well as the password.
+    TOTPEnabled bool `json:"totp_enabled"`
+    // TOTPSecret is the shared TOTP secret, held while an enrollment awaits
+    // confirmation and while TOTP is enabled.
+    TOTPSecret string `json:"-"`
+    // TOTPLastStep is the time step of the last accepted code. A code is
+    // only accepted from a later step, so a code cannot be replayed.
+    TOTPLastStep int64 `json:"-"`
+    // MFAFailures counts the wrong codes entered at login since the last
+    // accepted one.
+    MFAFailures int `json:"-"`
+    // MFALockedUntil is set once too many wrong codes have been entered at
+    // login; no code is accepted for the user before then.
+    MFALockedUntil *time.Time `json:"-"`
+    // RecoveryCodeHashes are the hashes of the unused one-time recovery codes.
+    RecoveryCodeHashes []string  `json:"-"`
+    CreatedAt          time.Time `json:"created_at"`
+    // DeletedAt is set once the account has been deleted. The record is kept,
+    // stripped of personal data, so that the user's orders still refer to it.
+    DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // Validate ensures the user is well formed.
@@ -22,5 +52,8 @@ func (u User) Validate() error {
     if !emailRegex.MatchString(u.Email) {
         return errors.New("email is invalid")
     }
+    if !u.Role.Valid() {
+        return fmt.Errorf("unknown role %q", u.Role)
+    }
     return nil
 }
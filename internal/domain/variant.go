@@ -0,0 +1,192 @@
+package domain
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+)
+
+// ProductOption is an axis along which a product's variants differ, such as
+// colour or network, with the values it may take.
+type ProductOption struct {
+    Name   string   `json:"name"`
+    Values []string `json:"values"`
+}
+
+// Variant is a purchasable version of a product, identified by its SKU and
+// by one value for each of the product's options.
+type Variant struct {
+    SKU string `json:"sku"`
+    // Options maps each option name of the product to this variant's value.
+    Options  map[string]string `json:"options"`
+    Price    Money             `json:"price"`
+    Stock    int               `json:"stock"`
+    Reserved int               `json:"reserved"`
+}
+
+// Available returns the quantity of the variant that can still be ordered.
+func (v Variant) Available() int {
+    return v.Stock - v.Reserved
+}
+
+// MarshalJSON adds the derived available quantity to the encoded variant.
+func (v Variant) MarshalJSON() ([]byte, error) {
+    type variant Variant
+    return json.Marshal(struct {
+        variant
+        Available int `json:"available"`
+    }{variant(v), v.Available()})
+}
+
+// validateVariants checks the product's options and variants: every variant
+// picks one allowed value per option, no two variants pick the same values,
+// and the product's stock is the sum of its variants'.
+func (p Product) validateVariants() error {
+    if len(p.Options) > 0 && len(p.Variants) == 0 {
+        return errors.New("a product with options must have at least one variant")
+    }
+    if len(p.Variants) > 0 && len(p.Options) == 0 {
+        return errors.New("a product with variants must declare its options")
+    }
+
+    allowed := make(map[string]map[string]bool, len(p.Options))
+    for _, option := range p.Options {
+        if option.Name == "" {
+            return errors.New("option name is required")
+        }
+        if allowed[option.Name] != nil {
+            return fmt.Errorf("option %q is declared twice", option.Name)
+        }
+        if len(option.Values) == 0 {
+            return fmt.Errorf("option %q must have at least one value", option.Name)
+        }
+        allowed[option.Name] = make(map[string]bool, len(option.Values))
+        for _, value := range option.Values {
+            if value == "" || allowed[option.Name][value] {
+                return fmt.Errorf("option %q values must be non-empty and distinct", option.Name)
+            }
+            allowed[option.Name][value] = true
+        }
+    }
+
+    skus := make(map[string]bool, len(p.Variants))
+    combinations := make(map[string]string, len(p.Variants))
+    stock, reserved := 0, 0
+    for _, variant := range p.Variants {
+        if variant.SKU == "" {
+            return errors.New("sku is required for each variant")
+        }
+        if skus[variant.SKU] {
+            return fmt.Errorf("sku %q is used by more than one variant", variant.SKU)
+        }
+        skus[variant.SKU] = true
+
+        if len(variant.Options) != len(p.Options) {
+            return fmt.Errorf("variant %s must set exactly one value for each option", variant.SKU)
+        }
+        key, err := json.Marshal(variant.Options)
+        if err != nil {
+            return err
+        }
+        for name, value := range variant.Options {
+            if !allowed[name][value] {
+                return fmt.Errorf("variant %s: %q is not a value of option %q", variant.SKU, value, name)
+            }
+        }
+        if other, ok := combinations[string(key)]; ok {
+            return fmt.Errorf("variants %s and %s have the same options", other, variant.SKU)
+        }
+        combinations[string(key)] = variant.SKU
+
+        if variant.Price.Currency != p.Price.Currency {
+            return fmt.Errorf("variant %s must be priced in the product's currency", variant.SKU)
+        }
+        if !variant.Price.IsPositive() {
+            return fmt.Errorf("variant %s price must be positive", variant.SKU)
+        }
+        if variant.Stock < 0 {
+            return fmt.Errorf("variant %s stock cannot be negative", variant.SKU)
+        }
+        if variant.Stock < variant.Reserved {
+            return fmt.Errorf("variant %s stock cannot be less than the quantity reserved for open orders", variant.SKU)
+        }
+        stock += variant.Stock
+        reserved += variant.Reserved
+    }
+    if len(p.Variants) > 0 && (p.Stock != stock || p.Reserved != reserved) {
+        return errors.New("product stock must equal the total stock of its variants")
+    }
+    return nil
+}
+
+// Variant returns the variant with the given SKU.
+func (p Product) Variant(sku string) (Variant, bool) {
+    for _, variant := range p.Variants {
+        if variant.SKU == sku {
+            return variant, true
+        }
+    }
+    return Variant{}, false
+}
+
+// HasVariants reports whether the product is sold as variants, in which
+// case every order and stock change names a SKU.
+func (p Product) HasVariants() bool {
+    return len(p.Variants) > 0
+}
+
+// CheckSKU reports whether sku correctly selects what is bought of the
+// product: a variant SKU for products with variants, and none otherwise.
+func (p Product) CheckSKU(sku string) error {
+    switch {
+    case sku == "" && p.HasVariants():
+        return fmt.Errorf("product %s is sold as variants; a sku is required", p.ID)
+    case sku != "" && !p.HasVariants():
+        return fmt.Errorf("product %s has no variants", p.ID)
+    case sku != "":
+        if _, ok := p.Variant(sku); !ok {
+            return fmt.Errorf("product %s has no variant %s", p.ID, sku)
+        }
+    }
+    return nil
+}
+
+// PriceOf returns the unit price of the product, or of its variant sku.
+func (p Product) PriceOf(sku string) Money {
+    if variant, ok := p.Variant(sku); ok {
+        return variant.Price
+    }
+    return p.Price
+}
+
+// AvailableOf returns the quantity of the product, or of its variant sku,
+// that can still be ordered.
+func (p Product) AvailableOf(sku string) int {
+    if sku == "" {
+        return p.Available()
+    }
+    variant, _ := p.Variant(sku)
+    return variant.Available()
+}
+
+// AdjustStock changes the product's stock and reserved quantities and, when
+// sku is set, those of that variant too, keeping the product totals equal to
+// the sum of its variants. Reserved quantities do not drop below zero.
+func (p *Product) AdjustStock(sku string, stockDelta, reservedDelta int) error {
+    if err := p.CheckSKU(sku); err != nil {
+        return err
+    }
+    for i := range p.Variants {
+        if p.Variants[i].SKU != sku {
+            continue
+        }
+        variant := &p.Variants[i]
+        variant.Stock += stockDelta
+        // Clamp the variant and keep the product total in step with it.
+        reservedDelta = max(variant.Reserved+reservedDelta, 0) - variant.Reserved
+        variant.Reserved += reservedDelta
+    }
+    p.Stock += stockDelta
+    p.Reserved = max(p.Reserved+reservedDelta, 0)
+    return nil
+}
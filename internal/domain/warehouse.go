@@ -0,0 +1,111 @@
+package domain
+
+import (
+    "encoding/json"
+    "errors"
+    "math"
+    "time"
+)
+
+// earthRadiusKm is the mean Earth radius used for great-circle distances.
+const earthRadiusKm = 6371.0
+
+// GeoPoint is a location in decimal degrees.
+type GeoPoint struct {
+    Latitude  float64 `json:"latitude"`
+    Longitude float64 `json:"longitude"`
+}
+
+// Validate ensures the coordinates are within range.
+func (p GeoPoint) Validate() error {
+    if p.Latitude < -90 || p.Latitude > 90 {
+        return errors.New("latitude must be between -90 and 90")
+    }
+    if p.Longitude < -180 || p.Longitude > 180 {
+        return errors.New("longitude must be between -180 and 180")
+    }
+    return nil
+}
+
+// DistanceKm returns the great-circle distance to q.
+func (p GeoPoint) DistanceKm(q GeoPoint) float64 {
+    lat1, lat2 := p.Latitude*math.Pi/180, q.Latitude*math.Pi/180
+    dLat := lat2 - lat1
+    dLon := (q.Longitude - p.Longitude) * math.Pi / 180
+    h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+    return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Warehouse is a location stock ships from.
+type Warehouse struct {
+    ID   string `json:"id"`
+    Name string `json:"name"`
+    // Location is used by nearest-warehouse allocation; warehouses without
+    // one are considered after those with a known distance.
+    Location *GeoPoint `json:"location,omitempty"`
+    // Priority orders warehouses for priority allocation, lowest first.
+    Priority  int       `json:"priority"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate ensures the warehouse is well formed before persistence.
+func (w Warehouse) Validate() error {
+    if w.Name == "" {
+        return errors.New("name is required")
+    }
+    if w.Location != nil {
+        if err := w.Location.Validate(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// StockLevel is the stock of one product, or of one of its variants, held at
+// one warehouse. The levels of a product account for part or all of its Stock
+// and Reserved totals, and those of a variant for part or all of the
+// variant's; the remainder is stock not yet assigned to any warehouse.
+type StockLevel struct {
+    WarehouseID string `json:"warehouse_id,omitempty"`
+    ProductID   string `json:"product_id"`
+    // SKU is the variant held, for products sold as variants.
+    SKU    string `json:"sku,omitempty"`
+    OnHand int    `json:"on_hand"`
+    // Reserved is the part of OnHand held for unpaid orders.
+    Reserved int `json:"reserved"`
+}
+
+// Available returns the quantity that can still be allocated from the warehouse.
+func (l StockLevel) Available() int {
+    return l.OnHand - l.Reserved
+}
+
+// MarshalJSON adds the derived available quantity to the encoded level.
+func (l StockLevel) MarshalJSON() ([]byte, error) {
+    type level StockLevel
+    return json.Marshal(struct {
+        level
+        Available int `json:"available"`
+    }{level(l), l.Available()})
+}
+
+// Validate ensures the level is consistent.
+func (l StockLevel) Validate() error {
+    if l.OnHand < 0 {
+        return errors.New("on_hand cannot be negative")
+    }
+    if l.OnHand < l.Reserved {
+        return errors.New("on_hand cannot be less than the quantity reserved for open orders")
+    }
+    return nil
+}
+
+// Allocation assigns part of an order line to the warehouse that ships it.
+// An empty WarehouseID means the units come from stock not assigned to any warehouse.
+type Allocation struct {
+    ProductID string `json:"product_id"`
+    // SKU is the variant allocated, for products sold as variants.
+    SKU         string `json:"sku,omitempty"`
+    WarehouseID string `json:"warehouse_id,omitempty"`
+    Quantity    int    `json:"quantity"`
+}
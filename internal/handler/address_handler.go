@@ -0,0 +1,122 @@
+package handler
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/service"
+)
+
+// AddressHandler exposes users' address books.
+type AddressHandler struct {
+    service *service.AddressService
+}
+
+// NewAddressHandler constructs an AddressHandler instance.
+func NewAddressHandler(service *service.AddressService) *AddressHandler {
+    return &AddressHandler{service: service}
+}
+
+// RegisterRoutes registers address book routes on the provided router group.
+func (h *AddressHandler) RegisterRoutes(rg *gin.RouterGroup) {
+    rg.GET("/users/:id/addresses", requireAuth, h.listAddresses)
+    rg.POST("/users/:id/addresses", requireAuth, h.createAddress)
+    rg.GET("/users/:id/addresses/:address_id", requireAuth, h.getAddress)
+    rg.PUT("/users/:id/addresses/:address_id", requireAuth, h.updateAddress)
+    rg.DELETE("/users/:id/addresses/:address_id", requireAuth, h.deleteAddress)
+}
+
+type addressRequest struct {
+    Type       string           `json:"type" binding:"required"`
+    Name       string           `json:"name" binding:"required"`
+    Company    string           `json:"company"`
+    Line1      string           `json:"line1" binding:"required"`
+    Line2      string           `json:"line2"`
+    City       string           `json:"city" binding:"required"`
+    Region     string           `json:"region"`
+    PostalCode string           `json:"postal_code"`
+    Country    string           `json:"country" binding:"required"`
+    Phone      string           `json:"phone"`
+    Location   *domain.GeoPoint `json:"location"`
+}
+
+func (r addressRequest) input() service.AddressInput {
+    return service.AddressInput{
+        Type: domain.AddressType(r.Type),
+        PostalAddress: domain.PostalAddress{
+            Name:       r.Name,
+            Company:    r.Company,
+            Line1:      r.Line1,
+            Line2:      r.Line2,
+            City:       r.City,
+            Region:     r.Region,
+            PostalCode: r.PostalCode,
+            Country:    r.Country,
+            Phone:      r.Phone,
+            Location:   r.Location,
+        },
+    }
+}
+
+func (h *AddressHandler) listAddresses(c *gin.Context) {
+    addresses, err := h.service.ListAddresses(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, addresses)
+}
+
+func (h *AddressHandler) createAddress(c *gin.Context) {
+    var req addressRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    address, err := h.service.CreateAddress(c.Request.Context(), c.Param("id"), req.input())
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusCreated, address)
+}
+
+func (h *AddressHandler) getAddress(c *gin.Context) {
+    address, err := h.service.GetAddress(c.Request.Context(), c.Param("id"), c.Param("address_id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, address)
+}
+
+func (h *AddressHandler) updateAddress(c *gin.Context) {
+    var req addressRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    address, err := h.service.UpdateAddress(c.Request.Context(), c.Param("id"), c.Param("address_id"), req.input())
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, address)
+}
+
+func (h *AddressHandler) deleteAddress(c *gin.Context) {
+    if err := h.service.DeleteAddress(c.Request.Context(), c.Param("id"), c.Param("address_id")); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/service"
+)
+
+// APIKeyHandler exposes API key management endpoints.
+type APIKeyHandler struct {
+    service *service.APIKeyService
+}
+
+// NewAPIKeyHandler constructs a new APIKeyHandler.
+func NewAPIKeyHandler(service *service.APIKeyService) *APIKeyHandler {
+    return &APIKeyHandler{service: service}
+}
+
+// RegisterRoutes registers API key routes on the provided router group.
+func (h *APIKeyHandler) RegisterRoutes(rg *gin.RouterGroup) {
+    manage := requirePermission(domain.PermissionAPIKeysManage)
+    rg.GET("/api-keys", manage, h.listAPIKeys)
+    rg.POST("/api-keys", manage, h.createAPIKey)
+    rg.GET("/api-keys/:id", manage, h.getAPIKey)
+    rg.DELETE("/api-keys/:id", manage, h.revokeAPIKey)
+}
+
+type apiKeyRequest struct {
+    Name      string              `json:"name" binding:"required"`
+    Scopes    []domain.Permission `json:"scopes" binding:"required"`
+    ExpiresAt *time.Time          `json:"expires_at"`
+}
+
+func (h *APIKeyHandler) createAPIKey(c *gin.Context) {
+    var req apiKeyRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    key, err := h.service.CreateAPIKey(c.Request.Context(), service.CreateAPIKeyInput{
+        Name:      req.Name,
+        Scopes:    req.Scopes,
+        ExpiresAt: req.ExpiresAt,
+    })
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusCreated, key)
+}
+
+func (h *APIKeyHandler) listAPIKeys(c *gin.Context) {
+    keys, err := h.service.ListAPIKeys(c.Request.Context())
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) getAPIKey(c *gin.Context) {
+    key, err := h.service.GetAPIKey(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, key)
+}
+
+func (h *APIKeyHandler) revokeAPIKey(c *gin.Context) {
+    key, err := h.service.RevokeAPIKey(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, key)
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/service"
+)
+
+// AuthHandler exposes login and session endpoints and the middleware that
+// authenticates requests.
+type AuthHandler struct {
+    service *service.AuthService
+    apiKeys *service.APIKeyService
+}
+
+// NewAuthHandler constructs a new AuthHandler.
+func NewAuthHandler(service *service.AuthService, apiKeys *service.APIKeyService) *AuthHandler {
+    return &AuthHandler{service: service, apiKeys: apiKeys}
+}
+
+// RegisterRoutes registers authentication routes on the provided router group.
+func (h *AuthHandler) RegisterRoutes(rg *gin.RouterGroup) {
+    rg.POST("/auth/login", h.login)
+    rg.POST("/auth/login/mfa", h.completeLogin)
+    rg.POST("/auth/refresh", h.refresh)
+    rg.POST("/auth/logout", h.logout)
+    rg.GET("/auth/me", requireUser, h.me)
+}
+
+type loginRequest struct {
+    Email    string `json:"email" binding:"required"`
+    Password string `json:"password" binding:"required"`
+}
+
+type mfaLoginRequest struct {
+    MFAToken     string `json:"mfa_token" binding:"required"`
+    Code         string `json:"code"`
+    RecoveryCode string `json:"recovery_code"`
+}
+
+type refreshRequest struct {
+    RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Authenticate resolves the request's credentials and stores who made it in
+// the request context: the user for a bearer access token, or the key for an
+// "ApiKey" authorization. Requests without an Authorization header continue
+// anonymously; routes that need credentials are guarded by requireAuth,
+// requireUser or requirePermission.
+func (h *AuthHandler) Authenticate(c *gin.Context) {
+    header := c.GetHeader("Authorization")
+    if header == "" {
+        c.Next()
+        return
+    }
+
+    scheme, credentials, _ := strings.Cut(header, " ")
+    credentials = strings.TrimSpace(credentials)
+    if credentials == "" {
+        respondError(c, fmt.Errorf("%w: missing credentials", service.ErrUnauthenticated))
+        c.Abort()
+        return
+    }
+
+    ctx := c.Request.Context()
+    switch {
+    case strings.EqualFold(scheme, "Bearer"):
+        user, err := h.service.Authenticate(ctx, credentials)
+        if err != nil {
+            respondError(c, err)
+            c.Abort()
+            return
+        }
+        ctx = service.WithUser(ctx, user)
+    case strings.EqualFold(scheme, "ApiKey"):
+        key, err := h.apiKeys.Authenticate(ctx, credentials)
+        if err != nil {
+            respondError(c, err)
+            c.Abort()
+            return
+        }
+        ctx = service.WithAPIKey(ctx, key)
+    default:
+        respondError(c, fmt.Errorf("%w: unsupported authorization scheme", service.ErrUnauthenticated))
+        c.Abort()
+        return
+    }
+
+    c.Request = c.Request.WithContext(ctx)
+    c.Next()
+}
+
+// requireAuth rejects requests that did not authenticate, with either a user
+// session or an API key. The service decides what the caller may see.
+func requireAuth(c *gin.Context) {
+    ctx := c.Request.Context()
+    _, isUser := service.UserFromContext(ctx)
+    _, isKey := service.APIKeyFromContext(ctx)
+    if !isUser && !isKey {
+        respondError(c, fmt.Errorf("%w: authentication required", service.ErrUnauthenticated))
+        c.Abort()
+        return
+    }
+    c.Next()
+}
+
+// requireUser rejects requests that were not made in a user session, such
+// as those that act for the user in the token.
+func requireUser(c *gin.Context) {
+    if err := checkUser(c); err != nil {
+        respondError(c, err)
+        c.Abort()
+        return
+    }
+    c.Next()
+}
+
+func checkUser(c *gin.Context) error {
+    ctx := c.Request.Context()
+    if _, ok := service.UserFromContext(ctx); ok {
+        return nil
+    }
+    if _, ok := service.APIKeyFromContext(ctx); ok {
+        return fmt.Errorf("%w: requires a user session, not an API key", service.ErrForbidden)
+    }
+    return fmt.Errorf("%w: authentication required", service.ErrUnauthenticated)
+}
+
+// requirePermission rejects requests whose user role or API key scopes lack perm.
+func requirePermission(perm domain.Permission) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if err := service.Authorize(c.Request.Context(), perm); err != nil {
+            respondError(c, err)
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
+
+// requireSelf restricts routes under /users/:id to the user they name.
+func requireSelf(c *gin.Context) {
+    if err := checkUser(c); err != nil {
+        respondError(c, err)
+        c.Abort()
+        return
+    }
+    user := currentUser(c)
+    if user.ID != c.Param("id") {
+        respondError(c, fmt.Errorf("%w: cannot access another user's account", service.ErrForbidden))
+        c.Abort()
+        return
+    }
+    c.Next()
+}
+
+// currentUser returns the user authenticated by requireUser or requireSelf.
+func currentUser(c *gin.Context) domain.User {
+    user, _ := service.UserFromContext(c.Request.Context())
+    return user
+}
+
+// currentActor names whoever authenticated the request for audit records:
+// "user:<id>" for a user, or "api_key:<id>" for an API key. It is never taken
+// from the request body, so callers cannot claim to be someone else.
+func currentActor(c *gin.Context) string {
+    if key, ok := service.APIKeyFromContext(c.Request.Context()); ok {
+        return "api_key:" + key.ID
+    }
+    return "user:" + currentUser(c).ID
+}
+
+func (h *AuthHandler) login(c *gin.Context) {
+    var req loginRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    result, err := h.service.Login(c.Request.Context(), req.Email, req.Password)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    if result.Challenge != nil {
+        c.JSON(http.StatusOK, result.Challenge)
+        return
+    }
+    c.JSON(http.StatusOK, result.Tokens)
+}
+
+func (h *AuthHandler) completeLogin(c *gin.Context) {
+    var req mfaLoginRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    pair, err := h.service.CompleteLogin(c.Request.Context(), req.MFAToken, req.Code, req.RecoveryCode)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, pair)
+}
+
+func (h *AuthHandler) refresh(c *gin.Context) {
+    var req refreshRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    pair, err := h.service.Refresh(c.Request.Context(), req.RefreshToken)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, pair)
+}
+
+func (h *AuthHandler) logout(c *gin.Context) {
+    var req refreshRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := h.service.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+// meResponse is the authenticated user with the permissions their role grants.
+type meResponse struct {
+    domain.User
+    Permissions []domain.Permission `json:"permissions"`
+}
+
+func (h *AuthHandler) me(c *gin.Context) {
+    user := currentUser(c)
+    permissions := user.Role.Permissions()
+    if permissions == nil {
+        permissions = []domain.Permission{}
+    }
+    c.JSON(http.StatusOK, meResponse{User: user, Permissions: permissions})
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/service"
+)
+
+// CartHandler exposes shopping cart endpoints.
+type CartHandler struct {
+    service *service.CartService
+}
+
+// NewCartHandler constructs a new CartHandler.
+func NewCartHandler(service *service.CartService) *CartHandler {
+    return &CartHandler{service: service}
+}
+
+// RegisterRoutes registers cart routes on the provided router group.
+func (h *CartHandler) RegisterRoutes(rg *gin.RouterGroup) {
+    rg.GET("/users/:id/cart", requireSelf, h.getCart)
+    rg.DELETE("/users/:id/cart", requireSelf, h.clearCart)
+    rg.POST("/users/:id/cart/items", requireSelf, h.addItem)
+    rg.PUT("/users/:id/cart/items/:product_id", requireSelf, h.updateItem)
+    rg.DELETE("/users/:id/cart/items/:product_id", requireSelf, h.removeItem)
+    rg.POST("/users/:id/cart/checkout", requireSelf, h.checkout)
+}
+
+type cartItemRequest struct {
+    ProductID string `json:"product_id" binding:"required"`
+    SKU       string `json:"sku"`
+    Quantity  int    `json:"quantity" binding:"required,gt=0"`
+}
+
+type cartQuantityRequest struct {
+    Quantity *int `json:"quantity" binding:"required,gte=0"`
+}
+
+type checkoutRequest struct {
+    Currency          string           `json:"currency"`
+    PaymentCurrency   string           `json:"payment_currency"`
+    PaymentMethod     string           `json:"payment_method"`
+    ShipTo            *domain.GeoPoint `json:"ship_to"`
+    ShippingAddressID string           `json:"shipping_address_id"`
+    BillingAddressID  string           `json:"billing_address_id"`
+    CouponCodes       []string         `json:"coupon_codes"`
+}
+
+func (h *CartHandler) getCart(c *gin.Context) {
+    cart, err := h.service.GetCart(c.Request.Context(), c.Param("id"), c.Query("currency"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, cart)
+}
+
+func (h *CartHandler) clearCart(c *gin.Context) {
+    if err := h.service.ClearCart(c.Request.Context(), c.Param("id")); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+func (h *CartHandler) addItem(c *gin.Context) {
+    var req cartItemRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    cart, err := h.service.AddItem(c.Request.Context(), c.Param("id"), req.ProductID, req.SKU, req.Quantity, c.Query("currency"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, cart)
+}
+
+func (h *CartHandler) updateItem(c *gin.Context) {
+    var req cartQuantityRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    cart, err := h.service.UpdateItem(c.Request.Context(), c.Param("id"), c.Param("product_id"), c.Query("sku"), *req.Quantity, c.Query("currency"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, cart)
+}
+
+func (h *CartHandler) removeItem(c *gin.Context) {
+    cart, err := h.service.RemoveItem(c.Request.Context(), c.Param("id"), c.Param("product_id"), c.Query("sku"), c.Query("currency"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, cart)
+}
+
+func (h *CartHandler) checkout(c *gin.Context) {
+    var req checkoutRequest
+    // The body is optional; an empty one checks out with the defaults.
+    if c.Request.ContentLength != 0 {
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+    }
+
+    order, err := h.service.Checkout(c.Request.Context(), c.Param("id"), service.CheckoutInput{
+        Currency:          req.Currency,
+        PaymentCurrency:   req.PaymentCurrency,
+        PaymentMethod:     domain.PaymentMethod(req.PaymentMethod),
+        ShipTo:            req.ShipTo,
+        ShippingAddressID: req.ShippingAddressID,
+        BillingAddressID:  req.BillingAddressID,
+        CouponCodes:       req.CouponCodes,
+    })
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusCreated, order)
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/service"
+)
+
+// CategoryHandler exposes category endpoints and product category assignment.
+type CategoryHandler struct {
+    service *service.CategoryService
+}
+
+// NewCategoryHandler constructs a CategoryHandler instance.
+func NewCategoryHandler(service *service.CategoryService) *CategoryHandler {
+    return &CategoryHandler{service: service}
+}
+
+// RegisterRoutes registers category routes on the provided router group.
+func (h *CategoryHandler) RegisterRoutes(rg *gin.RouterGroup) {
+    rg.GET("/categories", h.listCategories)
+    rg.POST("/categories", requirePermission(domain.PermissionCatalogWrite), h.createCategory)
+    rg.GET("/categories/:id", h.getCategory)
+    rg.PUT("/categories/:id", requirePermission(domain.PermissionCatalogWrite), h.updateCategory)
+    rg.DELETE("/categories/:id", requirePermission(domain.PermissionCatalogWrite), h.deleteCategory)
+    rg.GET("/categories/:id/products", h.listCategoryProducts)
+    rg.GET("/products/:id/categories", h.getProductCategories)
+    rg.PUT("/products/:id/categories", requirePermission(domain.PermissionCatalogWrite), h.setProductCategories)
+}
+
+type categoryRequest struct {
+    Name     string `json:"name" binding:"required"`
+    ParentID string `json:"parent_id"`
+}
+
+type productCategoriesRequest struct {
+    CategoryIDs []string `json:"category_ids" binding:"required"`
+}
+
+// categoryProductsRequest binds the product list filters accepted when
+// listing a category's products.
+type categoryProductsRequest struct {
+    listRequest
+    IncludeDescendants bool   `form:"include_descendants"`
+    PriceCurrency      string `form:"price_currency"`
+    MinPrice           string `form:"min_price"`
+    MaxPrice           string `form:"max_price"`
+    InStock            bool   `form:"in_stock"`
+}
+
+func (h *CategoryHandler) createCategory(c *gin.Context) {
+    var req categoryRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    category, err := h.service.CreateCategory(c.Request.Context(), domain.Category{Name: req.Name, ParentID: req.ParentID})
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusCreated, category)
+}
+
+func (h *CategoryHandler) listCategories(c *gin.Context) {
+    categories, err := h.service.ListCategories(c.Request.Context())
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, categories)
+}
+
+func (h *CategoryHandler) getCategory(c *gin.Context) {
+    category, err := h.service.GetCategory(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) updateCategory(c *gin.Context) {
+    var req categoryRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    category, err := h.service.UpdateCategory(c.Request.Context(), c.Param("id"), domain.Category{Name: req.Name, ParentID: req.ParentID})
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) deleteCategory(c *gin.Context) {
+    if err := h.service.DeleteCategory(c.Request.Context(), c.Param("id")); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+func (h *CategoryHandler) listCategoryProducts(c *gin.Context) {
+    var req categoryProductsRequest
+    if err := c.ShouldBindQuery(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    products, err := h.service.ListCategoryProducts(c.Request.Context(), c.Param("id"), req.IncludeDescendants, service.ListProductsInput{
+        ListInput:     req.input(),
+        PriceCurrency: req.PriceCurrency,
+        MinPrice:      req.MinPrice,
+        MaxPrice:      req.MaxPrice,
+        InStock:       req.InStock,
+    })
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, products)
+}
+
+func (h *CategoryHandler) getProductCategories(c *gin.Context) {
+    categories, err := h.service.GetProductCategories(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, categories)
+}
+
+func (h *CategoryHandler) setProductCategories(c *gin.Context) {
+    var req productCategoriesRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    categories, err := h.service.SetProductCategories(c.Request.Context(), c.Param("id"), req.CategoryIDs)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, categories)
+}
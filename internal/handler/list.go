@@ -0,0 +1,15 @@
+package handler
+
+import "cryptotrade/internal/service"
+
+// listRequest binds the paging and sorting query parameters shared by the
+// list endpoints.
+type listRequest struct {
+    Limit  int    `form:"limit"`
+    Cursor string `form:"cursor"`
+    Sort   string `form:"sort"`
+}
+
+func (r listRequest) input() service.ListInput {
+    return service.ListInput{Limit: r.Limit, Cursor: r.Cursor, Sort: r.Sort}
+}
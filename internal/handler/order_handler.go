@@ -2,6 +2,7 @@ package handler
 
 import (
     "net/http"
+    "time"
 
     "github.com/gin-gonic/gin"
 
@@ -21,19 +22,55 @@ func NewOrderHandler(service *service.OrderService) *OrderHandler {
 
 // RegisterRoutes registers order routes on the provided router group.
 func (h *OrderHandler) RegisterRoutes(rg *gin.RouterGroup) {
-    rg.GET("/orders", h.listOrders)
-    rg.GET("/orders/:id", h.getOrder)
-    rg.POST("/orders", h.createOrder)
+    rg.GET("/orders", requireAuth, h.listOrders)
+    rg.GET("/orders/:id", requireAuth, h.getOrder)
+    rg.GET("/orders/:id/invoice", requireAuth, h.getInvoice)
+    rg.POST("/orders", requireUser, h.createOrder)
+    rg.POST("/orders/:id/transitions", requirePermission(domain.PermissionOrdersManage), h.transitionOrder)
+    rg.POST("/orders/:id/cancel", requireAuth, h.cancelOrder)
+    rg.POST("/payments/lightning/settlements", h.settleLightningPayment)
 }
 
+// orderItemRequest names a product, a variant SKU, or both.
 type orderItemRequest struct {
-    ProductID string `json:"product_id" binding:"required"`
+    ProductID string `json:"product_id" binding:"required_without=SKU"`
+    SKU       string `json:"sku"`
     Quantity  int    `json:"quantity" binding:"required,gt=0"`
 }
 
+// orderRequest places an order for the authenticated user.
 type orderRequest struct {
-    UserID string             `json:"user_id" binding:"required"`
-    Items  []orderItemRequest `json:"items" binding:"required,dive"`
+    Items           []orderItemRequest `json:"items" binding:"required,dive"`
+    Currency        string             `json:"currency"`
+    PaymentCurrency string             `json:"payment_currency"`
+    PaymentMethod   string             `json:"payment_method"`
+    ShipTo          *domain.GeoPoint   `json:"ship_to"`
+    // ShippingAddressID and BillingAddressID pick entries of the user's
+    // address book, which are copied onto the order.
+    ShippingAddressID string   `json:"shipping_address_id"`
+    BillingAddressID  string   `json:"billing_address_id"`
+    CouponCodes       []string `json:"coupon_codes"`
+}
+
+type orderListRequest struct {
+    listRequest
+    UserID      string    `form:"user_id"`
+    Status      string    `form:"status"`
+    CreatedFrom time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+    CreatedTo   time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+    RefundDue   bool      `form:"refund_due"`
+}
+
+type transitionRequest struct {
+    Status string `json:"status" binding:"required"`
+}
+
+type cancelRequest struct {
+    Reason string `json:"reason" binding:"required"`
+}
+
+type lightningSettlementRequest struct {
+    Preimage string `json:"preimage" binding:"required"`
 }
 
 func (h *OrderHandler) createOrder(c *gin.Context) {
@@ -45,10 +82,20 @@ func (h *OrderHandler) createOrder(c *gin.Context) {
 
     items := make([]domain.OrderItem, 0, len(req.Items))
     for _, item := range req.Items {
-        items = append(items, domain.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity})
+        items = append(items, domain.OrderItem{ProductID: item.ProductID, SKU: item.SKU, Quantity: item.Quantity})
     }
 
-    order, err := h.service.CreateOrder(c.Request.Context(), req.UserID, items)
+    order, err := h.service.CreateOrder(c.Request.Context(), service.CreateOrderInput{
+        UserID:            currentUser(c).ID,
+        Items:             items,
+        Currency:          req.Currency,
+        PaymentCurrency:   req.PaymentCurrency,
+        PaymentMethod:     domain.PaymentMethod(req.PaymentMethod),
+        ShipTo:            req.ShipTo,
+        ShippingAddressID: req.ShippingAddressID,
+        BillingAddressID:  req.BillingAddressID,
+        CouponCodes:       req.CouponCodes,
+    })
     if err != nil {
         respondError(c, err)
         return
@@ -58,7 +105,20 @@ func (h *OrderHandler) createOrder(c *gin.Context) {
 }
 
 func (h *OrderHandler) listOrders(c *gin.Context) {
-    orders, err := h.service.ListOrders(c.Request.Context())
+    var req orderListRequest
+    if err := c.ShouldBindQuery(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    orders, err := h.service.ListOrders(c.Request.Context(), service.ListOrdersInput{
+        ListInput:   req.input(),
+        UserID:      req.UserID,
+        Status:      domain.OrderStatus(req.Status),
+        CreatedFrom: req.CreatedFrom,
+        CreatedTo:   req.CreatedTo,
+        RefundDue:   req.RefundDue,
+    })
     if err != nil {
         respondError(c, err)
         return
@@ -76,3 +136,61 @@ func (h *OrderHandler) getOrder(c *gin.Context) {
 
     c.JSON(http.StatusOK, order)
 }
+
+func (h *OrderHandler) transitionOrder(c *gin.Context) {
+    var req transitionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    order, err := h.service.TransitionOrder(c.Request.Context(), c.Param("id"), domain.OrderStatus(req.Status))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, order)
+}
+
+func (h *OrderHandler) cancelOrder(c *gin.Context) {
+    var req cancelRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    order, err := h.service.CancelOrder(c.Request.Context(), c.Param("id"), currentActor(c), req.Reason)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, order)
+}
+
+func (h *OrderHandler) getInvoice(c *gin.Context) {
+    invoice, err := h.service.GetInvoice(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, invoice)
+}
+
+func (h *OrderHandler) settleLightningPayment(c *gin.Context) {
+    var req lightningSettlementRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    invoice, err := h.service.SettleLightningPayment(c.Request.Context(), req.Preimage)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, invoice)
+}
@@ -22,17 +22,65 @@ func NewProductHandler(service *service.ProductService) *ProductHandler {
 // RegisterRoutes registers product routes on the provided router group.
 func (h *ProductHandler) RegisterRoutes(rg *gin.RouterGroup) {
 	rg.GET("/products", h.listProducts)
-	rg.POST("/products", h.createProduct)
+	rg.GET("/products/search", h.searchProducts)
+	rg.POST("/products", requirePermission(domain.PermissionCatalogWrite), h.createProduct)
 	rg.GET("/products/:id", h.getProduct)
-	rg.PUT("/products/:id", h.updateProduct)
-	rg.DELETE("/products/:id", h.deleteProduct)
+	rg.PUT("/products/:id", requirePermission(domain.PermissionCatalogWrite), h.updateProduct)
+	rg.DELETE("/products/:id", requirePermission(domain.PermissionCatalogWrite), h.deleteProduct)
 }
 
 type productRequest struct {
-	Name        string  `json:"name" binding:"required"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" binding:"required,gt=0"`
-	Stock       int     `json:"stock" binding:"gte=0"`
+	Name        string                 `json:"name" binding:"required"`
+	Description string                 `json:"description"`
+	Price       domain.Money           `json:"price" binding:"required"`
+	Stock       int                    `json:"stock" binding:"gte=0"`
+	Options     []domain.ProductOption `json:"options"`
+	Variants    []variantRequest       `json:"variants" binding:"dive"`
+}
+
+// variantRequest describes a product variant. Stock is only accepted when
+// the product is created.
+type variantRequest struct {
+	SKU     string            `json:"sku" binding:"required"`
+	Options map[string]string `json:"options"`
+	Price   domain.Money      `json:"price" binding:"required"`
+	Stock   *int              `json:"stock" binding:"omitempty,gte=0"`
+}
+
+// productUpdateRequest carries the product details that may be edited in
+// place. Stock is rejected so that every change reaches the stock ledger
+// through the adjustment endpoint.
+type productUpdateRequest struct {
+	Name        string                 `json:"name" binding:"required"`
+	Description string                 `json:"description"`
+	Price       domain.Money           `json:"price" binding:"required"`
+	Stock       *int                   `json:"stock"`
+	Options     []domain.ProductOption `json:"options"`
+	Variants    []variantRequest       `json:"variants" binding:"dive"`
+}
+
+func (r variantRequest) variant() domain.Variant {
+	variant := domain.Variant{SKU: r.SKU, Options: r.Options, Price: r.Price}
+	if r.Stock != nil {
+		variant.Stock = *r.Stock
+	}
+	return variant
+}
+
+// productListRequest binds the product list filters. Currency quotes the
+// listed prices; PriceCurrency filters by the currency products are priced in.
+type productListRequest struct {
+	listRequest
+	Currency      string `form:"currency"`
+	PriceCurrency string `form:"price_currency"`
+	MinPrice      string `form:"min_price"`
+	MaxPrice      string `form:"max_price"`
+	InStock       bool   `form:"in_stock"`
+}
+
+type productSearchRequest struct {
+	Query string `form:"q"`
+	Limit int    `form:"limit"`
 }
 
 func (h *ProductHandler) createProduct(c *gin.Context) {
@@ -42,12 +90,18 @@ func (h *ProductHandler) createProduct(c *gin.Context) {
 		return
 	}
 
-	product, err := h.service.CreateProduct(c.Request.Context(), domain.Product{
+	product := domain.Product{
 		Name:        req.Name,
 		Description: req.Description,
 		Price:       req.Price,
 		Stock:       req.Stock,
-	})
+		Options:     req.Options,
+	}
+	for _, variant := range req.Variants {
+		product.Variants = append(product.Variants, variant.variant())
+	}
+
+	product, err := h.service.CreateProduct(c.Request.Context(), product)
 	if err != nil {
 		respondError(c, err)
 		return
@@ -57,7 +111,30 @@ func (h *ProductHandler) createProduct(c *gin.Context) {
 }
 
 func (h *ProductHandler) listProducts(c *gin.Context) {
-	products, err := h.service.ListProducts(c.Request.Context())
+	var req productListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input := service.ListProductsInput{
+		ListInput:     req.input(),
+		PriceCurrency: req.PriceCurrency,
+		MinPrice:      req.MinPrice,
+		MaxPrice:      req.MaxPrice,
+		InStock:       req.InStock,
+	}
+
+	if req.Currency != "" {
+		quoted, err := h.service.QuoteProducts(c.Request.Context(), req.Currency, input)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, quoted)
+		return
+	}
+
+	products, err := h.service.ListProducts(c.Request.Context(), input)
 	if err != nil {
 		respondError(c, err)
 		return
@@ -66,7 +143,33 @@ func (h *ProductHandler) listProducts(c *gin.Context) {
 	c.JSON(http.StatusOK, products)
 }
 
+func (h *ProductHandler) searchProducts(c *gin.Context) {
+	var req productSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := h.service.SearchProducts(c.Request.Context(), req.Query, req.Limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
 func (h *ProductHandler) getProduct(c *gin.Context) {
+	if currency := c.Query("currency"); currency != "" {
+		quoted, err := h.service.QuoteProduct(c.Request.Context(), c.Param("id"), currency)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, quoted)
+		return
+	}
+
 	product, err := h.service.GetProduct(c.Request.Context(), c.Param("id"))
 	if err != nil {
 		respondError(c, err)
@@ -77,18 +180,28 @@ func (h *ProductHandler) getProduct(c *gin.Context) {
 }
 
 func (h *ProductHandler) updateProduct(c *gin.Context) {
-	var req productRequest
+	var req productUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	product, err := h.service.UpdateProduct(c.Request.Context(), c.Param("id"), domain.Product{
+	product := domain.Product{
 		Name:        req.Name,
 		Description: req.Description,
 		Price:       req.Price,
-		Stock:       req.Stock,
-	})
+		Options:     req.Options,
+	}
+	stockSet := req.Stock != nil
+	for _, variant := range req.Variants {
+		stockSet = stockSet || variant.Stock != nil
+		product.Variants = append(product.Variants, variant.variant())
+	}
+	if stockSet {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "stock cannot be set directly; use POST /api/v1/products/:id/stock-adjustments"})
+		return
+	}
+
+	product, err := h.service.UpdateProduct(c.Request.Context(), c.Param("id"), product)
 	if err != nil {
 		respondError(c, err)
 		return
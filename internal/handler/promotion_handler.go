@@ -0,0 +1,136 @@
+package handler
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/service"
+)
+
+// PromotionHandler exposes promotion and coupon management endpoints.
+type PromotionHandler struct {
+    service *service.PromotionService
+}
+
+// NewPromotionHandler constructs a new PromotionHandler.
+func NewPromotionHandler(service *service.PromotionService) *PromotionHandler {
+    return &PromotionHandler{service: service}
+}
+
+// RegisterRoutes registers promotion routes on the provided router group.
+func (h *PromotionHandler) RegisterRoutes(rg *gin.RouterGroup) {
+    manage := requirePermission(domain.PermissionPromotionsManage)
+    rg.GET("/promotions", manage, h.listPromotions)
+    rg.POST("/promotions", manage, h.createPromotion)
+    rg.GET("/promotions/:id", manage, h.getPromotion)
+    rg.PUT("/promotions/:id", manage, h.updatePromotion)
+    rg.DELETE("/promotions/:id", manage, h.deletePromotion)
+}
+
+type promotionRequest struct {
+    Code          string        `json:"code"`
+    Name          string        `json:"name" binding:"required"`
+    Description   string        `json:"description"`
+    Type          string        `json:"type" binding:"required"`
+    PercentOff    int           `json:"percent_off"`
+    AmountOff     *domain.Money `json:"amount_off"`
+    BuyQuantity   int           `json:"buy_quantity"`
+    GetQuantity   int           `json:"get_quantity"`
+    MinOrderValue *domain.Money `json:"min_order_value"`
+    ProductIDs    []string      `json:"product_ids"`
+    CategoryIDs   []string      `json:"category_ids"`
+    UsageLimit    int           `json:"usage_limit"`
+    PerUserLimit  int           `json:"per_user_limit"`
+    StartsAt      *time.Time    `json:"starts_at"`
+    EndsAt        *time.Time    `json:"ends_at"`
+    Stackable     bool          `json:"stackable"`
+    // Active defaults to true.
+    Active *bool `json:"active"`
+}
+
+func (r promotionRequest) promotion() domain.Promotion {
+    active := r.Active == nil || *r.Active
+    return domain.Promotion{
+        Code:          r.Code,
+        Name:          r.Name,
+        Description:   r.Description,
+        Type:          domain.PromotionType(r.Type),
+        PercentOff:    r.PercentOff,
+        AmountOff:     r.AmountOff,
+        BuyQuantity:   r.BuyQuantity,
+        GetQuantity:   r.GetQuantity,
+        MinOrderValue: r.MinOrderValue,
+        ProductIDs:    r.ProductIDs,
+        CategoryIDs:   r.CategoryIDs,
+        UsageLimit:    r.UsageLimit,
+        PerUserLimit:  r.PerUserLimit,
+        StartsAt:      r.StartsAt,
+        EndsAt:        r.EndsAt,
+        Stackable:     r.Stackable,
+        Active:        active,
+    }
+}
+
+func (h *PromotionHandler) createPromotion(c *gin.Context) {
+    var req promotionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    promotion, err := h.service.CreatePromotion(c.Request.Context(), req.promotion())
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusCreated, promotion)
+}
+
+func (h *PromotionHandler) listPromotions(c *gin.Context) {
+    promotions, err := h.service.ListPromotions(c.Request.Context())
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, promotions)
+}
+
+func (h *PromotionHandler) getPromotion(c *gin.Context) {
+    promotion, err := h.service.GetPromotion(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, promotion)
+}
+
+func (h *PromotionHandler) updatePromotion(c *gin.Context) {
+    var req promotionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    promotion, err := h.service.UpdatePromotion(c.Request.Context(), c.Param("id"), req.promotion())
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, promotion)
+}
+
+func (h *PromotionHandler) deletePromotion(c *gin.Context) {
+    if err := h.service.DeletePromotion(c.Request.Context(), c.Param("id")); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
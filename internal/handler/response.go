@@ -14,10 +14,17 @@ import (
 func respondError(c *gin.Context, err error) {
     switch {
     case errors.Is(err, service.ErrValidation):
-        c.JSON(http.StatusBadRequest, gin.H{"error": sanitizeValidationMessage(err)})
+        c.JSON(http.StatusBadRequest, gin.H{"error": trimSentinel(err, service.ErrValidation)})
     case errors.Is(err, repository.ErrNotFound):
         c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
     case errors.Is(err, repository.ErrConflict):
+        c.JSON(http.StatusConflict, gin.H{"error": trimSentinel(err, repository.ErrConflict)})
+    case errors.Is(err, service.ErrUnauthenticated):
+        c.Header("WWW-Authenticate", "Bearer, ApiKey")
+        c.JSON(http.StatusUnauthorized, gin.H{"error": trimSentinel(err, service.ErrUnauthenticated)})
+    case errors.Is(err, service.ErrForbidden):
+        c.JSON(http.StatusForbidden, gin.H{"error": trimSentinel(err, service.ErrForbidden)})
+    case errors.Is(err, service.ErrInvalidTransition):
         c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
     default:
         c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
@@ -25,9 +32,11 @@ func respondError(c *gin.Context, err error) {
     }
 }
 
-func sanitizeValidationMessage(err error) string {
+// trimSentinel drops the sentinel's text from the front of a wrapped error so
+// that the detail added when wrapping is what the client sees.
+func trimSentinel(err, sentinel error) string {
     msg := err.Error()
-    prefix := service.ErrValidation.Error() + ": "
+    prefix := sentinel.Error() + ": "
     if strings.HasPrefix(msg, prefix) {
         return strings.TrimPrefix(msg, prefix)
     }
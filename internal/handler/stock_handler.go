@@ -0,0 +1,81 @@
+package handler
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/service"
+)
+
+// StockHandler exposes the stock ledger and manual stock adjustments.
+type StockHandler struct {
+    service *service.StockService
+}
+
+// NewStockHandler constructs a StockHandler instance.
+func NewStockHandler(service *service.StockService) *StockHandler {
+    return &StockHandler{service: service}
+}
+
+// RegisterRoutes registers stock routes on the provided router group.
+func (h *StockHandler) RegisterRoutes(rg *gin.RouterGroup) {
+    rg.GET("/products/:id/stock-movements", requirePermission(domain.PermissionInventoryRead), h.listStockMovements)
+    rg.POST("/products/:id/stock-adjustments", requirePermission(domain.PermissionInventoryWrite), h.adjustStock)
+    rg.GET("/products/:id/stock-reconciliation", requirePermission(domain.PermissionInventoryRead), h.reconcileStock)
+}
+
+type stockAdjustmentRequest struct {
+    SKU         string `json:"sku"`
+    WarehouseID string `json:"warehouse_id"`
+    Delta       *int   `json:"delta"`
+    Stock       *int   `json:"stock" binding:"omitempty,gte=0"`
+    Reason      string `json:"reason"`
+    ReferenceID string `json:"reference_id"`
+}
+
+func (h *StockHandler) adjustStock(c *gin.Context) {
+    var req stockAdjustmentRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    entry, err := h.service.AdjustStock(c.Request.Context(), service.StockAdjustmentInput{
+        ProductID:   c.Param("id"),
+        SKU:         req.SKU,
+        WarehouseID: req.WarehouseID,
+        Delta:       req.Delta,
+        Stock:       req.Stock,
+        Reason:      domain.StockMovementReason(req.Reason),
+        ReferenceID: req.ReferenceID,
+        Actor:       currentActor(c),
+    })
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusCreated, entry)
+}
+
+func (h *StockHandler) listStockMovements(c *gin.Context) {
+    entries, err := h.service.ListStockMovements(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, entries)
+}
+
+func (h *StockHandler) reconcileStock(c *gin.Context) {
+    reconciliation, err := h.service.ReconcileStock(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, reconciliation)
+}
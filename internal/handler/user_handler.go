@@ -1,6 +1,7 @@
 package handler
 
 import (
+    "fmt"
     "net/http"
 
     "github.com/gin-gonic/gin"
@@ -21,16 +22,72 @@ func NewUserHandler(service *service.UserService) *UserHandler {
 
 // RegisterRoutes registers user routes on the provided router group.
 func (h *UserHandler) RegisterRoutes(rg *gin.RouterGroup) {
-    rg.GET("/users", h.listUsers)
+    rg.GET("/users", requirePermission(domain.PermissionUsersRead), h.listUsers)
     rg.POST("/users", h.createUser)
-    rg.GET("/users/:id", h.getUser)
+    rg.GET("/users/:id", requireAuth, h.getUser)
+    rg.PATCH("/users/:id", requireAuth, h.updateUser)
+    rg.DELETE("/users/:id", requireAuth, h.deleteUser)
+    rg.GET("/users/:id/export", requireAuth, h.exportUser)
+    rg.POST("/users/:id/totp", requireSelf, h.enrollTOTP)
+    rg.POST("/users/:id/totp/confirm", requireSelf, h.confirmTOTP)
+    rg.DELETE("/users/:id/totp", requireSelf, h.disableTOTP)
+    rg.POST("/users/:id/totp/recovery-codes", requireSelf, h.regenerateRecoveryCodes)
+    rg.PUT("/users/:id/role", requirePermission(domain.PermissionUsersManage), h.setRole)
+    rg.POST("/users/:id/email-verification", requireSelf, h.sendEmailVerification)
+    rg.POST("/auth/verify-email", h.verifyEmail)
+    rg.POST("/auth/password-reset", h.requestPasswordReset)
+    rg.POST("/auth/password-reset/confirm", h.resetPassword)
 }
 
 type userRequest struct {
-    Name  string `json:"name" binding:"required"`
+    Name     string `json:"name" binding:"required"`
+    Email    string `json:"email" binding:"required,email"`
+    Password string `json:"password" binding:"required"`
+}
+
+// updateUserRequest carries the profile fields to change; omitted fields
+// are left as they are.
+type updateUserRequest struct {
+    Name  *string `json:"name"`
+    Email *string `json:"email" binding:"omitempty,email"`
+}
+
+type roleRequest struct {
+    Role string `json:"role" binding:"required"`
+}
+
+type totpCodeRequest struct {
+    Code string `json:"code" binding:"required"`
+}
+
+// secondFactorRequest carries either a TOTP code or a recovery code.
+type secondFactorRequest struct {
+    Code         string `json:"code"`
+    RecoveryCode string `json:"recovery_code"`
+}
+
+type recoveryCodesResponse struct {
+    RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type tokenRequest struct {
+    Token string `json:"token" binding:"required"`
+}
+
+type passwordResetRequest struct {
     Email string `json:"email" binding:"required,email"`
 }
 
+type newPasswordRequest struct {
+    Token    string `json:"token" binding:"required"`
+    Password string `json:"password" binding:"required"`
+}
+
+type userListRequest struct {
+    listRequest
+    Email string `form:"email"`
+}
+
 func (h *UserHandler) createUser(c *gin.Context) {
     var req userRequest
     if err := c.ShouldBindJSON(&req); err != nil {
@@ -41,7 +98,7 @@ func (h *UserHandler) createUser(c *gin.Context) {
     user, err := h.service.CreateUser(c.Request.Context(), domain.User{
         Name:  req.Name,
         Email: req.Email,
-    })
+    }, req.Password)
     if err != nil {
         respondError(c, err)
         return
@@ -51,7 +108,16 @@ func (h *UserHandler) createUser(c *gin.Context) {
 }
 
 func (h *UserHandler) listUsers(c *gin.Context) {
-    users, err := h.service.ListUsers(c.Request.Context())
+    var req userListRequest
+    if err := c.ShouldBindQuery(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    users, err := h.service.ListUsers(c.Request.Context(), service.ListUsersInput{
+        ListInput: req.input(),
+        Email:     req.Email,
+    })
     if err != nil {
         respondError(c, err)
         return
@@ -69,3 +135,169 @@ func (h *UserHandler) getUser(c *gin.Context) {
 
     c.JSON(http.StatusOK, user)
 }
+
+func (h *UserHandler) updateUser(c *gin.Context) {
+    var req updateUserRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    user, err := h.service.UpdateUser(c.Request.Context(), c.Param("id"), service.UpdateUserInput{
+        Name:  req.Name,
+        Email: req.Email,
+    })
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) deleteUser(c *gin.Context) {
+    if err := h.service.DeleteUser(c.Request.Context(), c.Param("id")); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) exportUser(c *gin.Context) {
+    export, err := h.service.ExportUser(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%s.json"`, export.Profile.ID))
+    c.JSON(http.StatusOK, export)
+}
+
+func (h *UserHandler) setRole(c *gin.Context) {
+    var req roleRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    user, err := h.service.SetRole(c.Request.Context(), c.Param("id"), domain.Role(req.Role))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) enrollTOTP(c *gin.Context) {
+    enrollment, err := h.service.EnrollTOTP(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, enrollment)
+}
+
+func (h *UserHandler) confirmTOTP(c *gin.Context) {
+    var req totpCodeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    codes, err := h.service.ConfirmTOTP(c.Request.Context(), c.Param("id"), req.Code)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *UserHandler) disableTOTP(c *gin.Context) {
+    var req secondFactorRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := h.service.DisableTOTP(c.Request.Context(), c.Param("id"), req.Code, req.RecoveryCode); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) regenerateRecoveryCodes(c *gin.Context) {
+    var req totpCodeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    codes, err := h.service.RegenerateRecoveryCodes(c.Request.Context(), c.Param("id"), req.Code)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *UserHandler) sendEmailVerification(c *gin.Context) {
+    if err := h.service.SendEmailVerification(c.Request.Context(), c.Param("id")); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusAccepted)
+}
+
+func (h *UserHandler) verifyEmail(c *gin.Context) {
+    var req tokenRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := h.service.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) requestPasswordReset(c *gin.Context) {
+    var req passwordResetRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := h.service.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusAccepted)
+}
+
+func (h *UserHandler) resetPassword(c *gin.Context) {
+    var req newPasswordRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := h.service.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
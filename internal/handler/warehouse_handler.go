@@ -0,0 +1,148 @@
+package handler
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/service"
+)
+
+// WarehouseHandler exposes warehouse and per-warehouse stock endpoints.
+type WarehouseHandler struct {
+    service *service.WarehouseService
+}
+
+// NewWarehouseHandler constructs a WarehouseHandler instance.
+func NewWarehouseHandler(service *service.WarehouseService) *WarehouseHandler {
+    return &WarehouseHandler{service: service}
+}
+
+// RegisterRoutes registers warehouse routes on the provided router group.
+func (h *WarehouseHandler) RegisterRoutes(rg *gin.RouterGroup) {
+    rg.GET("/warehouses", h.listWarehouses)
+    rg.POST("/warehouses", requirePermission(domain.PermissionInventoryWrite), h.createWarehouse)
+    rg.GET("/warehouses/:id", h.getWarehouse)
+    rg.PUT("/warehouses/:id", requirePermission(domain.PermissionInventoryWrite), h.updateWarehouse)
+    rg.GET("/warehouses/:id/stock", requirePermission(domain.PermissionInventoryRead), h.listWarehouseStock)
+    rg.POST("/stock-transfers", requirePermission(domain.PermissionInventoryWrite), h.transferStock)
+    rg.GET("/products/:id/stock", h.getProductStock)
+}
+
+type warehouseRequest struct {
+    Name     string           `json:"name" binding:"required"`
+    Location *domain.GeoPoint `json:"location"`
+    Priority int              `json:"priority"`
+}
+
+type transferRequest struct {
+    ProductID       string `json:"product_id" binding:"required"`
+    SKU             string `json:"sku"`
+    FromWarehouseID string `json:"from_warehouse_id"`
+    ToWarehouseID   string `json:"to_warehouse_id"`
+    Quantity        int    `json:"quantity" binding:"required,gt=0"`
+}
+
+func (h *WarehouseHandler) createWarehouse(c *gin.Context) {
+    var req warehouseRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    warehouse, err := h.service.CreateWarehouse(c.Request.Context(), domain.Warehouse{
+        Name:     req.Name,
+        Location: req.Location,
+        Priority: req.Priority,
+    })
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusCreated, warehouse)
+}
+
+func (h *WarehouseHandler) listWarehouses(c *gin.Context) {
+    warehouses, err := h.service.ListWarehouses(c.Request.Context())
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, warehouses)
+}
+
+func (h *WarehouseHandler) getWarehouse(c *gin.Context) {
+    warehouse, err := h.service.GetWarehouse(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, warehouse)
+}
+
+func (h *WarehouseHandler) updateWarehouse(c *gin.Context) {
+    var req warehouseRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    warehouse, err := h.service.UpdateWarehouse(c.Request.Context(), c.Param("id"), domain.Warehouse{
+        Name:     req.Name,
+        Location: req.Location,
+        Priority: req.Priority,
+    })
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, warehouse)
+}
+
+func (h *WarehouseHandler) listWarehouseStock(c *gin.Context) {
+    levels, err := h.service.ListWarehouseStock(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, levels)
+}
+
+func (h *WarehouseHandler) transferStock(c *gin.Context) {
+    var req transferRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    stock, err := h.service.Transfer(c.Request.Context(), service.TransferInput{
+        ProductID:       req.ProductID,
+        SKU:             req.SKU,
+        FromWarehouseID: req.FromWarehouseID,
+        ToWarehouseID:   req.ToWarehouseID,
+        Quantity:        req.Quantity,
+        Actor:           currentActor(c),
+    })
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, stock)
+}
+
+func (h *WarehouseHandler) getProductStock(c *gin.Context) {
+    stock, err := h.service.GetProductStock(c.Request.Context(), c.Param("id"))
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, stock)
+}
@@ -0,0 +1,46 @@
+package mail
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+)
+
+// FileMailer writes each email as an .eml file into a directory instead of
+// sending it, for local development. The files hold live tokens, so they
+// are only readable by their owner.
+type FileMailer struct {
+    dir  string
+    from string
+}
+
+// NewFileMailer creates a mailer that drops emails into dir, creating it if
+// needed.
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+    if err := os.MkdirAll(dir, 0o700); err != nil {
+        return nil, fmt.Errorf("mail: create drop directory: %w", err)
+    }
+    return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+    now := time.Now()
+    data, err := compose(m.from, msg, now)
+    if err != nil {
+        return err
+    }
+    suffix := make([]byte, 4)
+    if _, err := rand.Read(suffix); err != nil {
+        return fmt.Errorf("mail: generate file name: %w", err)
+    }
+    // Names sort in the order the emails were sent.
+    name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+    if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o600); err != nil {
+        return fmt.Errorf("mail: write %s: %w", name, err)
+    }
+    return nil
+}
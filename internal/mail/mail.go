@@ -0,0 +1,87 @@
+// Package mail delivers the emails the shop sends to its users.
+package mail
+
+import (
+    "bytes"
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "mime"
+    "mime/quotedprintable"
+    netmail "net/mail"
+    "strings"
+    "time"
+)
+
+// Message is a plain-text email to a single recipient.
+type Message struct {
+    To      string
+    Subject string
+    Body    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+    Send(ctx context.Context, msg Message) error
+}
+
+// compose renders msg as an RFC 5322 message from the given sender.
+func compose(from string, msg Message, now time.Time) ([]byte, error) {
+    if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+        return nil, errors.New("mail: header contains a line break")
+    }
+    sender, err := netmail.ParseAddress(from)
+    if err != nil {
+        return nil, fmt.Errorf("mail: parse sender: %w", err)
+    }
+    id := make([]byte, 16)
+    if _, err := rand.Read(id); err != nil {
+        return nil, fmt.Errorf("mail: generate message id: %w", err)
+    }
+    domain := sender.Address[strings.LastIndex(sender.Address, "@")+1:]
+
+    var buf bytes.Buffer
+    fmt.Fprintf(&buf, "From: %s\r\n", sender.String())
+    fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+    fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+    fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+    fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+    buf.WriteString("MIME-Version: 1.0\r\n")
+    buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+
+    body := strings.ReplaceAll(msg.Body, "\n", "\r\n")
+    // Bodies that fit SMTP's limits are sent as they are, which keeps links
+    // in dropped files easy to copy.
+    if is7bit(body) {
+        buf.WriteString("Content-Transfer-Encoding: 7bit\r\n\r\n")
+        buf.WriteString(body)
+        return buf.Bytes(), nil
+    }
+    buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+    w := quotedprintable.NewWriter(&buf)
+    w.Write([]byte(body))
+    if err := w.Close(); err != nil {
+        return nil, fmt.Errorf("mail: encode body: %w", err)
+    }
+    return buf.Bytes(), nil
+}
+
+// maxLineLength is the longest line SMTP allows, excluding the CRLF.
+const maxLineLength = 998
+
+// is7bit reports whether body is ASCII in lines short enough for SMTP.
+func is7bit(body string) bool {
+    for _, line := range strings.Split(body, "\r\n") {
+        if len(line) > maxLineLength {
+            return false
+        }
+        for i := 0; i < len(line); i++ {
+            if line[i] >= 0x80 || line[i] == 0 || line[i] == '\r' {
+                return false
+            }
+        }
+    }
+    return true
+}
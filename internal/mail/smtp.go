@@ -0,0 +1,99 @@
+package mail
+
+import (
+    "context"
+    "crypto/tls"
+    "fmt"
+    "net"
+    netmail "net/mail"
+    "net/smtp"
+    "strconv"
+    "time"
+)
+
+// smtpTimeout bounds a delivery when the context carries no deadline.
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer delivers emails through an SMTP relay. It upgrades the
+// connection with STARTTLS whenever the server offers it, and refuses to
+// send credentials over a connection that was not upgraded.
+type SMTPMailer struct {
+    host string
+    addr string
+    auth smtp.Auth
+    from string
+}
+
+// NewSMTPMailer creates a mailer for the relay at host:port. Mail is sent
+// without authentication when username is empty.
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+    m := &SMTPMailer{
+        host: host,
+        addr: net.JoinHostPort(host, strconv.Itoa(port)),
+        from: from,
+    }
+    if username != "" {
+        m.auth = smtp.PlainAuth("", username, password, host)
+    }
+    return m
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+    data, err := compose(m.from, msg, time.Now())
+    if err != nil {
+        return err
+    }
+    sender, err := netmail.ParseAddress(m.from)
+    if err != nil {
+        return fmt.Errorf("mail: parse sender: %w", err)
+    }
+
+    var dialer net.Dialer
+    conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+    if err != nil {
+        return fmt.Errorf("smtp: dial %s: %w", m.addr, err)
+    }
+    deadline, ok := ctx.Deadline()
+    if !ok {
+        deadline = time.Now().Add(smtpTimeout)
+    }
+    if err := conn.SetDeadline(deadline); err != nil {
+        conn.Close()
+        return fmt.Errorf("smtp: %w", err)
+    }
+
+    client, err := smtp.NewClient(conn, m.host)
+    if err != nil {
+        conn.Close()
+        return fmt.Errorf("smtp: %w", err)
+    }
+    defer client.Close()
+
+    if ok, _ := client.Extension("STARTTLS"); ok {
+        if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+            return fmt.Errorf("smtp: starttls: %w", err)
+        }
+    }
+    if m.auth != nil {
+        if err := client.Auth(m.auth); err != nil {
+            return fmt.Errorf("smtp: auth: %w", err)
+        }
+    }
+    if err := client.Mail(sender.Address); err != nil {
+        return fmt.Errorf("smtp: mail from: %w", err)
+    }
+    if err := client.Rcpt(msg.To); err != nil {
+        return fmt.Errorf("smtp: rcpt to: %w", err)
+    }
+    w, err := client.Data()
+    if err != nil {
+        return fmt.Errorf("smtp: data: %w", err)
+    }
+    if _, err := w.Write(data); err != nil {
+        return fmt.Errorf("smtp: write message: %w", err)
+    }
+    if err := w.Close(); err != nil {
+        return fmt.Errorf("smtp: send message: %w", err)
+    }
+    return client.Quit()
+}
@@ -0,0 +1,61 @@
+// Package payment derives cryptocurrency deposit addresses for invoices.
+package payment
+
+import (
+    "errors"
+    "fmt"
+
+    "github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+// externalChain is the BIP44 "change" level used for receiving addresses.
+const externalChain = 0
+
+// AddressDeriver derives deposit addresses for one coin from an account-level
+// extended public key (m/44'/coin'/account').
+type AddressDeriver interface {
+    // Currency returns the coin code the deriver produces addresses for.
+    Currency() string
+    // DeriveAddress returns the receiving address at index on the external chain.
+    DeriveAddress(index uint32) (Address, error)
+}
+
+// Address is a derived deposit address and its path relative to the account key.
+type Address struct {
+    Address string
+    Path    string
+}
+
+// parseAccountKey parses an extended public key and returns its external
+// (receiving) chain so that individual addresses need only one more step.
+func parseAccountKey(xpub string) (*hdkeychain.ExtendedKey, *hdkeychain.ExtendedKey, error) {
+    account, err := hdkeychain.NewKeyFromString(xpub)
+    if err != nil {
+        return nil, nil, fmt.Errorf("parse extended public key: %w", err)
+    }
+    if account.IsPrivate() {
+        return nil, nil, errors.New("refusing to load a private extended key; configure the account xpub instead")
+    }
+
+    chain, err := account.Derive(externalChain)
+    if err != nil {
+        return nil, nil, fmt.Errorf("derive external chain: %w", err)
+    }
+    return account, chain, nil
+}
+
+// deriveChild derives a non-hardened child of the external chain.
+func deriveChild(chain *hdkeychain.ExtendedKey, index uint32) (*hdkeychain.ExtendedKey, error) {
+    if index >= hdkeychain.HardenedKeyStart {
+        return nil, fmt.Errorf("address index %d out of range", index)
+    }
+    child, err := chain.Derive(index)
+    if err != nil {
+        return nil, fmt.Errorf("derive address %d: %w", index, err)
+    }
+    return child, nil
+}
+
+func relativePath(index uint32) string {
+    return fmt.Sprintf("%d/%d", externalChain, index)
+}
@@ -0,0 +1,87 @@
+package payment
+
+import (
+    "encoding/hex"
+    "strings"
+    "testing"
+
+    "github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+// Account keys of the BIP39 test mnemonic "abandon abandon ... about" without
+// a passphrase, at m/44'/0'/0' and m/44'/60'/0'.
+const (
+    bip44BitcoinXPub  = "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj"
+    bip44EthereumXPub = "xpub6DCoCpSuQZB2jawqnGMEPS63ePKWkwWPH4TU45Q7LPXWuNd8TMtVxRrgjtEshuqpK3mdhaWHPFsBngh5GFZaM6si3yZdUsT8ddYM3PwnATt"
+)
+
+func TestDeriveAddress(t *testing.T) {
+    btc, err := NewBitcoinDeriver(bip44BitcoinXPub)
+    if err != nil {
+        t.Fatal(err)
+    }
+    eth, err := NewEthereumDeriver(bip44EthereumXPub)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        deriver AddressDeriver
+        index   uint32
+        want    Address
+    }{
+        {btc, 0, Address{Address: "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", Path: "0/0"}},
+        {btc, 1, Address{Address: "1Ak8PffB2meyfYnbXZR9EGfLfFZVpzJvQP", Path: "0/1"}},
+        {eth, 0, Address{Address: "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", Path: "0/0"}},
+    }
+    for _, tt := range tests {
+        got, err := tt.deriver.DeriveAddress(tt.index)
+        if err != nil {
+            t.Errorf("%s %d: %v", tt.deriver.Currency(), tt.index, err)
+            continue
+        }
+        if got != tt.want {
+            t.Errorf("%s %d: got %+v, want %+v", tt.deriver.Currency(), tt.index, got, tt.want)
+        }
+    }
+
+    if _, err := btc.DeriveAddress(hdkeychain.HardenedKeyStart); err == nil {
+        t.Error("derived an address at a hardened index")
+    }
+}
+
+func TestDeriverRefusesPrivateKeys(t *testing.T) {
+    // BIP32 test vector 1, chain m.
+    xprv := "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+    if _, err := NewBitcoinDeriver(xprv); err == nil || !strings.Contains(err.Error(), "private") {
+        t.Errorf("NewBitcoinDeriver(xprv) error = %v, want a private key refused", err)
+    }
+    if _, err := NewEthereumDeriver(xprv); err == nil || !strings.Contains(err.Error(), "private") {
+        t.Errorf("NewEthereumDeriver(xprv) error = %v, want a private key refused", err)
+    }
+    if _, err := NewBitcoinDeriver("xpub-not-a-key"); err == nil {
+        t.Error("NewBitcoinDeriver accepted a malformed key")
+    }
+}
+
+func TestChecksumAddress(t *testing.T) {
+    // The examples of EIP-55.
+    for _, want := range []string{
+        "0x52908400098527886E0F7030069857D2E4169EE7",
+        "0x8617E340B3D01FA5F11F306F4090FD50E238070D",
+        "0xde709f2102306220921060314715629080e2fb77",
+        "0x27b1fdb04752bbc536007a920d24acb045561c26",
+        "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+        "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+        "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+        "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+    } {
+        addr, err := hex.DecodeString(strings.ToLower(want[2:]))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := checksumAddress(addr); got != want {
+            t.Errorf("checksumAddress(%x) = %s, want %s", addr, got, want)
+        }
+    }
+}
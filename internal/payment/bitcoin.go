@@ -0,0 +1,43 @@
+package payment
+
+import (
+    "github.com/btcsuite/btcd/btcutil/hdkeychain"
+    "github.com/btcsuite/btcd/chaincfg"
+)
+
+// BitcoinDeriver derives legacy pay-to-pubkey-hash addresses following BIP44.
+// The network (mainnet or testnet) is taken from the key's version bytes.
+type BitcoinDeriver struct {
+    chain *hdkeychain.ExtendedKey
+    net   *chaincfg.Params
+}
+
+// NewBitcoinDeriver constructs a deriver from a BIP44 account xpub or tpub.
+func NewBitcoinDeriver(xpub string) (*BitcoinDeriver, error) {
+    account, chain, err := parseAccountKey(xpub)
+    if err != nil {
+        return nil, err
+    }
+
+    net := &chaincfg.MainNetParams
+    if account.IsForNet(&chaincfg.TestNet3Params) {
+        net = &chaincfg.TestNet3Params
+    }
+    return &BitcoinDeriver{chain: chain, net: net}, nil
+}
+
+func (d *BitcoinDeriver) Currency() string {
+    return "BTC"
+}
+
+func (d *BitcoinDeriver) DeriveAddress(index uint32) (Address, error) {
+    child, err := deriveChild(d.chain, index)
+    if err != nil {
+        return Address{}, err
+    }
+    addr, err := child.Address(d.net)
+    if err != nil {
+        return Address{}, err
+    }
+    return Address{Address: addr.EncodeAddress(), Path: relativePath(index)}, nil
+}
@@ -0,0 +1,312 @@
+package payment
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "math/big"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/btcsuite/btcd/btcec/v2"
+    "github.com/btcsuite/btcd/btcec/v2/ecdsa"
+    "github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+// BOLT11 tagged field types, as 5-bit values.
+const (
+    bolt11FieldPaymentHash   = 1
+    bolt11FieldExpiry        = 6
+    bolt11FieldDescription   = 13
+    bolt11FieldPaymentSecret = 16
+    bolt11FieldPayee         = 19
+)
+
+const (
+    // bolt11DefaultExpiry applies when an invoice carries no x field.
+    bolt11DefaultExpiry = time.Hour
+    // bolt11SignatureWords is the 65-byte recoverable signature in 5-bit words.
+    bolt11SignatureWords = 104
+    bolt11TimestampWords = 7
+)
+
+// BOLT11Invoice is a decoded and signature-checked Lightning payment request.
+type BOLT11Invoice struct {
+    // Network is the currency prefix: "bc" (mainnet), "tb" (testnet),
+    // "bcrt" (regtest) or "tbs" (signet).
+    Network string
+    // AmountMsat is zero for invoices that leave the amount to the payer.
+    AmountMsat  int64
+    Timestamp   time.Time
+    Expiry      time.Duration
+    PaymentHash [32]byte
+    // PaymentSecret is zero when the invoice does not carry one.
+    PaymentSecret [32]byte
+    Description   string
+    // Payee is the node public key that signed the invoice.
+    Payee *btcec.PublicKey
+}
+
+// ExpiresAt returns when the invoice stops being payable.
+func (inv BOLT11Invoice) ExpiresAt() time.Time {
+    return inv.Timestamp.Add(inv.Expiry)
+}
+
+// Validate checks that the invoice asks for exactly amountMsat and is still
+// payable at now.
+func (inv BOLT11Invoice) Validate(amountMsat int64, now time.Time) error {
+    if inv.AmountMsat != amountMsat {
+        return fmt.Errorf("bolt11: invoice amount %d msat, expected %d msat", inv.AmountMsat, amountMsat)
+    }
+    if !now.Before(inv.ExpiresAt()) {
+        return fmt.Errorf("bolt11: invoice expired at %s", inv.ExpiresAt().Format(time.RFC3339))
+    }
+    return nil
+}
+
+// PaymentHashHex returns the payment hash as lowercase hex.
+func (inv BOLT11Invoice) PaymentHashHex() string {
+    return hex.EncodeToString(inv.PaymentHash[:])
+}
+
+// DecodeBOLT11 parses a BOLT11 payment request and verifies its signature.
+func DecodeBOLT11(s string) (BOLT11Invoice, error) {
+    hrp, words, err := bech32.DecodeNoLimit(strings.ToLower(strings.TrimSpace(s)))
+    if err != nil {
+        return BOLT11Invoice{}, fmt.Errorf("bolt11: %w", err)
+    }
+    if len(words) < bolt11TimestampWords+bolt11SignatureWords {
+        return BOLT11Invoice{}, errors.New("bolt11: payment request too short")
+    }
+
+    var inv BOLT11Invoice
+    if inv.Network, inv.AmountMsat, err = parseBOLT11Prefix(hrp); err != nil {
+        return BOLT11Invoice{}, err
+    }
+
+    data := words[:len(words)-bolt11SignatureWords]
+    sigWords := words[len(words)-bolt11SignatureWords:]
+
+    inv.Timestamp = time.Unix(int64(wordsToUint(data[:bolt11TimestampWords])), 0).UTC()
+    inv.Expiry = bolt11DefaultExpiry
+
+    var hasHash bool
+    var declaredPayee []byte
+    for fields := data[bolt11TimestampWords:]; len(fields) > 0; {
+        if len(fields) < 3 {
+            return BOLT11Invoice{}, errors.New("bolt11: truncated tagged field")
+        }
+        tag := fields[0]
+        length := int(fields[1])<<5 | int(fields[2])
+        if len(fields) < 3+length {
+            return BOLT11Invoice{}, errors.New("bolt11: truncated tagged field")
+        }
+        value := fields[3 : 3+length]
+        fields = fields[3+length:]
+
+        // Fields with an unexpected length must be skipped, not rejected.
+        switch tag {
+        case bolt11FieldPaymentHash:
+            if length == 52 {
+                b, err := bech32.ConvertBits(value, 5, 8, false)
+                if err != nil {
+                    return BOLT11Invoice{}, fmt.Errorf("bolt11: payment hash: %w", err)
+                }
+                copy(inv.PaymentHash[:], b)
+                hasHash = true
+            }
+        case bolt11FieldPaymentSecret:
+            if length == 52 {
+                b, err := bech32.ConvertBits(value, 5, 8, false)
+                if err != nil {
+                    return BOLT11Invoice{}, fmt.Errorf("bolt11: payment secret: %w", err)
+                }
+                copy(inv.PaymentSecret[:], b)
+            }
+        case bolt11FieldDescription:
+            b, err := bech32.ConvertBits(value, 5, 8, false)
+            if err != nil {
+                return BOLT11Invoice{}, fmt.Errorf("bolt11: description: %w", err)
+            }
+            inv.Description = string(b)
+        case bolt11FieldExpiry:
+            inv.Expiry = time.Duration(wordsToUint(value)) * time.Second
+        case bolt11FieldPayee:
+            if length == 53 {
+                if declaredPayee, err = bech32.ConvertBits(value, 5, 8, false); err != nil {
+                    return BOLT11Invoice{}, fmt.Errorf("bolt11: payee: %w", err)
+                }
+            }
+        }
+    }
+    if !hasHash {
+        return BOLT11Invoice{}, errors.New("bolt11: missing payment hash")
+    }
+
+    sig, err := bech32.ConvertBits(sigWords, 5, 8, false)
+    if err != nil || len(sig) != 65 || sig[64] > 3 {
+        return BOLT11Invoice{}, errors.New("bolt11: malformed signature")
+    }
+    digest := bolt11SigHash(hrp, data)
+    compact := append([]byte{27 + 4 + sig[64]}, sig[:64]...)
+    payee, _, err := ecdsa.RecoverCompact(compact, digest)
+    if err != nil {
+        return BOLT11Invoice{}, fmt.Errorf("bolt11: invalid signature: %w", err)
+    }
+    if declaredPayee != nil && hex.EncodeToString(declaredPayee) != hex.EncodeToString(payee.SerializeCompressed()) {
+        return BOLT11Invoice{}, errors.New("bolt11: signature does not match payee")
+    }
+    inv.Payee = payee
+
+    return inv, nil
+}
+
+// EncodeBOLT11 builds and signs a payment request. It is used by node
+// implementations that do not delegate invoice creation to a real node.
+func EncodeBOLT11(inv BOLT11Invoice, key *btcec.PrivateKey) (string, error) {
+    hrp := "ln" + inv.Network
+    if inv.AmountMsat > 0 {
+        hrp += formatBOLT11Amount(inv.AmountMsat)
+    }
+
+    data := uintToWords(uint64(inv.Timestamp.Unix()), bolt11TimestampWords)
+    appendField := func(tag byte, value []byte) error {
+        if len(value) >= 1<<10 {
+            return fmt.Errorf("bolt11: field %d too long", tag)
+        }
+        data = append(data, tag, byte(len(value)>>5), byte(len(value)&31))
+        data = append(data, value...)
+        return nil
+    }
+    toWords := func(b []byte) []byte {
+        words, _ := bech32.ConvertBits(b, 8, 5, true)
+        return words
+    }
+
+    if err := appendField(bolt11FieldPaymentHash, toWords(inv.PaymentHash[:])); err != nil {
+        return "", err
+    }
+    if inv.PaymentSecret != ([32]byte{}) {
+        if err := appendField(bolt11FieldPaymentSecret, toWords(inv.PaymentSecret[:])); err != nil {
+            return "", err
+        }
+    }
+    if err := appendField(bolt11FieldDescription, toWords([]byte(inv.Description))); err != nil {
+        return "", err
+    }
+    if inv.Expiry > 0 && inv.Expiry != bolt11DefaultExpiry {
+        if err := appendField(bolt11FieldExpiry, uintToWords(uint64(inv.Expiry/time.Second), 0)); err != nil {
+            return "", err
+        }
+    }
+
+    compact, err := ecdsa.SignCompact(key, bolt11SigHash(hrp, data), true)
+    if err != nil {
+        return "", fmt.Errorf("bolt11: sign: %w", err)
+    }
+    sig := append(append([]byte{}, compact[1:]...), compact[0]-27-4)
+    data = append(data, toWords(sig)...)
+
+    return bech32.Encode(hrp, data)
+}
+
+// parseBOLT11Prefix splits "ln" + network + optional amount.
+func parseBOLT11Prefix(hrp string) (string, int64, error) {
+    if !strings.HasPrefix(hrp, "ln") {
+        return "", 0, fmt.Errorf("bolt11: unexpected prefix %q", hrp)
+    }
+    rest := hrp[2:]
+
+    var network string
+    for _, candidate := range []string{"bcrt", "tbs", "bc", "tb"} {
+        if strings.HasPrefix(rest, candidate) {
+            network = candidate
+            break
+        }
+    }
+    if network == "" {
+        return "", 0, fmt.Errorf("bolt11: unknown network in %q", hrp)
+    }
+    amount := rest[len(network):]
+    if amount == "" {
+        return network, 0, nil
+    }
+
+    // Amounts are BTC scaled by an optional multiplier; express everything in
+    // pico-BTC first since one msat is ten pico-BTC.
+    multiplier := amount[len(amount)-1]
+    digits := amount
+    picoPerUnit := new(big.Int).SetInt64(1_000_000_000_000)
+    switch multiplier {
+    case 'm':
+        picoPerUnit.SetInt64(1_000_000_000)
+    case 'u':
+        picoPerUnit.SetInt64(1_000_000)
+    case 'n':
+        picoPerUnit.SetInt64(1_000)
+    case 'p':
+        picoPerUnit.SetInt64(1)
+    default:
+        multiplier = 0
+    }
+    if multiplier != 0 {
+        digits = amount[:len(amount)-1]
+    }
+
+    n, err := strconv.ParseUint(digits, 10, 64)
+    if err != nil || n == 0 || (len(digits) > 1 && digits[0] == '0') {
+        return "", 0, fmt.Errorf("bolt11: invalid amount %q", amount)
+    }
+    pico := new(big.Int).Mul(new(big.Int).SetUint64(n), picoPerUnit)
+    msat, rem := new(big.Int).QuoRem(pico, big.NewInt(10), new(big.Int))
+    if rem.Sign() != 0 || !msat.IsInt64() {
+        return "", 0, fmt.Errorf("bolt11: invalid amount %q", amount)
+    }
+    return network, msat.Int64(), nil
+}
+
+// formatBOLT11Amount picks the largest multiplier that represents msat exactly.
+func formatBOLT11Amount(msat int64) string {
+    pico := msat * 10
+    for _, unit := range []struct {
+        suffix string
+        pico   int64
+    }{{"m", 1_000_000_000}, {"u", 1_000_000}, {"n", 1_000}} {
+        if pico%unit.pico == 0 {
+            return strconv.FormatInt(pico/unit.pico, 10) + unit.suffix
+        }
+    }
+    return strconv.FormatInt(pico, 10) + "p"
+}
+
+// bolt11SigHash is the digest signed by the payee: sha256 over the human
+// readable part followed by the data part packed into bytes.
+func bolt11SigHash(hrp string, data []byte) []byte {
+    packed, _ := bech32.ConvertBits(data, 5, 8, true)
+    sum := sha256.Sum256(append([]byte(hrp), packed...))
+    return sum[:]
+}
+
+func wordsToUint(words []byte) uint64 {
+    var n uint64
+    for _, w := range words {
+        n = n<<5 | uint64(w)
+    }
+    return n
+}
+
+// uintToWords encodes n big-endian in 5-bit words, left-padded to at least
+// minWords (or using the fewest words possible when minWords is zero).
+func uintToWords(n uint64, minWords int) []byte {
+    var words []byte
+    for n > 0 {
+        words = append([]byte{byte(n & 31)}, words...)
+        n >>= 5
+    }
+    for len(words) < minWords || len(words) == 0 {
+        words = append([]byte{0}, words...)
+    }
+    return words
+}
@@ -0,0 +1,256 @@
+package payment
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/btcsuite/btcd/btcec/v2"
+    "github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+func TestBOLT11RoundTrip(t *testing.T) {
+    key, err := btcec.NewPrivateKey()
+    if err != nil {
+        t.Fatal(err)
+    }
+    ts := time.Unix(1_700_000_000, 0).UTC()
+    var hash, secret [32]byte
+    copy(hash[:], strings.Repeat("h", 32))
+    copy(secret[:], strings.Repeat("s", 32))
+
+    tests := []struct {
+        name string
+        inv  BOLT11Invoice
+    }{
+        {"mainnet with amount", BOLT11Invoice{Network: "bc", AmountMsat: 250_000_000, Timestamp: ts, Expiry: 15 * time.Minute, PaymentHash: hash, PaymentSecret: secret, Description: "order 42"}},
+        {"any amount", BOLT11Invoice{Network: "bc", Timestamp: ts, Expiry: time.Hour, PaymentHash: hash, Description: "donation"}},
+        {"one msat", BOLT11Invoice{Network: "tb", AmountMsat: 1, Timestamp: ts, Expiry: time.Second, PaymentHash: hash}},
+        {"regtest", BOLT11Invoice{Network: "bcrt", AmountMsat: 123_456_789, Timestamp: ts, Expiry: 24 * time.Hour, PaymentHash: hash, PaymentSecret: secret, Description: "café ☕"}},
+        {"signet", BOLT11Invoice{Network: "tbs", AmountMsat: 100_000_000_000, Timestamp: ts, Expiry: 90 * time.Second, PaymentHash: hash}},
+        {"long description", BOLT11Invoice{Network: "bc", AmountMsat: 1000, Timestamp: ts, Expiry: time.Hour, PaymentHash: hash, Description: strings.Repeat("x", 600)}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            request, err := EncodeBOLT11(tt.inv, key)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !strings.HasPrefix(request, "ln"+tt.inv.Network) {
+                t.Errorf("request %q lacks the ln%s prefix", request, tt.inv.Network)
+            }
+            got, err := DecodeBOLT11(strings.ToUpper(request))
+            if err != nil {
+                t.Fatalf("DecodeBOLT11: %v", err)
+            }
+            if !got.Payee.IsEqual(key.PubKey()) {
+                t.Error("recovered payee is not the signing key")
+            }
+            got.Payee = nil
+            if got != tt.inv {
+                t.Errorf("round trip changed the invoice:\n got  %+v\n want %+v", got, tt.inv)
+            }
+        })
+    }
+}
+
+func TestEncodeBOLT11RejectsLongFields(t *testing.T) {
+    key, err := btcec.NewPrivateKey()
+    if err != nil {
+        t.Fatal(err)
+    }
+    inv := BOLT11Invoice{Network: "bc", Timestamp: time.Now(), Description: strings.Repeat("x", 640)}
+    if _, err := EncodeBOLT11(inv, key); err == nil {
+        t.Error("EncodeBOLT11 accepted a description longer than a tagged field holds")
+    }
+}
+
+// The "any amount" example from the BOLT #11 specification, signed by node
+// 03e7156ae33b0a208d0744199163177e909e80176e55d97a2f221ede0f934dd9ad.
+const bolt11SpecExample = "lnbc1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdpl2pkx2ctnv5sxxmmwwd5kgetjypeh2ursdae8g6twvus8g6rfwvs8qun0dfjkxaq8rkx3yf5tcsyz3d73gafnh3cax9rn449d9p5uxz9ezhhypd0elx87sjle52x86fux2ypatgddc6k63n7erqz25le42c4u4ecky03ylcqca784w"
+
+func TestDecodeBOLT11SpecExample(t *testing.T) {
+    inv, err := DecodeBOLT11(bolt11SpecExample)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if inv.Network != "bc" || inv.AmountMsat != 0 {
+        t.Errorf("network %q amount %d, want bc and no amount", inv.Network, inv.AmountMsat)
+    }
+    if inv.Timestamp.Unix() != 1496314658 || inv.Expiry != time.Hour {
+        t.Errorf("timestamp %d expiry %s, want 1496314658 and the 1h default", inv.Timestamp.Unix(), inv.Expiry)
+    }
+    if got, want := inv.PaymentHashHex(), "0001020304050607080900010203040506070809000102030405060708090102"; got != want {
+        t.Errorf("payment hash %s, want %s", got, want)
+    }
+    if want := "Please consider supporting this project"; inv.Description != want {
+        t.Errorf("description %q, want %q", inv.Description, want)
+    }
+    if got, want := hex.EncodeToString(inv.Payee.SerializeCompressed()), "03e7156ae33b0a208d0744199163177e909e80176e55d97a2f221ede0f934dd9ad"; got != want {
+        t.Errorf("payee %s, want %s", got, want)
+    }
+}
+
+func TestDecodeBOLT11Tampered(t *testing.T) {
+    key, err := btcec.NewPrivateKey()
+    if err != nil {
+        t.Fatal(err)
+    }
+    request, err := EncodeBOLT11(BOLT11Invoice{Network: "bc", AmountMsat: 5000, Timestamp: time.Now(), Expiry: time.Hour, Description: "x"}, key)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // A changed character breaks the bech32 checksum.
+    i := len(request) - 10
+    replacement := "q"
+    if request[i] == 'q' {
+        replacement = "p"
+    }
+    flipped := request[:i] + replacement + request[i+1:]
+    if _, err := DecodeBOLT11(flipped); err == nil {
+        t.Error("DecodeBOLT11 accepted a request with a bad checksum")
+    }
+
+    // Changing the data and recomputing the checksum leaves a valid bech32
+    // string, but the signature no longer recovers the payee.
+    hrp, words, err := bech32.DecodeNoLimit(request)
+    if err != nil {
+        t.Fatal(err)
+    }
+    words[bolt11TimestampWords-1] ^= 1
+    forged, err := bech32.Encode(hrp, words)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if inv, err := DecodeBOLT11(forged); err == nil && inv.Payee.IsEqual(key.PubKey()) {
+        t.Error("a modified invoice still recovers the original payee")
+    }
+
+    for _, bad := range []string{"", "lnbc1qqqq", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"} {
+        if _, err := DecodeBOLT11(bad); err == nil {
+            t.Errorf("DecodeBOLT11(%q) succeeded", bad)
+        }
+    }
+}
+
+func TestBOLT11Amounts(t *testing.T) {
+    tests := []struct {
+        msat int64
+        hrp  string
+    }{
+        {100_000_000, "1m"},
+        {250_000_000, "2500u"},
+        {100_000, "1u"},
+        {2_500, "25n"},
+        {100, "1n"},
+        {1, "10p"},
+        {123_456_789, "1234567890p"},
+    }
+    for _, tt := range tests {
+        if got := formatBOLT11Amount(tt.msat); got != tt.hrp {
+            t.Errorf("formatBOLT11Amount(%d) = %q, want %q", tt.msat, got, tt.hrp)
+        }
+        network, msat, err := parseBOLT11Prefix("lnbc" + tt.hrp)
+        if err != nil || network != "bc" || msat != tt.msat {
+            t.Errorf("parseBOLT11Prefix(lnbc%s) = %q, %d, %v; want bc, %d", tt.hrp, network, msat, err, tt.msat)
+        }
+    }
+
+    prefixes := []struct {
+        hrp     string
+        network string
+        msat    int64
+        wantErr bool
+    }{
+        {"lnbc", "bc", 0, false},
+        {"lntb", "tb", 0, false},
+        {"lnbcrt", "bcrt", 0, false},
+        {"lntbs", "tbs", 0, false},
+        {"lnbc2", "bc", 200_000_000_000, false},
+        {"lntbs20m", "tbs", 2_000_000_000, false},
+        {"lnbc1p", "", 0, true},
+        {"lnbc01m", "", 0, true},
+        {"lnbc0", "", 0, true},
+        {"lnbcm", "", 0, true},
+        {"lnbc1x", "", 0, true},
+        {"lnxy1m", "", 0, true},
+        {"bc1m", "", 0, true},
+    }
+    for _, tt := range prefixes {
+        network, msat, err := parseBOLT11Prefix(tt.hrp)
+        if tt.wantErr {
+            if err == nil {
+                t.Errorf("parseBOLT11Prefix(%q) = %q, %d; want an error", tt.hrp, network, msat)
+            }
+            continue
+        }
+        if err != nil || network != tt.network || msat != tt.msat {
+            t.Errorf("parseBOLT11Prefix(%q) = %q, %d, %v; want %q, %d", tt.hrp, network, msat, err, tt.network, tt.msat)
+        }
+    }
+}
+
+func TestBOLT11Validate(t *testing.T) {
+    issued := time.Unix(1_700_000_000, 0)
+    inv := BOLT11Invoice{AmountMsat: 1000, Timestamp: issued, Expiry: time.Minute}
+    tests := []struct {
+        name    string
+        amount  int64
+        now     time.Time
+        wantErr bool
+    }{
+        {"payable", 1000, issued.Add(59 * time.Second), false},
+        {"wrong amount", 999, issued, true},
+        {"expired", 1000, issued.Add(time.Minute), true},
+    }
+    for _, tt := range tests {
+        if err := inv.Validate(tt.amount, tt.now); (err != nil) != tt.wantErr {
+            t.Errorf("%s: Validate = %v, want error %t", tt.name, err, tt.wantErr)
+        }
+    }
+}
+
+func TestMockLightningNodePay(t *testing.T) {
+    ctx := context.Background()
+    node, err := NewMockLightningNode()
+    if err != nil {
+        t.Fatal(err)
+    }
+    request, err := node.CreateInvoice(ctx, 21_000, "order 1", 10*time.Minute)
+    if err != nil {
+        t.Fatal(err)
+    }
+    inv, err := DecodeBOLT11(request)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := inv.Validate(21_000, time.Now()); err != nil {
+        t.Fatal(err)
+    }
+
+    preimage, err := node.Pay(ctx, request)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if sha256.Sum256(preimage) != inv.PaymentHash {
+        t.Error("the preimage does not hash to the payment hash")
+    }
+    if _, err := node.Pay(ctx, request); err == nil {
+        t.Error("an invoice was paid twice")
+    }
+
+    other, err := NewMockLightningNode()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := other.Pay(ctx, request); err == nil {
+        t.Error("a node paid an invoice it did not issue")
+    }
+    if _, err := node.CreateInvoice(ctx, 0, "", time.Minute); err == nil {
+        t.Error("CreateInvoice accepted a zero amount")
+    }
+}
@@ -0,0 +1,27 @@
+package payment
+
+import (
+    "context"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+// Transaction is an on-chain transfer into a watched address.
+type Transaction struct {
+    Hash string
+    // Amount is the value paid to the watched address by this transaction.
+    Amount        domain.Money
+    Confirmations int
+    // SeenAt is when the transaction was first observed: its block time once
+    // confirmed, otherwise the time the client first saw it.
+    SeenAt time.Time
+}
+
+// ChainClient reads incoming transactions for one coin.
+type ChainClient interface {
+    // Currency returns the coin code the client watches.
+    Currency() string
+    // TransactionsTo lists transactions paying address with their current confirmation counts.
+    TransactionsTo(ctx context.Context, address string) ([]Transaction, error)
+}
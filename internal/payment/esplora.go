@@ -0,0 +1,118 @@
+package payment
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+// EsploraClient is a Bitcoin ChainClient backed by an Esplora HTTP API such
+// as https://blockstream.info/api.
+type EsploraClient struct {
+    baseURL string
+    http    *http.Client
+}
+
+// NewEsploraClient constructs a client for the Esplora instance at baseURL.
+func NewEsploraClient(baseURL string) *EsploraClient {
+    return &EsploraClient{
+        baseURL: strings.TrimRight(baseURL, "/"),
+        http:    &http.Client{Timeout: 15 * time.Second},
+    }
+}
+
+func (c *EsploraClient) Currency() string {
+    return "BTC"
+}
+
+type esploraTx struct {
+    TxID   string `json:"txid"`
+    Status struct {
+        Confirmed   bool  `json:"confirmed"`
+        BlockHeight int   `json:"block_height"`
+        BlockTime   int64 `json:"block_time"`
+    } `json:"status"`
+    Vout []struct {
+        Address string `json:"scriptpubkey_address"`
+        Value   int64  `json:"value"`
+    } `json:"vout"`
+}
+
+func (c *EsploraClient) TransactionsTo(ctx context.Context, address string) ([]Transaction, error) {
+    var txs []esploraTx
+    if err := c.get(ctx, "/address/"+address+"/txs", &txs); err != nil {
+        return nil, err
+    }
+    if len(txs) == 0 {
+        return nil, nil
+    }
+
+    var tipBody string
+    if err := c.get(ctx, "/blocks/tip/height", &tipBody); err != nil {
+        return nil, err
+    }
+    tip, err := strconv.Atoi(strings.TrimSpace(tipBody))
+    if err != nil {
+        return nil, fmt.Errorf("esplora: parse tip height: %w", err)
+    }
+
+    now := time.Now().UTC()
+    out := make([]Transaction, 0, len(txs))
+    for _, tx := range txs {
+        var sats int64
+        for _, vout := range tx.Vout {
+            if vout.Address == address {
+                sats += vout.Value
+            }
+        }
+        if sats == 0 {
+            // Outgoing or unrelated transaction touching the address.
+            continue
+        }
+
+        t := Transaction{Hash: tx.TxID, Amount: domain.NewMoney(sats, "BTC"), SeenAt: now}
+        if tx.Status.Confirmed {
+            t.Confirmations = tip - tx.Status.BlockHeight + 1
+            t.SeenAt = time.Unix(tx.Status.BlockTime, 0).UTC()
+        }
+        out = append(out, t)
+    }
+    return out, nil
+}
+
+// get fetches path and decodes JSON into out, or stores the raw body when out is a *string.
+func (c *EsploraClient) get(ctx context.Context, path string, out any) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+    if err != nil {
+        return err
+    }
+    resp, err := c.http.Do(req)
+    if err != nil {
+        return fmt.Errorf("esplora %s: %w", path, err)
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+    if err != nil {
+        return fmt.Errorf("esplora %s: %w", path, err)
+    }
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("esplora %s: unexpected status %s", path, resp.Status)
+    }
+
+    if s, ok := out.(*string); ok {
+        *s = string(body)
+        return nil
+    }
+    if err := json.Unmarshal(body, out); err != nil {
+        return fmt.Errorf("esplora %s: decode response: %w", path, err)
+    }
+    return nil
+}
@@ -0,0 +1,69 @@
+package payment
+
+import (
+    "encoding/hex"
+    "strings"
+
+    "github.com/btcsuite/btcd/btcutil/hdkeychain"
+    "golang.org/x/crypto/sha3"
+)
+
+// EthereumDeriver derives EIP-55 checksummed Ethereum addresses following BIP44.
+type EthereumDeriver struct {
+    chain *hdkeychain.ExtendedKey
+}
+
+// NewEthereumDeriver constructs a deriver from a BIP44 account xpub (m/44'/60'/0').
+func NewEthereumDeriver(xpub string) (*EthereumDeriver, error) {
+    _, chain, err := parseAccountKey(xpub)
+    if err != nil {
+        return nil, err
+    }
+    return &EthereumDeriver{chain: chain}, nil
+}
+
+func (d *EthereumDeriver) Currency() string {
+    return "ETH"
+}
+
+func (d *EthereumDeriver) DeriveAddress(index uint32) (Address, error) {
+    child, err := deriveChild(d.chain, index)
+    if err != nil {
+        return Address{}, err
+    }
+    pub, err := child.ECPubKey()
+    if err != nil {
+        return Address{}, err
+    }
+
+    // The address is the last 20 bytes of keccak256 over the uncompressed
+    // public key without its 0x04 prefix.
+    hash := keccak256(pub.SerializeUncompressed()[1:])
+    return Address{Address: checksumAddress(hash[12:]), Path: relativePath(index)}, nil
+}
+
+// checksumAddress applies EIP-55 mixed-case checksum encoding.
+func checksumAddress(addr []byte) string {
+    lower := hex.EncodeToString(addr)
+    hash := keccak256([]byte(lower))
+
+    var b strings.Builder
+    b.WriteString("0x")
+    for i, c := range lower {
+        nibble := hash[i/2]
+        if i%2 == 0 {
+            nibble >>= 4
+        }
+        if c >= 'a' && nibble&0x0f >= 8 {
+            c -= 'a' - 'A'
+        }
+        b.WriteRune(c)
+    }
+    return b.String()
+}
+
+func keccak256(data []byte) []byte {
+    h := sha3.NewLegacyKeccak256()
+    h.Write(data)
+    return h.Sum(nil)
+}
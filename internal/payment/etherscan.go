@@ -0,0 +1,169 @@
+package payment
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "math/big"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+// weiPerGwei converts the wei amounts explorers report into the gwei ETH is
+// tracked in.
+var weiPerGwei = big.NewInt(1_000_000_000)
+
+// EtherscanClient is an Ethereum ChainClient backed by an Etherscan-compatible
+// account API, such as https://api.etherscan.io/v2/api?chainid=1. It sees
+// plain ETH transfers mined into a block; ETH sent to the address by a
+// contract as an internal transaction is not reported.
+type EtherscanClient struct {
+    endpoint *url.URL
+    apiKey   string
+    http     *http.Client
+}
+
+// NewEtherscanClient constructs a client for the API at endpoint. Query
+// parameters already in endpoint, such as a chain ID, are kept on every
+// request.
+func NewEtherscanClient(endpoint, apiKey string) (*EtherscanClient, error) {
+    u, err := url.Parse(endpoint)
+    if err != nil {
+        return nil, fmt.Errorf("etherscan: parse endpoint: %w", err)
+    }
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return nil, fmt.Errorf("etherscan: endpoint %q is not an http(s) URL", endpoint)
+    }
+    return &EtherscanClient{endpoint: u, apiKey: apiKey, http: &http.Client{Timeout: 15 * time.Second}}, nil
+}
+
+func (c *EtherscanClient) Currency() string {
+    return "ETH"
+}
+
+type etherscanResponse struct {
+    Status  string          `json:"status"`
+    Message string          `json:"message"`
+    Result  json.RawMessage `json:"result"`
+}
+
+type etherscanTx struct {
+    Hash          string `json:"hash"`
+    To            string `json:"to"`
+    Value         string `json:"value"`
+    TimeStamp     string `json:"timeStamp"`
+    Confirmations string `json:"confirmations"`
+    IsError       string `json:"isError"`
+}
+
+func (c *EtherscanClient) TransactionsTo(ctx context.Context, address string) ([]Transaction, error) {
+    var txs []etherscanTx
+    if err := c.get(ctx, url.Values{
+        "module":  {"account"},
+        "action":  {"txlist"},
+        "address": {address},
+        "sort":    {"asc"},
+    }, &txs); err != nil {
+        return nil, err
+    }
+
+    var out []Transaction
+    for _, tx := range txs {
+        if !strings.EqualFold(tx.To, address) || tx.IsError != "0" {
+            // Outgoing, unrelated or reverted transaction touching the address.
+            continue
+        }
+        wei, ok := new(big.Int).SetString(tx.Value, 10)
+        if !ok {
+            return nil, fmt.Errorf("etherscan: transaction %s has value %q", tx.Hash, tx.Value)
+        }
+        // Amounts below a gwei cannot be represented and do not count.
+        gwei := new(big.Int).Quo(wei, weiPerGwei)
+        if gwei.Sign() == 0 {
+            continue
+        }
+        if !gwei.IsInt64() {
+            return nil, fmt.Errorf("etherscan: transaction %s value %s wei is out of range", tx.Hash, tx.Value)
+        }
+        confirmations, err := strconv.Atoi(tx.Confirmations)
+        if err != nil {
+            return nil, fmt.Errorf("etherscan: transaction %s has confirmations %q", tx.Hash, tx.Confirmations)
+        }
+        minedAt, err := strconv.ParseInt(tx.TimeStamp, 10, 64)
+        if err != nil {
+            return nil, fmt.Errorf("etherscan: transaction %s has timestamp %q", tx.Hash, tx.TimeStamp)
+        }
+        out = append(out, Transaction{
+            Hash:          tx.Hash,
+            Amount:        domain.NewMoney(gwei.Int64(), "ETH"),
+            Confirmations: confirmations,
+            SeenAt:        time.Unix(minedAt, 0).UTC(),
+        })
+    }
+    return out, nil
+}
+
+// get calls the API with params and decodes its result into out. An address
+// without transactions is reported as a failure by the API and is returned
+// as an empty result.
+func (c *EtherscanClient) get(ctx context.Context, params url.Values, out any) error {
+    u := *c.endpoint
+    query := u.Query()
+    for key, values := range params {
+        query[key] = values
+    }
+    if c.apiKey != "" {
+        query.Set("apikey", c.apiKey)
+    }
+    u.RawQuery = query.Encode()
+    action := params.Get("action")
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+    if err != nil {
+        return err
+    }
+    resp, err := c.http.Do(req)
+    if err != nil {
+        // The URL carries the API key, so it is left out of the error.
+        var urlErr *url.Error
+        if errors.As(err, &urlErr) {
+            err = urlErr.Err
+        }
+        return fmt.Errorf("etherscan %s: %w", action, err)
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+    if err != nil {
+        return fmt.Errorf("etherscan %s: %w", action, err)
+    }
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("etherscan %s: unexpected status %s", action, resp.Status)
+    }
+
+    var envelope etherscanResponse
+    if err := json.Unmarshal(body, &envelope); err != nil {
+        return fmt.Errorf("etherscan %s: decode response: %w", action, err)
+    }
+    if envelope.Status != "1" {
+        if envelope.Message == "No transactions found" {
+            return nil
+        }
+        var detail string
+        if json.Unmarshal(envelope.Result, &detail) != nil {
+            detail = envelope.Message
+        }
+        return fmt.Errorf("etherscan %s: %s", action, detail)
+    }
+    if err := json.Unmarshal(envelope.Result, out); err != nil {
+        return fmt.Errorf("etherscan %s: decode result: %w", action, err)
+    }
+    return nil
+}
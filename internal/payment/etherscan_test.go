@@ -0,0 +1,98 @@
+package payment
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+const etherscanTestAddress = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+// etherscanServer answers txlist calls for etherscanTestAddress with body,
+// checking the query the client sends.
+func etherscanServer(t *testing.T, body string) *httptest.Server {
+    t.Helper()
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        q := r.URL.Query()
+        if q.Get("chainid") != "1" || q.Get("module") != "account" || q.Get("action") != "txlist" ||
+            q.Get("address") != etherscanTestAddress || q.Get("apikey") != "secret" {
+            t.Errorf("unexpected query %s", r.URL.RawQuery)
+        }
+        w.Write([]byte(body))
+    }))
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func TestEtherscanTransactionsTo(t *testing.T) {
+    srv := etherscanServer(t, `{"status":"1","message":"OK","result":[
+        {"hash":"0xin","from":"0xpayer","to":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","value":"1500000000123","timeStamp":"1700000000","confirmations":"14","isError":"0"},
+        {"hash":"0xout","from":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","to":"0xother","value":"9000000000","timeStamp":"1700000100","confirmations":"10","isError":"0"},
+        {"hash":"0xreverted","from":"0xpayer","to":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","value":"9000000000","timeStamp":"1700000200","confirmations":"8","isError":"1"},
+        {"hash":"0xdust","from":"0xpayer","to":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed","value":"999999999","timeStamp":"1700000300","confirmations":"5","isError":"0"}
+    ]}`)
+    client, err := NewEtherscanClient(srv.URL+"/v2/api?chainid=1", "secret")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    txs, err := client.TransactionsTo(context.Background(), etherscanTestAddress)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := Transaction{Hash: "0xin", Amount: domain.NewMoney(1500, "ETH"), Confirmations: 14, SeenAt: time.Unix(1700000000, 0).UTC()}
+    if len(txs) != 1 || txs[0] != want {
+        t.Errorf("TransactionsTo = %+v, want only %+v", txs, want)
+    }
+}
+
+func TestEtherscanResponses(t *testing.T) {
+    tests := []struct {
+        name    string
+        body    string
+        wantErr string
+    }{
+        {"no transactions", `{"status":"0","message":"No transactions found","result":[]}`, ""},
+        {"api error", `{"status":"0","message":"NOTOK","result":"Invalid API Key"}`, "Invalid API Key"},
+        {"bad value", `{"status":"1","message":"OK","result":[{"hash":"0x1","to":"` + etherscanTestAddress + `","value":"1e18","timeStamp":"1","confirmations":"1","isError":"0"}]}`, "has value"},
+        {"not json", `<html>`, "decode response"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            client, err := NewEtherscanClient(etherscanServer(t, tt.body).URL+"?chainid=1", "secret")
+            if err != nil {
+                t.Fatal(err)
+            }
+            txs, err := client.TransactionsTo(context.Background(), etherscanTestAddress)
+            if tt.wantErr == "" {
+                if err != nil || len(txs) != 0 {
+                    t.Errorf("TransactionsTo = %v, %v; want nothing", txs, err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Errorf("TransactionsTo error = %v, want one mentioning %q", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestEtherscanErrorsHideAPIKey(t *testing.T) {
+    client, err := NewEtherscanClient("http://127.0.0.1:1/api", "secret")
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, err = client.TransactionsTo(context.Background(), etherscanTestAddress)
+    if err == nil || strings.Contains(err.Error(), "secret") {
+        t.Errorf("TransactionsTo error = %v, want a failure without the API key", err)
+    }
+
+    if _, err := NewEtherscanClient("api.etherscan.io", ""); err == nil {
+        t.Error("NewEtherscanClient accepted an endpoint without a scheme")
+    }
+}
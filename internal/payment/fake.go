@@ -0,0 +1,83 @@
+package payment
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "sync"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+// FakeChain is an in-process ChainClient that lets tests and local runs
+// simulate payments and block production without a network.
+type FakeChain struct {
+    currency string
+
+    mu     sync.Mutex
+    height int
+    txs    map[string][]fakeTx
+}
+
+type fakeTx struct {
+    hash   string
+    amount domain.Money
+    seenAt time.Time
+    // height is the block the transaction was mined in; zero while in the mempool.
+    height int
+}
+
+// NewFakeChain constructs an empty fake chain for currency.
+func NewFakeChain(currency string) *FakeChain {
+    return &FakeChain{currency: currency, txs: make(map[string][]fakeTx)}
+}
+
+func (c *FakeChain) Currency() string {
+    return c.currency
+}
+
+// Send places an unconfirmed transaction paying amount to address and returns its hash.
+func (c *FakeChain) Send(address string, amount domain.Money, at time.Time) string {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    buf := make([]byte, 32)
+    _, _ = rand.Read(buf)
+    hash := hex.EncodeToString(buf)
+    c.txs[address] = append(c.txs[address], fakeTx{hash: hash, amount: amount, seenAt: at})
+    return hash
+}
+
+// Mine produces n blocks. The first block confirms every pending transaction.
+func (c *FakeChain) Mine(n int) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    for i := 0; i < n; i++ {
+        c.height++
+        for addr, txs := range c.txs {
+            for j := range txs {
+                if txs[j].height == 0 {
+                    txs[j].height = c.height
+                }
+            }
+            c.txs[addr] = txs
+        }
+    }
+}
+
+func (c *FakeChain) TransactionsTo(_ context.Context, address string) ([]Transaction, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    out := make([]Transaction, 0, len(c.txs[address]))
+    for _, tx := range c.txs[address] {
+        confirmations := 0
+        if tx.height > 0 {
+            confirmations = c.height - tx.height + 1
+        }
+        out = append(out, Transaction{Hash: tx.hash, Amount: tx.amount, Confirmations: confirmations, SeenAt: tx.seenAt})
+    }
+    return out, nil
+}
@@ -2,183 +2,1657 @@ package memory
 
 import (
     "context"
+    "maps"
+    "slices"
+    "sort"
     "sync"
+    "time"
 
     "cryptotrade/internal/domain"
     "cryptotrade/internal/repository"
 )
 
-// ProductRepository is an in-memory implementation of repository.ProductRepository.
-type ProductRepository struct {
+// Store holds the in-memory tables shared by the repositories it hands out.
+// A single lock guards every table so that transactions can span entities.
+type Store struct {
     mu       sync.RWMutex
     products map[string]domain.Product
+    // skus maps each variant SKU to the product it belongs to.
+    skus     map[string]string
+    users    map[string]domain.User
+    orders   map[string]domain.Order
+    invoices map[string]domain.Invoice
+    carts    map[string]domain.Cart
+    holds    map[string]domain.Reservation
+    // warehouses and levels hold per-warehouse stock; levels is keyed by
+    // warehouse then product.
+    warehouses map[string]domain.Warehouse
+    levels     map[levelKey]domain.StockLevel
+    // movements is the append-only stock ledger in recording order.
+    movements []domain.StockMovement
+    // categories holds the taxonomy; productCategories maps a product ID to
+    // the set of categories it is assigned to.
+    categories        map[string]domain.Category
+    productCategories map[string]map[string]struct{}
+    refreshTokens     map[string]domain.RefreshToken
+    apiKeys           map[string]domain.APIKey
+    accountTokens     map[string]domain.AccountToken
+    addresses         map[string]domain.Address
+    promotions        map[string]domain.Promotion
+    // redemptions records every use of a promotion in the order it happened.
+    redemptions []domain.PromotionRedemption
+}
+
+type levelKey struct {
+    warehouseID, productID, sku string
+}
+
+// NewStore constructs an empty in-memory store.
+func NewStore() *Store {
+    return &Store{
+        products:   make(map[string]domain.Product),
+        skus:       make(map[string]string),
+        users:      make(map[string]domain.User),
+        orders:     make(map[string]domain.Order),
+        invoices:   make(map[string]domain.Invoice),
+        carts:      make(map[string]domain.Cart),
+        holds:      make(map[string]domain.Reservation),
+        warehouses: make(map[string]domain.Warehouse),
+        levels:     make(map[levelKey]domain.StockLevel),
+
+        categories:        make(map[string]domain.Category),
+        productCategories: make(map[string]map[string]struct{}),
+        refreshTokens:     make(map[string]domain.RefreshToken),
+        apiKeys:           make(map[string]domain.APIKey),
+        accountTokens:     make(map[string]domain.AccountToken),
+        addresses:         make(map[string]domain.Address),
+        promotions:        make(map[string]domain.Promotion),
+    }
+}
+
+// Repositories returns repositories that operate directly on the store.
+func (s *Store) Repositories() repository.Repositories {
+    return s.repositories(&session{store: s})
+}
+
+func (s *Store) repositories(sess *session) repository.Repositories {
+    return repository.Repositories{
+        Products:       &ProductRepository{sess: sess},
+        Users:          &UserRepository{sess: sess},
+        Orders:         &OrderRepository{sess: sess},
+        Invoices:       &InvoiceRepository{sess: sess},
+        Carts:          &CartRepository{sess: sess},
+        Reservations:   &ReservationRepository{sess: sess},
+        Warehouses:     &WarehouseRepository{sess: sess},
+        StockLevels:    &StockLevelRepository{sess: sess},
+        StockMovements: &StockMovementRepository{sess: sess},
+        Categories:     &CategoryRepository{sess: sess},
+        RefreshTokens:  &RefreshTokenRepository{sess: sess},
+        APIKeys:        &APIKeyRepository{sess: sess},
+        AccountTokens:  &AccountTokenRepository{sess: sess},
+        Addresses:      &AddressRepository{sess: sess},
+        Promotions:     &PromotionRepository{sess: sess},
+    }
+}
+
+// WithinTx implements repository.TxManager. The store lock is held for the
+// whole unit of work and every write records an undo step that is replayed
+// in reverse if fn fails.
+func (s *Store) WithinTx(ctx context.Context, fn func(ctx context.Context, repos repository.Repositories) error) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    sess := &session{store: s, inTx: true}
+    if err := fn(ctx, s.repositories(sess)); err != nil {
+        for i := len(sess.undo) - 1; i >= 0; i-- {
+            sess.undo[i]()
+        }
+        return err
+    }
+    return nil
+}
+
+// session decides how repositories synchronise with the store: outside a
+// transaction each call takes the store lock, inside one the lock is already
+// held by WithinTx and writes are journalled for rollback.
+type session struct {
+    store *Store
+    inTx  bool
+    undo  []func()
+}
+
+func (s *session) lock() {
+    if !s.inTx {
+        s.store.mu.Lock()
+    }
+}
+
+func (s *session) unlock() {
+    if !s.inTx {
+        s.store.mu.Unlock()
+    }
+}
+
+func (s *session) rlock() {
+    if !s.inTx {
+        s.store.mu.RLock()
+    }
+}
+
+func (s *session) runlock() {
+    if !s.inTx {
+        s.store.mu.RUnlock()
+    }
+}
+
+func (s *session) onRollback(fn func()) {
+    if s.inTx {
+        s.undo = append(s.undo, fn)
+    }
 }
 
-// NewProductRepository constructs a new in-memory product repository.
-func NewProductRepository() *ProductRepository {
-    return &ProductRepository{products: make(map[string]domain.Product)}
+// ProductRepository is an in-memory implementation of repository.ProductRepository.
+type ProductRepository struct {
+    sess *session
 }
 
 func (r *ProductRepository) Create(_ context.Context, product domain.Product) error {
-    r.mu.Lock()
-    defer r.mu.Unlock()
+    r.sess.lock()
+    defer r.sess.unlock()
 
-    if _, exists := r.products[product.ID]; exists {
+    products := r.sess.store.products
+    if _, exists := products[product.ID]; exists {
+        return repository.ErrConflict
+    }
+    if r.skuTaken(product) {
         return repository.ErrConflict
     }
 
-    r.products[product.ID] = product
+    product = cloneProduct(product)
+    products[product.ID] = product
+    r.indexSKUs(domain.Product{}, product)
+    r.sess.onRollback(func() {
+        delete(products, product.ID)
+        r.indexSKUs(product, domain.Product{})
+    })
     return nil
 }
 
 func (r *ProductRepository) Update(_ context.Context, product domain.Product) error {
-    r.mu.Lock()
-    defer r.mu.Unlock()
+    r.sess.lock()
+    defer r.sess.unlock()
 
-    if _, ok := r.products[product.ID]; !ok {
+    products := r.sess.store.products
+    previous, ok := products[product.ID]
+    if !ok {
         return repository.ErrNotFound
     }
-    r.products[product.ID] = product
+    if r.skuTaken(product) {
+        return repository.ErrConflict
+    }
+    product = cloneProduct(product)
+    products[product.ID] = product
+    r.indexSKUs(previous, product)
+    r.sess.onRollback(func() {
+        products[product.ID] = previous
+        r.indexSKUs(product, previous)
+    })
     return nil
 }
 
 func (r *ProductRepository) Delete(_ context.Context, id string) error {
-    r.mu.Lock()
-    defer r.mu.Unlock()
+    r.sess.lock()
+    defer r.sess.unlock()
 
-    if _, ok := r.products[id]; !ok {
+    products := r.sess.store.products
+    previous, ok := products[id]
+    if !ok {
         return repository.ErrNotFound
     }
-    delete(r.products, id)
+    delete(products, id)
+    r.indexSKUs(previous, domain.Product{})
+    r.sess.onRollback(func() {
+        products[id] = previous
+        r.indexSKUs(domain.Product{}, previous)
+    })
     return nil
 }
 
 func (r *ProductRepository) GetByID(_ context.Context, id string) (domain.Product, error) {
-    r.mu.RLock()
-    defer r.mu.RUnlock()
+    r.sess.rlock()
+    defer r.sess.runlock()
 
-    product, ok := r.products[id]
+    product, ok := r.sess.store.products[id]
     if !ok {
         return domain.Product{}, repository.ErrNotFound
     }
-    return product, nil
+    return cloneProduct(product), nil
 }
 
-func (r *ProductRepository) List(_ context.Context) ([]domain.Product, error) {
-    r.mu.RLock()
-    defer r.mu.RUnlock()
+func (r *ProductRepository) GetBySKU(_ context.Context, sku string) (domain.Product, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
 
-    products := make([]domain.Product, 0, len(r.products))
-    for _, product := range r.products {
-        products = append(products, product)
+    id, ok := r.sess.store.skus[sku]
+    if !ok {
+        return domain.Product{}, repository.ErrNotFound
     }
-    return products, nil
+    return cloneProduct(r.sess.store.products[id]), nil
 }
 
-// UserRepository is an in-memory implementation of repository.UserRepository.
-type UserRepository struct {
-    mu    sync.RWMutex
-    users map[string]domain.User
+// skuTaken reports whether any of the product's SKUs belongs to another product.
+func (r *ProductRepository) skuTaken(product domain.Product) bool {
+    for _, variant := range product.Variants {
+        if owner, ok := r.sess.store.skus[variant.SKU]; ok && owner != product.ID {
+            return true
+        }
+    }
+    return false
+}
+
+// indexSKUs replaces the SKU index entries of from with those of to.
+func (r *ProductRepository) indexSKUs(from, to domain.Product) {
+    for _, variant := range from.Variants {
+        delete(r.sess.store.skus, variant.SKU)
+    }
+    for _, variant := range to.Variants {
+        r.sess.store.skus[variant.SKU] = to.ID
+    }
+}
+
+var productSortKeys = sortKeys[domain.Product]{
+    "name":       func(p domain.Product) []any { return []any{p.Name} },
+    "price":      func(p domain.Product) []any { return []any{p.Price.Currency, p.Price.Amount} },
+    "stock":      func(p domain.Product) []any { return []any{int64(p.Stock)} },
+    "created_at": func(p domain.Product) []any { return []any{p.CreatedAt.UnixNano()} },
+}
+
+func (r *ProductRepository) List(_ context.Context, query repository.ProductQuery) (repository.Page[domain.Product], error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    products := make([]domain.Product, 0, len(r.sess.store.products))
+    for _, product := range r.sess.store.products {
+        if query.PriceCurrency != "" && product.Price.Currency != query.PriceCurrency {
+            continue
+        }
+        if query.MinPrice != nil && product.Price.Amount < *query.MinPrice {
+            continue
+        }
+        if query.MaxPrice != nil && product.Price.Amount > *query.MaxPrice {
+            continue
+        }
+        if query.InStock && product.Available() <= 0 {
+            continue
+        }
+        if len(query.CategoryIDs) > 0 && !slices.ContainsFunc(query.CategoryIDs, func(id string) bool {
+            _, ok := r.sess.store.productCategories[product.ID][id]
+            return ok
+        }) {
+            continue
+        }
+        products = append(products, cloneProduct(product))
+    }
+    return paginate(products, query.ListOptions, repository.DefaultProductSort, productSortKeys, func(p domain.Product) string { return p.ID })
 }
 
-// NewUserRepository constructs a new in-memory user repository.
-func NewUserRepository() *UserRepository {
-    return &UserRepository{users: make(map[string]domain.User)}
+// UserRepository is an in-memory implementation of repository.UserRepository.
+type UserRepository struct {
+    sess *session
 }
 
 func (r *UserRepository) Create(_ context.Context, user domain.User) error {
-    r.mu.Lock()
-    defer r.mu.Unlock()
+    r.sess.lock()
+    defer r.sess.unlock()
 
-    if _, exists := r.users[user.ID]; exists {
+    users := r.sess.store.users
+    if _, exists := users[user.ID]; exists {
         return repository.ErrConflict
     }
 
-    for _, existing := range r.users {
+    for _, existing := range users {
         if existing.Email == user.Email {
             return repository.ErrConflict
         }
     }
 
-    r.users[user.ID] = user
+    users[user.ID] = cloneUser(user)
+    r.sess.onRollback(func() { delete(users, user.ID) })
+    return nil
+}
+
+func (r *UserRepository) Update(_ context.Context, user domain.User) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    users := r.sess.store.users
+    previous, ok := users[user.ID]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    for _, existing := range users {
+        if existing.ID != user.ID && existing.Email == user.Email {
+            return repository.ErrConflict
+        }
+    }
+
+    users[user.ID] = cloneUser(user)
+    r.sess.onRollback(func() { users[user.ID] = previous })
     return nil
 }
 
 func (r *UserRepository) GetByID(_ context.Context, id string) (domain.User, error) {
-    r.mu.RLock()
-    defer r.mu.RUnlock()
+    r.sess.rlock()
+    defer r.sess.runlock()
 
-    user, ok := r.users[id]
+    user, ok := r.sess.store.users[id]
     if !ok {
         return domain.User{}, repository.ErrNotFound
     }
-    return user, nil
+    return cloneUser(user), nil
+}
+
+func (r *UserRepository) SetRole(_ context.Context, id string, role domain.Role) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    users := r.sess.store.users
+    previous, ok := users[id]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    user := previous
+    user.Role = role
+    users[id] = user
+    r.sess.onRollback(func() { users[id] = previous })
+    return nil
 }
 
 func (r *UserRepository) GetByEmail(_ context.Context, email string) (domain.User, error) {
-    r.mu.RLock()
-    defer r.mu.RUnlock()
+    r.sess.rlock()
+    defer r.sess.runlock()
 
-    for _, user := range r.users {
+    for _, user := range r.sess.store.users {
         if user.Email == email {
-            return user, nil
+            return cloneUser(user), nil
         }
     }
     return domain.User{}, repository.ErrNotFound
 }
 
-func (r *UserRepository) List(_ context.Context) ([]domain.User, error) {
-    r.mu.RLock()
-    defer r.mu.RUnlock()
+var userSortKeys = sortKeys[domain.User]{
+    "name":       func(u domain.User) []any { return []any{u.Name} },
+    "email":      func(u domain.User) []any { return []any{u.Email} },
+    "created_at": func(u domain.User) []any { return []any{u.CreatedAt.UnixNano()} },
+}
+
+func (r *UserRepository) List(_ context.Context, query repository.UserQuery) (repository.Page[domain.User], error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
 
-    users := make([]domain.User, 0, len(r.users))
-    for _, user := range r.users {
-        users = append(users, user)
+    users := make([]domain.User, 0, len(r.sess.store.users))
+    for _, user := range r.sess.store.users {
+        if query.Email != "" && user.Email != query.Email {
+            continue
+        }
+        users = append(users, cloneUser(user))
     }
-    return users, nil
+    return paginate(users, query.ListOptions, repository.DefaultUserSort, userSortKeys, func(u domain.User) string { return u.ID })
 }
 
 // OrderRepository is an in-memory implementation of repository.OrderRepository.
 type OrderRepository struct {
-    mu     sync.RWMutex
-    orders map[string]domain.Order
-}
-
-// NewOrderRepository constructs a new in-memory order repository.
-func NewOrderRepository() *OrderRepository {
-    return &OrderRepository{orders: make(map[string]domain.Order)}
+    sess *session
 }
 
 func (r *OrderRepository) Create(_ context.Context, order domain.Order) error {
-    r.mu.Lock()
-    defer r.mu.Unlock()
+    r.sess.lock()
+    defer r.sess.unlock()
 
-    if _, exists := r.orders[order.ID]; exists {
+    orders := r.sess.store.orders
+    if _, exists := orders[order.ID]; exists {
         return repository.ErrConflict
     }
 
-    r.orders[order.ID] = order
+    orders[order.ID] = cloneOrder(order)
+    r.sess.onRollback(func() { delete(orders, order.ID) })
+    return nil
+}
+
+func (r *OrderRepository) Update(_ context.Context, order domain.Order) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    orders := r.sess.store.orders
+    previous, ok := orders[order.ID]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    orders[order.ID] = cloneOrder(order)
+    r.sess.onRollback(func() { orders[order.ID] = previous })
     return nil
 }
 
 func (r *OrderRepository) GetByID(_ context.Context, id string) (domain.Order, error) {
-    r.mu.RLock()
-    defer r.mu.RUnlock()
+    r.sess.rlock()
+    defer r.sess.runlock()
 
-    order, ok := r.orders[id]
+    order, ok := r.sess.store.orders[id]
     if !ok {
         return domain.Order{}, repository.ErrNotFound
     }
-    return order, nil
+    return cloneOrder(order), nil
+}
+
+var orderSortKeys = sortKeys[domain.Order]{
+    "created_at": func(o domain.Order) []any { return []any{o.CreatedAt.UnixNano()} },
+    "total":      func(o domain.Order) []any { return []any{o.Total.Currency, o.Total.Amount} },
+    "status":     func(o domain.Order) []any { return []any{string(o.Status)} },
 }
 
-func (r *OrderRepository) List(_ context.Context) ([]domain.Order, error) {
-    r.mu.RLock()
-    defer r.mu.RUnlock()
+func (r *OrderRepository) List(_ context.Context, query repository.OrderQuery) (repository.Page[domain.Order], error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
 
-    orders := make([]domain.Order, 0, len(r.orders))
-    for _, order := range r.orders {
+    orders := make([]domain.Order, 0, len(r.sess.store.orders))
+    for _, order := range r.sess.store.orders {
+        if query.UserID != "" && order.UserID != query.UserID {
+            continue
+        }
+        if query.Status != "" && order.Status != query.Status {
+            continue
+        }
+        if !query.CreatedFrom.IsZero() && order.CreatedAt.Before(query.CreatedFrom) {
+            continue
+        }
+        if !query.CreatedTo.IsZero() && !order.CreatedAt.Before(query.CreatedTo) {
+            continue
+        }
+        if query.RefundDue && order.RefundDue == nil {
+            continue
+        }
         orders = append(orders, order)
     }
-    return orders, nil
+    page, err := paginate(orders, query.ListOptions, repository.DefaultOrderSort, orderSortKeys, func(o domain.Order) string { return o.ID })
+    for i, order := range page.Items {
+        page.Items[i] = cloneOrder(order)
+    }
+    return page, err
+}
+
+// InvoiceRepository is an in-memory implementation of repository.InvoiceRepository.
+type InvoiceRepository struct {
+    sess *session
+}
+
+func (r *InvoiceRepository) Create(_ context.Context, invoice domain.Invoice) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    invoices := r.sess.store.invoices
+    if _, exists := invoices[invoice.ID]; exists {
+        return repository.ErrConflict
+    }
+    for _, existing := range invoices {
+        if existing.OrderID == invoice.OrderID {
+            return repository.ErrConflict
+        }
+        if invoice.Address != "" && existing.Address == invoice.Address {
+            return repository.ErrConflict
+        }
+        if invoice.PaymentHash != "" && existing.PaymentHash == invoice.PaymentHash {
+            return repository.ErrConflict
+        }
+    }
+
+    invoices[invoice.ID] = cloneInvoice(invoice)
+    r.sess.onRollback(func() { delete(invoices, invoice.ID) })
+    return nil
+}
+
+func (r *InvoiceRepository) Update(_ context.Context, invoice domain.Invoice) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    invoices := r.sess.store.invoices
+    previous, ok := invoices[invoice.ID]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    invoices[invoice.ID] = cloneInvoice(invoice)
+    r.sess.onRollback(func() { invoices[invoice.ID] = previous })
+    return nil
+}
+
+func (r *InvoiceRepository) GetByID(_ context.Context, id string) (domain.Invoice, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    invoice, ok := r.sess.store.invoices[id]
+    if !ok {
+        return domain.Invoice{}, repository.ErrNotFound
+    }
+    return cloneInvoice(invoice), nil
+}
+
+func (r *InvoiceRepository) GetByOrderID(_ context.Context, orderID string) (domain.Invoice, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    for _, invoice := range r.sess.store.invoices {
+        if invoice.OrderID == orderID {
+            return cloneInvoice(invoice), nil
+        }
+    }
+    return domain.Invoice{}, repository.ErrNotFound
+}
+
+func (r *InvoiceRepository) GetByPaymentHash(_ context.Context, paymentHash string) (domain.Invoice, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    for _, invoice := range r.sess.store.invoices {
+        if invoice.PaymentHash == paymentHash {
+            return cloneInvoice(invoice), nil
+        }
+    }
+    return domain.Invoice{}, repository.ErrNotFound
+}
+
+func (r *InvoiceRepository) ListPending(_ context.Context) ([]domain.Invoice, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    invoices := make([]domain.Invoice, 0)
+    for _, invoice := range r.sess.store.invoices {
+        if invoice.Status == domain.InvoiceStatusPending {
+            invoices = append(invoices, cloneInvoice(invoice))
+        }
+    }
+    sort.Slice(invoices, func(i, j int) bool { return invoices[i].CreatedAt.Before(invoices[j].CreatedAt) })
+    return invoices, nil
+}
+
+func (r *InvoiceRepository) TransactionClaimant(_ context.Context, currency, txHash string) (string, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    for _, invoice := range r.sess.store.invoices {
+        if invoice.Method != domain.PaymentMethodOnChain || invoice.Amount.Currency != currency {
+            continue
+        }
+        for _, p := range invoice.Payments {
+            if p.TxHash == txHash {
+                return invoice.ID, nil
+            }
+        }
+    }
+    return "", repository.ErrNotFound
+}
+
+func (r *InvoiceRepository) NextDerivationIndex(_ context.Context, currency string) (uint32, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    var next uint32
+    for _, invoice := range r.sess.store.invoices {
+        if invoice.Method != domain.PaymentMethodOnChain || invoice.Amount.Currency != currency {
+            continue
+        }
+        if invoice.DerivationIndex >= next {
+            next = invoice.DerivationIndex + 1
+        }
+    }
+    return next, nil
+}
+
+// CartRepository is an in-memory implementation of repository.CartRepository.
+type CartRepository struct {
+    sess *session
+}
+
+func (r *CartRepository) Get(_ context.Context, userID string) (domain.Cart, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    cart, ok := r.sess.store.carts[userID]
+    if !ok {
+        return domain.Cart{}, repository.ErrNotFound
+    }
+    return cloneCart(cart), nil
+}
+
+func (r *CartRepository) Save(_ context.Context, cart domain.Cart) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    if _, ok := r.sess.store.users[cart.UserID]; !ok {
+        return repository.ErrNotFound
+    }
+
+    carts := r.sess.store.carts
+    previous, existed := carts[cart.UserID]
+    carts[cart.UserID] = cloneCart(cart)
+    r.sess.onRollback(func() {
+        if existed {
+            carts[cart.UserID] = previous
+        } else {
+            delete(carts, cart.UserID)
+        }
+    })
+    return nil
+}
+
+func (r *CartRepository) Delete(_ context.Context, userID string) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    carts := r.sess.store.carts
+    previous, ok := carts[userID]
+    if !ok {
+        return nil
+    }
+    delete(carts, userID)
+    r.sess.onRollback(func() { carts[userID] = previous })
+    return nil
+}
+
+// ReservationRepository is an in-memory implementation of repository.ReservationRepository.
+type ReservationRepository struct {
+    sess *session
+}
+
+func (r *ReservationRepository) Create(_ context.Context, reservation domain.Reservation) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    holds := r.sess.store.holds
+    if _, exists := holds[reservation.ID]; exists {
+        return repository.ErrConflict
+    }
+    if _, ok := r.sess.store.orders[reservation.OrderID]; !ok {
+        return repository.ErrNotFound
+    }
+
+    holds[reservation.ID] = cloneReservation(reservation)
+    r.sess.onRollback(func() { delete(holds, reservation.ID) })
+    return nil
+}
+
+func (r *ReservationRepository) Update(_ context.Context, reservation domain.Reservation) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    holds := r.sess.store.holds
+    previous, ok := holds[reservation.ID]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    holds[reservation.ID] = cloneReservation(reservation)
+    r.sess.onRollback(func() { holds[reservation.ID] = previous })
+    return nil
+}
+
+func (r *ReservationRepository) ListByOrder(_ context.Context, orderID string) ([]domain.Reservation, error) {
+    return r.list(func(reservation domain.Reservation) bool { return reservation.OrderID == orderID }), nil
+}
+
+func (r *ReservationRepository) ListExpired(_ context.Context, now time.Time) ([]domain.Reservation, error) {
+    return r.list(func(reservation domain.Reservation) bool {
+        return reservation.Status == domain.ReservationStatusHeld && !reservation.ExpiresAt.After(now)
+    }), nil
+}
+
+func (r *ReservationRepository) list(match func(domain.Reservation) bool) []domain.Reservation {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    reservations := make([]domain.Reservation, 0)
+    for _, reservation := range r.sess.store.holds {
+        if match(reservation) {
+            reservations = append(reservations, cloneReservation(reservation))
+        }
+    }
+    sort.Slice(reservations, func(i, j int) bool {
+        if !reservations[i].CreatedAt.Equal(reservations[j].CreatedAt) {
+            return reservations[i].CreatedAt.Before(reservations[j].CreatedAt)
+        }
+        return reservations[i].ID < reservations[j].ID
+    })
+    return reservations
+}
+
+// WarehouseRepository is an in-memory implementation of repository.WarehouseRepository.
+type WarehouseRepository struct {
+    sess *session
+}
+
+func (r *WarehouseRepository) Create(_ context.Context, warehouse domain.Warehouse) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    warehouses := r.sess.store.warehouses
+    if _, exists := warehouses[warehouse.ID]; exists {
+        return repository.ErrConflict
+    }
+    for _, existing := range warehouses {
+        if existing.Name == warehouse.Name {
+            return repository.ErrConflict
+        }
+    }
+
+    warehouses[warehouse.ID] = cloneWarehouse(warehouse)
+    r.sess.onRollback(func() { delete(warehouses, warehouse.ID) })
+    return nil
+}
+
+func (r *WarehouseRepository) Update(_ context.Context, warehouse domain.Warehouse) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    warehouses := r.sess.store.warehouses
+    previous, ok := warehouses[warehouse.ID]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    for _, existing := range warehouses {
+        if existing.ID != warehouse.ID && existing.Name == warehouse.Name {
+            return repository.ErrConflict
+        }
+    }
+    warehouses[warehouse.ID] = cloneWarehouse(warehouse)
+    r.sess.onRollback(func() { warehouses[warehouse.ID] = previous })
+    return nil
+}
+
+func (r *WarehouseRepository) GetByID(_ context.Context, id string) (domain.Warehouse, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    warehouse, ok := r.sess.store.warehouses[id]
+    if !ok {
+        return domain.Warehouse{}, repository.ErrNotFound
+    }
+    return cloneWarehouse(warehouse), nil
+}
+
+func (r *WarehouseRepository) List(_ context.Context) ([]domain.Warehouse, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    warehouses := make([]domain.Warehouse, 0, len(r.sess.store.warehouses))
+    for _, warehouse := range r.sess.store.warehouses {
+        warehouses = append(warehouses, cloneWarehouse(warehouse))
+    }
+    sort.Slice(warehouses, func(i, j int) bool {
+        if warehouses[i].Priority != warehouses[j].Priority {
+            return warehouses[i].Priority < warehouses[j].Priority
+        }
+        if warehouses[i].Name != warehouses[j].Name {
+            return warehouses[i].Name < warehouses[j].Name
+        }
+        return warehouses[i].ID < warehouses[j].ID
+    })
+    return warehouses, nil
+}
+
+// StockLevelRepository is an in-memory implementation of repository.StockLevelRepository.
+type StockLevelRepository struct {
+    sess *session
+}
+
+func (r *StockLevelRepository) Get(_ context.Context, warehouseID, productID, sku string) (domain.StockLevel, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    level, ok := r.sess.store.levels[levelKey{warehouseID, productID, sku}]
+    if !ok {
+        return domain.StockLevel{}, repository.ErrNotFound
+    }
+    return level, nil
+}
+
+func (r *StockLevelRepository) Save(_ context.Context, level domain.StockLevel) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    if _, ok := r.sess.store.warehouses[level.WarehouseID]; !ok {
+        return repository.ErrNotFound
+    }
+
+    levels := r.sess.store.levels
+    key := levelKey{level.WarehouseID, level.ProductID, level.SKU}
+    previous, existed := levels[key]
+    levels[key] = level
+    r.sess.onRollback(func() {
+        if existed {
+            levels[key] = previous
+        } else {
+            delete(levels, key)
+        }
+    })
+    return nil
+}
+
+func (r *StockLevelRepository) ListByProduct(_ context.Context, productID string) ([]domain.StockLevel, error) {
+    return r.list(func(level domain.StockLevel) bool { return level.ProductID == productID }), nil
+}
+
+func (r *StockLevelRepository) ListByWarehouse(_ context.Context, warehouseID string) ([]domain.StockLevel, error) {
+    return r.list(func(level domain.StockLevel) bool { return level.WarehouseID == warehouseID }), nil
+}
+
+func (r *StockLevelRepository) list(match func(domain.StockLevel) bool) []domain.StockLevel {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    levels := make([]domain.StockLevel, 0)
+    for _, level := range r.sess.store.levels {
+        if match(level) {
+            levels = append(levels, level)
+        }
+    }
+    sort.Slice(levels, func(i, j int) bool {
+        if levels[i].WarehouseID != levels[j].WarehouseID {
+            return levels[i].WarehouseID < levels[j].WarehouseID
+        }
+        if levels[i].ProductID != levels[j].ProductID {
+            return levels[i].ProductID < levels[j].ProductID
+        }
+        return levels[i].SKU < levels[j].SKU
+    })
+    return levels
+}
+
+// StockMovementRepository is an in-memory implementation of repository.StockMovementRepository.
+type StockMovementRepository struct {
+    sess *session
+}
+
+func (r *StockMovementRepository) Create(_ context.Context, movement domain.StockMovement) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    store := r.sess.store
+    for _, existing := range store.movements {
+        if existing.ID == movement.ID {
+            return repository.ErrConflict
+        }
+    }
+
+    n := len(store.movements)
+    store.movements = append(store.movements, movement)
+    r.sess.onRollback(func() { store.movements = store.movements[:n] })
+    return nil
+}
+
+func (r *StockMovementRepository) ListByProduct(_ context.Context, productID string) ([]domain.StockMovement, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    movements := make([]domain.StockMovement, 0)
+    for _, movement := range r.sess.store.movements {
+        if movement.ProductID == productID {
+            movements = append(movements, movement)
+        }
+    }
+    return movements, nil
+}
+
+// CategoryRepository is an in-memory implementation of repository.CategoryRepository.
+type CategoryRepository struct {
+    sess *session
+}
+
+func (r *CategoryRepository) Create(_ context.Context, category domain.Category) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    categories := r.sess.store.categories
+    if _, exists := categories[category.ID]; exists {
+        return repository.ErrConflict
+    }
+    if r.siblingNamed(category) {
+        return repository.ErrConflict
+    }
+
+    categories[category.ID] = category
+    r.sess.onRollback(func() { delete(categories, category.ID) })
+    return nil
+}
+
+func (r *CategoryRepository) Update(_ context.Context, category domain.Category) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    categories := r.sess.store.categories
+    previous, ok := categories[category.ID]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    if r.siblingNamed(category) {
+        return repository.ErrConflict
+    }
+    categories[category.ID] = category
+    r.sess.onRollback(func() { categories[category.ID] = previous })
+    return nil
+}
+
+// siblingNamed reports whether another category under the same parent
+// already has the category's name.
+func (r *CategoryRepository) siblingNamed(category domain.Category) bool {
+    for _, existing := range r.sess.store.categories {
+        if existing.ID != category.ID && existing.ParentID == category.ParentID && existing.Name == category.Name {
+            return true
+        }
+    }
+    return false
+}
+
+func (r *CategoryRepository) Delete(_ context.Context, id string) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    categories := r.sess.store.categories
+    previous, ok := categories[id]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    delete(categories, id)
+    r.sess.onRollback(func() { categories[id] = previous })
+    return nil
+}
+
+func (r *CategoryRepository) GetByID(_ context.Context, id string) (domain.Category, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    category, ok := r.sess.store.categories[id]
+    if !ok {
+        return domain.Category{}, repository.ErrNotFound
+    }
+    return category, nil
+}
+
+func (r *CategoryRepository) List(_ context.Context) ([]domain.Category, error) {
+    return r.list(func(domain.Category) bool { return true }), nil
+}
+
+func (r *CategoryRepository) ListByProduct(_ context.Context, productID string) ([]domain.Category, error) {
+    return r.list(func(category domain.Category) bool {
+        _, ok := r.sess.store.productCategories[productID][category.ID]
+        return ok
+    }), nil
+}
+
+func (r *CategoryRepository) list(match func(domain.Category) bool) []domain.Category {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    categories := make([]domain.Category, 0)
+    for _, category := range r.sess.store.categories {
+        if match(category) {
+            categories = append(categories, category)
+        }
+    }
+    sort.Slice(categories, func(i, j int) bool {
+        if categories[i].Name != categories[j].Name {
+            return categories[i].Name < categories[j].Name
+        }
+        return categories[i].ID < categories[j].ID
+    })
+    return categories
+}
+
+func (r *CategoryRepository) SetProductCategories(_ context.Context, productID string, categoryIDs []string) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    assignments := r.sess.store.productCategories
+    previous, existed := assignments[productID]
+    if len(categoryIDs) == 0 {
+        delete(assignments, productID)
+    } else {
+        set := make(map[string]struct{}, len(categoryIDs))
+        for _, id := range categoryIDs {
+            set[id] = struct{}{}
+        }
+        assignments[productID] = set
+    }
+    r.sess.onRollback(func() {
+        if existed {
+            assignments[productID] = previous
+        } else {
+            delete(assignments, productID)
+        }
+    })
+    return nil
+}
+
+func (r *CategoryRepository) CountProducts(_ context.Context, categoryID string) (int, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    n := 0
+    for _, set := range r.sess.store.productCategories {
+        if _, ok := set[categoryID]; ok {
+            n++
+        }
+    }
+    return n, nil
+}
+
+// RefreshTokenRepository is an in-memory implementation of repository.RefreshTokenRepository.
+type RefreshTokenRepository struct {
+    sess *session
+}
+
+func (r *RefreshTokenRepository) Create(_ context.Context, token domain.RefreshToken) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    tokens := r.sess.store.refreshTokens
+    if _, exists := tokens[token.ID]; exists {
+        return repository.ErrConflict
+    }
+
+    tokens[token.ID] = cloneRefreshToken(token)
+    r.sess.onRollback(func() { delete(tokens, token.ID) })
+    return nil
+}
+
+func (r *RefreshTokenRepository) Update(_ context.Context, token domain.RefreshToken) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    tokens := r.sess.store.refreshTokens
+    previous, ok := tokens[token.ID]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    tokens[token.ID] = cloneRefreshToken(token)
+    r.sess.onRollback(func() { tokens[token.ID] = previous })
+    return nil
+}
+
+func (r *RefreshTokenRepository) GetByID(_ context.Context, id string) (domain.RefreshToken, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    token, ok := r.sess.store.refreshTokens[id]
+    if !ok {
+        return domain.RefreshToken{}, repository.ErrNotFound
+    }
+    return cloneRefreshToken(token), nil
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(_ context.Context, familyID string, at time.Time) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    tokens := r.sess.store.refreshTokens
+    for id, token := range tokens {
+        if token.FamilyID != familyID || token.RevokedAt != nil {
+            continue
+        }
+        previous := token
+        revokedAt := at
+        token.RevokedAt = &revokedAt
+        tokens[id] = token
+        r.sess.onRollback(func() { tokens[id] = previous })
+    }
+    return nil
+}
+
+func (r *RefreshTokenRepository) RevokeUser(_ context.Context, userID string, at time.Time) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    tokens := r.sess.store.refreshTokens
+    for id, token := range tokens {
+        if token.UserID != userID || token.RevokedAt != nil {
+            continue
+        }
+        previous := token
+        revokedAt := at
+        token.RevokedAt = &revokedAt
+        tokens[id] = token
+        r.sess.onRollback(func() { tokens[id] = previous })
+    }
+    return nil
+}
+
+// APIKeyRepository is an in-memory implementation of repository.APIKeyRepository.
+type APIKeyRepository struct {
+    sess *session
+}
+
+func (r *APIKeyRepository) Create(_ context.Context, key domain.APIKey) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    keys := r.sess.store.apiKeys
+    if _, exists := keys[key.ID]; exists {
+        return repository.ErrConflict
+    }
+    for _, existing := range keys {
+        if existing.Prefix == key.Prefix {
+            return repository.ErrConflict
+        }
+    }
+
+    keys[key.ID] = cloneAPIKey(key)
+    r.sess.onRollback(func() { delete(keys, key.ID) })
+    return nil
+}
+
+func (r *APIKeyRepository) Update(_ context.Context, key domain.APIKey) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    keys := r.sess.store.apiKeys
+    previous, ok := keys[key.ID]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    keys[key.ID] = cloneAPIKey(key)
+    r.sess.onRollback(func() { keys[key.ID] = previous })
+    return nil
+}
+
+func (r *APIKeyRepository) GetByID(_ context.Context, id string) (domain.APIKey, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    key, ok := r.sess.store.apiKeys[id]
+    if !ok {
+        return domain.APIKey{}, repository.ErrNotFound
+    }
+    return cloneAPIKey(key), nil
+}
+
+func (r *APIKeyRepository) GetByPrefix(_ context.Context, prefix string) (domain.APIKey, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    for _, key := range r.sess.store.apiKeys {
+        if key.Prefix == prefix {
+            return cloneAPIKey(key), nil
+        }
+    }
+    return domain.APIKey{}, repository.ErrNotFound
+}
+
+func (r *APIKeyRepository) List(_ context.Context) ([]domain.APIKey, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    keys := make([]domain.APIKey, 0, len(r.sess.store.apiKeys))
+    for _, key := range r.sess.store.apiKeys {
+        keys = append(keys, cloneAPIKey(key))
+    }
+    sort.Slice(keys, func(i, j int) bool {
+        if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+            return keys[i].CreatedAt.After(keys[j].CreatedAt)
+        }
+        return keys[i].ID < keys[j].ID
+    })
+    return keys, nil
+}
+
+// AccountTokenRepository is an in-memory implementation of repository.AccountTokenRepository.
+type AccountTokenRepository struct {
+    sess *session
+}
+
+func (r *AccountTokenRepository) Create(_ context.Context, token domain.AccountToken) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    tokens := r.sess.store.accountTokens
+    if _, exists := tokens[token.ID]; exists {
+        return repository.ErrConflict
+    }
+    tokens[token.ID] = cloneAccountToken(token)
+    r.sess.onRollback(func() { delete(tokens, token.ID) })
+    return nil
+}
+
+func (r *AccountTokenRepository) GetByID(_ context.Context, id string) (domain.AccountToken, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    token, ok := r.sess.store.accountTokens[id]
+    if !ok {
+        return domain.AccountToken{}, repository.ErrNotFound
+    }
+    return cloneAccountToken(token), nil
+}
+
+func (r *AccountTokenRepository) UseAll(_ context.Context, userID string, purpose domain.AccountTokenPurpose, at time.Time) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    tokens := r.sess.store.accountTokens
+    for id, token := range tokens {
+        if token.UserID != userID || token.Purpose != purpose || token.UsedAt != nil {
+            continue
+        }
+        previous := token
+        usedAt := at
+        token.UsedAt = &usedAt
+        tokens[id] = token
+        r.sess.onRollback(func() { tokens[id] = previous })
+    }
+    return nil
+}
+
+// AddressRepository is an in-memory implementation of repository.AddressRepository.
+type AddressRepository struct {
+    sess *session
+}
+
+func (r *AddressRepository) Create(_ context.Context, address domain.Address) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    addresses := r.sess.store.addresses
+    if _, exists := addresses[address.ID]; exists {
+        return repository.ErrConflict
+    }
+    addresses[address.ID] = cloneAddress(address)
+    r.sess.onRollback(func() { delete(addresses, address.ID) })
+    return nil
+}
+
+func (r *AddressRepository) Update(_ context.Context, address domain.Address) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    addresses := r.sess.store.addresses
+    previous, ok := addresses[address.ID]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    addresses[address.ID] = cloneAddress(address)
+    r.sess.onRollback(func() { addresses[address.ID] = previous })
+    return nil
+}
+
+func (r *AddressRepository) Delete(_ context.Context, id string) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    addresses := r.sess.store.addresses
+    previous, ok := addresses[id]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    delete(addresses, id)
+    r.sess.onRollback(func() { addresses[id] = previous })
+    return nil
+}
+
+func (r *AddressRepository) GetByID(_ context.Context, id string) (domain.Address, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    address, ok := r.sess.store.addresses[id]
+    if !ok {
+        return domain.Address{}, repository.ErrNotFound
+    }
+    return cloneAddress(address), nil
+}
+
+func (r *AddressRepository) ListByUser(_ context.Context, userID string) ([]domain.Address, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    addresses := []domain.Address{}
+    for _, address := range r.sess.store.addresses {
+        if address.UserID == userID {
+            addresses = append(addresses, cloneAddress(address))
+        }
+    }
+    sort.Slice(addresses, func(i, j int) bool {
+        if !addresses[i].CreatedAt.Equal(addresses[j].CreatedAt) {
+            return addresses[i].CreatedAt.Before(addresses[j].CreatedAt)
+        }
+        return addresses[i].ID < addresses[j].ID
+    })
+    return addresses, nil
+}
+
+// PromotionRepository is an in-memory implementation of repository.PromotionRepository.
+type PromotionRepository struct {
+    sess *session
+}
+
+func (r *PromotionRepository) Create(_ context.Context, promotion domain.Promotion) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    promotions := r.sess.store.promotions
+    if _, exists := promotions[promotion.ID]; exists || r.codeTaken(promotion) {
+        return repository.ErrConflict
+    }
+    promotions[promotion.ID] = clonePromotion(promotion)
+    r.sess.onRollback(func() { delete(promotions, promotion.ID) })
+    return nil
+}
+
+func (r *PromotionRepository) Update(_ context.Context, promotion domain.Promotion) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    promotions := r.sess.store.promotions
+    previous, ok := promotions[promotion.ID]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    if r.codeTaken(promotion) {
+        return repository.ErrConflict
+    }
+    promotions[promotion.ID] = clonePromotion(promotion)
+    r.sess.onRollback(func() { promotions[promotion.ID] = previous })
+    return nil
+}
+
+// codeTaken reports whether another promotion already uses the promotion's
+// code. Callers must hold the store lock.
+func (r *PromotionRepository) codeTaken(promotion domain.Promotion) bool {
+    if promotion.Code == "" {
+        return false
+    }
+    for _, other := range r.sess.store.promotions {
+        if other.ID != promotion.ID && other.Code == promotion.Code {
+            return true
+        }
+    }
+    return false
+}
+
+func (r *PromotionRepository) Delete(_ context.Context, id string) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    promotions := r.sess.store.promotions
+    previous, ok := promotions[id]
+    if !ok {
+        return repository.ErrNotFound
+    }
+    delete(promotions, id)
+    r.sess.onRollback(func() { promotions[id] = previous })
+    return nil
+}
+
+func (r *PromotionRepository) GetByID(_ context.Context, id string) (domain.Promotion, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    promotion, ok := r.sess.store.promotions[id]
+    if !ok {
+        return domain.Promotion{}, repository.ErrNotFound
+    }
+    return r.withRedemptions(promotion), nil
+}
+
+func (r *PromotionRepository) GetByCode(_ context.Context, code string) (domain.Promotion, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    for _, promotion := range r.sess.store.promotions {
+        if promotion.Code != "" && promotion.Code == code {
+            return r.withRedemptions(promotion), nil
+        }
+    }
+    return domain.Promotion{}, repository.ErrNotFound
+}
+
+func (r *PromotionRepository) List(_ context.Context) ([]domain.Promotion, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    promotions := make([]domain.Promotion, 0, len(r.sess.store.promotions))
+    for _, promotion := range r.sess.store.promotions {
+        promotions = append(promotions, r.withRedemptions(promotion))
+    }
+    sort.Slice(promotions, func(i, j int) bool {
+        if !promotions[i].CreatedAt.Equal(promotions[j].CreatedAt) {
+            return promotions[i].CreatedAt.After(promotions[j].CreatedAt)
+        }
+        return promotions[i].ID < promotions[j].ID
+    })
+    return promotions, nil
+}
+
+// withRedemptions returns a copy of promotion with its redemptions counted.
+// Callers must hold the store lock.
+func (r *PromotionRepository) withRedemptions(promotion domain.Promotion) domain.Promotion {
+    promotion = clonePromotion(promotion)
+    promotion.Redemptions = 0
+    for _, redemption := range r.sess.store.redemptions {
+        if redemption.PromotionID == promotion.ID {
+            promotion.Redemptions++
+        }
+    }
+    return promotion
+}
+
+func (r *PromotionRepository) Redeem(_ context.Context, redemption domain.PromotionRedemption) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    store := r.sess.store
+    if _, ok := store.promotions[redemption.PromotionID]; !ok {
+        return repository.ErrNotFound
+    }
+    n := len(store.redemptions)
+    store.redemptions = append(store.redemptions, redemption)
+    r.sess.onRollback(func() { store.redemptions = store.redemptions[:n] })
+    return nil
+}
+
+func (r *PromotionRepository) CountUserRedemptions(_ context.Context, promotionID, userID string) (int, error) {
+    r.sess.rlock()
+    defer r.sess.runlock()
+
+    count := 0
+    for _, redemption := range r.sess.store.redemptions {
+        if redemption.PromotionID == promotionID && redemption.UserID == userID {
+            count++
+        }
+    }
+    return count, nil
+}
+
+func (r *PromotionRepository) ReleaseOrder(_ context.Context, orderID string) error {
+    r.sess.lock()
+    defer r.sess.unlock()
+
+    store := r.sess.store
+    previous := store.redemptions
+    store.redemptions = slices.DeleteFunc(slices.Clone(previous), func(redemption domain.PromotionRedemption) bool {
+        return redemption.OrderID == orderID
+    })
+    r.sess.onRollback(func() { store.redemptions = previous })
+    return nil
+}
+
+// cloneUser copies the slices and pointers held by a user.
+func cloneUser(user domain.User) domain.User {
+    user.RecoveryCodeHashes = slices.Clone(user.RecoveryCodeHashes)
+    if user.MFALockedUntil != nil {
+        lockedUntil := *user.MFALockedUntil
+        user.MFALockedUntil = &lockedUntil
+    }
+    if user.DeletedAt != nil {
+        deletedAt := *user.DeletedAt
+        user.DeletedAt = &deletedAt
+    }
+    return user
+}
+
+// cloneAPIKey copies the slices and pointers held by an API key.
+func cloneAPIKey(key domain.APIKey) domain.APIKey {
+    key.Scopes = slices.Clone(key.Scopes)
+    if key.ExpiresAt != nil {
+        expiresAt := *key.ExpiresAt
+        key.ExpiresAt = &expiresAt
+    }
+    if key.LastUsedAt != nil {
+        lastUsedAt := *key.LastUsedAt
+        key.LastUsedAt = &lastUsedAt
+    }
+    if key.RevokedAt != nil {
+        revokedAt := *key.RevokedAt
+        key.RevokedAt = &revokedAt
+    }
+    return key
+}
+
+// cloneRefreshToken copies the pointers held by a refresh token.
+func cloneRefreshToken(token domain.RefreshToken) domain.RefreshToken {
+    if token.RevokedAt != nil {
+        revokedAt := *token.RevokedAt
+        token.RevokedAt = &revokedAt
+    }
+    return token
+}
+
+// cloneAccountToken copies the pointers held by an account token.
+func cloneAccountToken(token domain.AccountToken) domain.AccountToken {
+    if token.UsedAt != nil {
+        usedAt := *token.UsedAt
+        token.UsedAt = &usedAt
+    }
+    return token
+}
+
+// cloneProduct copies the options and variants held by a product.
+func cloneProduct(product domain.Product) domain.Product {
+    product.Options = slices.Clone(product.Options)
+    for i, option := range product.Options {
+        product.Options[i].Values = slices.Clone(option.Values)
+    }
+    product.Variants = slices.Clone(product.Variants)
+    for i, variant := range product.Variants {
+        product.Variants[i].Options = maps.Clone(variant.Options)
+    }
+    return product
+}
+
+// cloneWarehouse copies the location held by a warehouse.
+func cloneWarehouse(warehouse domain.Warehouse) domain.Warehouse {
+    if warehouse.Location != nil {
+        location := *warehouse.Location
+        warehouse.Location = &location
+    }
+    return warehouse
+}
+
+// cloneReservation copies the pointers held by a reservation.
+func cloneReservation(reservation domain.Reservation) domain.Reservation {
+    if reservation.ResolvedAt != nil {
+        resolvedAt := *reservation.ResolvedAt
+        reservation.ResolvedAt = &resolvedAt
+    }
+    return reservation
+}
+
+// clonePromotion copies the slices and pointers held by a promotion.
+func clonePromotion(promotion domain.Promotion) domain.Promotion {
+    promotion.ProductIDs = slices.Clone(promotion.ProductIDs)
+    promotion.CategoryIDs = slices.Clone(promotion.CategoryIDs)
+    promotion.AmountOff = cloneMoney(promotion.AmountOff)
+    promotion.MinOrderValue = cloneMoney(promotion.MinOrderValue)
+    if promotion.StartsAt != nil {
+        startsAt := *promotion.StartsAt
+        promotion.StartsAt = &startsAt
+    }
+    if promotion.EndsAt != nil {
+        endsAt := *promotion.EndsAt
+        promotion.EndsAt = &endsAt
+    }
+    return promotion
+}
+
+// cloneCart copies the items held by a cart.
+func cloneCart(cart domain.Cart) domain.Cart {
+    cart.Items = slices.Clone(cart.Items)
+    return cart
+}
+
+// cloneOrder copies the slices held by an order so callers cannot mutate
+// stored state through a value they were handed.
+func cloneOrder(order domain.Order) domain.Order {
+    order.Items = slices.Clone(order.Items)
+    for i, item := range order.Items {
+        order.Items[i].UnitPrice = cloneMoney(item.UnitPrice)
+        order.Items[i].Subtotal = cloneMoney(item.Subtotal)
+        order.Items[i].Discounts = slices.Clone(item.Discounts)
+        order.Items[i].Total = cloneMoney(item.Total)
+    }
+    order.Subtotal = cloneMoney(order.Subtotal)
+    order.Discount = cloneMoney(order.Discount)
+    order.Shipping = cloneMoney(order.Shipping)
+    order.Promotions = slices.Clone(order.Promotions)
+    order.StatusHistory = slices.Clone(order.StatusHistory)
+    order.ExchangeRates = slices.Clone(order.ExchangeRates)
+    order.Allocations = slices.Clone(order.Allocations)
+    if order.Cancellation != nil {
+        cancellation := *order.Cancellation
+        order.Cancellation = &cancellation
+    }
+    if order.RefundDue != nil {
+        refund := *order.RefundDue
+        order.RefundDue = &refund
+    }
+    if order.ShippingAddress != nil {
+        address := clonePostalAddress(*order.ShippingAddress)
+        order.ShippingAddress = &address
+    }
+    if order.BillingAddress != nil {
+        address := clonePostalAddress(*order.BillingAddress)
+        order.BillingAddress = &address
+    }
+    return order
+}
+
+// cloneMoney copies an optional amount.
+func cloneMoney(m *domain.Money) *domain.Money {
+    if m == nil {
+        return nil
+    }
+    c := *m
+    return &c
+}
+
+// cloneAddress copies the pointers held by an address book entry.
+func cloneAddress(address domain.Address) domain.Address {
+    address.PostalAddress = clonePostalAddress(address.PostalAddress)
+    return address
+}
+
+// clonePostalAddress copies the location held by a postal address.
+func clonePostalAddress(address domain.PostalAddress) domain.PostalAddress {
+    if address.Location != nil {
+        location := *address.Location
+        address.Location = &location
+    }
+    return address
+}
+
+// cloneInvoice copies the slices and pointers held by an invoice.
+func cloneInvoice(invoice domain.Invoice) domain.Invoice {
+    invoice.Payments = slices.Clone(invoice.Payments)
+    if invoice.Rate != nil {
+        rate := *invoice.Rate
+        invoice.Rate = &rate
+    }
+    if invoice.PaidAt != nil {
+        paidAt := *invoice.PaidAt
+        invoice.PaidAt = &paidAt
+    }
+    return invoice
 }
@@ -0,0 +1,116 @@
+package memory
+
+import (
+    "cmp"
+    "slices"
+    "strings"
+
+    "cryptotrade/internal/repository"
+)
+
+// sortKeys maps each sortable field to the values an item sorts by for it.
+// Values are strings or int64s.
+type sortKeys[T any] map[string]func(T) []any
+
+// paginate orders items by the requested sort, breaking ties by ID, and
+// returns the page that follows the query's cursor.
+func paginate[T any](items []T, opts repository.ListOptions, def []repository.SortField, keys sortKeys[T], id func(T) string) (repository.Page[T], error) {
+    order := opts.OrderBy(def)
+    var after []any
+    if opts.Cursor != "" {
+        var err error
+        if after, err = opts.CursorKeys(order); err != nil {
+            return repository.Page[T]{}, err
+        }
+    }
+
+    var desc []bool
+    keyed := make([]keyedItem[T], 0, len(items))
+    for _, item := range items {
+        var key []any
+        desc = desc[:0]
+        for _, field := range order {
+            values := keys[field.Field](item)
+            key = append(key, values...)
+            for range values {
+                desc = append(desc, field.Desc)
+            }
+        }
+        key = append(key, id(item))
+        desc = append(desc, false)
+        keyed = append(keyed, keyedItem[T]{item: item, key: key})
+    }
+    slices.SortFunc(keyed, func(a, b keyedItem[T]) int {
+        return compareKeys(a.key, b.key, desc)
+    })
+
+    start := 0
+    if after != nil && len(keyed) > 0 {
+        if !sameKinds(after, keyed[0].key) {
+            return repository.Page[T]{}, repository.ErrInvalidCursor
+        }
+        start, _ = slices.BinarySearchFunc(keyed, after, func(item keyedItem[T], target []any) int {
+            if compareKeys(item.key, target, desc) <= 0 {
+                return -1
+            }
+            return 1
+        })
+    }
+
+    page := repository.Page[T]{Items: make([]T, 0, opts.PageSize())}
+    end := min(start+opts.PageSize(), len(keyed))
+    for _, k := range keyed[start:end] {
+        page.Items = append(page.Items, k.item)
+    }
+    if end < len(keyed) {
+        page.NextCursor = repository.NewCursor(order, keyed[end-1].key)
+    }
+    return page, nil
+}
+
+type keyedItem[T any] struct {
+    item T
+    key  []any
+}
+
+func compareKeys(a, b []any, desc []bool) int {
+    for i := range a {
+        var c int
+        switch x := a[i].(type) {
+        case string:
+            c = strings.Compare(x, b[i].(string))
+        case int64:
+            c = cmp.Compare(x, b[i].(int64))
+        }
+        if c != 0 {
+            if desc[i] {
+                return -c
+            }
+            return c
+        }
+    }
+    return 0
+}
+
+// sameKinds reports whether a cursor's keys line up with an item's key, so
+// that a cursor forged for another sort cannot be compared against it.
+func sameKinds(a, b []any) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        switch a[i].(type) {
+        case string:
+            if _, ok := b[i].(string); !ok {
+                return false
+            }
+        case int64:
+            if _, ok := b[i].(int64); !ok {
+                return false
+            }
+        default:
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+    "bytes"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "slices"
+    "strings"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+// ErrInvalidCursor is returned when a cursor is malformed or was issued for a
+// different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+    // DefaultLimit is the page size used when a query does not set one.
+    DefaultLimit = 50
+    // MaxLimit is the largest page size a query may request.
+    MaxLimit = 200
+)
+
+// Sortable fields for each list query. Results are always ordered by ID
+// after the requested fields so that pages are stable.
+var (
+    ProductSortFields = []string{"name", "price", "stock", "created_at"}
+    UserSortFields    = []string{"name", "email", "created_at"}
+    OrderSortFields   = []string{"created_at", "total", "status"}
+)
+
+// Default orders used when a query does not request one.
+var (
+    DefaultProductSort = []SortField{{Field: "name"}}
+    DefaultUserSort    = []SortField{{Field: "email"}}
+    DefaultOrderSort   = []SortField{{Field: "created_at"}}
+)
+
+// SortField orders list results by one field.
+type SortField struct {
+    Field string
+    Desc  bool
+}
+
+// ParseSort parses a comma-separated sort expression such as
+// "price,-created_at"; a leading '-' sorts that field in descending order.
+func ParseSort(expr string) ([]SortField, error) {
+    if strings.TrimSpace(expr) == "" {
+        return nil, nil
+    }
+    var fields []SortField
+    for _, part := range strings.Split(expr, ",") {
+        part = strings.TrimSpace(part)
+        field := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+        if field.Field == "" {
+            return nil, fmt.Errorf("sort %q has an empty field", expr)
+        }
+        fields = append(fields, field)
+    }
+    return fields, nil
+}
+
+// FormatSort renders fields in the syntax accepted by ParseSort.
+func FormatSort(fields []SortField) string {
+    parts := make([]string, len(fields))
+    for i, field := range fields {
+        parts[i] = field.Field
+        if field.Desc {
+            parts[i] = "-" + field.Field
+        }
+    }
+    return strings.Join(parts, ",")
+}
+
+// ListOptions carries the paging and sorting shared by every list query.
+type ListOptions struct {
+    // Limit caps the number of items returned; zero means DefaultLimit.
+    Limit int
+    // Cursor continues a listing from the page that returned it as
+    // NextCursor. It is only valid with the same Sort.
+    Cursor string
+    // Sort orders the results; empty means the entity's default order.
+    Sort []SortField
+}
+
+// validate checks the options against the fields the entity can be sorted
+// by and its default order.
+func (o ListOptions) validate(sortable []string, def []SortField) error {
+    if o.Limit < 0 || o.Limit > MaxLimit {
+        return fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+    }
+    seen := make(map[string]bool, len(o.Sort))
+    for _, field := range o.Sort {
+        if !slices.Contains(sortable, field.Field) {
+            return fmt.Errorf("cannot sort by %q; sortable fields are %s", field.Field, strings.Join(sortable, ", "))
+        }
+        if seen[field.Field] {
+            return fmt.Errorf("cannot sort by %q more than once", field.Field)
+        }
+        seen[field.Field] = true
+    }
+    if o.Cursor != "" {
+        if _, err := o.CursorKeys(o.OrderBy(def)); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// PageSize returns the effective limit.
+func (o ListOptions) PageSize() int {
+    if o.Limit == 0 {
+        return DefaultLimit
+    }
+    return o.Limit
+}
+
+// OrderBy returns the requested sort, or def when none was requested.
+func (o ListOptions) OrderBy(def []SortField) []SortField {
+    if len(o.Sort) == 0 {
+        return def
+    }
+    return o.Sort
+}
+
+// cursor is the decoded form of a page cursor: the sort it was issued for
+// and the sort key of the last item on the page.
+type cursor struct {
+    Sort string `json:"s"`
+    Keys []any  `json:"k"`
+}
+
+// NewCursor encodes the sort key of the last item on a page. Keys are the
+// item's values for each sort column followed by its ID, and must be strings
+// or integers.
+func NewCursor(sort []SortField, keys []any) string {
+    data, _ := json.Marshal(cursor{Sort: FormatSort(sort), Keys: keys})
+    return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// CursorKeys decodes the options' cursor, which must have been issued for
+// sort. Integer keys are returned as int64.
+func (o ListOptions) CursorKeys(sort []SortField) ([]any, error) {
+    data, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+    if err != nil {
+        return nil, ErrInvalidCursor
+    }
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.UseNumber()
+    var c cursor
+    if err := dec.Decode(&c); err != nil || len(c.Keys) == 0 {
+        return nil, ErrInvalidCursor
+    }
+    if c.Sort != FormatSort(sort) {
+        return nil, fmt.Errorf("%w: it was issued for sort %q", ErrInvalidCursor, c.Sort)
+    }
+    for i, key := range c.Keys {
+        switch k := key.(type) {
+        case string:
+        case json.Number:
+            n, err := k.Int64()
+            if err != nil {
+                return nil, ErrInvalidCursor
+            }
+            c.Keys[i] = n
+        default:
+            return nil, ErrInvalidCursor
+        }
+    }
+    return c.Keys, nil
+}
+
+// Page is one page of list results.
+type Page[T any] struct {
+    Items []T `json:"items"`
+    // NextCursor fetches the following page; it is empty on the last page.
+    NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ProductQuery selects products to list.
+type ProductQuery struct {
+    ListOptions
+    // PriceCurrency keeps only products priced in that currency.
+    PriceCurrency string
+    // MinPrice and MaxPrice bound the price, inclusive, in minor units of
+    // PriceCurrency.
+    MinPrice *int64
+    MaxPrice *int64
+    // InStock keeps only products with units available to order.
+    InStock bool
+    // CategoryIDs keeps only products assigned to at least one of the
+    // categories.
+    CategoryIDs []string
+}
+
+// Validate ensures the query can be run.
+func (q ProductQuery) Validate() error {
+    if err := q.validate(ProductSortFields, DefaultProductSort); err != nil {
+        return err
+    }
+    if (q.MinPrice != nil || q.MaxPrice != nil) && q.PriceCurrency == "" {
+        return errors.New("a price range requires a price currency")
+    }
+    if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+        return errors.New("minimum price cannot exceed maximum price")
+    }
+    return nil
+}
+
+// UserQuery selects users to list.
+type UserQuery struct {
+    ListOptions
+    // Email keeps only the user with that address.
+    Email string
+}
+
+// Validate ensures the query can be run.
+func (q UserQuery) Validate() error {
+    return q.validate(UserSortFields, DefaultUserSort)
+}
+
+// OrderQuery selects orders to list.
+type OrderQuery struct {
+    ListOptions
+    UserID string
+    Status domain.OrderStatus
+    // CreatedFrom and CreatedTo bound the creation time to the half-open
+    // range [CreatedFrom, CreatedTo); a zero time leaves that side open.
+    CreatedFrom time.Time
+    CreatedTo   time.Time
+    // RefundDue selects only orders with a payment waiting to be refunded.
+    RefundDue bool
+}
+
+// Validate ensures the query can be run.
+func (q OrderQuery) Validate() error {
+    if err := q.validate(OrderSortFields, DefaultOrderSort); err != nil {
+        return err
+    }
+    if q.Status != "" && !q.Status.Valid() {
+        return fmt.Errorf("unknown order status %q", q.Status)
+    }
+    if !q.CreatedFrom.IsZero() && !q.CreatedTo.IsZero() && !q.CreatedFrom.Before(q.CreatedTo) {
+        return errors.New("created_from must be before created_to")
+    }
+    return nil
+}
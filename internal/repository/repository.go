@@ -3,6 +3,7 @@ package repository
 import (
     "context"
     "errors"
+    "time"
 
     "cryptotrade/internal/domain"
 )
@@ -14,12 +15,17 @@ var ErrNotFound = errors.New("entity not found")
 var ErrConflict = errors.New("entity already exists")
 
 // ProductRepository describes the persistence operations for products.
+// Variants are stored with their product; Create and Update return
+// ErrConflict when a variant SKU already belongs to another product.
 type ProductRepository interface {
     Create(ctx context.Context, product domain.Product) error
     Update(ctx context.Context, product domain.Product) error
     Delete(ctx context.Context, id string) error
     GetByID(ctx context.Context, id string) (domain.Product, error)
-    List(ctx context.Context) ([]domain.Product, error)
+    // GetBySKU returns the product with a variant of the given SKU.
+    GetBySKU(ctx context.Context, sku string) (domain.Product, error)
+    // List returns a page of the products matching query.
+    List(ctx context.Context, query ProductQuery) (Page[domain.Product], error)
 }
 
 // UserRepository describes persistence operations for users.
@@ -27,12 +33,200 @@ type UserRepository interface {
     Create(ctx context.Context, user domain.User) error
     GetByID(ctx context.Context, id string) (domain.User, error)
     GetByEmail(ctx context.Context, email string) (domain.User, error)
-    List(ctx context.Context) ([]domain.User, error)
+    // Update replaces a stored user; it returns ErrConflict when the email
+    // belongs to another user.
+    Update(ctx context.Context, user domain.User) error
+    // SetRole changes a user's role.
+    SetRole(ctx context.Context, id string, role domain.Role) error
+    // List returns a page of the users matching query.
+    List(ctx context.Context, query UserQuery) (Page[domain.User], error)
 }
 
 // OrderRepository describes persistence operations for orders.
 type OrderRepository interface {
     Create(ctx context.Context, order domain.Order) error
+    Update(ctx context.Context, order domain.Order) error
     GetByID(ctx context.Context, id string) (domain.Order, error)
-    List(ctx context.Context) ([]domain.Order, error)
+    // List returns a page of the orders matching query.
+    List(ctx context.Context, query OrderQuery) (Page[domain.Order], error)
+}
+
+// InvoiceRepository describes persistence operations for payment invoices.
+type InvoiceRepository interface {
+    Create(ctx context.Context, invoice domain.Invoice) error
+    Update(ctx context.Context, invoice domain.Invoice) error
+    GetByID(ctx context.Context, id string) (domain.Invoice, error)
+    GetByOrderID(ctx context.Context, orderID string) (domain.Invoice, error)
+    // GetByPaymentHash finds the Lightning invoice with the given hex payment hash.
+    GetByPaymentHash(ctx context.Context, paymentHash string) (domain.Invoice, error)
+    // ListPending returns invoices still awaiting settlement, oldest first.
+    ListPending(ctx context.Context) ([]domain.Invoice, error)
+    // NextDerivationIndex returns the lowest address index not yet used by an
+    // on-chain invoice in currency. Call it inside a transaction so concurrent
+    // invoices cannot be handed the same address.
+    NextDerivationIndex(ctx context.Context, currency string) (uint32, error)
+    // TransactionClaimant returns the ID of the on-chain invoice in currency
+    // that recorded the transaction txHash as one of its payments, or
+    // ErrNotFound when none has.
+    TransactionClaimant(ctx context.Context, currency, txHash string) (string, error)
+}
+
+// CartRepository describes persistence operations for shopping carts. Each
+// user has at most one cart.
+type CartRepository interface {
+    // Get returns the user's cart, or ErrNotFound when none has been saved.
+    Get(ctx context.Context, userID string) (domain.Cart, error)
+    // Save creates or replaces the user's cart.
+    Save(ctx context.Context, cart domain.Cart) error
+    // Delete removes the user's cart; deleting a missing cart is not an error.
+    Delete(ctx context.Context, userID string) error
+}
+
+// ReservationRepository describes persistence operations for stock reservations.
+type ReservationRepository interface {
+    Create(ctx context.Context, reservation domain.Reservation) error
+    Update(ctx context.Context, reservation domain.Reservation) error
+    // ListByOrder returns the reservations placed for an order, oldest first.
+    ListByOrder(ctx context.Context, orderID string) ([]domain.Reservation, error)
+    // ListExpired returns held reservations that expire at or before now, oldest first.
+    ListExpired(ctx context.Context, now time.Time) ([]domain.Reservation, error)
+}
+
+// WarehouseRepository describes persistence operations for warehouses.
+type WarehouseRepository interface {
+    Create(ctx context.Context, warehouse domain.Warehouse) error
+    Update(ctx context.Context, warehouse domain.Warehouse) error
+    GetByID(ctx context.Context, id string) (domain.Warehouse, error)
+    // List returns all warehouses ordered by priority, then name.
+    List(ctx context.Context) ([]domain.Warehouse, error)
+}
+
+// StockLevelRepository describes persistence operations for per-warehouse stock.
+type StockLevelRepository interface {
+    // Get returns the level of a product, or of its variant sku, at a
+    // warehouse, or ErrNotFound when the warehouse has never stocked it.
+    Get(ctx context.Context, warehouseID, productID, sku string) (domain.StockLevel, error)
+    // Save creates or replaces a level. The warehouse must exist.
+    Save(ctx context.Context, level domain.StockLevel) error
+    // ListByProduct returns a product's levels across warehouses and variants.
+    ListByProduct(ctx context.Context, productID string) ([]domain.StockLevel, error)
+    // ListByWarehouse returns every level held at a warehouse.
+    ListByWarehouse(ctx context.Context, warehouseID string) ([]domain.StockLevel, error)
+}
+
+// StockMovementRepository describes persistence operations for the stock
+// ledger. Movements are append-only and are never updated or deleted.
+type StockMovementRepository interface {
+    Create(ctx context.Context, movement domain.StockMovement) error
+    // ListByProduct returns a product's movements in the order they were recorded.
+    ListByProduct(ctx context.Context, productID string) ([]domain.StockMovement, error)
+}
+
+// CategoryRepository describes persistence operations for categories and
+// their product assignments.
+type CategoryRepository interface {
+    // Create stores a new category; names must be unique among siblings.
+    Create(ctx context.Context, category domain.Category) error
+    Update(ctx context.Context, category domain.Category) error
+    Delete(ctx context.Context, id string) error
+    GetByID(ctx context.Context, id string) (domain.Category, error)
+    // List returns every category ordered by name.
+    List(ctx context.Context) ([]domain.Category, error)
+    // ListByProduct returns the categories a product is assigned to, ordered by name.
+    ListByProduct(ctx context.Context, productID string) ([]domain.Category, error)
+    // SetProductCategories replaces the categories a product is assigned to.
+    SetProductCategories(ctx context.Context, productID string, categoryIDs []string) error
+    // CountProducts returns the number of products assigned to a category.
+    CountProducts(ctx context.Context, categoryID string) (int, error)
+}
+
+// RefreshTokenRepository stores issued refresh tokens so that they can be
+// rotated and revoked.
+type RefreshTokenRepository interface {
+    Create(ctx context.Context, token domain.RefreshToken) error
+    Update(ctx context.Context, token domain.RefreshToken) error
+    GetByID(ctx context.Context, id string) (domain.RefreshToken, error)
+    // RevokeFamily revokes every token of the family that is not revoked yet.
+    RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+    // RevokeUser revokes every token of the user that is not revoked yet.
+    RevokeUser(ctx context.Context, userID string, at time.Time) error
+}
+
+// PromotionRepository describes persistence operations for promotions and
+// their redemptions. Promotions are returned with Redemptions counted from
+// the redemption records.
+type PromotionRepository interface {
+    // Create stores a new promotion; it returns ErrConflict when the code
+    // belongs to another promotion.
+    Create(ctx context.Context, promotion domain.Promotion) error
+    Update(ctx context.Context, promotion domain.Promotion) error
+    Delete(ctx context.Context, id string) error
+    GetByID(ctx context.Context, id string) (domain.Promotion, error)
+    // GetByCode finds the promotion with a normalised coupon code.
+    GetByCode(ctx context.Context, code string) (domain.Promotion, error)
+    // List returns every promotion, newest first.
+    List(ctx context.Context) ([]domain.Promotion, error)
+    // Redeem records that a promotion was applied to an order.
+    Redeem(ctx context.Context, redemption domain.PromotionRedemption) error
+    // CountUserRedemptions returns how many orders of a user the promotion was applied to.
+    CountUserRedemptions(ctx context.Context, promotionID, userID string) (int, error)
+    // ReleaseOrder deletes the redemptions recorded for an order.
+    ReleaseOrder(ctx context.Context, orderID string) error
+}
+
+// AddressRepository describes persistence operations for address books.
+type AddressRepository interface {
+    Create(ctx context.Context, address domain.Address) error
+    Update(ctx context.Context, address domain.Address) error
+    Delete(ctx context.Context, id string) error
+    GetByID(ctx context.Context, id string) (domain.Address, error)
+    // ListByUser returns the user's addresses, oldest first.
+    ListByUser(ctx context.Context, userID string) ([]domain.Address, error)
+}
+
+// AccountTokenRepository stores the one-time tokens mailed to users.
+type AccountTokenRepository interface {
+    Create(ctx context.Context, token domain.AccountToken) error
+    GetByID(ctx context.Context, id string) (domain.AccountToken, error)
+    // UseAll marks every unused token of the user for purpose as used.
+    UseAll(ctx context.Context, userID string, purpose domain.AccountTokenPurpose, at time.Time) error
+}
+
+// APIKeyRepository describes persistence operations for API keys.
+type APIKeyRepository interface {
+    // Create stores a new key; it returns ErrConflict when the prefix is taken.
+    Create(ctx context.Context, key domain.APIKey) error
+    Update(ctx context.Context, key domain.APIKey) error
+    GetByID(ctx context.Context, id string) (domain.APIKey, error)
+    GetByPrefix(ctx context.Context, prefix string) (domain.APIKey, error)
+    // List returns every key, newest first.
+    List(ctx context.Context) ([]domain.APIKey, error)
+}
+
+// Repositories groups the repositories that can take part in a transaction.
+type Repositories struct {
+    Products       ProductRepository
+    Users          UserRepository
+    Orders         OrderRepository
+    Invoices       InvoiceRepository
+    Carts          CartRepository
+    Reservations   ReservationRepository
+    Warehouses     WarehouseRepository
+    StockLevels    StockLevelRepository
+    StockMovements StockMovementRepository
+    Categories     CategoryRepository
+    RefreshTokens  RefreshTokenRepository
+    APIKeys        APIKeyRepository
+    AccountTokens  AccountTokenRepository
+    Addresses      AddressRepository
+    Promotions     PromotionRepository
+}
+
+// TxManager runs units of work atomically against a storage backend.
+type TxManager interface {
+    // WithinTx calls fn with repositories bound to a single transaction. The
+    // transaction commits when fn returns nil and rolls back otherwise. fn must
+    // only use the repositories it is given; touching the backend through any
+    // other handle while the transaction is open may block.
+    WithinTx(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error
 }
@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+// AccountTokenRepository is a SQLite implementation of repository.AccountTokenRepository.
+type AccountTokenRepository struct {
+    db dbtx
+}
+
+func (r *AccountTokenRepository) Create(ctx context.Context, token domain.AccountToken) error {
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO account_tokens (id, user_id, purpose, email, expires_at, created_at, used_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+        token.ID, token.UserID, string(token.Purpose), token.Email,
+        formatTime(token.ExpiresAt), formatTime(token.CreatedAt), nullTime(token.UsedAt))
+    return mapError(err)
+}
+
+func (r *AccountTokenRepository) GetByID(ctx context.Context, id string) (domain.AccountToken, error) {
+    var (
+        token                         domain.AccountToken
+        purpose, expiresAt, createdAt string
+        usedAt                        sql.NullString
+    )
+    err := r.db.QueryRowContext(ctx,
+        `SELECT id, user_id, purpose, email, expires_at, created_at, used_at FROM account_tokens WHERE id = ?`, id).
+        Scan(&token.ID, &token.UserID, &purpose, &token.Email, &expiresAt, &createdAt, &usedAt)
+    if err != nil {
+        return domain.AccountToken{}, mapError(err)
+    }
+    token.Purpose = domain.AccountTokenPurpose(purpose)
+    if token.ExpiresAt, err = parseTime(expiresAt); err != nil {
+        return domain.AccountToken{}, fmt.Errorf("decode account token expires_at: %w", err)
+    }
+    if token.CreatedAt, err = parseTime(createdAt); err != nil {
+        return domain.AccountToken{}, fmt.Errorf("decode account token created_at: %w", err)
+    }
+    if token.UsedAt, err = parseNullTime(usedAt); err != nil {
+        return domain.AccountToken{}, fmt.Errorf("decode account token used_at: %w", err)
+    }
+    return token, nil
+}
+
+func (r *AccountTokenRepository) UseAll(ctx context.Context, userID string, purpose domain.AccountTokenPurpose, at time.Time) error {
+    _, err := r.db.ExecContext(ctx,
+        `UPDATE account_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL`,
+        formatTime(at), userID, string(purpose))
+    return mapError(err)
+}
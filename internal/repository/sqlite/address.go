@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+
+    "cryptotrade/internal/domain"
+)
+
+// AddressRepository is a SQLite implementation of repository.AddressRepository.
+type AddressRepository struct {
+    db dbtx
+}
+
+const selectAddressSQL = `SELECT id, user_id, type, name, company, line1, line2, city, region, postal_code, country, phone,
+    latitude, longitude, created_at, updated_at FROM addresses`
+
+func (r *AddressRepository) Create(ctx context.Context, address domain.Address) error {
+    lat, lon := nullLocation(address.Location)
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO addresses (id, user_id, type, name, company, line1, line2, city, region, postal_code, country, phone,
+        latitude, longitude, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        address.ID, address.UserID, string(address.Type), address.Name, address.Company, address.Line1, address.Line2,
+        address.City, address.Region, address.PostalCode, address.Country, address.Phone,
+        lat, lon, formatTime(address.CreatedAt), formatTime(address.UpdatedAt))
+    return mapError(err)
+}
+
+func (r *AddressRepository) Update(ctx context.Context, address domain.Address) error {
+    lat, lon := nullLocation(address.Location)
+    res, err := r.db.ExecContext(ctx,
+        `UPDATE addresses SET type = ?, name = ?, company = ?, line1 = ?, line2 = ?, city = ?, region = ?, postal_code = ?,
+        country = ?, phone = ?, latitude = ?, longitude = ?, updated_at = ? WHERE id = ?`,
+        string(address.Type), address.Name, address.Company, address.Line1, address.Line2, address.City, address.Region,
+        address.PostalCode, address.Country, address.Phone, lat, lon, formatTime(address.UpdatedAt), address.ID)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *AddressRepository) Delete(ctx context.Context, id string) error {
+    res, err := r.db.ExecContext(ctx, `DELETE FROM addresses WHERE id = ?`, id)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *AddressRepository) GetByID(ctx context.Context, id string) (domain.Address, error) {
+    address, err := scanAddress(r.db.QueryRowContext(ctx, selectAddressSQL+` WHERE id = ?`, id))
+    if err != nil {
+        return domain.Address{}, mapError(err)
+    }
+    return address, nil
+}
+
+func (r *AddressRepository) ListByUser(ctx context.Context, userID string) ([]domain.Address, error) {
+    rows, err := r.db.QueryContext(ctx, selectAddressSQL+` WHERE user_id = ? ORDER BY created_at, id`, userID)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    defer rows.Close()
+
+    addresses := make([]domain.Address, 0)
+    for rows.Next() {
+        address, err := scanAddress(rows)
+        if err != nil {
+            return nil, err
+        }
+        addresses = append(addresses, address)
+    }
+    return addresses, rows.Err()
+}
+
+func scanAddress(s scanner) (domain.Address, error) {
+    var (
+        address                           domain.Address
+        addressType, createdAt, updatedAt string
+        lat, lon                          sql.NullFloat64
+    )
+    if err := s.Scan(&address.ID, &address.UserID, &addressType, &address.Name, &address.Company, &address.Line1,
+        &address.Line2, &address.City, &address.Region, &address.PostalCode, &address.Country, &address.Phone,
+        &lat, &lon, &createdAt, &updatedAt); err != nil {
+        return domain.Address{}, err
+    }
+    address.Type = domain.AddressType(addressType)
+    if lat.Valid && lon.Valid {
+        address.Location = &domain.GeoPoint{Latitude: lat.Float64, Longitude: lon.Float64}
+    }
+    var err error
+    if address.CreatedAt, err = parseTime(createdAt); err != nil {
+        return domain.Address{}, fmt.Errorf("decode address created_at: %w", err)
+    }
+    if address.UpdatedAt, err = parseTime(updatedAt); err != nil {
+        return domain.Address{}, fmt.Errorf("decode address updated_at: %w", err)
+    }
+    return address, nil
+}
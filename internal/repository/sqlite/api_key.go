@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+
+    "cryptotrade/internal/domain"
+)
+
+// APIKeyRepository is a SQLite implementation of repository.APIKeyRepository.
+type APIKeyRepository struct {
+    db dbtx
+}
+
+const selectAPIKeySQL = `SELECT id, name, prefix, hash, scopes, created_by, expires_at, last_used_at, revoked_at, created_at FROM api_keys`
+
+func (r *APIKeyRepository) Create(ctx context.Context, key domain.APIKey) error {
+    scopes, err := json.Marshal(key.Scopes)
+    if err != nil {
+        return fmt.Errorf("encode api key scopes: %w", err)
+    }
+    _, err = r.db.ExecContext(ctx,
+        `INSERT INTO api_keys (id, name, prefix, hash, scopes, created_by, expires_at, last_used_at, revoked_at, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        key.ID, key.Name, key.Prefix, key.Hash, string(scopes), key.CreatedBy,
+        nullTime(key.ExpiresAt), nullTime(key.LastUsedAt), nullTime(key.RevokedAt), formatTime(key.CreatedAt))
+    return mapError(err)
+}
+
+func (r *APIKeyRepository) Update(ctx context.Context, key domain.APIKey) error {
+    scopes, err := json.Marshal(key.Scopes)
+    if err != nil {
+        return fmt.Errorf("encode api key scopes: %w", err)
+    }
+    res, err := r.db.ExecContext(ctx,
+        `UPDATE api_keys SET name = ?, prefix = ?, hash = ?, scopes = ?, created_by = ?, expires_at = ?, last_used_at = ?, revoked_at = ?, created_at = ?
+        WHERE id = ?`,
+        key.Name, key.Prefix, key.Hash, string(scopes), key.CreatedBy,
+        nullTime(key.ExpiresAt), nullTime(key.LastUsedAt), nullTime(key.RevokedAt), formatTime(key.CreatedAt), key.ID)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *APIKeyRepository) GetByID(ctx context.Context, id string) (domain.APIKey, error) {
+    return r.get(ctx, selectAPIKeySQL+` WHERE id = ?`, id)
+}
+
+func (r *APIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (domain.APIKey, error) {
+    return r.get(ctx, selectAPIKeySQL+` WHERE prefix = ?`, prefix)
+}
+
+func (r *APIKeyRepository) List(ctx context.Context) ([]domain.APIKey, error) {
+    rows, err := r.db.QueryContext(ctx, selectAPIKeySQL+` ORDER BY created_at DESC, id`)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    defer rows.Close()
+
+    keys := make([]domain.APIKey, 0)
+    for rows.Next() {
+        key, err := scanAPIKey(rows)
+        if err != nil {
+            return nil, err
+        }
+        keys = append(keys, key)
+    }
+    return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) get(ctx context.Context, query string, arg any) (domain.APIKey, error) {
+    key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, arg))
+    if err != nil {
+        return domain.APIKey{}, mapError(err)
+    }
+    return key, nil
+}
+
+func scanAPIKey(s scanner) (domain.APIKey, error) {
+    var (
+        key                              domain.APIKey
+        scopes, createdAt                string
+        expiresAt, lastUsedAt, revokedAt sql.NullString
+    )
+    if err := s.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.CreatedBy,
+        &expiresAt, &lastUsedAt, &revokedAt, &createdAt); err != nil {
+        return domain.APIKey{}, err
+    }
+    if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+        return domain.APIKey{}, fmt.Errorf("decode api key scopes: %w", err)
+    }
+    var err error
+    if key.CreatedAt, err = parseTime(createdAt); err != nil {
+        return domain.APIKey{}, fmt.Errorf("decode api key created_at: %w", err)
+    }
+    if key.ExpiresAt, err = parseNullTime(expiresAt); err != nil {
+        return domain.APIKey{}, fmt.Errorf("decode api key expires_at: %w", err)
+    }
+    if key.LastUsedAt, err = parseNullTime(lastUsedAt); err != nil {
+        return domain.APIKey{}, fmt.Errorf("decode api key last_used_at: %w", err)
+    }
+    if key.RevokedAt, err = parseNullTime(revokedAt); err != nil {
+        return domain.APIKey{}, fmt.Errorf("decode api key revoked_at: %w", err)
+    }
+    return key, nil
+}
@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+
+    "cryptotrade/internal/domain"
+)
+
+// CartRepository is a SQLite implementation of repository.CartRepository.
+type CartRepository struct {
+    db dbtx
+}
+
+func (r *CartRepository) Get(ctx context.Context, userID string) (domain.Cart, error) {
+    var items, updatedAt string
+    err := r.db.QueryRowContext(ctx,
+        `SELECT items, updated_at FROM carts WHERE user_id = ?`, userID).Scan(&items, &updatedAt)
+    if err != nil {
+        return domain.Cart{}, mapError(err)
+    }
+
+    cart := domain.Cart{UserID: userID}
+    if err := json.Unmarshal([]byte(items), &cart.Items); err != nil {
+        return domain.Cart{}, fmt.Errorf("decode cart items: %w", err)
+    }
+    if cart.UpdatedAt, err = parseTime(updatedAt); err != nil {
+        return domain.Cart{}, fmt.Errorf("decode cart updated_at: %w", err)
+    }
+    return cart, nil
+}
+
+func (r *CartRepository) Save(ctx context.Context, cart domain.Cart) error {
+    items, err := json.Marshal(cart.Items)
+    if err != nil {
+        return fmt.Errorf("encode cart items: %w", err)
+    }
+
+    _, err = r.db.ExecContext(ctx,
+        `INSERT INTO carts (user_id, items, updated_at) VALUES (?, ?, ?)
+        ON CONFLICT (user_id) DO UPDATE SET items = excluded.items, updated_at = excluded.updated_at`,
+        cart.UserID, string(items), formatTime(cart.UpdatedAt))
+    return mapError(err)
+}
+
+func (r *CartRepository) Delete(ctx context.Context, userID string) error {
+    _, err := r.db.ExecContext(ctx, `DELETE FROM carts WHERE user_id = ?`, userID)
+    return mapError(err)
+}
@@ -0,0 +1,110 @@
+package sqlite
+
+import (
+    "context"
+    "fmt"
+
+    "cryptotrade/internal/domain"
+)
+
+// CategoryRepository is a SQLite implementation of repository.CategoryRepository.
+type CategoryRepository struct {
+    db dbtx
+}
+
+const selectCategorySQL = `SELECT id, name, parent_id, created_at FROM categories`
+
+func (r *CategoryRepository) Create(ctx context.Context, category domain.Category) error {
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO categories (id, name, parent_id, created_at) VALUES (?, ?, ?, ?)`,
+        category.ID, category.Name, category.ParentID, formatTime(category.CreatedAt))
+    return mapError(err)
+}
+
+func (r *CategoryRepository) Update(ctx context.Context, category domain.Category) error {
+    res, err := r.db.ExecContext(ctx,
+        `UPDATE categories SET name = ?, parent_id = ?, created_at = ? WHERE id = ?`,
+        category.Name, category.ParentID, formatTime(category.CreatedAt), category.ID)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *CategoryRepository) Delete(ctx context.Context, id string) error {
+    res, err := r.db.ExecContext(ctx, `DELETE FROM categories WHERE id = ?`, id)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *CategoryRepository) GetByID(ctx context.Context, id string) (domain.Category, error) {
+    category, err := scanCategory(r.db.QueryRowContext(ctx, selectCategorySQL+` WHERE id = ?`, id))
+    if err != nil {
+        return domain.Category{}, mapError(err)
+    }
+    return category, nil
+}
+
+func (r *CategoryRepository) List(ctx context.Context) ([]domain.Category, error) {
+    return r.list(ctx, selectCategorySQL+` ORDER BY name, id`)
+}
+
+func (r *CategoryRepository) ListByProduct(ctx context.Context, productID string) ([]domain.Category, error) {
+    return r.list(ctx, selectCategorySQL+` WHERE id IN (SELECT category_id FROM product_categories WHERE product_id = ?) ORDER BY name, id`, productID)
+}
+
+func (r *CategoryRepository) list(ctx context.Context, query string, args ...any) ([]domain.Category, error) {
+    rows, err := r.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    defer rows.Close()
+
+    categories := make([]domain.Category, 0)
+    for rows.Next() {
+        category, err := scanCategory(rows)
+        if err != nil {
+            return nil, err
+        }
+        categories = append(categories, category)
+    }
+    return categories, rows.Err()
+}
+
+func (r *CategoryRepository) SetProductCategories(ctx context.Context, productID string, categoryIDs []string) error {
+    if _, err := r.db.ExecContext(ctx, `DELETE FROM product_categories WHERE product_id = ?`, productID); err != nil {
+        return mapError(err)
+    }
+    for _, categoryID := range categoryIDs {
+        _, err := r.db.ExecContext(ctx,
+            `INSERT INTO product_categories (product_id, category_id) VALUES (?, ?) ON CONFLICT DO NOTHING`, productID, categoryID)
+        if err != nil {
+            return mapError(err)
+        }
+    }
+    return nil
+}
+
+func (r *CategoryRepository) CountProducts(ctx context.Context, categoryID string) (int, error) {
+    var n int
+    err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM product_categories WHERE category_id = ?`, categoryID).Scan(&n)
+    return n, mapError(err)
+}
+
+func scanCategory(s scanner) (domain.Category, error) {
+    var (
+        category  domain.Category
+        createdAt string
+    )
+    if err := s.Scan(&category.ID, &category.Name, &category.ParentID, &createdAt); err != nil {
+        return domain.Category{}, err
+    }
+    t, err := parseTime(createdAt)
+    if err != nil {
+        return domain.Category{}, fmt.Errorf("decode category created_at: %w", err)
+    }
+    category.CreatedAt = t
+    return category, nil
+}
@@ -0,0 +1,224 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "strings"
+
+    "cryptotrade/internal/domain"
+)
+
+// InvoiceRepository is a SQLite implementation of repository.InvoiceRepository.
+type InvoiceRepository struct {
+    db dbtx
+}
+
+// invoiceColumns lists the invoice columns in the order used by invoiceValues and scanInvoice.
+var invoiceColumns = []string{
+    "id", "order_id", "method", "amount", "currency", "address", "derivation_path", "derivation_index",
+    "payment_request", "payment_hash", "rate", "status", "received_amount", "payments", "paid_at",
+    "expires_at", "created_at",
+}
+
+var (
+    selectInvoiceSQL = `SELECT ` + strings.Join(invoiceColumns, ", ") + ` FROM invoices`
+    insertInvoiceSQL = `INSERT INTO invoices (` + strings.Join(invoiceColumns, ", ") + `) VALUES (?` + strings.Repeat(", ?", len(invoiceColumns)-1) + `)`
+    updateInvoiceSQL = `UPDATE invoices SET ` + strings.Join(invoiceColumns[1:], " = ?, ") + ` = ? WHERE id = ?`
+)
+
+func (r *InvoiceRepository) Create(ctx context.Context, invoice domain.Invoice) error {
+    values, err := invoiceValues(invoice)
+    if err != nil {
+        return err
+    }
+
+    if _, err := r.db.ExecContext(ctx, insertInvoiceSQL, values...); err != nil {
+        return mapError(err)
+    }
+    return r.claimTransactions(ctx, invoice)
+}
+
+func (r *InvoiceRepository) Update(ctx context.Context, invoice domain.Invoice) error {
+    values, err := invoiceValues(invoice)
+    if err != nil {
+        return err
+    }
+
+    res, err := r.db.ExecContext(ctx, updateInvoiceSQL, append(values[1:], invoice.ID)...)
+    if err != nil {
+        return mapError(err)
+    }
+    if err := requireAffected(res); err != nil {
+        return err
+    }
+    return r.claimTransactions(ctx, invoice)
+}
+
+// claimTransactions records the transactions paying an on-chain invoice. A
+// transaction another invoice already claimed keeps its first claimant.
+func (r *InvoiceRepository) claimTransactions(ctx context.Context, invoice domain.Invoice) error {
+    if invoice.Method != domain.PaymentMethodOnChain {
+        return nil
+    }
+    for _, p := range invoice.Payments {
+        _, err := r.db.ExecContext(ctx,
+            `INSERT OR IGNORE INTO invoice_transactions (currency, tx_hash, invoice_id) VALUES (?, ?, ?)`,
+            invoice.Amount.Currency, p.TxHash, invoice.ID)
+        if err != nil {
+            return mapError(err)
+        }
+    }
+    return nil
+}
+
+func (r *InvoiceRepository) GetByID(ctx context.Context, id string) (domain.Invoice, error) {
+    invoice, err := scanInvoice(r.db.QueryRowContext(ctx, selectInvoiceSQL+` WHERE id = ?`, id))
+    if err != nil {
+        return domain.Invoice{}, mapError(err)
+    }
+    return invoice, nil
+}
+
+func (r *InvoiceRepository) GetByOrderID(ctx context.Context, orderID string) (domain.Invoice, error) {
+    invoice, err := scanInvoice(r.db.QueryRowContext(ctx, selectInvoiceSQL+` WHERE order_id = ?`, orderID))
+    if err != nil {
+        return domain.Invoice{}, mapError(err)
+    }
+    return invoice, nil
+}
+
+func (r *InvoiceRepository) GetByPaymentHash(ctx context.Context, paymentHash string) (domain.Invoice, error) {
+    invoice, err := scanInvoice(r.db.QueryRowContext(ctx, selectInvoiceSQL+` WHERE payment_hash = ?`, paymentHash))
+    if err != nil {
+        return domain.Invoice{}, mapError(err)
+    }
+    return invoice, nil
+}
+
+func (r *InvoiceRepository) ListPending(ctx context.Context) ([]domain.Invoice, error) {
+    rows, err := r.db.QueryContext(ctx, selectInvoiceSQL+` WHERE status = ? ORDER BY created_at, id`, string(domain.InvoiceStatusPending))
+    if err != nil {
+        return nil, mapError(err)
+    }
+    defer rows.Close()
+
+    invoices := make([]domain.Invoice, 0)
+    for rows.Next() {
+        invoice, err := scanInvoice(rows)
+        if err != nil {
+            return nil, err
+        }
+        invoices = append(invoices, invoice)
+    }
+    return invoices, rows.Err()
+}
+
+func (r *InvoiceRepository) NextDerivationIndex(ctx context.Context, currency string) (uint32, error) {
+    var next int64
+    err := r.db.QueryRowContext(ctx,
+        `SELECT COALESCE(MAX(derivation_index) + 1, 0) FROM invoices WHERE currency = ? AND method = ?`,
+        currency, string(domain.PaymentMethodOnChain)).Scan(&next)
+    if err != nil {
+        return 0, mapError(err)
+    }
+    return uint32(next), nil
+}
+
+func (r *InvoiceRepository) TransactionClaimant(ctx context.Context, currency, txHash string) (string, error) {
+    var invoiceID string
+    err := r.db.QueryRowContext(ctx,
+        `SELECT invoice_id FROM invoice_transactions WHERE currency = ? AND tx_hash = ?`, currency, txHash).Scan(&invoiceID)
+    if err != nil {
+        return "", mapError(err)
+    }
+    return invoiceID, nil
+}
+
+// invoiceValues flattens an invoice into column values matching invoiceColumns.
+func invoiceValues(invoice domain.Invoice) ([]any, error) {
+    var rate sql.NullString
+    if invoice.Rate != nil {
+        encoded, err := json.Marshal(invoice.Rate)
+        if err != nil {
+            return nil, fmt.Errorf("encode invoice rate: %w", err)
+        }
+        rate = sql.NullString{String: string(encoded), Valid: true}
+    }
+    payments, err := json.Marshal(invoice.Payments)
+    if err != nil {
+        return nil, fmt.Errorf("encode invoice payments: %w", err)
+    }
+    var paidAt sql.NullString
+    if invoice.PaidAt != nil {
+        paidAt = sql.NullString{String: formatTime(*invoice.PaidAt), Valid: true}
+    }
+
+    // Address columns are NULL for Lightning invoices and the payment request
+    // columns NULL for on-chain ones, so the UNIQUE constraints ignore them.
+    var address, path, request, hash sql.NullString
+    var index sql.NullInt64
+    if invoice.Method == domain.PaymentMethodLightning {
+        request = sql.NullString{String: invoice.PaymentRequest, Valid: true}
+        hash = sql.NullString{String: invoice.PaymentHash, Valid: true}
+    } else {
+        address = sql.NullString{String: invoice.Address, Valid: true}
+        path = sql.NullString{String: invoice.DerivationPath, Valid: true}
+        index = sql.NullInt64{Int64: int64(invoice.DerivationIndex), Valid: true}
+    }
+
+    return []any{
+        invoice.ID, invoice.OrderID, string(invoice.Method), invoice.Amount.Amount, invoice.Amount.Currency,
+        address, path, index, request, hash, rate, string(invoice.Status),
+        invoice.Received.Amount, string(payments), paidAt, formatTime(invoice.ExpiresAt), formatTime(invoice.CreatedAt),
+    }, nil
+}
+
+func scanInvoice(s scanner) (domain.Invoice, error) {
+    var (
+        invoice                                        domain.Invoice
+        index                                          sql.NullInt64
+        address, path, request, hash, rate, paidAt     sql.NullString
+        method, status, payments, expiresAt, createdAt string
+    )
+    if err := s.Scan(&invoice.ID, &invoice.OrderID, &method, &invoice.Amount.Amount, &invoice.Amount.Currency,
+        &address, &path, &index, &request, &hash, &rate, &status, &invoice.Received.Amount, &payments, &paidAt,
+        &expiresAt, &createdAt); err != nil {
+        return domain.Invoice{}, err
+    }
+    invoice.Method = domain.PaymentMethod(method)
+    invoice.Address = address.String
+    invoice.DerivationPath = path.String
+    invoice.DerivationIndex = uint32(index.Int64)
+    invoice.PaymentRequest = request.String
+    invoice.PaymentHash = hash.String
+    invoice.Received.Currency = invoice.Amount.Currency
+    if err := json.Unmarshal([]byte(payments), &invoice.Payments); err != nil {
+        return domain.Invoice{}, fmt.Errorf("decode invoice payments: %w", err)
+    }
+    if paidAt.Valid {
+        t, err := parseTime(paidAt.String)
+        if err != nil {
+            return domain.Invoice{}, fmt.Errorf("decode invoice paid_at: %w", err)
+        }
+        invoice.PaidAt = &t
+    }
+
+    invoice.Status = domain.InvoiceStatus(status)
+    if rate.Valid {
+        invoice.Rate = new(domain.ExchangeRate)
+        if err := json.Unmarshal([]byte(rate.String), invoice.Rate); err != nil {
+            return domain.Invoice{}, fmt.Errorf("decode invoice rate: %w", err)
+        }
+    }
+
+    var err error
+    if invoice.ExpiresAt, err = parseTime(expiresAt); err != nil {
+        return domain.Invoice{}, fmt.Errorf("decode invoice expires_at: %w", err)
+    }
+    if invoice.CreatedAt, err = parseTime(createdAt); err != nil {
+        return domain.Invoice{}, fmt.Errorf("decode invoice created_at: %w", err)
+    }
+    return invoice, nil
+}
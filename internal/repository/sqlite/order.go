@@ -0,0 +1,252 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "strings"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// OrderRepository is a SQLite implementation of repository.OrderRepository.
+// Line items, warehouse allocations, status history, exchange rate and address snapshots, applied promotions and
+// any refund due are always read and written with their order, so they are stored as JSON documents alongside the order row. The
+// subtotal, discount and shipping amounts share the currency of the total.
+type OrderRepository struct {
+    db dbtx
+}
+
+// orderColumns lists the order columns in the order used by orderValues and scanOrder.
+var orderColumns = []string{
+    "id", "user_id", "items", "total_amount", "total_currency", "exchange_rates", "status", "status_history",
+    "cancelled_by", "cancel_reason", "cancelled_at", "invoice_id", "created_at", "allocations",
+    "shipping_address", "billing_address", "subtotal_amount", "discount_amount", "shipping_amount", "promotions",
+    "refund_due",
+}
+
+var (
+    selectOrderSQL = `SELECT ` + strings.Join(orderColumns, ", ") + ` FROM orders`
+    insertOrderSQL = `INSERT INTO orders (` + strings.Join(orderColumns, ", ") + `) VALUES (?` + strings.Repeat(", ?", len(orderColumns)-1) + `)`
+    updateOrderSQL = `UPDATE orders SET ` + strings.Join(orderColumns[1:], " = ?, ") + ` = ? WHERE id = ?`
+)
+
+func (r *OrderRepository) Create(ctx context.Context, order domain.Order) error {
+    values, err := orderValues(order)
+    if err != nil {
+        return err
+    }
+
+    _, err = r.db.ExecContext(ctx, insertOrderSQL, values...)
+    return mapError(err)
+}
+
+func (r *OrderRepository) Update(ctx context.Context, order domain.Order) error {
+    values, err := orderValues(order)
+    if err != nil {
+        return err
+    }
+
+    res, err := r.db.ExecContext(ctx, updateOrderSQL, append(values[1:], order.ID)...)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *OrderRepository) GetByID(ctx context.Context, id string) (domain.Order, error) {
+    row := r.db.QueryRowContext(ctx, selectOrderSQL+` WHERE id = ?`, id)
+    order, err := scanOrder(row)
+    if err != nil {
+        return domain.Order{}, mapError(err)
+    }
+    return order, nil
+}
+
+var orderSortFields = map[string]sortField[domain.Order]{
+    "created_at": {[]string{"created_at"}, func(o domain.Order) []any { return []any{formatTime(o.CreatedAt)} }},
+    "total":      {[]string{"total_currency", "total_amount"}, func(o domain.Order) []any { return []any{o.Total.Currency, o.Total.Amount} }},
+    "status":     {[]string{"status"}, func(o domain.Order) []any { return []any{string(o.Status)} }},
+}
+
+func (r *OrderRepository) List(ctx context.Context, query repository.OrderQuery) (repository.Page[domain.Order], error) {
+    q := pageQuery[domain.Order]{
+        selectSQL: selectOrderSQL,
+        sortable:  orderSortFields,
+        id:        func(o domain.Order) string { return o.ID },
+        scan:      scanOrder,
+    }
+    if query.UserID != "" {
+        q.filters = append(q.filters, "user_id = ?")
+        q.args = append(q.args, query.UserID)
+    }
+    if query.Status != "" {
+        q.filters = append(q.filters, "status = ?")
+        q.args = append(q.args, string(query.Status))
+    }
+    if !query.CreatedFrom.IsZero() {
+        q.filters = append(q.filters, "created_at >= ?")
+        q.args = append(q.args, formatTime(query.CreatedFrom))
+    }
+    if !query.CreatedTo.IsZero() {
+        q.filters = append(q.filters, "created_at < ?")
+        q.args = append(q.args, formatTime(query.CreatedTo))
+    }
+    if query.RefundDue {
+        q.filters = append(q.filters, "refund_due IS NOT NULL")
+    }
+    return q.list(ctx, r.db, query.ListOptions, repository.DefaultOrderSort)
+}
+
+// orderValues flattens an order into column values matching orderColumns.
+func orderValues(order domain.Order) ([]any, error) {
+    items, err := json.Marshal(order.Items)
+    if err != nil {
+        return nil, fmt.Errorf("encode order items: %w", err)
+    }
+    history, err := json.Marshal(order.StatusHistory)
+    if err != nil {
+        return nil, fmt.Errorf("encode order status history: %w", err)
+    }
+    rates, err := json.Marshal(order.ExchangeRates)
+    if err != nil {
+        return nil, fmt.Errorf("encode order exchange rates: %w", err)
+    }
+    allocations, err := json.Marshal(order.Allocations)
+    if err != nil {
+        return nil, fmt.Errorf("encode order allocations: %w", err)
+    }
+
+    promotions, err := json.Marshal(order.Promotions)
+    if err != nil {
+        return nil, fmt.Errorf("encode order promotions: %w", err)
+    }
+
+    shippingAddress, err := nullJSON(order.ShippingAddress)
+    if err != nil {
+        return nil, fmt.Errorf("encode order shipping address: %w", err)
+    }
+    billingAddress, err := nullJSON(order.BillingAddress)
+    if err != nil {
+        return nil, fmt.Errorf("encode order billing address: %w", err)
+    }
+    refundDue, err := nullJSON(order.RefundDue)
+    if err != nil {
+        return nil, fmt.Errorf("encode order refund due: %w", err)
+    }
+
+    var cancelledBy, cancelReason, cancelledAt sql.NullString
+    if c := order.Cancellation; c != nil {
+        cancelledBy = sql.NullString{String: c.By, Valid: true}
+        cancelReason = sql.NullString{String: c.Reason, Valid: true}
+        cancelledAt = sql.NullString{String: formatTime(c.At), Valid: true}
+    }
+
+    return []any{
+        order.ID, order.UserID, string(items), order.Total.Amount, order.Total.Currency, string(rates), string(order.Status), string(history),
+        cancelledBy, cancelReason, cancelledAt, order.InvoiceID, formatTime(order.CreatedAt), string(allocations),
+        shippingAddress, billingAddress, nullAmount(order.Subtotal), nullAmount(order.Discount), nullAmount(order.Shipping), string(promotions),
+        refundDue,
+    }, nil
+}
+
+type scanner interface {
+    Scan(dest ...any) error
+}
+
+func scanOrder(s scanner) (domain.Order, error) {
+    var (
+        order                                    domain.Order
+        items, rates, status, history, createdAt string
+        allocations, promotions                  string
+        cancelledBy, cancelReason, cancelledAt   sql.NullString
+        shippingAddress, billingAddress          sql.NullString
+        refundDue                                sql.NullString
+        subtotal, discount, shipping             sql.NullInt64
+    )
+    if err := s.Scan(&order.ID, &order.UserID, &items, &order.Total.Amount, &order.Total.Currency, &rates, &status, &history,
+        &cancelledBy, &cancelReason, &cancelledAt, &order.InvoiceID, &createdAt, &allocations,
+        &shippingAddress, &billingAddress, &subtotal, &discount, &shipping, &promotions,
+        &refundDue); err != nil {
+        return domain.Order{}, err
+    }
+    if err := json.Unmarshal([]byte(items), &order.Items); err != nil {
+        return domain.Order{}, fmt.Errorf("decode order items: %w", err)
+    }
+    if err := json.Unmarshal([]byte(history), &order.StatusHistory); err != nil {
+        return domain.Order{}, fmt.Errorf("decode order status history: %w", err)
+    }
+    if err := json.Unmarshal([]byte(rates), &order.ExchangeRates); err != nil {
+        return domain.Order{}, fmt.Errorf("decode order exchange rates: %w", err)
+    }
+    if err := json.Unmarshal([]byte(allocations), &order.Allocations); err != nil {
+        return domain.Order{}, fmt.Errorf("decode order allocations: %w", err)
+    }
+    if err := json.Unmarshal([]byte(promotions), &order.Promotions); err != nil {
+        return domain.Order{}, fmt.Errorf("decode order promotions: %w", err)
+    }
+    order.Subtotal = parseNullAmount(subtotal, order.Total.Currency)
+    order.Discount = parseNullAmount(discount, order.Total.Currency)
+    order.Shipping = parseNullAmount(shipping, order.Total.Currency)
+    if shippingAddress.Valid {
+        if err := json.Unmarshal([]byte(shippingAddress.String), &order.ShippingAddress); err != nil {
+            return domain.Order{}, fmt.Errorf("decode order shipping address: %w", err)
+        }
+    }
+    if billingAddress.Valid {
+        if err := json.Unmarshal([]byte(billingAddress.String), &order.BillingAddress); err != nil {
+            return domain.Order{}, fmt.Errorf("decode order billing address: %w", err)
+        }
+    }
+    if refundDue.Valid {
+        if err := json.Unmarshal([]byte(refundDue.String), &order.RefundDue); err != nil {
+            return domain.Order{}, fmt.Errorf("decode order refund due: %w", err)
+        }
+    }
+    t, err := parseTime(createdAt)
+    if err != nil {
+        return domain.Order{}, fmt.Errorf("decode order created_at: %w", err)
+    }
+    order.Status = domain.OrderStatus(status)
+    order.CreatedAt = t
+
+    if cancelledAt.Valid {
+        at, err := parseTime(cancelledAt.String)
+        if err != nil {
+            return domain.Order{}, fmt.Errorf("decode order cancelled_at: %w", err)
+        }
+        order.Cancellation = &domain.OrderCancellation{By: cancelledBy.String, Reason: cancelReason.String, At: at}
+    }
+    return order, nil
+}
+
+// nullJSON encodes a snapshot held by pointer, or NULL when it is nil.
+func nullJSON[T any](v *T) (sql.NullString, error) {
+    if v == nil {
+        return sql.NullString{}, nil
+    }
+    encoded, err := json.Marshal(v)
+    if err != nil {
+        return sql.NullString{}, err
+    }
+    return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+// nullAmount stores an optional amount held in the order's total currency.
+func nullAmount(m *domain.Money) sql.NullInt64 {
+    if m == nil {
+        return sql.NullInt64{}
+    }
+    return sql.NullInt64{Int64: m.Amount, Valid: true}
+}
+
+// parseNullAmount reassembles an amount written by nullAmount.
+func parseNullAmount(amount sql.NullInt64, currency string) *domain.Money {
+    if !amount.Valid {
+        return nil
+    }
+    m := domain.NewMoney(amount.Int64, currency)
+    return &m
+}
@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+    "context"
+    "strings"
+
+    "cryptotrade/internal/repository"
+)
+
+// sortField describes how results are ordered by one sortable field: the
+// columns compared, and the values of those columns for a loaded item,
+// which become the page cursor.
+type sortField[T any] struct {
+    columns []string
+    key     func(T) []any
+}
+
+// pageQuery is a list query: a SELECT without WHERE clause, its filters and
+// how each sortable field is ordered.
+type pageQuery[T any] struct {
+    selectSQL string
+    filters   []string
+    args      []any
+    sortable  map[string]sortField[T]
+    id        func(T) string
+    scan      func(scanner) (T, error)
+}
+
+// list runs q with keyset pagination: results are ordered by the requested
+// sort and then by id, and the cursor becomes a condition that skips every
+// row up to and including the last one already returned.
+func (q pageQuery[T]) list(ctx context.Context, db dbtx, opts repository.ListOptions, def []repository.SortField) (repository.Page[T], error) {
+    order := opts.OrderBy(def)
+    var (
+        columns []string
+        desc    []bool
+    )
+    for _, field := range order {
+        for _, column := range q.sortable[field.Field].columns {
+            columns = append(columns, column)
+            desc = append(desc, field.Desc)
+        }
+    }
+    columns = append(columns, "id")
+    desc = append(desc, false)
+
+    filters, args := q.filters, q.args
+    if opts.Cursor != "" {
+        after, err := opts.CursorKeys(order)
+        if err != nil {
+            return repository.Page[T]{}, err
+        }
+        if len(after) != len(columns) {
+            return repository.Page[T]{}, repository.ErrInvalidCursor
+        }
+        disjuncts := make([]string, len(columns))
+        for i, column := range columns {
+            var terms []string
+            for j := 0; j < i; j++ {
+                terms = append(terms, columns[j]+" = ?")
+                args = append(args, after[j])
+            }
+            op := " > ?"
+            if desc[i] {
+                op = " < ?"
+            }
+            terms = append(terms, column+op)
+            args = append(args, after[i])
+            disjuncts[i] = "(" + strings.Join(terms, " AND ") + ")"
+        }
+        filters = append(filters, "("+strings.Join(disjuncts, " OR ")+")")
+    }
+
+    query := q.selectSQL
+    if len(filters) > 0 {
+        query += " WHERE " + strings.Join(filters, " AND ")
+    }
+    orderBy := make([]string, len(columns))
+    for i, column := range columns {
+        orderBy[i] = column
+        if desc[i] {
+            orderBy[i] += " DESC"
+        }
+    }
+    query += " ORDER BY " + strings.Join(orderBy, ", ") + " LIMIT ?"
+    // One row beyond the page tells whether another page follows.
+    args = append(args, opts.PageSize()+1)
+
+    rows, err := db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return repository.Page[T]{}, mapError(err)
+    }
+    defer rows.Close()
+
+    page := repository.Page[T]{Items: make([]T, 0, opts.PageSize())}
+    more := false
+    for rows.Next() {
+        if len(page.Items) == opts.PageSize() {
+            more = true
+            break
+        }
+        item, err := q.scan(rows)
+        if err != nil {
+            return repository.Page[T]{}, err
+        }
+        page.Items = append(page.Items, item)
+    }
+    if err := rows.Err(); err != nil {
+        return repository.Page[T]{}, err
+    }
+
+    if more {
+        last := page.Items[len(page.Items)-1]
+        var keys []any
+        for _, field := range order {
+            keys = append(keys, q.sortable[field.Field].key(last)...)
+        }
+        page.NextCursor = repository.NewCursor(order, append(keys, q.id(last)))
+    }
+    return page, nil
+}
@@ -0,0 +1,203 @@
+package sqlite
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// ProductRepository is a SQLite implementation of repository.ProductRepository.
+// Options are stored as a JSON document on the product row; variants have a
+// table of their own so that SKUs are unique across the catalog.
+type ProductRepository struct {
+    db dbtx
+}
+
+const selectProductSQL = `SELECT id, name, description, price_amount, price_currency, stock, reserved, options, created_at FROM products`
+
+var productSortFields = map[string]sortField[domain.Product]{
+    "name":       {[]string{"name"}, func(p domain.Product) []any { return []any{p.Name} }},
+    "price":      {[]string{"price_currency", "price_amount"}, func(p domain.Product) []any { return []any{p.Price.Currency, p.Price.Amount} }},
+    "stock":      {[]string{"stock"}, func(p domain.Product) []any { return []any{int64(p.Stock)} }},
+    "created_at": {[]string{"created_at"}, func(p domain.Product) []any { return []any{formatTime(p.CreatedAt)} }},
+}
+
+func (r *ProductRepository) Create(ctx context.Context, product domain.Product) error {
+    options, err := json.Marshal(product.Options)
+    if err != nil {
+        return fmt.Errorf("encode product options: %w", err)
+    }
+    _, err = r.db.ExecContext(ctx,
+        `INSERT INTO products (id, name, description, price_amount, price_currency, stock, reserved, options, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        product.ID, product.Name, product.Description, product.Price.Amount, product.Price.Currency, product.Stock, product.Reserved, string(options), formatTime(product.CreatedAt))
+    if err != nil {
+        return mapError(err)
+    }
+    return r.insertVariants(ctx, product)
+}
+
+func (r *ProductRepository) Update(ctx context.Context, product domain.Product) error {
+    options, err := json.Marshal(product.Options)
+    if err != nil {
+        return fmt.Errorf("encode product options: %w", err)
+    }
+    res, err := r.db.ExecContext(ctx,
+        `UPDATE products SET name = ?, description = ?, price_amount = ?, price_currency = ?, stock = ?, reserved = ?, options = ? WHERE id = ?`,
+        product.Name, product.Description, product.Price.Amount, product.Price.Currency, product.Stock, product.Reserved, string(options), product.ID)
+    if err != nil {
+        return mapError(err)
+    }
+    if err := requireAffected(res); err != nil {
+        return err
+    }
+    if _, err := r.db.ExecContext(ctx, `DELETE FROM product_variants WHERE product_id = ?`, product.ID); err != nil {
+        return mapError(err)
+    }
+    return r.insertVariants(ctx, product)
+}
+
+func (r *ProductRepository) Delete(ctx context.Context, id string) error {
+    if _, err := r.db.ExecContext(ctx, `DELETE FROM product_variants WHERE product_id = ?`, id); err != nil {
+        return mapError(err)
+    }
+    res, err := r.db.ExecContext(ctx, `DELETE FROM products WHERE id = ?`, id)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *ProductRepository) GetByID(ctx context.Context, id string) (domain.Product, error) {
+    return r.get(ctx, selectProductSQL+` WHERE id = ?`, id)
+}
+
+func (r *ProductRepository) GetBySKU(ctx context.Context, sku string) (domain.Product, error) {
+    return r.get(ctx, selectProductSQL+` WHERE id = (SELECT product_id FROM product_variants WHERE sku = ?)`, sku)
+}
+
+func (r *ProductRepository) get(ctx context.Context, query string, args ...any) (domain.Product, error) {
+    product, err := scanProduct(r.db.QueryRowContext(ctx, query, args...))
+    if err != nil {
+        return domain.Product{}, mapError(err)
+    }
+    products := []domain.Product{product}
+    if err := r.loadVariants(ctx, products); err != nil {
+        return domain.Product{}, err
+    }
+    return products[0], nil
+}
+
+func (r *ProductRepository) List(ctx context.Context, query repository.ProductQuery) (repository.Page[domain.Product], error) {
+    q := pageQuery[domain.Product]{
+        selectSQL: selectProductSQL,
+        sortable:  productSortFields,
+        id:        func(p domain.Product) string { return p.ID },
+        scan:      scanProduct,
+    }
+    if query.PriceCurrency != "" {
+        q.filters = append(q.filters, "price_currency = ?")
+        q.args = append(q.args, query.PriceCurrency)
+    }
+    if query.MinPrice != nil {
+        q.filters = append(q.filters, "price_amount >= ?")
+        q.args = append(q.args, *query.MinPrice)
+    }
+    if query.MaxPrice != nil {
+        q.filters = append(q.filters, "price_amount <= ?")
+        q.args = append(q.args, *query.MaxPrice)
+    }
+    if query.InStock {
+        q.filters = append(q.filters, "stock > reserved")
+    }
+    if len(query.CategoryIDs) > 0 {
+        q.filters = append(q.filters, "id IN (SELECT product_id FROM product_categories WHERE category_id IN ("+placeholders(len(query.CategoryIDs))+"))")
+        for _, id := range query.CategoryIDs {
+            q.args = append(q.args, id)
+        }
+    }
+    page, err := q.list(ctx, r.db, query.ListOptions, repository.DefaultProductSort)
+    if err != nil {
+        return repository.Page[domain.Product]{}, err
+    }
+    if err := r.loadVariants(ctx, page.Items); err != nil {
+        return repository.Page[domain.Product]{}, err
+    }
+    return page, nil
+}
+
+func (r *ProductRepository) insertVariants(ctx context.Context, product domain.Product) error {
+    for i, variant := range product.Variants {
+        options, err := json.Marshal(variant.Options)
+        if err != nil {
+            return fmt.Errorf("encode variant options: %w", err)
+        }
+        if _, err := r.db.ExecContext(ctx,
+            `INSERT INTO product_variants (sku, product_id, position, options, price_amount, price_currency, stock, reserved) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+            variant.SKU, product.ID, i, string(options), variant.Price.Amount, variant.Price.Currency, variant.Stock, variant.Reserved); err != nil {
+            return mapError(err)
+        }
+    }
+    return nil
+}
+
+// loadVariants fills in the variants of products with a single query.
+func (r *ProductRepository) loadVariants(ctx context.Context, products []domain.Product) error {
+    if len(products) == 0 {
+        return nil
+    }
+    index := make(map[string]int, len(products))
+    args := make([]any, len(products))
+    for i, product := range products {
+        index[product.ID] = i
+        args[i] = product.ID
+    }
+
+    rows, err := r.db.QueryContext(ctx,
+        `SELECT product_id, sku, options, price_amount, price_currency, stock, reserved FROM product_variants
+        WHERE product_id IN (`+placeholders(len(products))+`) ORDER BY product_id, position`, args...)
+    if err != nil {
+        return mapError(err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var (
+            productID, options string
+            variant            domain.Variant
+        )
+        if err := rows.Scan(&productID, &variant.SKU, &options, &variant.Price.Amount, &variant.Price.Currency, &variant.Stock, &variant.Reserved); err != nil {
+            return err
+        }
+        if err := json.Unmarshal([]byte(options), &variant.Options); err != nil {
+            return fmt.Errorf("decode variant options: %w", err)
+        }
+        i := index[productID]
+        products[i].Variants = append(products[i].Variants, variant)
+    }
+    return rows.Err()
+}
+
+func scanProduct(s scanner) (domain.Product, error) {
+    var (
+        product            domain.Product
+        options, createdAt string
+    )
+    if err := s.Scan(&product.ID, &product.Name, &product.Description, &product.Price.Amount, &product.Price.Currency, &product.Stock, &product.Reserved, &options, &createdAt); err != nil {
+        return domain.Product{}, err
+    }
+    if err := json.Unmarshal([]byte(options), &product.Options); err != nil {
+        return domain.Product{}, fmt.Errorf("decode product options: %w", err)
+    }
+    if len(product.Options) == 0 {
+        product.Options = nil
+    }
+    t, err := parseTime(createdAt)
+    if err != nil {
+        return domain.Product{}, fmt.Errorf("decode product created_at: %w", err)
+    }
+    product.CreatedAt = t
+    return product, nil
+}
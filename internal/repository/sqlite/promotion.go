@@ -0,0 +1,197 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+
+    "cryptotrade/internal/domain"
+)
+
+// PromotionRepository is a SQLite implementation of repository.PromotionRepository.
+type PromotionRepository struct {
+    db dbtx
+}
+
+const selectPromotionSQL = `SELECT id, code, name, description, type, percent_off, amount_off_amount, amount_off_currency,
+    buy_quantity, get_quantity, min_order_amount, min_order_currency, product_ids, category_ids, usage_limit, per_user_limit,
+    starts_at, ends_at, stackable, active, created_at, updated_at,
+    (SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = promotions.id) FROM promotions`
+
+func (r *PromotionRepository) Create(ctx context.Context, promotion domain.Promotion) error {
+    values, err := promotionValues(promotion)
+    if err != nil {
+        return err
+    }
+    _, err = r.db.ExecContext(ctx,
+        `INSERT INTO promotions (code, name, description, type, percent_off, amount_off_amount, amount_off_currency,
+        buy_quantity, get_quantity, min_order_amount, min_order_currency, product_ids, category_ids, usage_limit, per_user_limit,
+        starts_at, ends_at, stackable, active, created_at, updated_at, id)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        values...)
+    return mapError(err)
+}
+
+func (r *PromotionRepository) Update(ctx context.Context, promotion domain.Promotion) error {
+    values, err := promotionValues(promotion)
+    if err != nil {
+        return err
+    }
+    res, err := r.db.ExecContext(ctx,
+        `UPDATE promotions SET code = ?, name = ?, description = ?, type = ?, percent_off = ?, amount_off_amount = ?,
+        amount_off_currency = ?, buy_quantity = ?, get_quantity = ?, min_order_amount = ?, min_order_currency = ?,
+        product_ids = ?, category_ids = ?, usage_limit = ?, per_user_limit = ?, starts_at = ?, ends_at = ?,
+        stackable = ?, active = ?, created_at = ?, updated_at = ? WHERE id = ?`,
+        values...)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *PromotionRepository) Delete(ctx context.Context, id string) error {
+    res, err := r.db.ExecContext(ctx, `DELETE FROM promotions WHERE id = ?`, id)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *PromotionRepository) GetByID(ctx context.Context, id string) (domain.Promotion, error) {
+    return r.get(ctx, selectPromotionSQL+` WHERE id = ?`, id)
+}
+
+func (r *PromotionRepository) GetByCode(ctx context.Context, code string) (domain.Promotion, error) {
+    return r.get(ctx, selectPromotionSQL+` WHERE code = ?`, code)
+}
+
+func (r *PromotionRepository) List(ctx context.Context) ([]domain.Promotion, error) {
+    rows, err := r.db.QueryContext(ctx, selectPromotionSQL+` ORDER BY created_at DESC, id`)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    defer rows.Close()
+
+    promotions := make([]domain.Promotion, 0)
+    for rows.Next() {
+        promotion, err := scanPromotion(rows)
+        if err != nil {
+            return nil, err
+        }
+        promotions = append(promotions, promotion)
+    }
+    return promotions, rows.Err()
+}
+
+func (r *PromotionRepository) Redeem(ctx context.Context, redemption domain.PromotionRedemption) error {
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO promotion_redemptions (promotion_id, user_id, order_id, created_at) VALUES (?, ?, ?, ?)`,
+        redemption.PromotionID, redemption.UserID, redemption.OrderID, formatTime(redemption.CreatedAt))
+    return mapError(err)
+}
+
+func (r *PromotionRepository) CountUserRedemptions(ctx context.Context, promotionID, userID string) (int, error) {
+    var count int
+    err := r.db.QueryRowContext(ctx,
+        `SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = ? AND user_id = ?`, promotionID, userID).Scan(&count)
+    if err != nil {
+        return 0, mapError(err)
+    }
+    return count, nil
+}
+
+func (r *PromotionRepository) ReleaseOrder(ctx context.Context, orderID string) error {
+    _, err := r.db.ExecContext(ctx, `DELETE FROM promotion_redemptions WHERE order_id = ?`, orderID)
+    return mapError(err)
+}
+
+func (r *PromotionRepository) get(ctx context.Context, query string, arg any) (domain.Promotion, error) {
+    promotion, err := scanPromotion(r.db.QueryRowContext(ctx, query, arg))
+    if err != nil {
+        return domain.Promotion{}, mapError(err)
+    }
+    return promotion, nil
+}
+
+// promotionValues flattens a promotion into the column values shared by
+// Create and Update, ending with the ID.
+func promotionValues(p domain.Promotion) ([]any, error) {
+    productIDs, err := json.Marshal(p.ProductIDs)
+    if err != nil {
+        return nil, fmt.Errorf("encode promotion product ids: %w", err)
+    }
+    categoryIDs, err := json.Marshal(p.CategoryIDs)
+    if err != nil {
+        return nil, fmt.Errorf("encode promotion category ids: %w", err)
+    }
+    var code sql.NullString
+    if p.Code != "" {
+        code = sql.NullString{String: p.Code, Valid: true}
+    }
+    amountOff, amountOffCurrency := nullMoney(p.AmountOff)
+    minOrder, minOrderCurrency := nullMoney(p.MinOrderValue)
+
+    return []any{
+        code, p.Name, p.Description, string(p.Type), p.PercentOff, amountOff, amountOffCurrency,
+        p.BuyQuantity, p.GetQuantity, minOrder, minOrderCurrency, string(productIDs), string(categoryIDs), p.UsageLimit, p.PerUserLimit,
+        nullTime(p.StartsAt), nullTime(p.EndsAt), p.Stackable, p.Active, formatTime(p.CreatedAt), formatTime(p.UpdatedAt), p.ID,
+    }, nil
+}
+
+func scanPromotion(s scanner) (domain.Promotion, error) {
+    var (
+        p                                      domain.Promotion
+        code, amountOffCurrency                sql.NullString
+        minOrderCurrency, startsAt, endsAt     sql.NullString
+        amountOff, minOrder                    sql.NullInt64
+        promotionType, productIDs, categoryIDs string
+        createdAt, updatedAt                   string
+    )
+    if err := s.Scan(&p.ID, &code, &p.Name, &p.Description, &promotionType, &p.PercentOff, &amountOff, &amountOffCurrency,
+        &p.BuyQuantity, &p.GetQuantity, &minOrder, &minOrderCurrency, &productIDs, &categoryIDs, &p.UsageLimit, &p.PerUserLimit,
+        &startsAt, &endsAt, &p.Stackable, &p.Active, &createdAt, &updatedAt, &p.Redemptions); err != nil {
+        return domain.Promotion{}, err
+    }
+    p.Code = code.String
+    p.Type = domain.PromotionType(promotionType)
+    p.AmountOff = parseNullMoney(amountOff, amountOffCurrency)
+    p.MinOrderValue = parseNullMoney(minOrder, minOrderCurrency)
+    if err := json.Unmarshal([]byte(productIDs), &p.ProductIDs); err != nil {
+        return domain.Promotion{}, fmt.Errorf("decode promotion product ids: %w", err)
+    }
+    if err := json.Unmarshal([]byte(categoryIDs), &p.CategoryIDs); err != nil {
+        return domain.Promotion{}, fmt.Errorf("decode promotion category ids: %w", err)
+    }
+    var err error
+    if p.StartsAt, err = parseNullTime(startsAt); err != nil {
+        return domain.Promotion{}, fmt.Errorf("decode promotion starts_at: %w", err)
+    }
+    if p.EndsAt, err = parseNullTime(endsAt); err != nil {
+        return domain.Promotion{}, fmt.Errorf("decode promotion ends_at: %w", err)
+    }
+    if p.CreatedAt, err = parseTime(createdAt); err != nil {
+        return domain.Promotion{}, fmt.Errorf("decode promotion created_at: %w", err)
+    }
+    if p.UpdatedAt, err = parseTime(updatedAt); err != nil {
+        return domain.Promotion{}, fmt.Errorf("decode promotion updated_at: %w", err)
+    }
+    return p, nil
+}
+
+// nullMoney splits an optional amount into nullable amount and currency columns.
+func nullMoney(m *domain.Money) (sql.NullInt64, sql.NullString) {
+    if m == nil {
+        return sql.NullInt64{}, sql.NullString{}
+    }
+    return sql.NullInt64{Int64: m.Amount, Valid: true}, sql.NullString{String: m.Currency, Valid: true}
+}
+
+// parseNullMoney reassembles an amount written by nullMoney.
+func parseNullMoney(amount sql.NullInt64, currency sql.NullString) *domain.Money {
+    if !amount.Valid {
+        return nil
+    }
+    m := domain.NewMoney(amount.Int64, currency.String)
+    return &m
+}
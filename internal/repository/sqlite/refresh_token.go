@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+// RefreshTokenRepository is a SQLite implementation of repository.RefreshTokenRepository.
+type RefreshTokenRepository struct {
+    db dbtx
+}
+
+func (r *RefreshTokenRepository) Create(ctx context.Context, token domain.RefreshToken) error {
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO refresh_tokens (id, user_id, family_id, expires_at, created_at, revoked_at) VALUES (?, ?, ?, ?, ?, ?)`,
+        token.ID, token.UserID, token.FamilyID, formatTime(token.ExpiresAt), formatTime(token.CreatedAt), nullTime(token.RevokedAt))
+    return mapError(err)
+}
+
+func (r *RefreshTokenRepository) Update(ctx context.Context, token domain.RefreshToken) error {
+    res, err := r.db.ExecContext(ctx,
+        `UPDATE refresh_tokens SET user_id = ?, family_id = ?, expires_at = ?, created_at = ?, revoked_at = ? WHERE id = ?`,
+        token.UserID, token.FamilyID, formatTime(token.ExpiresAt), formatTime(token.CreatedAt), nullTime(token.RevokedAt), token.ID)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *RefreshTokenRepository) GetByID(ctx context.Context, id string) (domain.RefreshToken, error) {
+    var (
+        token                domain.RefreshToken
+        expiresAt, createdAt string
+        revokedAt            sql.NullString
+    )
+    err := r.db.QueryRowContext(ctx,
+        `SELECT id, user_id, family_id, expires_at, created_at, revoked_at FROM refresh_tokens WHERE id = ?`, id).
+        Scan(&token.ID, &token.UserID, &token.FamilyID, &expiresAt, &createdAt, &revokedAt)
+    if err != nil {
+        return domain.RefreshToken{}, mapError(err)
+    }
+    if token.ExpiresAt, err = parseTime(expiresAt); err != nil {
+        return domain.RefreshToken{}, fmt.Errorf("decode refresh token expires_at: %w", err)
+    }
+    if token.CreatedAt, err = parseTime(createdAt); err != nil {
+        return domain.RefreshToken{}, fmt.Errorf("decode refresh token created_at: %w", err)
+    }
+    if revokedAt.Valid {
+        t, err := parseTime(revokedAt.String)
+        if err != nil {
+            return domain.RefreshToken{}, fmt.Errorf("decode refresh token revoked_at: %w", err)
+        }
+        token.RevokedAt = &t
+    }
+    return token, nil
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+    _, err := r.db.ExecContext(ctx,
+        `UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`,
+        formatTime(at), familyID)
+    return mapError(err)
+}
+
+func (r *RefreshTokenRepository) RevokeUser(ctx context.Context, userID string, at time.Time) error {
+    _, err := r.db.ExecContext(ctx,
+        `UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`,
+        formatTime(at), userID)
+    return mapError(err)
+}
@@ -0,0 +1,114 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+// ReservationRepository is a SQLite implementation of repository.ReservationRepository.
+type ReservationRepository struct {
+    db dbtx
+}
+
+const selectReservationSQL = `SELECT id, order_id, product_id, sku, warehouse_id, quantity, status, expires_at, created_at, resolved_at FROM reservations`
+
+func (r *ReservationRepository) Create(ctx context.Context, reservation domain.Reservation) error {
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO reservations (id, order_id, product_id, sku, warehouse_id, quantity, status, expires_at, created_at, resolved_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        reservation.ID, reservation.OrderID, reservation.ProductID, reservation.SKU, reservation.WarehouseID, reservation.Quantity, string(reservation.Status),
+        formatTime(reservation.ExpiresAt), formatTime(reservation.CreatedAt), nullTime(reservation.ResolvedAt))
+    return mapError(err)
+}
+
+func (r *ReservationRepository) Update(ctx context.Context, reservation domain.Reservation) error {
+    res, err := r.db.ExecContext(ctx,
+        `UPDATE reservations SET order_id = ?, product_id = ?, sku = ?, warehouse_id = ?, quantity = ?, status = ?, expires_at = ?, created_at = ?, resolved_at = ?
+        WHERE id = ?`,
+        reservation.OrderID, reservation.ProductID, reservation.SKU, reservation.WarehouseID, reservation.Quantity, string(reservation.Status),
+        formatTime(reservation.ExpiresAt), formatTime(reservation.CreatedAt), nullTime(reservation.ResolvedAt), reservation.ID)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *ReservationRepository) ListByOrder(ctx context.Context, orderID string) ([]domain.Reservation, error) {
+    return r.list(ctx, selectReservationSQL+` WHERE order_id = ? ORDER BY created_at, id`, orderID)
+}
+
+func (r *ReservationRepository) ListExpired(ctx context.Context, now time.Time) ([]domain.Reservation, error) {
+    // Timestamps are stored as RFC 3339 in UTC, which compares correctly as text
+    // only at a fixed width, so expiry is checked after decoding.
+    held, err := r.list(ctx, selectReservationSQL+` WHERE status = ? ORDER BY created_at, id`, string(domain.ReservationStatusHeld))
+    if err != nil {
+        return nil, err
+    }
+    expired := make([]domain.Reservation, 0)
+    for _, reservation := range held {
+        if !reservation.ExpiresAt.After(now) {
+            expired = append(expired, reservation)
+        }
+    }
+    return expired, nil
+}
+
+func (r *ReservationRepository) list(ctx context.Context, query string, args ...any) ([]domain.Reservation, error) {
+    rows, err := r.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    defer rows.Close()
+
+    reservations := make([]domain.Reservation, 0)
+    for rows.Next() {
+        var (
+            reservation                  domain.Reservation
+            status, expiresAt, createdAt string
+            resolvedAt                   sql.NullString
+        )
+        if err := rows.Scan(&reservation.ID, &reservation.OrderID, &reservation.ProductID, &reservation.SKU, &reservation.WarehouseID, &reservation.Quantity,
+            &status, &expiresAt, &createdAt, &resolvedAt); err != nil {
+            return nil, err
+        }
+        reservation.Status = domain.ReservationStatus(status)
+        if reservation.ExpiresAt, err = parseTime(expiresAt); err != nil {
+            return nil, fmt.Errorf("decode reservation expires_at: %w", err)
+        }
+        if reservation.CreatedAt, err = parseTime(createdAt); err != nil {
+            return nil, fmt.Errorf("decode reservation created_at: %w", err)
+        }
+        if resolvedAt.Valid {
+            t, err := parseTime(resolvedAt.String)
+            if err != nil {
+                return nil, fmt.Errorf("decode reservation resolved_at: %w", err)
+            }
+            reservation.ResolvedAt = &t
+        }
+        reservations = append(reservations, reservation)
+    }
+    return reservations, rows.Err()
+}
+
+func nullTime(t *time.Time) sql.NullString {
+    if t == nil {
+        return sql.NullString{}
+    }
+    return sql.NullString{String: formatTime(*t), Valid: true}
+}
+
+// parseNullTime decodes a nullable timestamp written by nullTime.
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+    if !s.Valid {
+        return nil, nil
+    }
+    t, err := parseTime(s.String)
+    if err != nil {
+        return nil, err
+    }
+    return &t, nil
+}
@@ -0,0 +1,516 @@
+// Package sqlite provides repository implementations backed by an embedded SQLite database.
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    sqlite "modernc.org/sqlite"
+    sqlite3 "modernc.org/sqlite/lib"
+
+    "cryptotrade/internal/repository"
+)
+
+// migrations are applied in order; the index of the last applied entry is
+// tracked through PRAGMA user_version so existing files are upgraded in place.
+var migrations = []string{
+    `CREATE TABLE products (
+        id          TEXT PRIMARY KEY,
+        name        TEXT NOT NULL,
+        description TEXT NOT NULL DEFAULT '',
+        price       REAL NOT NULL,
+        stock       INTEGER NOT NULL
+    );
+    CREATE TABLE users (
+        id    TEXT PRIMARY KEY,
+        name  TEXT NOT NULL,
+        email TEXT NOT NULL UNIQUE
+    );
+    CREATE TABLE orders (
+        id         TEXT PRIMARY KEY,
+        user_id    TEXT NOT NULL REFERENCES users(id),
+        items      TEXT NOT NULL,
+        total      REAL NOT NULL,
+        created_at TEXT NOT NULL
+    );
+    CREATE INDEX orders_user_id ON orders(user_id);`,
+    `ALTER TABLE orders ADD COLUMN status TEXT NOT NULL DEFAULT 'pending';
+    ALTER TABLE orders ADD COLUMN status_history TEXT NOT NULL DEFAULT '[]';`,
+    `ALTER TABLE orders ADD COLUMN cancelled_by TEXT;
+    ALTER TABLE orders ADD COLUMN cancel_reason TEXT;
+    ALTER TABLE orders ADD COLUMN cancelled_at TEXT;`,
+    // Monetary values move from REAL to integer minor units. Rows written
+    // before this migration carried no currency and are treated as USD.
+    `ALTER TABLE products ADD COLUMN price_amount INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE products ADD COLUMN price_currency TEXT NOT NULL DEFAULT 'USD';
+    UPDATE products SET price_amount = CAST(ROUND(price * 100) AS INTEGER);
+    ALTER TABLE products DROP COLUMN price;
+    ALTER TABLE orders ADD COLUMN total_amount INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE orders ADD COLUMN total_currency TEXT NOT NULL DEFAULT 'USD';
+    UPDATE orders SET total_amount = CAST(ROUND(total * 100) AS INTEGER);
+    ALTER TABLE orders DROP COLUMN total;`,
+    `ALTER TABLE orders ADD COLUMN exchange_rates TEXT NOT NULL DEFAULT '[]';`,
+    `ALTER TABLE orders ADD COLUMN invoice_id TEXT NOT NULL DEFAULT '';
+    CREATE TABLE invoices (
+        id               TEXT PRIMARY KEY,
+        order_id         TEXT NOT NULL UNIQUE REFERENCES orders(id),
+        amount           INTEGER NOT NULL,
+        currency         TEXT NOT NULL,
+        address          TEXT NOT NULL UNIQUE,
+        derivation_path  TEXT NOT NULL,
+        derivation_index INTEGER NOT NULL,
+        rate             TEXT,
+        status           TEXT NOT NULL,
+        expires_at       TEXT NOT NULL,
+        created_at       TEXT NOT NULL,
+        UNIQUE (currency, derivation_index)
+    );`,
+    `ALTER TABLE invoices ADD COLUMN received_amount INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE invoices ADD COLUMN payments TEXT NOT NULL DEFAULT '[]';
+    ALTER TABLE invoices ADD COLUMN paid_at TEXT;
+    CREATE INDEX invoices_status ON invoices(status);`,
+    // Lightning invoices have no deposit address, so the invoices table is
+    // rebuilt with nullable address columns; SQLite cannot relax NOT NULL in place.
+    `CREATE TABLE invoices_new (
+        id               TEXT PRIMARY KEY,
+        order_id         TEXT NOT NULL UNIQUE REFERENCES orders(id),
+        method           TEXT NOT NULL DEFAULT 'onchain',
+        amount           INTEGER NOT NULL,
+        currency         TEXT NOT NULL,
+        address          TEXT UNIQUE,
+        derivation_path  TEXT,
+        derivation_index INTEGER,
+        payment_request  TEXT,
+        payment_hash     TEXT UNIQUE,
+        rate             TEXT,
+        status           TEXT NOT NULL,
+        received_amount  INTEGER NOT NULL DEFAULT 0,
+        payments         TEXT NOT NULL DEFAULT '[]',
+        paid_at          TEXT,
+        expires_at       TEXT NOT NULL,
+        created_at       TEXT NOT NULL,
+        UNIQUE (currency, derivation_index)
+    );
+    INSERT INTO invoices_new (id, order_id, amount, currency, address, derivation_path, derivation_index,
+        rate, status, received_amount, payments, paid_at, expires_at, created_at)
+    SELECT id, order_id, amount, currency, address, derivation_path, derivation_index,
+        rate, status, received_amount, payments, paid_at, expires_at, created_at FROM invoices;
+    DROP TABLE invoices;
+    ALTER TABLE invoices_new RENAME TO invoices;
+    CREATE INDEX invoices_status ON invoices(status);`,
+    `CREATE TABLE carts (
+        user_id    TEXT PRIMARY KEY REFERENCES users(id),
+        items      TEXT NOT NULL,
+        updated_at TEXT NOT NULL
+    );`,
+    `ALTER TABLE products ADD COLUMN reserved INTEGER NOT NULL DEFAULT 0;
+    CREATE TABLE reservations (
+        id          TEXT PRIMARY KEY,
+        order_id    TEXT NOT NULL REFERENCES orders(id),
+        product_id  TEXT NOT NULL,
+        quantity    INTEGER NOT NULL,
+        status      TEXT NOT NULL,
+        expires_at  TEXT NOT NULL,
+        created_at  TEXT NOT NULL,
+        resolved_at TEXT
+    );
+    CREATE INDEX reservations_order_id ON reservations(order_id);
+    CREATE INDEX reservations_status_expires_at ON reservations(status, expires_at);`,
+    `CREATE TABLE warehouses (
+        id         TEXT PRIMARY KEY,
+        name       TEXT NOT NULL UNIQUE,
+        latitude   REAL,
+        longitude  REAL,
+        priority   INTEGER NOT NULL DEFAULT 0,
+        created_at TEXT NOT NULL
+    );
+    CREATE TABLE stock_levels (
+        warehouse_id TEXT NOT NULL REFERENCES warehouses(id),
+        product_id   TEXT NOT NULL,
+        on_hand      INTEGER NOT NULL,
+        reserved     INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (warehouse_id, product_id)
+    );
+    CREATE INDEX stock_levels_product_id ON stock_levels(product_id);
+    ALTER TABLE orders ADD COLUMN allocations TEXT NOT NULL DEFAULT '[]';
+    ALTER TABLE reservations ADD COLUMN warehouse_id TEXT NOT NULL DEFAULT '';`,
+    // Existing stock is carried into the ledger as opening balances: one per
+    // warehouse level and one for each product's unassigned remainder.
+    `CREATE TABLE stock_movements (
+        seq          INTEGER PRIMARY KEY AUTOINCREMENT,
+        id           TEXT NOT NULL UNIQUE,
+        product_id   TEXT NOT NULL,
+        warehouse_id TEXT NOT NULL DEFAULT '',
+        quantity     INTEGER NOT NULL,
+        reason       TEXT NOT NULL,
+        reference_id TEXT NOT NULL DEFAULT '',
+        actor        TEXT NOT NULL,
+        created_at   TEXT NOT NULL
+    );
+    CREATE INDEX stock_movements_product_id ON stock_movements(product_id, seq);
+    INSERT INTO stock_movements (id, product_id, warehouse_id, quantity, reason, reference_id, actor, created_at)
+    SELECT 'opening-' || warehouse_id || '-' || product_id, product_id, warehouse_id, on_hand, 'adjustment', 'opening-balance', 'system',
+        strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+    FROM stock_levels WHERE on_hand <> 0;
+    INSERT INTO stock_movements (id, product_id, quantity, reason, reference_id, actor, created_at)
+    SELECT 'opening-' || p.id, p.id, p.stock - COALESCE((SELECT SUM(l.on_hand) FROM stock_levels l WHERE l.product_id = p.id), 0),
+        'adjustment', 'opening-balance', 'system', strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+    FROM products p
+    WHERE p.stock - COALESCE((SELECT SUM(l.on_hand) FROM stock_levels l WHERE l.product_id = p.id), 0) <> 0;`,
+    // Products and users gain a creation time; existing rows are stamped with
+    // the upgrade time. Times compared in SQL are rewritten with a fixed-width
+    // fraction so that they sort as text.
+    `ALTER TABLE products ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+    UPDATE products SET created_at = strftime('%Y-%m-%dT%H:%M:%f000000Z', 'now');
+    ALTER TABLE users ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+    UPDATE users SET created_at = strftime('%Y-%m-%dT%H:%M:%f000000Z', 'now');
+    UPDATE orders SET created_at = substr(created_at, 1, 19) || '.' ||
+        substr(CASE WHEN length(created_at) > 20 THEN substr(created_at, 21, length(created_at) - 21) ELSE '' END || '000000000', 1, 9) || 'Z';
+    UPDATE reservations SET expires_at = substr(expires_at, 1, 19) || '.' ||
+        substr(CASE WHEN length(expires_at) > 20 THEN substr(expires_at, 21, length(expires_at) - 21) ELSE '' END || '000000000', 1, 9) || 'Z';
+    CREATE INDEX products_created_at ON products(created_at);
+    CREATE INDEX users_created_at ON users(created_at);
+    CREATE INDEX orders_created_at ON orders(created_at);`,
+    // Top-level categories have an empty parent_id rather than NULL so that
+    // the sibling name constraint also covers them.
+    `CREATE TABLE categories (
+        id         TEXT PRIMARY KEY,
+        name       TEXT NOT NULL,
+        parent_id  TEXT NOT NULL DEFAULT '',
+        created_at TEXT NOT NULL,
+        UNIQUE (parent_id, name)
+    );
+    CREATE TABLE product_categories (
+        product_id  TEXT NOT NULL REFERENCES products(id),
+        category_id TEXT NOT NULL REFERENCES categories(id),
+        PRIMARY KEY (product_id, category_id)
+    );
+    CREATE INDEX product_categories_category_id ON product_categories(category_id);`,
+    // Variant SKUs are the primary key so that they are unique across the
+    // catalog; position keeps variants in the order they were given.
+    `ALTER TABLE products ADD COLUMN options TEXT NOT NULL DEFAULT '[]';
+    CREATE TABLE product_variants (
+        sku            TEXT PRIMARY KEY,
+        product_id     TEXT NOT NULL REFERENCES products(id),
+        position       INTEGER NOT NULL,
+        options        TEXT NOT NULL,
+        price_amount   INTEGER NOT NULL,
+        price_currency TEXT NOT NULL,
+        stock          INTEGER NOT NULL,
+        reserved       INTEGER NOT NULL DEFAULT 0
+    );
+    CREATE INDEX product_variants_product_id ON product_variants(product_id, position);
+    ALTER TABLE reservations ADD COLUMN sku TEXT NOT NULL DEFAULT '';
+    ALTER TABLE stock_movements ADD COLUMN sku TEXT NOT NULL DEFAULT '';`,
+    // Existing users have no password and cannot log in until they set one.
+    `ALTER TABLE users ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
+    CREATE TABLE refresh_tokens (
+        id         TEXT PRIMARY KEY,
+        user_id    TEXT NOT NULL REFERENCES users(id),
+        family_id  TEXT NOT NULL,
+        expires_at TEXT NOT NULL,
+        created_at TEXT NOT NULL,
+        revoked_at TEXT
+    );
+    CREATE INDEX refresh_tokens_family_id ON refresh_tokens(family_id);`,
+    `ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'customer';`,
+    `CREATE TABLE api_keys (
+        id           TEXT PRIMARY KEY,
+        name         TEXT NOT NULL,
+        prefix       TEXT NOT NULL UNIQUE,
+        hash         TEXT NOT NULL,
+        scopes       TEXT NOT NULL,
+        created_by   TEXT NOT NULL REFERENCES users(id),
+        expires_at   TEXT,
+        last_used_at TEXT,
+        revoked_at   TEXT,
+        created_at   TEXT NOT NULL
+    );`,
+    `ALTER TABLE users ADD COLUMN totp_enabled INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+    ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE users ADD COLUMN recovery_code_hashes TEXT NOT NULL DEFAULT '[]';`,
+    `ALTER TABLE users ADD COLUMN deleted_at TEXT;`,
+    // Accounts registered before email verification are taken as verified,
+    // so that existing customers can still check out.
+    `ALTER TABLE users ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 0;
+    UPDATE users SET email_verified = 1;
+    CREATE TABLE account_tokens (
+        id         TEXT PRIMARY KEY,
+        user_id    TEXT NOT NULL REFERENCES users(id),
+        purpose    TEXT NOT NULL,
+        email      TEXT NOT NULL,
+        expires_at TEXT NOT NULL,
+        created_at TEXT NOT NULL,
+        used_at    TEXT
+    );
+    CREATE INDEX account_tokens_user_id ON account_tokens(user_id);`,
+    `CREATE TABLE addresses (
+        id          TEXT PRIMARY KEY,
+        user_id     TEXT NOT NULL REFERENCES users(id),
+        type        TEXT NOT NULL,
+        name        TEXT NOT NULL,
+        company     TEXT NOT NULL,
+        line1       TEXT NOT NULL,
+        line2       TEXT NOT NULL,
+        city        TEXT NOT NULL,
+        region      TEXT NOT NULL,
+        postal_code TEXT NOT NULL,
+        country     TEXT NOT NULL,
+        phone       TEXT NOT NULL,
+        latitude    REAL,
+        longitude   REAL,
+        created_at  TEXT NOT NULL,
+        updated_at  TEXT NOT NULL
+    );
+    CREATE INDEX addresses_user_id ON addresses(user_id);
+    ALTER TABLE orders ADD COLUMN shipping_address TEXT;
+    ALTER TABLE orders ADD COLUMN billing_address TEXT;`,
+    // Automatic promotions have no coupon code; NULL keeps them out of the
+    // code's unique constraint. The order breakdown is left NULL for orders
+    // placed before promotions.
+    `CREATE TABLE promotions (
+        id                  TEXT PRIMARY KEY,
+        code                TEXT UNIQUE,
+        name                TEXT NOT NULL,
+        description         TEXT NOT NULL,
+        type                TEXT NOT NULL,
+        percent_off         INTEGER NOT NULL,
+        amount_off_amount   INTEGER,
+        amount_off_currency TEXT,
+        buy_quantity        INTEGER NOT NULL,
+        get_quantity        INTEGER NOT NULL,
+        min_order_amount    INTEGER,
+        min_order_currency  TEXT,
+        product_ids         TEXT NOT NULL,
+        category_ids        TEXT NOT NULL,
+        usage_limit         INTEGER NOT NULL,
+        per_user_limit      INTEGER NOT NULL,
+        starts_at           TEXT,
+        ends_at             TEXT,
+        stackable           INTEGER NOT NULL,
+        active              INTEGER NOT NULL,
+        created_at          TEXT NOT NULL,
+        updated_at          TEXT NOT NULL
+    );
+    CREATE TABLE promotion_redemptions (
+        promotion_id TEXT NOT NULL REFERENCES promotions(id),
+        user_id      TEXT NOT NULL REFERENCES users(id),
+        order_id     TEXT NOT NULL REFERENCES orders(id),
+        created_at   TEXT NOT NULL,
+        PRIMARY KEY (promotion_id, order_id)
+    );
+    CREATE INDEX promotion_redemptions_user_id ON promotion_redemptions(promotion_id, user_id);
+    CREATE INDEX promotion_redemptions_order_id ON promotion_redemptions(order_id);
+    ALTER TABLE orders ADD COLUMN subtotal_amount INTEGER;
+    ALTER TABLE orders ADD COLUMN discount_amount INTEGER;
+    ALTER TABLE orders ADD COLUMN shipping_amount INTEGER;
+    ALTER TABLE orders ADD COLUMN promotions TEXT NOT NULL DEFAULT '[]';`,
+    // Each on-chain transaction may pay only one invoice, even when an
+    // address is handed out again; existing payments claim theirs.
+    `CREATE TABLE invoice_transactions (
+        currency   TEXT NOT NULL,
+        tx_hash    TEXT NOT NULL,
+        invoice_id TEXT NOT NULL REFERENCES invoices(id),
+        PRIMARY KEY (currency, tx_hash)
+    );
+    INSERT OR IGNORE INTO invoice_transactions (currency, tx_hash, invoice_id)
+        SELECT invoices.currency, json_extract(p.value, '$.tx_hash'), invoices.id
+        FROM invoices, json_each(invoices.payments) AS p
+        WHERE invoices.method = 'onchain'
+        ORDER BY invoices.created_at;`,
+    `ALTER TABLE orders ADD COLUMN refund_due TEXT;
+    CREATE INDEX orders_refund_due ON orders(refund_due) WHERE refund_due IS NOT NULL;`,
+    `ALTER TABLE users ADD COLUMN mfa_failures INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE users ADD COLUMN mfa_locked_until TEXT;`,
+    // Warehouse levels are kept per variant. The levels of products sold as
+    // variants cannot be split between their SKUs, so their units are
+    // transferred back to unassigned stock, in the ledger too, and the open
+    // reservations allocated from them are moved along with them.
+    `CREATE TABLE stock_levels_new (
+        warehouse_id TEXT NOT NULL REFERENCES warehouses(id),
+        product_id   TEXT NOT NULL,
+        sku          TEXT NOT NULL DEFAULT '',
+        on_hand      INTEGER NOT NULL,
+        reserved     INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (warehouse_id, product_id, sku)
+    );
+    INSERT INTO stock_levels_new (warehouse_id, product_id, on_hand, reserved)
+    SELECT warehouse_id, product_id, on_hand, reserved FROM stock_levels
+    WHERE product_id NOT IN (SELECT product_id FROM product_variants);
+    INSERT INTO stock_movements (id, product_id, warehouse_id, quantity, reason, reference_id, actor, created_at)
+    SELECT 'sku-levels-' || warehouse_id || '-' || product_id || '-out', product_id, warehouse_id, -on_hand, 'transfer',
+        'sku-levels-' || product_id, 'system', strftime('%Y-%m-%dT%H:%M:%f000000Z', 'now')
+    FROM stock_levels
+    WHERE on_hand <> 0 AND product_id IN (SELECT product_id FROM product_variants);
+    INSERT INTO stock_movements (id, product_id, warehouse_id, quantity, reason, reference_id, actor, created_at)
+    SELECT 'sku-levels-' || warehouse_id || '-' || product_id || '-in', product_id, '', on_hand, 'transfer',
+        'sku-levels-' || product_id, 'system', strftime('%Y-%m-%dT%H:%M:%f000000Z', 'now')
+    FROM stock_levels
+    WHERE on_hand <> 0 AND product_id IN (SELECT product_id FROM product_variants);
+    UPDATE reservations SET warehouse_id = ''
+    WHERE warehouse_id <> '' AND status <> 'released' AND product_id IN (SELECT product_id FROM product_variants);
+    DROP TABLE stock_levels;
+    ALTER TABLE stock_levels_new RENAME TO stock_levels;
+    CREATE INDEX stock_levels_product_id ON stock_levels(product_id, sku);`,
+}
+
+// dbtx is the subset of *sql.DB and *sql.Tx used by the repositories.
+type dbtx interface {
+    ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+    QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+    QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Store owns the database handle and hands out repositories bound to it.
+type Store struct {
+    db *sql.DB
+}
+
+// Open opens (creating if necessary) the SQLite database at path and applies pending migrations.
+func Open(path string) (*Store, error) {
+    dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+    db, err := sql.Open("sqlite", dsn)
+    if err != nil {
+        return nil, fmt.Errorf("open sqlite database: %w", err)
+    }
+    // SQLite permits a single writer; serialising access avoids SQLITE_BUSY under load.
+    db.SetMaxOpenConns(1)
+
+    if err := migrate(context.Background(), db); err != nil {
+        db.Close()
+        return nil, err
+    }
+    return &Store{db: db}, nil
+}
+
+// Close releases the underlying database handle.
+func (s *Store) Close() error {
+    return s.db.Close()
+}
+
+// Repositories returns repositories that run each call in its own implicit transaction.
+func (s *Store) Repositories() repository.Repositories {
+    return newRepositories(s.db)
+}
+
+// WithinTx implements repository.TxManager on top of a database transaction.
+// The pool holds a single connection, so concurrent units of work are
+// serialised and a stock check cannot be invalidated before it commits.
+func (s *Store) WithinTx(ctx context.Context, fn func(ctx context.Context, repos repository.Repositories) error) error {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("begin transaction: %w", err)
+    }
+
+    if err := fn(ctx, newRepositories(tx)); err != nil {
+        if rbErr := tx.Rollback(); rbErr != nil {
+            return errors.Join(err, fmt.Errorf("rollback transaction: %w", rbErr))
+        }
+        return err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("commit transaction: %w", err)
+    }
+    return nil
+}
+
+func newRepositories(db dbtx) repository.Repositories {
+    return repository.Repositories{
+        Products:       &ProductRepository{db: db},
+        Users:          &UserRepository{db: db},
+        Orders:         &OrderRepository{db: db},
+        Invoices:       &InvoiceRepository{db: db},
+        Carts:          &CartRepository{db: db},
+        Reservations:   &ReservationRepository{db: db},
+        Warehouses:     &WarehouseRepository{db: db},
+        StockLevels:    &StockLevelRepository{db: db},
+        StockMovements: &StockMovementRepository{db: db},
+        Categories:     &CategoryRepository{db: db},
+        RefreshTokens:  &RefreshTokenRepository{db: db},
+        APIKeys:        &APIKeyRepository{db: db},
+        AccountTokens:  &AccountTokenRepository{db: db},
+        Addresses:      &AddressRepository{db: db},
+        Promotions:     &PromotionRepository{db: db},
+    }
+}
+
+func migrate(ctx context.Context, db *sql.DB) error {
+    var version int
+    if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+        return fmt.Errorf("read schema version: %w", err)
+    }
+
+    for i := version; i < len(migrations); i++ {
+        tx, err := db.BeginTx(ctx, nil)
+        if err != nil {
+            return fmt.Errorf("begin migration %d: %w", i+1, err)
+        }
+        if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+            tx.Rollback()
+            return fmt.Errorf("apply migration %d: %w", i+1, err)
+        }
+        if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+            tx.Rollback()
+            return fmt.Errorf("record migration %d: %w", i+1, err)
+        }
+        if err := tx.Commit(); err != nil {
+            return fmt.Errorf("commit migration %d: %w", i+1, err)
+        }
+    }
+    return nil
+}
+
+// mapError translates driver errors into the repository sentinel errors.
+func mapError(err error) error {
+    if err == nil {
+        return nil
+    }
+    if errors.Is(err, sql.ErrNoRows) {
+        return repository.ErrNotFound
+    }
+
+    var sqliteErr *sqlite.Error
+    if errors.As(err, &sqliteErr) {
+        switch sqliteErr.Code() {
+        case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+            return repository.ErrConflict
+        }
+    }
+    return err
+}
+
+// requireAffected returns repository.ErrNotFound when a statement touched no rows.
+func requireAffected(res sql.Result) error {
+    n, err := res.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if n == 0 {
+        return repository.ErrNotFound
+    }
+    return nil
+}
+
+// placeholders returns n comma-separated bind parameters for an IN list.
+func placeholders(n int) string {
+    return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// timeLayout is RFC 3339 with a fixed nine-digit fraction, so stored times
+// sort correctly when compared as text. parseTime also accepts the shorter
+// RFC3339Nano form written by earlier versions.
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+func formatTime(t time.Time) string {
+    return t.UTC().Format(timeLayout)
+}
+
+func parseTime(s string) (time.Time, error) {
+    return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+func openTestStore(t *testing.T, path string) *Store {
+    t.Helper()
+    store, err := Open(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { store.Close() })
+    return store
+}
+
+// schema describes the tables and indexes in db along with its version.
+func schema(t *testing.T, db *sql.DB) string {
+    t.Helper()
+    var version int
+    if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+        t.Fatal(err)
+    }
+    rows, err := db.Query(`SELECT type, name, COALESCE(sql, '') FROM sqlite_master ORDER BY type, name`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer rows.Close()
+    var b strings.Builder
+    fmt.Fprintf(&b, "version %d\n", version)
+    for rows.Next() {
+        var typ, name, ddl string
+        if err := rows.Scan(&typ, &name, &ddl); err != nil {
+            t.Fatal(err)
+        }
+        fmt.Fprintf(&b, "%s %s: %s\n", typ, name, ddl)
+    }
+    if err := rows.Err(); err != nil {
+        t.Fatal(err)
+    }
+    return b.String()
+}
+
+func TestOpenAppliesMigrations(t *testing.T) {
+    dir := t.TempDir()
+    fresh := openTestStore(t, filepath.Join(dir, "fresh.db"))
+    want := schema(t, fresh.db)
+    if !strings.HasPrefix(want, fmt.Sprintf("version %d\n", len(migrations))) {
+        t.Fatalf("fresh database is at %s, want version %d", strings.SplitN(want, "\n", 2)[0], len(migrations))
+    }
+
+    // An existing but empty file is at user_version 0.
+    emptyPath := filepath.Join(dir, "empty.db")
+    if err := os.WriteFile(emptyPath, nil, 0o600); err != nil {
+        t.Fatal(err)
+    }
+    if got := schema(t, openTestStore(t, emptyPath).db); got != want {
+        t.Errorf("empty file migrated to\n%s\nwant\n%s", got, want)
+    }
+
+    // Opening a migrated database again applies nothing.
+    fresh.Close()
+    if got := schema(t, openTestStore(t, filepath.Join(dir, "fresh.db")).db); got != want {
+        t.Errorf("reopened database has\n%s\nwant\n%s", got, want)
+    }
+}
+
+func TestOpenUpgradesFirstSchema(t *testing.T) {
+    ctx := context.Background()
+    dir := t.TempDir()
+    want := schema(t, openTestStore(t, filepath.Join(dir, "fresh.db")).db)
+
+    // A database written by the first release, with a user in it.
+    path := filepath.Join(dir, "v1.db")
+    db, err := sql.Open("sqlite", path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, stmt := range []string{
+        migrations[0],
+        "PRAGMA user_version = 1",
+        `INSERT INTO users (id, name, email) VALUES ('u1', 'Ada', 'ada@example.com')`,
+    } {
+        if _, err := db.Exec(stmt); err != nil {
+            t.Fatal(err)
+        }
+    }
+    db.Close()
+
+    store := openTestStore(t, path)
+    if got := schema(t, store.db); got != want {
+        t.Errorf("upgraded database has\n%s\nwant\n%s", got, want)
+    }
+    user, err := store.Repositories().Users.GetByEmail(ctx, "ada@example.com")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if user.ID != "u1" || user.Name != "Ada" {
+        t.Errorf("user after the upgrade = %+v, want u1 Ada", user)
+    }
+}
+
+func TestProductRoundTrip(t *testing.T) {
+    ctx := context.Background()
+    repos := openTestStore(t, filepath.Join(t.TempDir(), "shop.db")).Repositories()
+
+    price := domain.NewMoney(150_000, "BTC")
+    product := domain.Product{
+        ID:          "wallet",
+        Name:        "Wallet",
+        Description: "Hardware wallet",
+        Price:       price,
+        Stock:       7,
+        Reserved:    2,
+        Options:     []domain.ProductOption{{Name: "colour", Values: []string{"black", "orange"}}},
+        Variants: []domain.Variant{
+            {SKU: "BLK", Options: map[string]string{"colour": "black"}, Price: price, Stock: 4, Reserved: 2},
+            {SKU: "ORG", Options: map[string]string{"colour": "orange"}, Price: price, Stock: 3},
+        },
+        CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC),
+    }
+    if err := repos.Products.Create(ctx, product); err != nil {
+        t.Fatal(err)
+    }
+    got, err := repos.Products.GetByID(ctx, product.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(got, product) {
+        t.Fatalf("stored %+v, read back %+v", product, got)
+    }
+
+    product.Name, product.Stock = "Wallet Pro", 3
+    product.Variants = product.Variants[1:]
+    if err := repos.Products.Update(ctx, product); err != nil {
+        t.Fatal(err)
+    }
+    if got, err := repos.Products.GetBySKU(ctx, "ORG"); err != nil || !reflect.DeepEqual(got, product) {
+        t.Errorf("after the update read %+v, %v; want %+v", got, err, product)
+    }
+    if _, err := repos.Products.GetBySKU(ctx, "BLK"); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("removed variant: got %v, want ErrNotFound", err)
+    }
+
+    if err := repos.Products.Delete(ctx, product.ID); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := repos.Products.GetByID(ctx, product.ID); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("deleted product: got %v, want ErrNotFound", err)
+    }
+}
+
+func TestErrorMapping(t *testing.T) {
+    ctx := context.Background()
+    repos := openTestStore(t, filepath.Join(t.TempDir(), "shop.db")).Repositories()
+    created := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+    user := domain.User{ID: "u1", Name: "Ada", Email: "ada@example.com", Role: domain.RoleCustomer, CreatedAt: created}
+    if err := repos.Users.Create(ctx, user); err != nil {
+        t.Fatal(err)
+    }
+    product := domain.Product{ID: "ledger", Name: "Ledger", Price: domain.NewMoney(100, "BTC"), CreatedAt: created}
+    if err := repos.Products.Create(ctx, product); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name string
+        err  error
+        want error
+    }{
+        {"duplicate primary key", repos.Products.Create(ctx, product), repository.ErrConflict},
+        {"duplicate unique email", repos.Users.Create(ctx, domain.User{ID: "u2", Name: "Eve", Email: user.Email, Role: domain.RoleCustomer, CreatedAt: created}), repository.ErrConflict},
+        {"get missing row", func() error { _, err := repos.Users.GetByID(ctx, "nobody"); return err }(), repository.ErrNotFound},
+        {"update missing row", repos.Products.Update(ctx, domain.Product{ID: "missing", Name: "Missing", Price: product.Price}), repository.ErrNotFound},
+        {"delete missing row", repos.Products.Delete(ctx, "missing"), repository.ErrNotFound},
+    }
+    for _, tt := range tests {
+        if !errors.Is(tt.err, tt.want) {
+            t.Errorf("%s: got %v, want %v", tt.name, tt.err, tt.want)
+        }
+    }
+}
+
+func TestWithinTxRollsBack(t *testing.T) {
+    ctx := context.Background()
+    store := openTestStore(t, filepath.Join(t.TempDir(), "shop.db"))
+    failure := errors.New("boom")
+
+    err := store.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        if err := repos.Products.Create(ctx, domain.Product{ID: "ledger", Name: "Ledger", Price: domain.NewMoney(100, "BTC")}); err != nil {
+            return err
+        }
+        return failure
+    })
+    if !errors.Is(err, failure) {
+        t.Fatalf("WithinTx = %v, want the function's error", err)
+    }
+    if _, err := store.Repositories().Products.GetByID(ctx, "ledger"); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("product from a rolled back transaction: got %v, want ErrNotFound", err)
+    }
+}
@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+    "context"
+    "fmt"
+
+    "cryptotrade/internal/domain"
+)
+
+// StockMovementRepository is a SQLite implementation of repository.StockMovementRepository.
+// Rows carry an autoincrement sequence so the ledger keeps its recording
+// order even when timestamps collide.
+type StockMovementRepository struct {
+    db dbtx
+}
+
+func (r *StockMovementRepository) Create(ctx context.Context, movement domain.StockMovement) error {
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO stock_movements (id, product_id, sku, warehouse_id, quantity, reason, reference_id, actor, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        movement.ID, movement.ProductID, movement.SKU, movement.WarehouseID, movement.Quantity, string(movement.Reason),
+        movement.ReferenceID, movement.Actor, formatTime(movement.CreatedAt))
+    return mapError(err)
+}
+
+func (r *StockMovementRepository) ListByProduct(ctx context.Context, productID string) ([]domain.StockMovement, error) {
+    rows, err := r.db.QueryContext(ctx,
+        `SELECT id, product_id, sku, warehouse_id, quantity, reason, reference_id, actor, created_at
+        FROM stock_movements WHERE product_id = ? ORDER BY seq`, productID)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    defer rows.Close()
+
+    movements := make([]domain.StockMovement, 0)
+    for rows.Next() {
+        var (
+            movement          domain.StockMovement
+            reason, createdAt string
+        )
+        if err := rows.Scan(&movement.ID, &movement.ProductID, &movement.SKU, &movement.WarehouseID, &movement.Quantity, &reason,
+            &movement.ReferenceID, &movement.Actor, &createdAt); err != nil {
+            return nil, err
+        }
+        movement.Reason = domain.StockMovementReason(reason)
+        if movement.CreatedAt, err = parseTime(createdAt); err != nil {
+            return nil, fmt.Errorf("decode stock movement created_at: %w", err)
+        }
+        movements = append(movements, movement)
+    }
+    return movements, rows.Err()
+}
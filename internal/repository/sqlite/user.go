@@ -0,0 +1,131 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// UserRepository is a SQLite implementation of repository.UserRepository.
+type UserRepository struct {
+    db dbtx
+}
+
+const selectUserSQL = `SELECT id, name, email, email_verified, role, password_hash, totp_enabled, totp_secret, totp_last_step, mfa_failures, mfa_locked_until, recovery_code_hashes, created_at, deleted_at FROM users`
+
+var userSortFields = map[string]sortField[domain.User]{
+    "name":       {[]string{"name"}, func(u domain.User) []any { return []any{u.Name} }},
+    "email":      {[]string{"email"}, func(u domain.User) []any { return []any{u.Email} }},
+    "created_at": {[]string{"created_at"}, func(u domain.User) []any { return []any{formatTime(u.CreatedAt)} }},
+}
+
+func (r *UserRepository) Create(ctx context.Context, user domain.User) error {
+    recoveryCodes, err := encodeRecoveryCodes(user)
+    if err != nil {
+        return err
+    }
+    _, err = r.db.ExecContext(ctx,
+        `INSERT INTO users (id, name, email, email_verified, role, password_hash, totp_enabled, totp_secret, totp_last_step, mfa_failures, mfa_locked_until, recovery_code_hashes, created_at, deleted_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        user.ID, user.Name, user.Email, user.EmailVerified, string(user.Role), user.PasswordHash,
+        user.TOTPEnabled, user.TOTPSecret, user.TOTPLastStep, user.MFAFailures, nullTime(user.MFALockedUntil), recoveryCodes, formatTime(user.CreatedAt), nullTime(user.DeletedAt))
+    return mapError(err)
+}
+
+func (r *UserRepository) Update(ctx context.Context, user domain.User) error {
+    recoveryCodes, err := encodeRecoveryCodes(user)
+    if err != nil {
+        return err
+    }
+    res, err := r.db.ExecContext(ctx,
+        `UPDATE users SET name = ?, email = ?, email_verified = ?, role = ?, password_hash = ?, totp_enabled = ?, totp_secret = ?, totp_last_step = ?, mfa_failures = ?, mfa_locked_until = ?, recovery_code_hashes = ?, deleted_at = ?
+        WHERE id = ?`,
+        user.Name, user.Email, user.EmailVerified, string(user.Role), user.PasswordHash,
+        user.TOTPEnabled, user.TOTPSecret, user.TOTPLastStep, user.MFAFailures, nullTime(user.MFALockedUntil), recoveryCodes, nullTime(user.DeletedAt), user.ID)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, id string, role domain.Role) error {
+    res, err := r.db.ExecContext(ctx, `UPDATE users SET role = ? WHERE id = ?`, string(role), id)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (domain.User, error) {
+    return r.get(ctx, selectUserSQL+` WHERE id = ?`, id)
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (domain.User, error) {
+    return r.get(ctx, selectUserSQL+` WHERE email = ?`, email)
+}
+
+func (r *UserRepository) List(ctx context.Context, query repository.UserQuery) (repository.Page[domain.User], error) {
+    q := pageQuery[domain.User]{
+        selectSQL: selectUserSQL,
+        sortable:  userSortFields,
+        id:        func(u domain.User) string { return u.ID },
+        scan:      scanUser,
+    }
+    if query.Email != "" {
+        q.filters = append(q.filters, "email = ?")
+        q.args = append(q.args, query.Email)
+    }
+    return q.list(ctx, r.db, query.ListOptions, repository.DefaultUserSort)
+}
+
+func (r *UserRepository) get(ctx context.Context, query string, arg any) (domain.User, error) {
+    user, err := scanUser(r.db.QueryRowContext(ctx, query, arg))
+    if err != nil {
+        return domain.User{}, mapError(err)
+    }
+    return user, nil
+}
+
+func scanUser(s scanner) (domain.User, error) {
+    var (
+        user                           domain.User
+        role, recoveryCodes, createdAt string
+        lockedUntil, deletedAt         sql.NullString
+    )
+    if err := s.Scan(&user.ID, &user.Name, &user.Email, &user.EmailVerified, &role, &user.PasswordHash,
+        &user.TOTPEnabled, &user.TOTPSecret, &user.TOTPLastStep, &user.MFAFailures, &lockedUntil, &recoveryCodes, &createdAt, &deletedAt); err != nil {
+        return domain.User{}, err
+    }
+    user.Role = domain.Role(role)
+    if err := json.Unmarshal([]byte(recoveryCodes), &user.RecoveryCodeHashes); err != nil {
+        return domain.User{}, fmt.Errorf("decode user recovery_code_hashes: %w", err)
+    }
+    t, err := parseTime(createdAt)
+    if err != nil {
+        return domain.User{}, fmt.Errorf("decode user created_at: %w", err)
+    }
+    user.CreatedAt = t
+    if user.MFALockedUntil, err = parseNullTime(lockedUntil); err != nil {
+        return domain.User{}, fmt.Errorf("decode user mfa_locked_until: %w", err)
+    }
+    if user.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+        return domain.User{}, fmt.Errorf("decode user deleted_at: %w", err)
+    }
+    return user, nil
+}
+
+func encodeRecoveryCodes(user domain.User) (string, error) {
+    hashes := user.RecoveryCodeHashes
+    if hashes == nil {
+        hashes = []string{}
+    }
+    encoded, err := json.Marshal(hashes)
+    if err != nil {
+        return "", fmt.Errorf("encode user recovery_code_hashes: %w", err)
+    }
+    return string(encoded), nil
+}
@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+
+    "cryptotrade/internal/domain"
+)
+
+// WarehouseRepository is a SQLite implementation of repository.WarehouseRepository.
+type WarehouseRepository struct {
+    db dbtx
+}
+
+const selectWarehouseSQL = `SELECT id, name, latitude, longitude, priority, created_at FROM warehouses`
+
+func (r *WarehouseRepository) Create(ctx context.Context, warehouse domain.Warehouse) error {
+    lat, lon := nullLocation(warehouse.Location)
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO warehouses (id, name, latitude, longitude, priority, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+        warehouse.ID, warehouse.Name, lat, lon, warehouse.Priority, formatTime(warehouse.CreatedAt))
+    return mapError(err)
+}
+
+func (r *WarehouseRepository) Update(ctx context.Context, warehouse domain.Warehouse) error {
+    lat, lon := nullLocation(warehouse.Location)
+    res, err := r.db.ExecContext(ctx,
+        `UPDATE warehouses SET name = ?, latitude = ?, longitude = ?, priority = ?, created_at = ? WHERE id = ?`,
+        warehouse.Name, lat, lon, warehouse.Priority, formatTime(warehouse.CreatedAt), warehouse.ID)
+    if err != nil {
+        return mapError(err)
+    }
+    return requireAffected(res)
+}
+
+func (r *WarehouseRepository) GetByID(ctx context.Context, id string) (domain.Warehouse, error) {
+    warehouse, err := scanWarehouse(r.db.QueryRowContext(ctx, selectWarehouseSQL+` WHERE id = ?`, id))
+    if err != nil {
+        return domain.Warehouse{}, mapError(err)
+    }
+    return warehouse, nil
+}
+
+func (r *WarehouseRepository) List(ctx context.Context) ([]domain.Warehouse, error) {
+    rows, err := r.db.QueryContext(ctx, selectWarehouseSQL+` ORDER BY priority, name, id`)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    defer rows.Close()
+
+    warehouses := make([]domain.Warehouse, 0)
+    for rows.Next() {
+        warehouse, err := scanWarehouse(rows)
+        if err != nil {
+            return nil, err
+        }
+        warehouses = append(warehouses, warehouse)
+    }
+    return warehouses, rows.Err()
+}
+
+func scanWarehouse(s scanner) (domain.Warehouse, error) {
+    var (
+        warehouse domain.Warehouse
+        lat, lon  sql.NullFloat64
+        createdAt string
+    )
+    if err := s.Scan(&warehouse.ID, &warehouse.Name, &lat, &lon, &warehouse.Priority, &createdAt); err != nil {
+        return domain.Warehouse{}, err
+    }
+    if lat.Valid && lon.Valid {
+        warehouse.Location = &domain.GeoPoint{Latitude: lat.Float64, Longitude: lon.Float64}
+    }
+    t, err := parseTime(createdAt)
+    if err != nil {
+        return domain.Warehouse{}, fmt.Errorf("decode warehouse created_at: %w", err)
+    }
+    warehouse.CreatedAt = t
+    return warehouse, nil
+}
+
+func nullLocation(p *domain.GeoPoint) (sql.NullFloat64, sql.NullFloat64) {
+    if p == nil {
+        return sql.NullFloat64{}, sql.NullFloat64{}
+    }
+    return sql.NullFloat64{Float64: p.Latitude, Valid: true}, sql.NullFloat64{Float64: p.Longitude, Valid: true}
+}
+
+// StockLevelRepository is a SQLite implementation of repository.StockLevelRepository.
+type StockLevelRepository struct {
+    db dbtx
+}
+
+const selectStockLevelSQL = `SELECT warehouse_id, product_id, sku, on_hand, reserved FROM stock_levels`
+
+func (r *StockLevelRepository) Get(ctx context.Context, warehouseID, productID, sku string) (domain.StockLevel, error) {
+    var level domain.StockLevel
+    err := r.db.QueryRowContext(ctx, selectStockLevelSQL+` WHERE warehouse_id = ? AND product_id = ? AND sku = ?`, warehouseID, productID, sku).
+        Scan(&level.WarehouseID, &level.ProductID, &level.SKU, &level.OnHand, &level.Reserved)
+    if err != nil {
+        return domain.StockLevel{}, mapError(err)
+    }
+    return level, nil
+}
+
+func (r *StockLevelRepository) Save(ctx context.Context, level domain.StockLevel) error {
+    // The warehouse must exist; the foreign key only reports a generic
+    // constraint failure, so it is checked explicitly.
+    var exists int
+    if err := r.db.QueryRowContext(ctx, `SELECT 1 FROM warehouses WHERE id = ?`, level.WarehouseID).Scan(&exists); err != nil {
+        return mapError(err)
+    }
+
+    _, err := r.db.ExecContext(ctx,
+        `INSERT INTO stock_levels (warehouse_id, product_id, sku, on_hand, reserved) VALUES (?, ?, ?, ?, ?)
+        ON CONFLICT (warehouse_id, product_id, sku) DO UPDATE SET on_hand = excluded.on_hand, reserved = excluded.reserved`,
+        level.WarehouseID, level.ProductID, level.SKU, level.OnHand, level.Reserved)
+    return mapError(err)
+}
+
+func (r *StockLevelRepository) ListByProduct(ctx context.Context, productID string) ([]domain.StockLevel, error) {
+    return r.list(ctx, selectStockLevelSQL+` WHERE product_id = ? ORDER BY warehouse_id, sku`, productID)
+}
+
+func (r *StockLevelRepository) ListByWarehouse(ctx context.Context, warehouseID string) ([]domain.StockLevel, error) {
+    return r.list(ctx, selectStockLevelSQL+` WHERE warehouse_id = ? ORDER BY product_id, sku`, warehouseID)
+}
+
+func (r *StockLevelRepository) list(ctx context.Context, query string, args ...any) ([]domain.StockLevel, error) {
+    rows, err := r.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    defer rows.Close()
+
+    levels := make([]domain.StockLevel, 0)
+    for rows.Next() {
+        var level domain.StockLevel
+        if err := rows.Scan(&level.WarehouseID, &level.ProductID, &level.SKU, &level.OnHand, &level.Reserved); err != nil {
+            return nil, err
+        }
+        levels = append(levels, level)
+    }
+    return levels, rows.Err()
+}
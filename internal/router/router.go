@@ -10,7 +10,7 @@ import (
 )
 
 // SetupRouter configures the HTTP routes and middleware stack.
-func SetupRouter(cfg config.Config, productHandler *handler.ProductHandler, userHandler *handler.UserHandler, orderHandler *handler.OrderHandler) *gin.Engine {
+func SetupRouter(cfg config.Config, productHandler *handler.ProductHandler, userHandler *handler.UserHandler, orderHandler *handler.OrderHandler, cartHandler *handler.CartHandler, warehouseHandler *handler.WarehouseHandler, stockHandler *handler.StockHandler, categoryHandler *handler.CategoryHandler, authHandler *handler.AuthHandler, apiKeyHandler *handler.APIKeyHandler, addressHandler *handler.AddressHandler, promotionHandler *handler.PromotionHandler) *gin.Engine {
     if cfg.Environment == "production" {
         gin.SetMode(gin.ReleaseMode)
     }
@@ -26,9 +26,18 @@ func SetupRouter(cfg config.Config, productHandler *handler.ProductHandler, user
     })
 
     api := r.Group("/api/v1")
+    api.Use(authHandler.Authenticate)
+    authHandler.RegisterRoutes(api)
+    apiKeyHandler.RegisterRoutes(api)
     productHandler.RegisterRoutes(api)
     userHandler.RegisterRoutes(api)
     orderHandler.RegisterRoutes(api)
+    cartHandler.RegisterRoutes(api)
+    addressHandler.RegisterRoutes(api)
+    warehouseHandler.RegisterRoutes(api)
+    stockHandler.RegisterRoutes(api)
+    categoryHandler.RegisterRoutes(api)
+    promotionHandler.RegisterRoutes(api)
 
     return r
 }
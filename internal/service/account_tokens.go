@@ -0,0 +1,215 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+
+    "cryptotrade/internal/auth"
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/mail"
+    "cryptotrade/internal/repository"
+)
+
+// accountTokenKind describes one kind of mailed one-time token.
+type accountTokenKind struct {
+    purpose   domain.AccountTokenPurpose
+    tokenType string
+    ttl       time.Duration
+    // path is the storefront page the mailed link opens.
+    path  string
+    email func(user domain.User, link string) mail.Message
+}
+
+var (
+    verifyEmailToken = accountTokenKind{
+        purpose:   domain.PurposeVerifyEmail,
+        tokenType: auth.TokenVerifyEmail,
+        ttl:       24 * time.Hour,
+        path:      "/verify-email",
+        email: func(user domain.User, link string) mail.Message {
+            return mail.Message{
+                To:      user.Email,
+                Subject: "Confirm your email address",
+                Body: fmt.Sprintf("Hi %s,\n\n"+
+                    "Please confirm your email address by opening this link within 24 hours:\n\n%s\n\n"+
+                    "If you did not create an account, you can ignore this email.\n", user.Name, link),
+            }
+        },
+    }
+    resetPasswordToken = accountTokenKind{
+        purpose:   domain.PurposeResetPassword,
+        tokenType: auth.TokenResetPassword,
+        ttl:       time.Hour,
+        path:      "/reset-password",
+        email: func(user domain.User, link string) mail.Message {
+            return mail.Message{
+                To:      user.Email,
+                Subject: "Reset your password",
+                Body: fmt.Sprintf("Hi %s,\n\n"+
+                    "Someone asked to reset the password of your account. To choose a new password, open this link within an hour:\n\n%s\n\n"+
+                    "If it was not you, you can ignore this email; your password has not changed.\n", user.Name, link),
+            }
+        },
+    }
+)
+
+// errInvalidAccountToken covers every way a mailed token can fail, so that
+// a guessed or stale token reveals nothing about the account.
+var errInvalidAccountToken = fmt.Errorf("%w: the link is invalid, expired or already used", ErrValidation)
+
+// SendEmailVerification mails the user a new link to verify their email.
+func (s *UserService) SendEmailVerification(ctx context.Context, id string) error {
+    user, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        return err
+    }
+    if user.DeletedAt != nil {
+        return errAccountDeleted
+    }
+    if user.EmailVerified {
+        return fmt.Errorf("%w: email is already verified", repository.ErrConflict)
+    }
+    return s.sendAccountToken(ctx, user, verifyEmailToken)
+}
+
+// VerifyEmail marks the email a verification token was sent to as verified,
+// making the user an admin if it is the admin email. The token no longer
+// works once the user has changed their email.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) error {
+    return s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        user, err := useAccountToken(ctx, repos, s.signer, token, verifyEmailToken, time.Now().UTC())
+        if err != nil {
+            return err
+        }
+        user.EmailVerified = true
+        if s.isAdmin(user) {
+            user.Role = domain.RoleAdmin
+        }
+        return repos.Users.Update(ctx, user)
+    })
+}
+
+// RequestPasswordReset mails a password reset link to the account
+// registered with email. It reports success whether or not there is such an
+// account, so that it cannot be used to find out who is registered.
+func (s *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+    user, err := s.repo.GetByEmail(ctx, email)
+    if errors.Is(err, repository.ErrNotFound) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if user.DeletedAt != nil {
+        return nil
+    }
+    // A failure is logged rather than returned for the same reason.
+    if err := s.sendAccountToken(ctx, user, resetPasswordToken); err != nil {
+        log.Printf("users: send password reset to user %s: %v", user.ID, err)
+    }
+    return nil
+}
+
+// ResetPassword sets a new password with a password reset token. The user's
+// other reset links stop working and every session is revoked. Since the
+// link arrived by email, it verifies the email as well.
+func (s *UserService) ResetPassword(ctx context.Context, token, password string) error {
+    if err := validatePassword(password); err != nil {
+        return fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+    hash, err := auth.HashPassword(password)
+    if err != nil {
+        return err
+    }
+
+    return s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        now := time.Now().UTC()
+        user, err := useAccountToken(ctx, repos, s.signer, token, resetPasswordToken, now)
+        if err != nil {
+            return err
+        }
+        user.PasswordHash = hash
+        user.EmailVerified = true
+        if s.isAdmin(user) {
+            user.Role = domain.RoleAdmin
+        }
+        if err := repos.Users.Update(ctx, user); err != nil {
+            return err
+        }
+        return repos.RefreshTokens.RevokeUser(ctx, user.ID, now)
+    })
+}
+
+// sendAccountToken stores a new token of kind for user and mails them a
+// link carrying it.
+func (s *UserService) sendAccountToken(ctx context.Context, user domain.User, kind accountTokenKind) error {
+    now := time.Now().UTC()
+    record := domain.AccountToken{
+        ID:        uuid.NewString(),
+        UserID:    user.ID,
+        Purpose:   kind.purpose,
+        Email:     user.Email,
+        ExpiresAt: now.Add(kind.ttl),
+        CreatedAt: now,
+    }
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        return repos.AccountTokens.Create(ctx, record)
+    })
+    if err != nil {
+        return err
+    }
+
+    token, err := s.signer.Sign(auth.Claims{
+        Subject:   user.ID,
+        Type:      kind.tokenType,
+        ID:        record.ID,
+        IssuedAt:  now.Unix(),
+        ExpiresAt: record.ExpiresAt.Unix(),
+    })
+    if err != nil {
+        return err
+    }
+    link := strings.TrimSuffix(s.publicURL, "/") + kind.path + "?token=" + url.QueryEscape(token)
+    return s.mailer.Send(ctx, kind.email(user, link))
+}
+
+// useAccountToken checks a mailed token of kind and spends it, along with
+// the user's other tokens for the same purpose. It returns the user the
+// token was issued to.
+func useAccountToken(ctx context.Context, repos repository.Repositories, signer *auth.Signer, token string, kind accountTokenKind, now time.Time) (domain.User, error) {
+    claims, err := signer.Verify(token, now)
+    if err != nil || claims.Type != kind.tokenType || claims.ID == "" {
+        return domain.User{}, errInvalidAccountToken
+    }
+    record, err := repos.AccountTokens.GetByID(ctx, claims.ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        return domain.User{}, errInvalidAccountToken
+    }
+    if err != nil {
+        return domain.User{}, err
+    }
+    if record.Purpose != kind.purpose || record.UserID != claims.Subject || !record.Usable(now) {
+        return domain.User{}, errInvalidAccountToken
+    }
+
+    user, err := repos.Users.GetByID(ctx, record.UserID)
+    if err != nil {
+        return domain.User{}, err
+    }
+    // A token only speaks for the address it was sent to.
+    if user.DeletedAt != nil || user.Email != record.Email {
+        return domain.User{}, errInvalidAccountToken
+    }
+
+    if err := repos.AccountTokens.UseAll(ctx, user.ID, kind.purpose, now); err != nil {
+        return domain.User{}, err
+    }
+    return user, nil
+}
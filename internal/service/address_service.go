@@ -0,0 +1,162 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// maxAddressesPerUser caps the size of an address book.
+const maxAddressesPerUser = 20
+
+// AddressService contains the business logic for users' address books.
+// Users manage their own addresses; staff need users:read to read someone
+// else's and users:manage to change them.
+type AddressService struct {
+    addresses repository.AddressRepository
+    tx        repository.TxManager
+}
+
+// NewAddressService creates a new AddressService.
+func NewAddressService(addressRepo repository.AddressRepository, tx repository.TxManager) *AddressService {
+    return &AddressService{addresses: addressRepo, tx: tx}
+}
+
+// AddressInput describes an address book entry to create or replace.
+type AddressInput struct {
+    Type domain.AddressType
+    domain.PostalAddress
+}
+
+// ListAddresses returns the user's address book, oldest entry first.
+func (s *AddressService) ListAddresses(ctx context.Context, userID string) ([]domain.Address, error) {
+    if err := authorizeOwner(ctx, "address book", userID, domain.PermissionUsersRead); err != nil {
+        return nil, err
+    }
+    return s.addresses.ListByUser(ctx, userID)
+}
+
+// GetAddress returns one of the user's addresses.
+func (s *AddressService) GetAddress(ctx context.Context, userID, id string) (domain.Address, error) {
+    if err := authorizeOwner(ctx, "address book", userID, domain.PermissionUsersRead); err != nil {
+        return domain.Address{}, err
+    }
+    return userAddress(ctx, s.addresses, userID, id)
+}
+
+// CreateAddress adds an address to the user's address book.
+func (s *AddressService) CreateAddress(ctx context.Context, userID string, input AddressInput) (domain.Address, error) {
+    if err := authorizeOwner(ctx, "address book", userID, domain.PermissionUsersManage); err != nil {
+        return domain.Address{}, err
+    }
+
+    now := time.Now().UTC()
+    address := domain.Address{
+        ID:            uuid.NewString(),
+        UserID:        userID,
+        Type:          input.Type,
+        PostalAddress: normalizeAddress(input.PostalAddress),
+        CreatedAt:     now,
+        UpdatedAt:     now,
+    }
+    if err := address.Validate(); err != nil {
+        return domain.Address{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        user, err := repos.Users.GetByID(ctx, userID)
+        if err != nil {
+            return err
+        }
+        if user.DeletedAt != nil {
+            return errAccountDeleted
+        }
+        existing, err := repos.Addresses.ListByUser(ctx, userID)
+        if err != nil {
+            return err
+        }
+        if len(existing) >= maxAddressesPerUser {
+            return fmt.Errorf("%w: an address book holds at most %d addresses", ErrValidation, maxAddressesPerUser)
+        }
+        return repos.Addresses.Create(ctx, address)
+    })
+    if err != nil {
+        return domain.Address{}, err
+    }
+    return address, nil
+}
+
+// UpdateAddress replaces one of the user's addresses. Orders placed with it
+// keep the address as it was.
+func (s *AddressService) UpdateAddress(ctx context.Context, userID, id string, input AddressInput) (domain.Address, error) {
+    if err := authorizeOwner(ctx, "address book", userID, domain.PermissionUsersManage); err != nil {
+        return domain.Address{}, err
+    }
+
+    var address domain.Address
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        address, err = userAddress(ctx, repos.Addresses, userID, id)
+        if err != nil {
+            return err
+        }
+        address.Type = input.Type
+        address.PostalAddress = normalizeAddress(input.PostalAddress)
+        address.UpdatedAt = time.Now().UTC()
+        if err := address.Validate(); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        return repos.Addresses.Update(ctx, address)
+    })
+    if err != nil {
+        return domain.Address{}, err
+    }
+    return address, nil
+}
+
+// DeleteAddress removes one of the user's addresses.
+func (s *AddressService) DeleteAddress(ctx context.Context, userID, id string) error {
+    if err := authorizeOwner(ctx, "address book", userID, domain.PermissionUsersManage); err != nil {
+        return err
+    }
+    return s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        if _, err := userAddress(ctx, repos.Addresses, userID, id); err != nil {
+            return err
+        }
+        return repos.Addresses.Delete(ctx, id)
+    })
+}
+
+// userAddress loads an address and checks that it belongs to userID. The
+// addresses of other users are reported as missing.
+func userAddress(ctx context.Context, addresses repository.AddressRepository, userID, id string) (domain.Address, error) {
+    address, err := addresses.GetByID(ctx, id)
+    if err != nil {
+        return domain.Address{}, err
+    }
+    if address.UserID != userID {
+        return domain.Address{}, repository.ErrNotFound
+    }
+    return address, nil
+}
+
+// normalizeAddress trims every field and upper-cases the country and postal
+// code, which is how the per-country rules expect them.
+func normalizeAddress(address domain.PostalAddress) domain.PostalAddress {
+    address.Name = strings.TrimSpace(address.Name)
+    address.Company = strings.TrimSpace(address.Company)
+    address.Line1 = strings.TrimSpace(address.Line1)
+    address.Line2 = strings.TrimSpace(address.Line2)
+    address.City = strings.TrimSpace(address.City)
+    address.Region = strings.TrimSpace(address.Region)
+    address.PostalCode = strings.ToUpper(strings.TrimSpace(address.PostalCode))
+    address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+    address.Phone = strings.TrimSpace(address.Phone)
+    return address
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+    "fmt"
+    "math"
+    "slices"
+    "sort"
+
+    "cryptotrade/internal/domain"
+)
+
+// Allocation strategy names accepted by NewAllocationStrategy.
+const (
+    AllocationPriority     = "priority"
+    AllocationNearest      = "nearest"
+    AllocationFewestSplits = "fewest_splits"
+)
+
+// AllocationRequest describes an order line to be split across warehouses.
+type AllocationRequest struct {
+    ProductID string
+    // SKU is the variant ordered, for products sold as variants.
+    SKU      string
+    Quantity int
+    // ShipTo is the delivery location, when the customer supplied one.
+    ShipTo *domain.GeoPoint
+}
+
+// AllocationCandidate is a warehouse with available stock of the requested
+// product, or of the requested variant.
+type AllocationCandidate struct {
+    Warehouse domain.Warehouse
+    Available int
+}
+
+// AllocationStrategy decides which warehouses ship an order line.
+type AllocationStrategy interface {
+    // Allocate splits the requested quantity across candidates without
+    // asking any of them for more than it has available. Whatever it leaves
+    // unallocated is taken from stock not assigned to a warehouse.
+    Allocate(req AllocationRequest, candidates []AllocationCandidate) []domain.Allocation
+}
+
+// NewAllocationStrategy returns the strategy registered under name. An empty
+// name selects priority allocation.
+func NewAllocationStrategy(name string) (AllocationStrategy, error) {
+    switch name {
+    case "", AllocationPriority:
+        return PriorityAllocation{}, nil
+    case AllocationNearest:
+        return NearestAllocation{}, nil
+    case AllocationFewestSplits:
+        return FewestSplitsAllocation{}, nil
+    default:
+        return nil, fmt.Errorf("unknown allocation strategy %q", name)
+    }
+}
+
+// PriorityAllocation fills each line from warehouses in ascending priority.
+type PriorityAllocation struct{}
+
+func (PriorityAllocation) Allocate(req AllocationRequest, candidates []AllocationCandidate) []domain.Allocation {
+    ordered := byPriority(candidates)
+    return fill(req, ordered)
+}
+
+// NearestAllocation fills each line from the warehouses closest to the
+// delivery location. Warehouses without a location come last, and priority
+// order is used when the order has no delivery location.
+type NearestAllocation struct{}
+
+func (NearestAllocation) Allocate(req AllocationRequest, candidates []AllocationCandidate) []domain.Allocation {
+    ordered := byPriority(candidates)
+    if req.ShipTo != nil {
+        distance := func(c AllocationCandidate) float64 {
+            if c.Warehouse.Location == nil {
+                return math.Inf(1)
+            }
+            return req.ShipTo.DistanceKm(*c.Warehouse.Location)
+        }
+        sort.SliceStable(ordered, func(i, j int) bool { return distance(ordered[i]) < distance(ordered[j]) })
+    }
+    return fill(req, ordered)
+}
+
+// FewestSplitsAllocation ships each line from as few warehouses as possible:
+// a single warehouse when one can cover it, otherwise the largest stocks first.
+type FewestSplitsAllocation struct{}
+
+func (FewestSplitsAllocation) Allocate(req AllocationRequest, candidates []AllocationCandidate) []domain.Allocation {
+    ordered := byPriority(candidates)
+    for _, c := range ordered {
+        if c.Available >= req.Quantity {
+            return fill(req, []AllocationCandidate{c})
+        }
+    }
+    sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Available > ordered[j].Available })
+    return fill(req, ordered)
+}
+
+// byPriority returns a copy of candidates in ascending priority, then name.
+func byPriority(candidates []AllocationCandidate) []AllocationCandidate {
+    ordered := slices.Clone(candidates)
+    sort.SliceStable(ordered, func(i, j int) bool {
+        a, b := ordered[i].Warehouse, ordered[j].Warehouse
+        if a.Priority != b.Priority {
+            return a.Priority < b.Priority
+        }
+        if a.Name != b.Name {
+            return a.Name < b.Name
+        }
+        return a.ID < b.ID
+    })
+    return ordered
+}
+
+// fill takes as much of the line as possible from each candidate in turn.
+func fill(req AllocationRequest, ordered []AllocationCandidate) []domain.Allocation {
+    var allocations []domain.Allocation
+    remaining := req.Quantity
+    for _, c := range ordered {
+        if remaining == 0 {
+            break
+        }
+        take := min(remaining, c.Available)
+        if take <= 0 {
+            continue
+        }
+        allocations = append(allocations, domain.Allocation{ProductID: req.ProductID, SKU: req.SKU, WarehouseID: c.Warehouse.ID, Quantity: take})
+        remaining -= take
+    }
+    return allocations
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "slices"
+    "time"
+
+    "github.com/google/uuid"
+
+    "cryptotrade/internal/auth"
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// lastUsedResolution bounds how often authenticating with a key writes its
+// last-used time, so that a busy integration does not turn every request
+// into a write.
+const lastUsedResolution = time.Minute
+
+// APIKeyService issues and checks the API keys that other systems use to call
+// the API without a user session.
+type APIKeyService struct {
+    repo repository.APIKeyRepository
+}
+
+// NewAPIKeyService creates a new APIKeyService.
+func NewAPIKeyService(repo repository.APIKeyRepository) *APIKeyService {
+    return &APIKeyService{repo: repo}
+}
+
+// CreateAPIKeyInput describes a key to issue.
+type CreateAPIKeyInput struct {
+    Name   string
+    Scopes []domain.Permission
+    // ExpiresAt is optional; keys without it stay valid until revoked.
+    ExpiresAt *time.Time
+}
+
+// IssuedAPIKey is a newly issued key together with its secret, which is
+// returned this once and cannot be recovered afterwards.
+type IssuedAPIKey struct {
+    domain.APIKey
+    Key string `json:"key"`
+}
+
+// CreateAPIKey issues a key on behalf of the user in ctx.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, input CreateAPIKeyInput) (IssuedAPIKey, error) {
+    actor, err := authorize(ctx, domain.PermissionAPIKeysManage)
+    if err != nil {
+        return IssuedAPIKey{}, err
+    }
+
+    now := time.Now().UTC()
+    apiKey := domain.APIKey{
+        ID:        uuid.NewString(),
+        Name:      input.Name,
+        Scopes:    slices.Compact(slices.Sorted(slices.Values(input.Scopes))),
+        CreatedBy: actor.ID,
+        CreatedAt: now,
+    }
+    if input.ExpiresAt != nil {
+        expiresAt := input.ExpiresAt.UTC()
+        apiKey.ExpiresAt = &expiresAt
+    }
+    if err := apiKey.Validate(); err != nil {
+        return IssuedAPIKey{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+
+    // Prefixes are random, so a clash is rare and a second draw settles it.
+    for attempt := 0; ; attempt++ {
+        key, prefix, hash, err := auth.NewAPIKey()
+        if err != nil {
+            return IssuedAPIKey{}, err
+        }
+        apiKey.Prefix, apiKey.Hash = prefix, hash
+        err = s.repo.Create(ctx, apiKey)
+        if errors.Is(err, repository.ErrConflict) && attempt == 0 {
+            continue
+        }
+        if err != nil {
+            return IssuedAPIKey{}, err
+        }
+        return IssuedAPIKey{APIKey: apiKey, Key: key}, nil
+    }
+}
+
+// GetAPIKey returns an API key by ID.
+func (s *APIKeyService) GetAPIKey(ctx context.Context, id string) (domain.APIKey, error) {
+    return s.repo.GetByID(ctx, id)
+}
+
+// ListAPIKeys returns every API key, newest first, revoked and expired ones
+// included.
+func (s *APIKeyService) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+    return s.repo.List(ctx)
+}
+
+// RevokeAPIKey stops a key from authenticating. Revoking a key twice keeps
+// the first revocation time.
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id string) (domain.APIKey, error) {
+    apiKey, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        return domain.APIKey{}, err
+    }
+    if apiKey.RevokedAt != nil {
+        return apiKey, nil
+    }
+    now := time.Now().UTC()
+    apiKey.RevokedAt = &now
+    if err := s.repo.Update(ctx, apiKey); err != nil {
+        return domain.APIKey{}, err
+    }
+    return apiKey, nil
+}
+
+// Authenticate resolves a presented key to its record and notes that it was
+// used.
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (domain.APIKey, error) {
+    errInvalid := fmt.Errorf("%w: invalid API key", ErrUnauthenticated)
+
+    prefix, ok := auth.ParseAPIKeyPrefix(key)
+    if !ok {
+        return domain.APIKey{}, errInvalid
+    }
+    apiKey, err := s.repo.GetByPrefix(ctx, prefix)
+    if errors.Is(err, repository.ErrNotFound) {
+        return domain.APIKey{}, errInvalid
+    }
+    if err != nil {
+        return domain.APIKey{}, err
+    }
+    if !auth.VerifyAPIKey(apiKey.Hash, key) {
+        return domain.APIKey{}, errInvalid
+    }
+
+    now := time.Now().UTC()
+    if !apiKey.Active(now) {
+        return domain.APIKey{}, fmt.Errorf("%w: API key is revoked or expired", ErrUnauthenticated)
+    }
+    if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= lastUsedResolution {
+        apiKey.LastUsedAt = &now
+        if err := s.repo.Update(ctx, apiKey); err != nil {
+            return domain.APIKey{}, err
+        }
+    }
+    return apiKey, nil
+}
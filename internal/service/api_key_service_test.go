@@ -0,0 +1,169 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "slices"
+    "strings"
+    "testing"
+    "time"
+
+    "cryptotrade/internal/auth"
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+    "cryptotrade/internal/repository/memory"
+)
+
+func newTestAPIKeyService(t *testing.T) (*APIKeyService, repository.APIKeyRepository, context.Context) {
+    t.Helper()
+    repo := memory.NewStore().Repositories().APIKeys
+    admin := WithUser(context.Background(), domain.User{ID: "admin", Role: domain.RoleAdmin})
+    return NewAPIKeyService(repo), repo, admin
+}
+
+func issueTestAPIKey(t *testing.T, svc *APIKeyService, ctx context.Context) IssuedAPIKey {
+    t.Helper()
+    issued, err := svc.CreateAPIKey(ctx, CreateAPIKeyInput{Name: "erp", Scopes: []domain.Permission{domain.PermissionOrdersRead}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    return issued
+}
+
+func TestCreateAPIKeyStoresOnlyHash(t *testing.T) {
+    svc, repo, ctx := newTestAPIKeyService(t)
+    issued, err := svc.CreateAPIKey(ctx, CreateAPIKeyInput{
+        Name:   "erp",
+        Scopes: []domain.Permission{domain.PermissionOrdersRead, domain.PermissionCatalogWrite, domain.PermissionOrdersRead},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    stored, err := repo.GetByID(context.Background(), issued.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if prefix, ok := auth.ParseAPIKeyPrefix(issued.Key); !ok || prefix != stored.Prefix {
+        t.Errorf("key %q does not carry the stored prefix %q", issued.Key, stored.Prefix)
+    }
+    if stored.Hash == issued.Key || !auth.VerifyAPIKey(stored.Hash, issued.Key) {
+        t.Errorf("stored hash %q does not verify the issued key", stored.Hash)
+    }
+    if want := []domain.Permission{domain.PermissionCatalogWrite, domain.PermissionOrdersRead}; !slices.Equal(stored.Scopes, want) {
+        t.Errorf("scopes %v, want %v", stored.Scopes, want)
+    }
+    if stored.CreatedBy != "admin" {
+        t.Errorf("created by %q, want admin", stored.CreatedBy)
+    }
+}
+
+func TestCreateAPIKeyRefusesCredentialScopes(t *testing.T) {
+    svc, _, ctx := newTestAPIKeyService(t)
+    for _, scope := range []domain.Permission{domain.PermissionUsersManage, domain.PermissionAPIKeysManage} {
+        _, err := svc.CreateAPIKey(ctx, CreateAPIKeyInput{Name: "erp", Scopes: []domain.Permission{domain.PermissionOrdersRead, scope}})
+        if !errors.Is(err, ErrValidation) {
+            t.Errorf("key with %s: got %v, want ErrValidation", scope, err)
+        }
+    }
+
+    // Nor can a key, or a user without the permission, issue keys.
+    key := WithAPIKey(context.Background(), domain.APIKey{Scopes: []domain.Permission{domain.PermissionOrdersManage}})
+    customer := WithUser(context.Background(), domain.User{ID: "buyer", Role: domain.RoleCustomer})
+    for name, ctx := range map[string]context.Context{"api key": key, "customer": customer} {
+        if _, err := svc.CreateAPIKey(ctx, CreateAPIKeyInput{Name: "erp", Scopes: []domain.Permission{domain.PermissionOrdersRead}}); !errors.Is(err, ErrForbidden) {
+            t.Errorf("%s issuing a key: got %v, want ErrForbidden", name, err)
+        }
+    }
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+    svc, _, ctx := newTestAPIKeyService(t)
+    issued := issueTestAPIKey(t, svc, ctx)
+
+    apiKey, err := svc.Authenticate(ctx, issued.Key)
+    if err != nil || apiKey.ID != issued.ID {
+        t.Fatalf("Authenticate = %s, %v; want %s", apiKey.ID, err, issued.ID)
+    }
+
+    prefix, _ := auth.ParseAPIKeyPrefix(issued.Key)
+    tests := []struct{ name, key string }{
+        {"wrong secret", "ct_" + prefix + "_" + strings.Repeat("A", 43)},
+        {"unknown prefix", "ct_000000000000_" + strings.Repeat("A", 43)},
+        {"malformed", strings.TrimPrefix(issued.Key, "ct_")},
+        {"empty", ""},
+    }
+    for _, tt := range tests {
+        if _, err := svc.Authenticate(ctx, tt.key); !errors.Is(err, ErrUnauthenticated) {
+            t.Errorf("%s: got %v, want ErrUnauthenticated", tt.name, err)
+        }
+    }
+}
+
+func TestAuthenticateRefusesRevokedAndExpiredKeys(t *testing.T) {
+    svc, repo, ctx := newTestAPIKeyService(t)
+
+    revoked := issueTestAPIKey(t, svc, ctx)
+    if _, err := svc.RevokeAPIKey(ctx, revoked.ID); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := svc.Authenticate(ctx, revoked.Key); !errors.Is(err, ErrUnauthenticated) {
+        t.Errorf("revoked key: got %v, want ErrUnauthenticated", err)
+    }
+
+    expired := issueTestAPIKey(t, svc, ctx)
+    past := time.Now().Add(-time.Second)
+    expired.APIKey.ExpiresAt = &past
+    if err := repo.Update(ctx, expired.APIKey); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := svc.Authenticate(ctx, expired.Key); !errors.Is(err, ErrUnauthenticated) {
+        t.Errorf("expired key: got %v, want ErrUnauthenticated", err)
+    }
+}
+
+func TestAuthenticateAPIKeyThrottlesLastUsed(t *testing.T) {
+    svc, repo, ctx := newTestAPIKeyService(t)
+    issued := issueTestAPIKey(t, svc, ctx)
+    lastUsed := func() time.Time {
+        t.Helper()
+        apiKey, err := repo.GetByID(ctx, issued.ID)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if apiKey.LastUsedAt == nil {
+            t.Fatal("last use was not recorded")
+        }
+        return *apiKey.LastUsedAt
+    }
+
+    if _, err := svc.Authenticate(ctx, issued.Key); err != nil {
+        t.Fatal(err)
+    }
+    first := lastUsed()
+
+    // Another use within the resolution is not written...
+    if _, err := svc.Authenticate(ctx, issued.Key); err != nil {
+        t.Fatal(err)
+    }
+    if got := lastUsed(); !got.Equal(first) {
+        t.Errorf("last used moved from %s to %s within %s", first, got, lastUsedResolution)
+    }
+
+    // ...but one after it is.
+    stale := first.Add(-lastUsedResolution)
+    apiKey, err := repo.GetByID(ctx, issued.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    apiKey.LastUsedAt = &stale
+    if err := repo.Update(ctx, apiKey); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := svc.Authenticate(ctx, issued.Key); err != nil {
+        t.Fatal(err)
+    }
+    if got := lastUsed(); !got.After(stale) {
+        t.Errorf("last used stayed at %s after %s", got, lastUsedResolution)
+    }
+}
@@ -0,0 +1,405 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+
+    "cryptotrade/internal/auth"
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// AuthService logs users in with their password and issues the access and
+// refresh tokens that authenticate later requests.
+//
+// Refresh tokens rotate: each one can be exchanged once, for a new pair in
+// the same family. Presenting a refresh token that was already exchanged
+// means it has leaked, so the whole family is revoked and both holders have
+// to log in again.
+//
+// Users with two-factor authentication get a short-lived MFA challenge
+// instead of tokens from Login, which CompleteLogin exchanges for tokens
+// along with a TOTP or recovery code.
+type AuthService struct {
+    users         repository.UserRepository
+    refreshTokens repository.RefreshTokenRepository
+    tx            repository.TxManager
+    signer        *auth.Signer
+    accessTTL     time.Duration
+    refreshTTL    time.Duration
+}
+
+const (
+    // mfaChallengeTTL is how long a user has to enter a code after their password.
+    mfaChallengeTTL = 5 * time.Minute
+    // maxMFAFailures is how many wrong codes in a row lock a user's second
+    // factor, whichever challenges they were entered against.
+    maxMFAFailures = 5
+    // mfaLockout is how long a locked second factor refuses every code.
+    mfaLockout = 15 * time.Minute
+)
+
+// NewAuthService creates a new AuthService.
+func NewAuthService(userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository, tx repository.TxManager, signer *auth.Signer, accessTTL, refreshTTL time.Duration) *AuthService {
+    return &AuthService{
+        users:         userRepo,
+        refreshTokens: refreshTokenRepo,
+        tx:            tx,
+        signer:        signer,
+        accessTTL:     accessTTL,
+        refreshTTL:    refreshTTL,
+    }
+}
+
+// TokenPair is the result of a login or refresh.
+type TokenPair struct {
+    AccessToken  string `json:"access_token"`
+    RefreshToken string `json:"refresh_token"`
+    TokenType    string `json:"token_type"`
+    // ExpiresIn is the access token's lifetime in seconds.
+    ExpiresIn int `json:"expires_in"`
+}
+
+// LoginResult is the outcome of a correct password: tokens, or a challenge
+// for users with two-factor authentication.
+type LoginResult struct {
+    Tokens    *TokenPair
+    Challenge *MFAChallenge
+}
+
+// MFAChallenge asks for a second factor to complete a login.
+type MFAChallenge struct {
+    MFARequired bool   `json:"mfa_required"`
+    MFAToken    string `json:"mfa_token"`
+    // ExpiresIn is the challenge's lifetime in seconds.
+    ExpiresIn int `json:"expires_in"`
+}
+
+var (
+    errBadCredentials      = fmt.Errorf("%w: invalid email or password", ErrUnauthenticated)
+    errInvalidRefreshToken = fmt.Errorf("%w: invalid refresh token", ErrUnauthenticated)
+)
+
+// dummyPasswordHash is verified against when no user has the submitted email,
+// so that a failed login takes as long whether or not the account exists.
+var dummyPasswordHash = sync.OnceValue(func() string {
+    hash, err := auth.HashPassword(uuid.NewString())
+    if err != nil {
+        panic(fmt.Sprintf("hash dummy password: %v", err))
+    }
+    return hash
+})
+
+// Login checks a user's password and starts a new session, or challenges
+// users with two-factor authentication for a code.
+func (s *AuthService) Login(ctx context.Context, email, password string) (LoginResult, error) {
+    user, err := s.users.GetByEmail(ctx, email)
+    if err != nil && !errors.Is(err, repository.ErrNotFound) {
+        return LoginResult{}, err
+    }
+
+    // Users created before passwords were introduced have no hash and
+    // cannot log in; they still pay for a verification.
+    hash := user.PasswordHash
+    if err != nil || hash == "" {
+        hash = dummyPasswordHash()
+    }
+    ok, verifyErr := auth.VerifyPassword(hash, password)
+    if verifyErr != nil {
+        return LoginResult{}, verifyErr
+    }
+    if err != nil || user.PasswordHash == "" || !ok {
+        return LoginResult{}, errBadCredentials
+    }
+
+    now := time.Now().UTC()
+    if user.TOTPEnabled {
+        token, err := s.signer.Sign(auth.Claims{
+            Subject:   user.ID,
+            Type:      auth.TokenMFA,
+            ID:        uuid.NewString(),
+            IssuedAt:  now.Unix(),
+            ExpiresAt: now.Add(mfaChallengeTTL).Unix(),
+        })
+        if err != nil {
+            return LoginResult{}, err
+        }
+        return LoginResult{Challenge: &MFAChallenge{
+            MFARequired: true,
+            MFAToken:    token,
+            ExpiresIn:   int(mfaChallengeTTL / time.Second),
+        }}, nil
+    }
+
+    var pair TokenPair
+    err = s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        pair, err = s.issue(ctx, repos.RefreshTokens, user.ID, uuid.NewString(), now)
+        return err
+    })
+    if err != nil {
+        return LoginResult{}, err
+    }
+    return LoginResult{Tokens: &pair}, nil
+}
+
+// CompleteLogin answers an MFA challenge with a TOTP code or a recovery code
+// and starts the session.
+func (s *AuthService) CompleteLogin(ctx context.Context, mfaToken, code, recoveryCode string) (TokenPair, error) {
+    now := time.Now().UTC()
+    claims, err := s.verify(mfaToken, auth.TokenMFA, now)
+    if err != nil {
+        return TokenPair{}, err
+    }
+
+    var (
+        pair   TokenPair
+        failed bool
+    )
+    err = s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        user, err := repos.Users.GetByID(ctx, claims.Subject)
+        if errors.Is(err, repository.ErrNotFound) {
+            return fmt.Errorf("%w: invalid mfa token", ErrUnauthenticated)
+        }
+        if err != nil {
+            return err
+        }
+        if !user.TOTPEnabled {
+            return fmt.Errorf("%w: invalid mfa token", ErrUnauthenticated)
+        }
+        if user.MFALockedUntil != nil && now.Before(*user.MFALockedUntil) {
+            return fmt.Errorf("%w: too many wrong codes; try again after %s", ErrUnauthenticated, user.MFALockedUntil.Format(time.RFC3339))
+        }
+        if err := checkSecondFactor(&user, code, recoveryCode, now); err != nil {
+            failed = true
+            return fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+        }
+        user.MFAFailures = 0
+        user.MFALockedUntil = nil
+        if err := repos.Users.Update(ctx, user); err != nil {
+            return err
+        }
+        pair, err = s.issue(ctx, repos.RefreshTokens, user.ID, uuid.NewString(), now)
+        return err
+    })
+    if failed {
+        // The failed attempt rolled back, so it is counted in a transaction
+        // of its own.
+        return TokenPair{}, errors.Join(err, s.recordMFAFailure(context.WithoutCancel(ctx), claims.Subject, now))
+    }
+    if err != nil {
+        return TokenPair{}, err
+    }
+    return pair, nil
+}
+
+// recordMFAFailure counts a wrong code against the user and locks their
+// second factor once maxMFAFailures have been entered in a row.
+func (s *AuthService) recordMFAFailure(ctx context.Context, userID string, now time.Time) error {
+    return s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        user, err := repos.Users.GetByID(ctx, userID)
+        if err != nil {
+            return err
+        }
+        user.MFAFailures++
+        if user.MFAFailures >= maxMFAFailures {
+            lockedUntil := now.Add(mfaLockout)
+            user.MFAFailures = 0
+            user.MFALockedUntil = &lockedUntil
+        }
+        return repos.Users.Update(ctx, user)
+    })
+}
+
+// Refresh exchanges a refresh token for a new token pair and revokes it.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+    now := time.Now().UTC()
+    claims, err := s.verify(refreshToken, auth.TokenRefresh, now)
+    if err != nil {
+        return TokenPair{}, err
+    }
+
+    var (
+        pair   TokenPair
+        reused bool
+    )
+    err = s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        stored, err := repos.RefreshTokens.GetByID(ctx, claims.ID)
+        if errors.Is(err, repository.ErrNotFound) {
+            return errInvalidRefreshToken
+        }
+        if err != nil {
+            return err
+        }
+        if stored.UserID != claims.Subject {
+            return errInvalidRefreshToken
+        }
+        if stored.RevokedAt != nil {
+            // A revoked token may have been rotated and then replayed, so
+            // the rest of its family goes too. The revocation has to commit,
+            // so the error is reported after the transaction.
+            reused = true
+            return repos.RefreshTokens.RevokeFamily(ctx, stored.FamilyID, now)
+        }
+        if !stored.Active(now) {
+            return errInvalidRefreshToken
+        }
+        user, err := repos.Users.GetByID(ctx, stored.UserID)
+        if errors.Is(err, repository.ErrNotFound) {
+            return errInvalidRefreshToken
+        }
+        if err != nil {
+            return err
+        }
+        if user.DeletedAt != nil {
+            return errInvalidRefreshToken
+        }
+
+        stored.RevokedAt = &now
+        if err := repos.RefreshTokens.Update(ctx, stored); err != nil {
+            return err
+        }
+        pair, err = s.issue(ctx, repos.RefreshTokens, stored.UserID, stored.FamilyID, now)
+        return err
+    })
+    if err != nil {
+        return TokenPair{}, err
+    }
+    if reused {
+        return TokenPair{}, fmt.Errorf("%w: refresh token has been revoked; log in again", ErrUnauthenticated)
+    }
+    return pair, nil
+}
+
+// Logout ends the session a refresh token belongs to, revoking every refresh
+// token issued in it. Access tokens already issued stay valid until they expire.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+    now := time.Now().UTC()
+    claims, err := s.verify(refreshToken, auth.TokenRefresh, now)
+    if err != nil {
+        return err
+    }
+
+    stored, err := s.refreshTokens.GetByID(ctx, claims.ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        return errInvalidRefreshToken
+    }
+    if err != nil {
+        return err
+    }
+    if stored.UserID != claims.Subject {
+        return errInvalidRefreshToken
+    }
+    return s.refreshTokens.RevokeFamily(ctx, stored.FamilyID, now)
+}
+
+// Authenticate resolves an access token to the user it was issued to.
+func (s *AuthService) Authenticate(ctx context.Context, accessToken string) (domain.User, error) {
+    claims, err := s.verify(accessToken, auth.TokenAccess, time.Now())
+    if err != nil {
+        return domain.User{}, err
+    }
+    user, err := s.users.GetByID(ctx, claims.Subject)
+    if errors.Is(err, repository.ErrNotFound) {
+        return domain.User{}, fmt.Errorf("%w: invalid access token", ErrUnauthenticated)
+    }
+    if err != nil {
+        return domain.User{}, err
+    }
+    // A deleted account keeps its record, so tokens issued before the
+    // deletion still name it.
+    if user.DeletedAt != nil {
+        return domain.User{}, fmt.Errorf("%w: invalid access token", ErrUnauthenticated)
+    }
+    return user, nil
+}
+
+// issue signs a new access token and stores and signs a new refresh token in
+// the given session family.
+func (s *AuthService) issue(ctx context.Context, refreshTokens repository.RefreshTokenRepository, userID, familyID string, now time.Time) (TokenPair, error) {
+    stored := domain.RefreshToken{
+        ID:        uuid.NewString(),
+        UserID:    userID,
+        FamilyID:  familyID,
+        ExpiresAt: now.Add(s.refreshTTL),
+        CreatedAt: now,
+    }
+    if err := refreshTokens.Create(ctx, stored); err != nil {
+        return TokenPair{}, err
+    }
+
+    access, err := s.signer.Sign(auth.Claims{
+        Subject:   userID,
+        Type:      auth.TokenAccess,
+        IssuedAt:  now.Unix(),
+        ExpiresAt: now.Add(s.accessTTL).Unix(),
+    })
+    if err != nil {
+        return TokenPair{}, err
+    }
+    refresh, err := s.signer.Sign(auth.Claims{
+        Subject:   userID,
+        Type:      auth.TokenRefresh,
+        ID:        stored.ID,
+        IssuedAt:  now.Unix(),
+        ExpiresAt: stored.ExpiresAt.Unix(),
+    })
+    if err != nil {
+        return TokenPair{}, err
+    }
+
+    return TokenPair{
+        AccessToken:  access,
+        RefreshToken: refresh,
+        TokenType:    "Bearer",
+        ExpiresIn:    int(s.accessTTL / time.Second),
+    }, nil
+}
+
+// verify checks a token's signature, expiry and type.
+func (s *AuthService) verify(token, tokenType string, now time.Time) (auth.Claims, error) {
+    claims, err := s.signer.Verify(token, now)
+    switch {
+    case errors.Is(err, auth.ErrTokenExpired):
+        return auth.Claims{}, fmt.Errorf("%w: %s token expired", ErrUnauthenticated, tokenType)
+    case err != nil:
+        return auth.Claims{}, fmt.Errorf("%w: invalid %s token", ErrUnauthenticated, tokenType)
+    case claims.Type != tokenType:
+        return auth.Claims{}, fmt.Errorf("%w: invalid %s token", ErrUnauthenticated, tokenType)
+    case tokenType != auth.TokenAccess && claims.ID == "":
+        return auth.Claims{}, fmt.Errorf("%w: invalid %s token", ErrUnauthenticated, tokenType)
+    }
+    return claims, nil
+}
+
+type userContextKey struct{}
+
+// WithUser returns a copy of ctx carrying the authenticated user.
+func WithUser(ctx context.Context, user domain.User) context.Context {
+    return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// UserFromContext returns the authenticated user stored in ctx, if any.
+func UserFromContext(ctx context.Context) (domain.User, bool) {
+    user, ok := ctx.Value(userContextKey{}).(domain.User)
+    return user, ok
+}
+
+type apiKeyContextKey struct{}
+
+// WithAPIKey returns a copy of ctx carrying the API key that authenticated
+// the request.
+func WithAPIKey(ctx context.Context, key domain.APIKey) context.Context {
+    return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// APIKeyFromContext returns the API key stored in ctx, if any.
+func APIKeyFromContext(ctx context.Context) (domain.APIKey, bool) {
+    key, ok := ctx.Value(apiKeyContextKey{}).(domain.APIKey)
+    return key, ok
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "cryptotrade/internal/auth"
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+    "cryptotrade/internal/repository/memory"
+)
+
+const testPassword = "password123"
+
+// authFixture is an AuthService over a memory store holding one customer
+// with a password.
+type authFixture struct {
+    repos  repository.Repositories
+    signer *auth.Signer
+    svc    *AuthService
+    user   domain.User
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+    t.Helper()
+    store := memory.NewStore()
+    repos := store.Repositories()
+    hash, err := auth.HashPassword(testPassword)
+    if err != nil {
+        t.Fatal(err)
+    }
+    user := domain.User{ID: "u1", Name: "Ada", Email: "ada@example.com", Role: domain.RoleCustomer, PasswordHash: hash, CreatedAt: time.Now()}
+    if err := repos.Users.Create(context.Background(), user); err != nil {
+        t.Fatal(err)
+    }
+    signer := auth.NewSigner([]byte("test key"))
+    return &authFixture{
+        repos:  repos,
+        signer: signer,
+        svc:    NewAuthService(repos.Users, repos.RefreshTokens, store, signer, time.Minute, time.Hour),
+        user:   user,
+    }
+}
+
+func (f *authFixture) login(t *testing.T) TokenPair {
+    t.Helper()
+    result, err := f.svc.Login(context.Background(), f.user.Email, testPassword)
+    if err != nil || result.Tokens == nil {
+        t.Fatalf("Login = %+v, %v; want tokens", result, err)
+    }
+    return *result.Tokens
+}
+
+func TestLoginRefusesBadCredentialsAlike(t *testing.T) {
+    ctx := context.Background()
+    f := newAuthFixture(t)
+    if err := f.repos.Users.Create(ctx, domain.User{ID: "u2", Name: "Legacy", Email: "legacy@example.com", Role: domain.RoleCustomer, CreatedAt: time.Now()}); err != nil {
+        t.Fatal(err)
+    }
+
+    // Every failure reads the same, so a login cannot tell which accounts
+    // exist.
+    tests := []struct{ name, email, password string }{
+        {"wrong password", f.user.Email, "password124"},
+        {"unknown email", "nobody@example.com", testPassword},
+        {"account without a password", "legacy@example.com", ""},
+    }
+    for _, tt := range tests {
+        result, err := f.svc.Login(ctx, tt.email, tt.password)
+        if err != errBadCredentials {
+            t.Errorf("%s: Login = %+v, %v; want %v", tt.name, result, err, errBadCredentials)
+        }
+    }
+}
+
+func TestDummyPasswordHashCostsAsMuchAsARealOne(t *testing.T) {
+    // Logins for unknown emails verify against the dummy hash, which must
+    // use the same argon2id parameters as real hashes to take as long.
+    hash, err := auth.HashPassword(testPassword)
+    if err != nil {
+        t.Fatal(err)
+    }
+    params := func(hash string) string { return strings.Join(strings.Split(hash, "$")[:4], "$") }
+    if got, want := params(dummyPasswordHash()), params(hash); got != want {
+        t.Errorf("dummy hash parameters %s, want %s", got, want)
+    }
+    if ok, err := auth.VerifyPassword(dummyPasswordHash(), ""); err != nil || ok {
+        t.Errorf("VerifyPassword(dummy, \"\") = %t, %v; want a verified mismatch", ok, err)
+    }
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+    ctx := context.Background()
+    f := newAuthFixture(t)
+    first := f.login(t)
+
+    second, err := f.svc.Refresh(ctx, first.RefreshToken)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if second.RefreshToken == first.RefreshToken {
+        t.Fatal("refresh returned the same refresh token")
+    }
+    if user, err := f.svc.Authenticate(ctx, second.AccessToken); err != nil || user.ID != f.user.ID {
+        t.Errorf("Authenticate(new access token) = %s, %v; want %s", user.ID, err, f.user.ID)
+    }
+    if _, err := f.svc.Refresh(ctx, second.RefreshToken); err != nil {
+        t.Errorf("refreshing with the rotated token: %v", err)
+    }
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+    ctx := context.Background()
+    f := newAuthFixture(t)
+    stolen := f.login(t)
+    other := f.login(t)
+
+    rotated, err := f.svc.Refresh(ctx, stolen.RefreshToken)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Replaying the exchanged token gives the theft away...
+    if _, err := f.svc.Refresh(ctx, stolen.RefreshToken); !errors.Is(err, ErrUnauthenticated) || !strings.Contains(err.Error(), "revoked") {
+        t.Fatalf("replayed refresh token: got %v, want it refused as revoked", err)
+    }
+    // ...so the token it was rotated into stops working too.
+    if _, err := f.svc.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, ErrUnauthenticated) {
+        t.Errorf("rotated token after a replay: got %v, want ErrUnauthenticated", err)
+    }
+    // Other sessions are left alone.
+    if _, err := f.svc.Refresh(ctx, other.RefreshToken); err != nil {
+        t.Errorf("another session's refresh: %v", err)
+    }
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+    ctx := context.Background()
+    f := newAuthFixture(t)
+    first := f.login(t)
+    second, err := f.svc.Refresh(ctx, first.RefreshToken)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if err := f.svc.Logout(ctx, second.RefreshToken); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := f.svc.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrUnauthenticated) {
+        t.Errorf("refresh after logout: got %v, want ErrUnauthenticated", err)
+    }
+    if err := f.svc.Logout(ctx, second.AccessToken); !errors.Is(err, ErrUnauthenticated) {
+        t.Errorf("logout with an access token: got %v, want ErrUnauthenticated", err)
+    }
+}
+
+func TestDeletedUserLosesSession(t *testing.T) {
+    ctx := context.Background()
+    f := newAuthFixture(t)
+    pair := f.login(t)
+
+    deleted := time.Now()
+    f.user.DeletedAt = &deleted
+    if err := f.repos.Users.Update(ctx, f.user); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := f.svc.Authenticate(ctx, pair.AccessToken); !errors.Is(err, ErrUnauthenticated) {
+        t.Errorf("access token of a deleted user: got %v, want ErrUnauthenticated", err)
+    }
+    if _, err := f.svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrUnauthenticated) {
+        t.Errorf("refresh token of a deleted user: got %v, want ErrUnauthenticated", err)
+    }
+}
+
+func TestAuthenticateAcceptsOnlyAccessTokens(t *testing.T) {
+    ctx := context.Background()
+    f := newAuthFixture(t)
+    pair := f.login(t)
+    now := time.Now()
+
+    sign := func(signer *auth.Signer, claims auth.Claims) string {
+        t.Helper()
+        token, err := signer.Sign(claims)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return token
+    }
+    valid := auth.Claims{Subject: f.user.ID, Type: auth.TokenAccess, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+    mfa := valid
+    mfa.Type, mfa.ID = auth.TokenMFA, "challenge"
+    expired := valid
+    expired.IssuedAt, expired.ExpiresAt = now.Add(-time.Hour).Unix(), now.Add(-time.Minute).Unix()
+    unknown := valid
+    unknown.Subject = "nobody"
+
+    if _, err := f.svc.Authenticate(ctx, sign(f.signer, valid)); err != nil {
+        t.Fatalf("valid access token: %v", err)
+    }
+    tests := []struct{ name, token string }{
+        {"refresh token", pair.RefreshToken},
+        {"mfa token", sign(f.signer, mfa)},
+        {"expired", sign(f.signer, expired)},
+        {"other signing key", sign(auth.NewSigner([]byte("other key")), valid)},
+        {"unknown user", sign(f.signer, unknown)},
+        {"garbage", "not.a.token"},
+    }
+    for _, tt := range tests {
+        if user, err := f.svc.Authenticate(ctx, tt.token); !errors.Is(err, ErrUnauthenticated) {
+            t.Errorf("%s: Authenticate = %s, %v; want ErrUnauthenticated", tt.name, user.ID, err)
+        }
+    }
+
+    // Nor do access tokens pass as refresh tokens.
+    if _, err := f.svc.Refresh(ctx, pair.AccessToken); !errors.Is(err, ErrUnauthenticated) {
+        t.Errorf("refresh with an access token: got %v, want ErrUnauthenticated", err)
+    }
+}
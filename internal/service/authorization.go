@@ -0,0 +1,52 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+
+    "cryptotrade/internal/domain"
+)
+
+// A request is authenticated either by a user session, which acts with the
+// permissions of the user's role, or by an API key, which acts with the
+// key's scopes.
+
+// Authorize checks that the request in ctx holds perm.
+func Authorize(ctx context.Context, perm domain.Permission) error {
+    _, err := authorize(ctx, perm)
+    return err
+}
+
+// authorize checks that the request in ctx holds perm and returns the user
+// behind it, which is the zero User for requests made with an API key.
+func authorize(ctx context.Context, perm domain.Permission) (domain.User, error) {
+    if key, ok := APIKeyFromContext(ctx); ok {
+        if !key.Can(perm) {
+            return domain.User{}, fmt.Errorf("%w: API key lacks the %s scope", ErrForbidden, perm)
+        }
+        return domain.User{}, nil
+    }
+    user, ok := UserFromContext(ctx)
+    if !ok {
+        return domain.User{}, fmt.Errorf("%w: authentication required", ErrUnauthenticated)
+    }
+    if !user.Role.Can(perm) {
+        return domain.User{}, fmt.Errorf("%w: requires the %s permission", ErrForbidden, perm)
+    }
+    return user, nil
+}
+
+// authorizeOwner checks that the user in ctx is ownerID or that the request
+// holds perm, which lets staff and integrations act on resources that belong
+// to someone else. resource names what is being accessed in the error.
+func authorizeOwner(ctx context.Context, resource, ownerID string, perm domain.Permission) error {
+    if user, ok := UserFromContext(ctx); ok && user.ID == ownerID {
+        return nil
+    }
+    err := Authorize(ctx, perm)
+    if errors.Is(err, ErrForbidden) {
+        return fmt.Errorf("%w: %s belongs to another user", ErrForbidden, resource)
+    }
+    return err
+}
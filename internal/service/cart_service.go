@@ -0,0 +1,290 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "time"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// CartService contains the business logic for shopping carts.
+type CartService struct {
+    carts    repository.CartRepository
+    users    repository.UserRepository
+    products repository.ProductRepository
+    tx       repository.TxManager
+    rates    ExchangeRateProvider
+    orders   *OrderService
+}
+
+// NewCartService creates a new CartService. Checkout places orders through orders.
+func NewCartService(cartRepo repository.CartRepository, userRepo repository.UserRepository, productRepo repository.ProductRepository, tx repository.TxManager, rates ExchangeRateProvider, orders *OrderService) *CartService {
+    return &CartService{carts: cartRepo, users: userRepo, products: productRepo, tx: tx, rates: rates, orders: orders}
+}
+
+// CartLine is a cart item annotated with the product's current price and stock.
+type CartLine struct {
+    domain.CartItem
+    Name string `json:"name,omitempty"`
+    // UnitPrice and LineTotal are in the cart's currency; both are nil when
+    // the product no longer exists.
+    UnitPrice *domain.Money `json:"unit_price,omitempty"`
+    LineTotal *domain.Money `json:"line_total,omitempty"`
+    Available int           `json:"available"`
+    // InStock reports whether available stock covers the quantity.
+    InStock bool `json:"in_stock"`
+    // Unavailable marks products, or variants, removed from the catalog
+    // since they were added.
+    Unavailable bool `json:"unavailable,omitempty"`
+}
+
+// CartView is a user's cart priced and checked against the live catalog.
+type CartView struct {
+    UserID string     `json:"user_id"`
+    Items  []CartLine `json:"items"`
+    // Subtotal is nil for an empty cart.
+    Subtotal      *domain.Money         `json:"subtotal,omitempty"`
+    ExchangeRates []domain.ExchangeRate `json:"exchange_rates,omitempty"`
+    // CheckoutReady is true when the cart has items and every line can be fulfilled.
+    CheckoutReady bool      `json:"checkout_ready"`
+    UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// CheckoutInput carries the order options chosen at checkout.
+type CheckoutInput struct {
+    Currency        string
+    PaymentCurrency string
+    PaymentMethod   domain.PaymentMethod
+    ShipTo          *domain.GeoPoint
+    // ShippingAddressID and BillingAddressID pick entries of the user's
+    // address book.
+    ShippingAddressID string
+    BillingAddressID  string
+    // CouponCodes are the coupons to apply to the order.
+    CouponCodes []string
+}
+
+// GetCart returns the user's cart priced in currency. When currency is empty
+// the currency of the first item's product is used.
+func (s *CartService) GetCart(ctx context.Context, userID, currency string) (CartView, error) {
+    if _, err := s.users.GetByID(ctx, userID); err != nil {
+        return CartView{}, err
+    }
+    cart, err := s.loadCart(ctx, s.carts, userID)
+    if err != nil {
+        return CartView{}, err
+    }
+    return s.annotate(ctx, cart, currency)
+}
+
+// AddItem adds quantity of a product, or of its variant sku, to the cart,
+// merging with any existing line.
+func (s *CartService) AddItem(ctx context.Context, userID, productID, sku string, quantity int, currency string) (CartView, error) {
+    if quantity <= 0 {
+        return CartView{}, fmt.Errorf("%w: quantity must be positive", ErrValidation)
+    }
+    return s.update(ctx, userID, productID, sku, currency, func(cart *domain.Cart) int {
+        return cart.Quantity(productID, sku) + quantity
+    })
+}
+
+// UpdateItem sets the quantity of a product, or of its variant sku, already
+// in the cart. A quantity of zero removes the line.
+func (s *CartService) UpdateItem(ctx context.Context, userID, productID, sku string, quantity int, currency string) (CartView, error) {
+    if quantity < 0 {
+        return CartView{}, fmt.Errorf("%w: quantity must not be negative", ErrValidation)
+    }
+    return s.update(ctx, userID, productID, sku, currency, func(*domain.Cart) int {
+        return quantity
+    })
+}
+
+// RemoveItem removes a product, or its variant sku, from the cart.
+func (s *CartService) RemoveItem(ctx context.Context, userID, productID, sku, currency string) (CartView, error) {
+    return s.UpdateItem(ctx, userID, productID, sku, 0, currency)
+}
+
+// ClearCart empties the user's cart.
+func (s *CartService) ClearCart(ctx context.Context, userID string) error {
+    if _, err := s.users.GetByID(ctx, userID); err != nil {
+        return err
+    }
+    return s.carts.Delete(ctx, userID)
+}
+
+// Checkout places an order for everything in the cart and empties it. The
+// order and the emptied cart commit together, so a failed checkout leaves the
+// cart as it was.
+func (s *CartService) Checkout(ctx context.Context, userID string, input CheckoutInput) (domain.Order, error) {
+    var (
+        cart      domain.Cart
+        order     domain.Order
+        lightning *domain.Invoice
+    )
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        if _, err := repos.Users.GetByID(ctx, userID); err != nil {
+            return err
+        }
+        var err error
+        cart, err = s.loadCart(ctx, repos.Carts, userID)
+        if err != nil {
+            return err
+        }
+        if len(cart.Items) == 0 {
+            return fmt.Errorf("%w: cart is empty", ErrValidation)
+        }
+
+        order, lightning, err = s.orders.createOrder(ctx, repos, CreateOrderInput{
+            UserID:            userID,
+            Items:             cart.OrderItems(),
+            Currency:          input.Currency,
+            PaymentCurrency:   input.PaymentCurrency,
+            PaymentMethod:     input.PaymentMethod,
+            ShipTo:            input.ShipTo,
+            ShippingAddressID: input.ShippingAddressID,
+            BillingAddressID:  input.BillingAddressID,
+            CouponCodes:       input.CouponCodes,
+        })
+        if err != nil {
+            return err
+        }
+        return repos.Carts.Delete(ctx, userID)
+    })
+    if err != nil {
+        return domain.Order{}, err
+    }
+    if lightning != nil {
+        placed, err := s.orders.finishLightningInvoice(ctx, order, *lightning)
+        if err != nil {
+            // The order was cancelled again, so the customer gets their
+            // cart back unless they have started a new one.
+            restoreErr := s.tx.WithinTx(context.WithoutCancel(ctx), func(ctx context.Context, repos repository.Repositories) error {
+                current, err := s.loadCart(ctx, repos.Carts, userID)
+                if err != nil || len(current.Items) > 0 {
+                    return err
+                }
+                return repos.Carts.Save(ctx, cart)
+            })
+            return domain.Order{}, errors.Join(err, restoreErr)
+        }
+        return placed, nil
+    }
+
+    return order, nil
+}
+
+// update sets the quantity of a cart line inside a transaction. change
+// returns the line's new quantity; a positive quantity must be of a known
+// product or variant with enough stock.
+func (s *CartService) update(ctx context.Context, userID, productID, sku, currency string, change func(cart *domain.Cart) int) (CartView, error) {
+    var cart domain.Cart
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        if _, err := repos.Users.GetByID(ctx, userID); err != nil {
+            return err
+        }
+        var err error
+        if cart, err = s.loadCart(ctx, repos.Carts, userID); err != nil {
+            return err
+        }
+
+        quantity := change(&cart)
+        if quantity > 0 {
+            product, err := repos.Products.GetByID(ctx, productID)
+            if err != nil {
+                return err
+            }
+            if err := product.CheckSKU(sku); err != nil {
+                return fmt.Errorf("%w: %w", ErrValidation, err)
+            }
+            if available := product.AvailableOf(sku); available < quantity {
+                return fmt.Errorf("%w: only %d of %s available", ErrValidation, available, describeItem(product.ID, sku))
+            }
+        } else if cart.Quantity(productID, sku) == 0 {
+            return repository.ErrNotFound
+        }
+
+        if err := cart.SetQuantity(productID, sku, quantity); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        cart.UpdatedAt = time.Now().UTC()
+        return repos.Carts.Save(ctx, cart)
+    })
+    if err != nil {
+        return CartView{}, err
+    }
+
+    return s.annotate(ctx, cart, currency)
+}
+
+// loadCart returns the user's saved cart, or an empty one if none was saved.
+func (s *CartService) loadCart(ctx context.Context, carts repository.CartRepository, userID string) (domain.Cart, error) {
+    cart, err := carts.Get(ctx, userID)
+    if errors.Is(err, repository.ErrNotFound) {
+        return domain.Cart{UserID: userID}, nil
+    }
+    return cart, err
+}
+
+// annotate prices each cart line in currency from the live catalog.
+func (s *CartService) annotate(ctx context.Context, cart domain.Cart, currency string) (CartView, error) {
+    view := CartView{
+        UserID:        cart.UserID,
+        Items:         make([]CartLine, 0, len(cart.Items)),
+        CheckoutReady: len(cart.Items) > 0,
+        UpdatedAt:     cart.UpdatedAt,
+    }
+
+    converter := newLineConverter(s.rates, currency)
+    var subtotal domain.Money
+    for _, item := range cart.Items {
+        line := CartLine{CartItem: item}
+        product, err := s.products.GetByID(ctx, item.ProductID)
+        if err == nil && product.CheckSKU(item.SKU) != nil {
+            // The variant was removed from the product since it was added.
+            err = repository.ErrNotFound
+        }
+        if errors.Is(err, repository.ErrNotFound) {
+            line.Unavailable = true
+            view.CheckoutReady = false
+            view.Items = append(view.Items, line)
+            continue
+        }
+        if err != nil {
+            return CartView{}, err
+        }
+
+        line.Name = product.Name
+        line.Available = product.AvailableOf(item.SKU)
+        line.InStock = line.Available >= item.Quantity
+        if !line.InStock {
+            view.CheckoutReady = false
+        }
+
+        price := product.PriceOf(item.SKU)
+        unit, err := converter.convert(ctx, price)
+        if err != nil {
+            return CartView{}, err
+        }
+        // Priced as checkout prices it, so the cart quotes what the order
+        // will charge.
+        total, err := unit.Mul(int64(item.Quantity))
+        if err != nil {
+            return CartView{}, fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        line.UnitPrice, line.LineTotal = &unit, &total
+
+        if view.Subtotal == nil {
+            subtotal = domain.NewMoney(0, total.Currency)
+        }
+        if subtotal, err = subtotal.Add(total); err != nil {
+            return CartView{}, fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        view.Subtotal = &subtotal
+        view.Items = append(view.Items, line)
+    }
+    view.ExchangeRates = converter.usedRates()
+    return view, nil
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "slices"
+    "time"
+
+    "github.com/google/uuid"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// CategoryService contains the business logic for the category taxonomy and
+// the assignment of products to categories.
+type CategoryService struct {
+    categories repository.CategoryRepository
+    products   repository.ProductRepository
+    tx         repository.TxManager
+}
+
+// NewCategoryService creates a new CategoryService.
+func NewCategoryService(categoryRepo repository.CategoryRepository, productRepo repository.ProductRepository, tx repository.TxManager) *CategoryService {
+    return &CategoryService{categories: categoryRepo, products: productRepo, tx: tx}
+}
+
+// CreateCategory persists a new category under an existing parent, or at the
+// top level when ParentID is empty.
+func (s *CategoryService) CreateCategory(ctx context.Context, input domain.Category) (domain.Category, error) {
+    category := domain.Category{
+        ID:        uuid.NewString(),
+        Name:      input.Name,
+        ParentID:  input.ParentID,
+        CreatedAt: time.Now().UTC(),
+    }
+
+    if err := category.Validate(); err != nil {
+        return domain.Category{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        if category.ParentID != "" {
+            if _, err := repos.Categories.GetByID(ctx, category.ParentID); err != nil {
+                return err
+            }
+        }
+        return repos.Categories.Create(ctx, category)
+    })
+    if err != nil {
+        return domain.Category{}, err
+    }
+
+    return category, nil
+}
+
+// UpdateCategory renames a category or moves it under another parent. A
+// category cannot be moved beneath one of its own descendants.
+func (s *CategoryService) UpdateCategory(ctx context.Context, id string, input domain.Category) (domain.Category, error) {
+    var category domain.Category
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        category, err = repos.Categories.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+
+        category.Name = input.Name
+        category.ParentID = input.ParentID
+
+        if err := category.Validate(); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        // Walk up from the new parent; meeting the category itself means the
+        // move would create a cycle.
+        for ancestorID := category.ParentID; ancestorID != ""; {
+            if ancestorID == category.ID {
+                return fmt.Errorf("%w: a category cannot be moved beneath its own subcategory", ErrValidation)
+            }
+            ancestor, err := repos.Categories.GetByID(ctx, ancestorID)
+            if err != nil {
+                return err
+            }
+            ancestorID = ancestor.ParentID
+        }
+        return repos.Categories.Update(ctx, category)
+    })
+    if err != nil {
+        return domain.Category{}, err
+    }
+
+    return category, nil
+}
+
+// DeleteCategory removes a category that has no subcategories and no products.
+func (s *CategoryService) DeleteCategory(ctx context.Context, id string) error {
+    return s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        if _, err := repos.Categories.GetByID(ctx, id); err != nil {
+            return err
+        }
+        all, err := repos.Categories.List(ctx)
+        if err != nil {
+            return err
+        }
+        if slices.ContainsFunc(all, func(c domain.Category) bool { return c.ParentID == id }) {
+            return fmt.Errorf("%w: category %s still has subcategories", repository.ErrConflict, id)
+        }
+        n, err := repos.Categories.CountProducts(ctx, id)
+        if err != nil {
+            return err
+        }
+        if n > 0 {
+            return fmt.Errorf("%w: category %s still has products assigned", repository.ErrConflict, id)
+        }
+        return repos.Categories.Delete(ctx, id)
+    })
+}
+
+// GetCategory returns a category by ID.
+func (s *CategoryService) GetCategory(ctx context.Context, id string) (domain.Category, error) {
+    return s.categories.GetByID(ctx, id)
+}
+
+// ListCategories returns every category ordered by name.
+func (s *CategoryService) ListCategories(ctx context.Context) ([]domain.Category, error) {
+    return s.categories.List(ctx)
+}
+
+// ListCategoryProducts returns a page of the products in a category and,
+// when includeDescendants is set, in any of its subcategories.
+func (s *CategoryService) ListCategoryProducts(ctx context.Context, id string, includeDescendants bool, input ListProductsInput) (repository.Page[domain.Product], error) {
+    if _, err := s.categories.GetByID(ctx, id); err != nil {
+        return repository.Page[domain.Product]{}, err
+    }
+    query, err := input.query()
+    if err != nil {
+        return repository.Page[domain.Product]{}, err
+    }
+
+    query.CategoryIDs = []string{id}
+    if includeDescendants {
+        all, err := s.categories.List(ctx)
+        if err != nil {
+            return repository.Page[domain.Product]{}, err
+        }
+        query.CategoryIDs = descendants(all, id)
+    }
+    return s.products.List(ctx, query)
+}
+
+// GetProductCategories returns the categories a product is assigned to.
+func (s *CategoryService) GetProductCategories(ctx context.Context, productID string) ([]domain.Category, error) {
+    if _, err := s.products.GetByID(ctx, productID); err != nil {
+        return nil, err
+    }
+    return s.categories.ListByProduct(ctx, productID)
+}
+
+// SetProductCategories replaces the categories a product is assigned to and
+// returns them.
+func (s *CategoryService) SetProductCategories(ctx context.Context, productID string, categoryIDs []string) ([]domain.Category, error) {
+    var categories []domain.Category
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        if _, err := repos.Products.GetByID(ctx, productID); err != nil {
+            return err
+        }
+        ids := slices.Compact(slices.Sorted(slices.Values(categoryIDs)))
+        for _, id := range ids {
+            if _, err := repos.Categories.GetByID(ctx, id); err != nil {
+                return err
+            }
+        }
+        if err := repos.Categories.SetProductCategories(ctx, productID, ids); err != nil {
+            return err
+        }
+        var err error
+        categories, err = repos.Categories.ListByProduct(ctx, productID)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return categories, nil
+}
+
+// descendants returns id followed by the IDs of every category nested
+// beneath it.
+func descendants(all []domain.Category, id string) []string {
+    children := make(map[string][]string)
+    for _, c := range all {
+        children[c.ParentID] = append(children[c.ParentID], c.ID)
+    }
+    ids := []string{id}
+    for i := 0; i < len(ids); i++ {
+        ids = append(ids, children[ids[i]]...)
+    }
+    return ids
+}
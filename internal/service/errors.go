@@ -4,3 +4,15 @@ import "errors"
 
 // ErrValidation indicates the input payload failed validation.
 var ErrValidation = errors.New("validation error")
+
+// ErrRateUnavailable is returned when no exchange rate is known for a currency pair.
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+// ErrInvalidTransition indicates a requested order status change is not allowed from the current status.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// ErrUnauthenticated indicates the request carries no valid credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrForbidden indicates the authenticated user may not perform the request.
+var ErrForbidden = errors.New("forbidden")
@@ -0,0 +1,216 @@
+package service
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math/big"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+// ExchangeRateProvider supplies the rate at which one currency converts to another.
+type ExchangeRateProvider interface {
+    // Rate returns how many units of to one unit of from is worth.
+    Rate(ctx context.Context, from, to string) (domain.ExchangeRate, error)
+}
+
+// StaticRateProvider serves rates from a fixed table keyed "FROM/TO". Inverse
+// pairs are derived automatically, pairs missing from the table are crossed
+// through a shared currency, and identical currencies convert at 1.
+type StaticRateProvider struct {
+    rates map[string]*big.Rat
+    // currencies is the sorted set of codes in the table, tried in order as cross-rate pivots.
+    currencies []string
+    asOf       time.Time
+}
+
+// NewStaticRateProvider parses a table of decimal rates such as {"BTC/USD": "65000"}.
+func NewStaticRateProvider(table map[string]string, asOf time.Time) (*StaticRateProvider, error) {
+    rates := make(map[string]*big.Rat, len(table))
+    seen := make(map[string]bool)
+    for pair, value := range table {
+        from, to, ok := strings.Cut(pair, "/")
+        if !ok || from == "" || to == "" {
+            return nil, fmt.Errorf("invalid currency pair %q", pair)
+        }
+        rate, ok := new(big.Rat).SetString(value)
+        if !ok || rate.Sign() <= 0 {
+            return nil, fmt.Errorf("invalid rate %q for %s", value, pair)
+        }
+        rates[pair] = rate
+        seen[from], seen[to] = true, true
+    }
+
+    currencies := make([]string, 0, len(seen))
+    for currency := range seen {
+        currencies = append(currencies, currency)
+    }
+    sort.Strings(currencies)
+    return &StaticRateProvider{rates: rates, currencies: currencies, asOf: asOf.UTC()}, nil
+}
+
+func (p *StaticRateProvider) Rate(_ context.Context, from, to string) (domain.ExchangeRate, error) {
+    if from == to {
+        return domain.NewExchangeRate(from, to, big.NewRat(1, 1), p.asOf)
+    }
+    if rate, ok := p.direct(from, to); ok {
+        return domain.NewExchangeRate(from, to, rate, p.asOf)
+    }
+    for _, via := range p.currencies {
+        first, ok := p.direct(from, via)
+        if !ok {
+            continue
+        }
+        second, ok := p.direct(via, to)
+        if !ok {
+            continue
+        }
+        return domain.NewExchangeRate(from, to, new(big.Rat).Mul(first, second), p.asOf)
+    }
+    return domain.ExchangeRate{}, fmt.Errorf("%w: %s to %s", ErrRateUnavailable, from, to)
+}
+
+// direct looks up a pair or its inverse in the table.
+func (p *StaticRateProvider) direct(from, to string) (*big.Rat, bool) {
+    if rate, ok := p.rates[from+"/"+to]; ok {
+        return rate, true
+    }
+    if rate, ok := p.rates[to+"/"+from]; ok {
+        return new(big.Rat).Inv(rate), true
+    }
+    return nil, false
+}
+
+// FileRateProvider serves rates from a JSON file and reloads it whenever its
+// modification time changes, which makes it convenient for local runs.
+//
+// The file has the shape {"as_of": "2024-01-01T00:00:00Z", "rates": {"BTC/USD": "65000"}};
+// when as_of is omitted the file's modification time is used.
+type FileRateProvider struct {
+    path string
+
+    mu      sync.Mutex
+    modTime time.Time
+    static  *StaticRateProvider
+}
+
+// NewFileRateProvider loads the rate file at path.
+func NewFileRateProvider(path string) (*FileRateProvider, error) {
+    p := &FileRateProvider{path: path}
+    if _, err := p.current(); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+func (p *FileRateProvider) Rate(ctx context.Context, from, to string) (domain.ExchangeRate, error) {
+    static, err := p.current()
+    if err != nil {
+        return domain.ExchangeRate{}, err
+    }
+    return static.Rate(ctx, from, to)
+}
+
+type rateFile struct {
+    AsOf  time.Time         `json:"as_of"`
+    Rates map[string]string `json:"rates"`
+}
+
+func (p *FileRateProvider) current() (*StaticRateProvider, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    info, err := os.Stat(p.path)
+    if err != nil {
+        return nil, fmt.Errorf("stat rate file: %w", err)
+    }
+    if p.static != nil && info.ModTime().Equal(p.modTime) {
+        return p.static, nil
+    }
+
+    data, err := os.ReadFile(p.path)
+    if err != nil {
+        return nil, fmt.Errorf("read rate file: %w", err)
+    }
+    var file rateFile
+    if err := json.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("decode rate file: %w", err)
+    }
+    asOf := file.AsOf
+    if asOf.IsZero() {
+        asOf = info.ModTime()
+    }
+
+    static, err := NewStaticRateProvider(file.Rates, asOf)
+    if err != nil {
+        return nil, fmt.Errorf("rate file %s: %w", p.path, err)
+    }
+    p.static = static
+    p.modTime = info.ModTime()
+    return static, nil
+}
+
+// quote converts price into currency using provider, returning the rate used.
+func quote(ctx context.Context, provider ExchangeRateProvider, price domain.Money, currency string) (domain.Money, domain.ExchangeRate, error) {
+    if _, ok := domain.CurrencyExponent(currency); !ok {
+        return domain.Money{}, domain.ExchangeRate{}, fmt.Errorf("%w: unsupported currency %q", ErrValidation, currency)
+    }
+
+    rate, err := provider.Rate(ctx, price.Currency, currency)
+    if errors.Is(err, ErrRateUnavailable) {
+        return domain.Money{}, domain.ExchangeRate{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+    if err != nil {
+        return domain.Money{}, domain.ExchangeRate{}, err
+    }
+
+    converted, err := rate.Convert(price, domain.RoundHalfEven)
+    if err != nil {
+        return domain.Money{}, domain.ExchangeRate{}, err
+    }
+    return converted, rate, nil
+}
+
+// lineConverter converts amounts into one target currency, fetching each
+// source currency's rate once so every line of a cart or order shares the
+// same snapshot. An empty target adopts the currency of the first amount.
+type lineConverter struct {
+    provider ExchangeRateProvider
+    currency string
+    rates    map[string]domain.ExchangeRate
+}
+
+func newLineConverter(provider ExchangeRateProvider, currency string) *lineConverter {
+    return &lineConverter{provider: provider, currency: currency, rates: make(map[string]domain.ExchangeRate)}
+}
+
+func (c *lineConverter) convert(ctx context.Context, amount domain.Money) (domain.Money, error) {
+    if c.currency == "" {
+        c.currency = amount.Currency
+    }
+    if amount.Currency == c.currency {
+        return amount, nil
+    }
+
+    rate, ok := c.rates[amount.Currency]
+    if !ok {
+        var err error
+        if _, rate, err = quote(ctx, c.provider, amount, c.currency); err != nil {
+            return domain.Money{}, err
+        }
+        c.rates[amount.Currency] = rate
+    }
+    return rate.Convert(amount, domain.RoundHalfEven)
+}
+
+// usedRates returns the rates applied so far, ordered by source currency.
+func (c *lineConverter) usedRates() []domain.ExchangeRate {
+    return sortedRates(c.rates)
+}
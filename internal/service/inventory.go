@@ -0,0 +1,478 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/google/uuid"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// systemActor is recorded as the actor of stock movements the service makes
+// on its own account, such as committing a paid order.
+const systemActor = "system"
+
+// recordMovement appends a stock change to the ledger, assigning an ID when
+// the movement has none.
+func recordMovement(ctx context.Context, repos repository.Repositories, movement domain.StockMovement) error {
+    if movement.ID == "" {
+        movement.ID = uuid.NewString()
+    }
+    if err := movement.Validate(); err != nil {
+        return fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+    return repos.StockMovements.Create(ctx, movement)
+}
+
+// holdStock reserves quantity of a product, or of one of its variants, so
+// concurrent orders cannot claim it, allocating the units to warehouses with
+// strategy. The product and its
+// warehouse levels are written immediately so repeated product IDs in one
+// order check against the already-held stock; the reservation rows are
+// recorded by recordHolds once the order exists.
+func holdStock(ctx context.Context, repos repository.Repositories, strategy AllocationStrategy, req AllocationRequest) (domain.Product, []domain.Allocation, error) {
+    product, err := repos.Products.GetByID(ctx, req.ProductID)
+    if err != nil {
+        return domain.Product{}, nil, err
+    }
+    if err := product.CheckSKU(req.SKU); err != nil {
+        return domain.Product{}, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+    if product.AvailableOf(req.SKU) < req.Quantity {
+        return domain.Product{}, nil, fmt.Errorf("%w: insufficient stock for %s", ErrValidation, describeItem(product.ID, req.SKU))
+    }
+
+    levels, err := repos.StockLevels.ListByProduct(ctx, product.ID)
+    if err != nil {
+        return domain.Product{}, nil, err
+    }
+    // Only warehouses holding the variant ordered are candidates.
+    byWarehouse := make(map[string]domain.StockLevel, len(levels))
+    candidates := make([]AllocationCandidate, 0, len(levels))
+    for _, level := range levels {
+        if level.SKU != req.SKU {
+            continue
+        }
+        byWarehouse[level.WarehouseID] = level
+        if level.Available() <= 0 {
+            continue
+        }
+        warehouse, err := repos.Warehouses.GetByID(ctx, level.WarehouseID)
+        if err != nil {
+            return domain.Product{}, nil, err
+        }
+        candidates = append(candidates, AllocationCandidate{Warehouse: warehouse, Available: level.Available()})
+    }
+
+    allocations := strategy.Allocate(req, candidates)
+    remaining := req.Quantity
+    for i, allocation := range allocations {
+        level, ok := byWarehouse[allocation.WarehouseID]
+        if !ok || allocation.Quantity <= 0 || allocation.Quantity > level.Available() || allocation.Quantity > remaining {
+            return domain.Product{}, nil, fmt.Errorf("allocation strategy returned an invalid allocation of %d from warehouse %q", allocation.Quantity, allocation.WarehouseID)
+        }
+        allocations[i].ProductID = product.ID
+        allocations[i].SKU = req.SKU
+        level.Reserved += allocation.Quantity
+        if err := repos.StockLevels.Save(ctx, level); err != nil {
+            return domain.Product{}, nil, err
+        }
+        byWarehouse[level.WarehouseID] = level
+        remaining -= allocation.Quantity
+    }
+    if remaining > 0 {
+        if unassignedStockOf(product, req.SKU, levels).Available() < remaining {
+            return domain.Product{}, nil, fmt.Errorf("%w: insufficient stock for %s", ErrValidation, describeItem(product.ID, req.SKU))
+        }
+        allocations = append(allocations, domain.Allocation{ProductID: product.ID, SKU: req.SKU, Quantity: remaining})
+    }
+
+    if err := product.AdjustStock(req.SKU, 0, req.Quantity); err != nil {
+        return domain.Product{}, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+    if err := repos.Products.Update(ctx, product); err != nil {
+        return domain.Product{}, nil, err
+    }
+    return product, allocations, nil
+}
+
+// describeItem names a product, or a variant of it, in error messages.
+func describeItem(productID, sku string) string {
+    if sku == "" {
+        return "product " + productID
+    }
+    return "variant " + sku + " of product " + productID
+}
+
+// unassignedStock returns the part of a product's stock and reservations not
+// held at any warehouse, as a level without a warehouse ID.
+func unassignedStock(product domain.Product, levels []domain.StockLevel) domain.StockLevel {
+    unassigned := domain.StockLevel{ProductID: product.ID, OnHand: product.Stock, Reserved: product.Reserved}
+    for _, level := range levels {
+        unassigned.OnHand -= level.OnHand
+        unassigned.Reserved -= level.Reserved
+    }
+    return unassigned
+}
+
+// unassignedStockOf returns the part of the stock of a product, or of its
+// variant sku, not held at any warehouse.
+func unassignedStockOf(product domain.Product, sku string, levels []domain.StockLevel) domain.StockLevel {
+    unassigned := domain.StockLevel{ProductID: product.ID, SKU: sku, OnHand: product.Stock, Reserved: product.Reserved}
+    if variant, ok := product.Variant(sku); ok {
+        unassigned.OnHand, unassigned.Reserved = variant.Stock, variant.Reserved
+    }
+    for _, level := range levels {
+        if level.SKU == sku {
+            unassigned.OnHand -= level.OnHand
+            unassigned.Reserved -= level.Reserved
+        }
+    }
+    return unassigned
+}
+
+// recordHolds stores a held reservation for every allocation of order.
+func recordHolds(ctx context.Context, repos repository.Repositories, order domain.Order, expiresAt time.Time) error {
+    for _, allocation := range order.Allocations {
+        reservation := domain.Reservation{
+            ID:          uuid.NewString(),
+            OrderID:     order.ID,
+            ProductID:   allocation.ProductID,
+            SKU:         allocation.SKU,
+            WarehouseID: allocation.WarehouseID,
+            Quantity:    allocation.Quantity,
+            Status:      domain.ReservationStatusHeld,
+            ExpiresAt:   expiresAt,
+            CreatedAt:   order.CreatedAt,
+        }
+        if err := repos.Reservations.Create(ctx, reservation); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// commitReservations turns an order's holds into a permanent stock decrement
+// once it is paid. Holds that already expired are taken again from the same
+// warehouse, failing with ErrValidation when too little is left there.
+func commitReservations(ctx context.Context, repos repository.Repositories, orderID string, now time.Time) error {
+    reservations, err := repos.Reservations.ListByOrder(ctx, orderID)
+    if err != nil {
+        return err
+    }
+
+    for _, reservation := range reservations {
+        if reservation.Status == domain.ReservationStatusCommitted {
+            continue
+        }
+
+        product, err := repos.Products.GetByID(ctx, reservation.ProductID)
+        if errors.Is(err, repository.ErrNotFound) {
+            // The product was removed from the catalog; there is no stock to take.
+            continue
+        }
+        if err != nil {
+            return err
+        }
+
+        held := reservation.Status == domain.ReservationStatusHeld
+        reservedDelta := 0
+        if held {
+            reservedDelta = -reservation.Quantity
+        } else {
+            available, err := sourceAvailable(ctx, repos, product, reservation.SKU, reservation.WarehouseID)
+            if err != nil {
+                return err
+            }
+            if reservation.SKU != "" {
+                available = min(available, product.AvailableOf(reservation.SKU))
+            }
+            if available < reservation.Quantity {
+                return fmt.Errorf("%w: insufficient stock for %s; its reservation expired before payment", ErrValidation, describeItem(product.ID, reservation.SKU))
+            }
+        }
+        if err := product.AdjustStock(reservation.SKU, -reservation.Quantity, reservedDelta); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        if err := repos.Products.Update(ctx, product); err != nil {
+            return err
+        }
+        if err := adjustLevel(ctx, repos, reservation, -reservation.Quantity, reservedDelta); err != nil {
+            return err
+        }
+        if err := recordMovement(ctx, repos, domain.StockMovement{
+            ProductID:   reservation.ProductID,
+            SKU:         reservation.SKU,
+            WarehouseID: reservation.WarehouseID,
+            Quantity:    -reservation.Quantity,
+            Reason:      domain.StockMovementSale,
+            ReferenceID: orderID,
+            Actor:       systemActor,
+            CreatedAt:   now,
+        }); err != nil {
+            return err
+        }
+
+        reservation.Status = domain.ReservationStatusCommitted
+        reservation.ResolvedAt = &now
+        if err := repos.Reservations.Update(ctx, reservation); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// sourceAvailable returns the available stock of product, or of its variant
+// sku, at warehouseID, or in its unassigned stock when warehouseID is empty.
+func sourceAvailable(ctx context.Context, repos repository.Repositories, product domain.Product, sku, warehouseID string) (int, error) {
+    if warehouseID != "" {
+        level, err := repos.StockLevels.Get(ctx, warehouseID, product.ID, sku)
+        if errors.Is(err, repository.ErrNotFound) {
+            return 0, nil
+        }
+        if err != nil {
+            return 0, err
+        }
+        return level.Available(), nil
+    }
+
+    levels, err := repos.StockLevels.ListByProduct(ctx, product.ID)
+    if err != nil {
+        return 0, err
+    }
+    return unassignedStockOf(product, sku, levels).Available(), nil
+}
+
+// adjustLevel applies a reservation's stock change to the warehouse it was
+// allocated from. Reservations on unassigned stock have no level to change.
+func adjustLevel(ctx context.Context, repos repository.Repositories, reservation domain.Reservation, onHandDelta, reservedDelta int) error {
+    if reservation.WarehouseID == "" {
+        return nil
+    }
+    level, err := repos.StockLevels.Get(ctx, reservation.WarehouseID, reservation.ProductID, reservation.SKU)
+    if err != nil {
+        return err
+    }
+    level.OnHand += onHandDelta
+    level.Reserved = max(level.Reserved+reservedDelta, 0)
+    return repos.StockLevels.Save(ctx, level)
+}
+
+// releaseReservations gives an order's stock back: held units return to
+// available stock and committed units to physical stock at the warehouse
+// they were allocated from, recorded in the ledger against actor. It reports
+// false when the order has no reservations because it predates them, in
+// which case its stock was taken outright at creation.
+func releaseReservations(ctx context.Context, repos repository.Repositories, orderID, actor string, now time.Time) (bool, error) {
+    reservations, err := repos.Reservations.ListByOrder(ctx, orderID)
+    if err != nil {
+        return false, err
+    }
+    if len(reservations) == 0 {
+        return false, nil
+    }
+
+    for _, reservation := range reservations {
+        if err := releaseReservation(ctx, repos, reservation, actor, now); err != nil {
+            return true, err
+        }
+    }
+    return true, nil
+}
+
+func releaseReservation(ctx context.Context, repos repository.Repositories, reservation domain.Reservation, actor string, now time.Time) error {
+    if reservation.Status == domain.ReservationStatusReleased {
+        return nil
+    }
+
+    product, err := repos.Products.GetByID(ctx, reservation.ProductID)
+    switch {
+    case errors.Is(err, repository.ErrNotFound):
+        // The product was removed from the catalog; there is nothing to restock.
+    case err != nil:
+        return err
+    case product.CheckSKU(reservation.SKU) != nil:
+        // The variant was removed, or the product's variants were reworked,
+        // since the reservation; there is nothing to restock.
+    default:
+        onHandDelta, reservedDelta := 0, 0
+        if reservation.Status == domain.ReservationStatusHeld {
+            reservedDelta = -reservation.Quantity
+        } else {
+            onHandDelta = reservation.Quantity
+        }
+        if err := product.AdjustStock(reservation.SKU, onHandDelta, reservedDelta); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        if err := repos.Products.Update(ctx, product); err != nil {
+            return err
+        }
+        if err := adjustLevel(ctx, repos, reservation, onHandDelta, reservedDelta); err != nil {
+            return err
+        }
+        if onHandDelta != 0 {
+            if err := recordMovement(ctx, repos, domain.StockMovement{
+                ProductID:   reservation.ProductID,
+                SKU:         reservation.SKU,
+                WarehouseID: reservation.WarehouseID,
+                Quantity:    onHandDelta,
+                Reason:      domain.StockMovementCancel,
+                ReferenceID: reservation.OrderID,
+                Actor:       actor,
+                CreatedAt:   now,
+            }); err != nil {
+                return err
+            }
+        }
+    }
+
+    reservation.Status = domain.ReservationStatusReleased
+    reservation.ResolvedAt = &now
+    return repos.Reservations.Update(ctx, reservation)
+}
+
+// ReservationSweeper periodically deals with stock holds that expired before
+// their order was paid. Holds whose invoice can still be paid, or has a
+// payment gathering confirmations, are extended so that the payment does not
+// settle an order whose stock was sold elsewhere. Otherwise the order is
+// cancelled, returning its units to available stock and its coupons.
+type ReservationSweeper struct {
+    tx       repository.TxManager
+    interval time.Duration
+    // extension is how long an expired hold is extended by while its
+    // invoice awaits payment.
+    extension time.Duration
+    now       func() time.Time
+}
+
+// NewReservationSweeper creates a sweeper that runs every interval and
+// extends holds by extension at a time.
+func NewReservationSweeper(tx repository.TxManager, interval, extension time.Duration) *ReservationSweeper {
+    return &ReservationSweeper{
+        tx:        tx,
+        interval:  interval,
+        extension: extension,
+        now:       func() time.Time { return time.Now().UTC() },
+    }
+}
+
+// expiredHoldReason is recorded on orders the sweeper cancels.
+const expiredHoldReason = "payment was not received before the stock hold expired"
+
+// Run sweeps until ctx is cancelled.
+func (s *ReservationSweeper) Run(ctx context.Context) {
+    ticker := time.NewTicker(s.interval)
+    defer ticker.Stop()
+
+    for {
+        if released, err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+            log.Printf("reservation sweeper: %v", err)
+        } else if released > 0 {
+            log.Printf("reservation sweeper: released %d expired holds", released)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// Sweep handles the expired holds of each order in a transaction of its own
+// and returns how many were released. An order that cannot be swept is
+// logged and left for the next sweep rather than holding up the others.
+func (s *ReservationSweeper) Sweep(ctx context.Context) (int, error) {
+    now := s.now()
+    var expired []domain.Reservation
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        expired, err = repos.Reservations.ListExpired(ctx, now)
+        return err
+    })
+    if err != nil {
+        return 0, fmt.Errorf("list expired reservations: %w", err)
+    }
+
+    var orderIDs []string
+    seen := make(map[string]bool)
+    for _, reservation := range expired {
+        if !seen[reservation.OrderID] {
+            seen[reservation.OrderID] = true
+            orderIDs = append(orderIDs, reservation.OrderID)
+        }
+    }
+
+    var released int
+    for _, orderID := range orderIDs {
+        if err := ctx.Err(); err != nil {
+            return released, err
+        }
+        var n int
+        err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+            var err error
+            n, err = s.sweepOrder(ctx, repos, orderID, now)
+            return err
+        })
+        if err != nil {
+            log.Printf("reservation sweeper: order %s: %v", orderID, err)
+            continue
+        }
+        released += n
+    }
+    return released, nil
+}
+
+// sweepOrder extends or releases the expired holds of one order and returns
+// how many it released. The holds are read again in the order's own
+// transaction, since the order may have been paid or cancelled since they
+// were listed.
+func (s *ReservationSweeper) sweepOrder(ctx context.Context, repos repository.Repositories, orderID string, now time.Time) (int, error) {
+    reservations, err := repos.Reservations.ListByOrder(ctx, orderID)
+    if err != nil {
+        return 0, err
+    }
+    var expired []domain.Reservation
+    for _, reservation := range reservations {
+        if reservation.Status == domain.ReservationStatusHeld && !reservation.ExpiresAt.After(now) {
+            expired = append(expired, reservation)
+        }
+    }
+    if len(expired) == 0 {
+        return 0, nil
+    }
+
+    invoice, err := repos.Invoices.GetByOrderID(ctx, orderID)
+    if err != nil && !errors.Is(err, repository.ErrNotFound) {
+        return 0, err
+    }
+    if err == nil && invoice.AwaitingPayment(now) {
+        for _, reservation := range expired {
+            reservation.ExpiresAt = now.Add(s.extension)
+            if err := repos.Reservations.Update(ctx, reservation); err != nil {
+                return 0, err
+            }
+        }
+        return 0, nil
+    }
+
+    order, err := repos.Orders.GetByID(ctx, orderID)
+    if err != nil {
+        return 0, err
+    }
+    if order.Status == domain.OrderStatusPending {
+        // The order goes the way of a cancellation, releasing all its holds
+        // and the coupons it used.
+        return len(expired), cancelOrder(ctx, repos, &order, systemActor, expiredHoldReason, now)
+    }
+    for _, reservation := range expired {
+        if err := releaseReservation(ctx, repos, reservation, systemActor, now); err != nil {
+            return 0, fmt.Errorf("reservation %s: %w", reservation.ID, err)
+        }
+    }
+    return len(expired), nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+    "fmt"
+    "time"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// ListInput carries the paging and sorting requested for a list.
+type ListInput struct {
+    // Limit is the page size; zero means repository.DefaultLimit.
+    Limit int
+    // Cursor is the next_cursor of the previous page.
+    Cursor string
+    // Sort is a comma-separated list of fields, each prefixed with '-' for
+    // descending order, such as "price,-created_at".
+    Sort string
+}
+
+func (in ListInput) options() (repository.ListOptions, error) {
+    sort, err := repository.ParseSort(in.Sort)
+    if err != nil {
+        return repository.ListOptions{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+    return repository.ListOptions{Limit: in.Limit, Cursor: in.Cursor, Sort: sort}, nil
+}
+
+// ListProductsInput selects a page of products.
+type ListProductsInput struct {
+    ListInput
+    // PriceCurrency keeps only products priced in that currency; MinPrice
+    // and MaxPrice are decimal amounts in it.
+    PriceCurrency string
+    MinPrice      string
+    MaxPrice      string
+    InStock       bool
+}
+
+func (in ListProductsInput) query() (repository.ProductQuery, error) {
+    opts, err := in.options()
+    if err != nil {
+        return repository.ProductQuery{}, err
+    }
+    query := repository.ProductQuery{ListOptions: opts, PriceCurrency: in.PriceCurrency, InStock: in.InStock}
+    if in.PriceCurrency != "" {
+        if _, ok := domain.CurrencyExponent(in.PriceCurrency); !ok {
+            return repository.ProductQuery{}, fmt.Errorf("%w: price currency %q is not supported", ErrValidation, in.PriceCurrency)
+        }
+    }
+    for _, bound := range []struct {
+        amount string
+        dst    **int64
+    }{{in.MinPrice, &query.MinPrice}, {in.MaxPrice, &query.MaxPrice}} {
+        if bound.amount == "" {
+            continue
+        }
+        if in.PriceCurrency == "" {
+            return repository.ProductQuery{}, fmt.Errorf("%w: a price range requires a price currency", ErrValidation)
+        }
+        price, err := domain.ParseMoney(bound.amount, in.PriceCurrency)
+        if err != nil {
+            return repository.ProductQuery{}, fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        *bound.dst = &price.Amount
+    }
+    if err := query.Validate(); err != nil {
+        return repository.ProductQuery{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+    return query, nil
+}
+
+// ListUsersInput selects a page of users.
+type ListUsersInput struct {
+    ListInput
+    Email string
+}
+
+func (in ListUsersInput) query() (repository.UserQuery, error) {
+    opts, err := in.options()
+    if err != nil {
+        return repository.UserQuery{}, err
+    }
+    query := repository.UserQuery{ListOptions: opts, Email: in.Email}
+    if err := query.Validate(); err != nil {
+        return repository.UserQuery{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+    return query, nil
+}
+
+// ListOrdersInput selects a page of orders.
+type ListOrdersInput struct {
+    ListInput
+    UserID string
+    Status domain.OrderStatus
+    // CreatedFrom and CreatedTo bound the creation time to
+    // [CreatedFrom, CreatedTo); zero times leave that side open.
+    CreatedFrom time.Time
+    CreatedTo   time.Time
+    // RefundDue selects only orders with a payment waiting to be refunded.
+    RefundDue bool
+}
+
+func (in ListOrdersInput) query() (repository.OrderQuery, error) {
+    opts, err := in.options()
+    if err != nil {
+        return repository.OrderQuery{}, err
+    }
+    query := repository.OrderQuery{
+        ListOptions: opts,
+        UserID:      in.UserID,
+        Status:      in.Status,
+        CreatedFrom: in.CreatedFrom,
+        CreatedTo:   in.CreatedTo,
+        RefundDue:   in.RefundDue,
+    }
+    if err := query.Validate(); err != nil {
+        return repository.OrderQuery{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+    return query, nil
+}
@@ -2,7 +2,10 @@ package service
 
 import (
     "context"
+    "errors"
     "fmt"
+    "slices"
+    "sort"
     "time"
 
     "github.com/google/uuid"
@@ -16,68 +19,530 @@ type OrderService struct {
     orders   repository.OrderRepository
     users    repository.UserRepository
     products repository.ProductRepository
+    tx       repository.TxManager
+    rates    ExchangeRateProvider
+    payments *PaymentService
+    holdTTL  time.Duration
+    strategy AllocationStrategy
+    shipping *domain.Money
 }
 
-// NewOrderService creates a new OrderService.
-func NewOrderService(orderRepo repository.OrderRepository, userRepo repository.UserRepository, productRepo repository.ProductRepository) *OrderService {
-    return &OrderService{orders: orderRepo, users: userRepo, products: productRepo}
+// NewOrderService creates a new OrderService. Stock for a new order is held
+// for holdTTL; unpaid holds are released after that by a ReservationSweeper.
+// strategy decides which warehouses ship each item. shipping is the flat fee
+// charged on every order, or nil when orders ship free.
+func NewOrderService(orderRepo repository.OrderRepository, userRepo repository.UserRepository, productRepo repository.ProductRepository, tx repository.TxManager, rates ExchangeRateProvider, payments *PaymentService, holdTTL time.Duration, strategy AllocationStrategy, shipping *domain.Money) *OrderService {
+    return &OrderService{orders: orderRepo, users: userRepo, products: productRepo, tx: tx, rates: rates, payments: payments, holdTTL: holdTTL, strategy: strategy, shipping: shipping}
+}
+
+// CreateOrderInput describes an order to be placed.
+type CreateOrderInput struct {
+    UserID string
+    Items  []domain.OrderItem
+    // Currency the order is quoted and totalled in. When empty the currency
+    // of the first item's product is used.
+    Currency string
+    // PaymentCurrency is the coin the invoice is issued in. When empty the
+    // first configured coin is used; no invoice is issued if payments are disabled.
+    PaymentCurrency string
+    // PaymentMethod selects on-chain or Lightning payment. When empty
+    // on-chain is used if any coin is configured.
+    PaymentMethod domain.PaymentMethod
+    // ShipTo is the delivery location used by nearest-warehouse allocation.
+    // When it is nil the location of the shipping address is used, if any.
+    ShipTo *domain.GeoPoint
+    // ShippingAddressID and BillingAddressID pick entries of the user's
+    // address book, which are copied onto the order.
+    ShippingAddressID string
+    BillingAddressID  string
+    // CouponCodes are the coupons the customer entered; each must apply to
+    // the order. Promotions without a code are applied on top of them.
+    CouponCodes []string
 }
 
 // CreateOrder creates a new order for the supplied user and items.
-func (s *OrderService) CreateOrder(ctx context.Context, userID string, items []domain.OrderItem) (domain.Order, error) {
+func (s *OrderService) CreateOrder(ctx context.Context, input CreateOrderInput) (domain.Order, error) {
+    var (
+        order     domain.Order
+        lightning *domain.Invoice
+    )
+    // The stock check, holds and order insert share one transaction so a
+    // failure part-way leaves stock untouched and concurrent orders cannot oversell.
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        order, lightning, err = s.createOrder(ctx, repos, input)
+        return err
+    })
+    if err != nil {
+        return domain.Order{}, err
+    }
+    if lightning != nil {
+        return s.finishLightningInvoice(ctx, order, *lightning)
+    }
+
+    return order, nil
+}
+
+// finishLightningInvoice asks the Lightning node for the payment request of
+// an invoice drafted by createOrder and records the invoice on the order. It
+// runs after the order has committed so that the call to the node does not
+// hold a transaction open. When the node fails, the order is cancelled again.
+func (s *OrderService) finishLightningInvoice(ctx context.Context, order domain.Order, invoice domain.Invoice) (domain.Order, error) {
+    requestErr := s.payments.requestLightningPayment(ctx, &invoice)
+    // The order has to be settled one way or the other even if the request
+    // that placed it was cancelled meanwhile.
+    ctx = context.WithoutCancel(ctx)
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        current, err := repos.Orders.GetByID(ctx, order.ID)
+        if err != nil {
+            return err
+        }
+        if requestErr != nil {
+            return cancelOrder(ctx, repos, &current, systemActor, "the payment invoice could not be issued", time.Now().UTC())
+        }
+        if err := repos.Invoices.Create(ctx, invoice); err != nil {
+            return err
+        }
+        current.InvoiceID = invoice.ID
+        order = current
+        return repos.Orders.Update(ctx, current)
+    })
+    if requestErr != nil {
+        return domain.Order{}, errors.Join(requestErr, err)
+    }
+    if err != nil {
+        return domain.Order{}, err
+    }
+
+    return order, nil
+}
+
+// createOrder places an order using repos, so callers can combine it with
+// other writes in one transaction. A Lightning invoice cannot be issued
+// within the transaction, as it calls out to the node; it is returned
+// drafted instead, for the caller to finish with finishLightningInvoice once
+// the transaction has committed.
+func (s *OrderService) createOrder(ctx context.Context, repos repository.Repositories, input CreateOrderInput) (domain.Order, *domain.Invoice, error) {
     order := domain.Order{
         ID:     uuid.NewString(),
-        UserID: userID,
-        Items:  items,
+        UserID: input.UserID,
+        Items:  slices.Clone(input.Items),
     }
 
     if err := order.Validate(); err != nil {
-        return domain.Order{}, fmt.Errorf("%w: %w", ErrValidation, err)
+        return domain.Order{}, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+    if input.ShipTo != nil {
+        if err := input.ShipTo.Validate(); err != nil {
+            return domain.Order{}, nil, fmt.Errorf("%w: ship_to: %w", ErrValidation, err)
+        }
     }
 
-    if _, err := s.users.GetByID(ctx, userID); err != nil {
-        return domain.Order{}, err
+    user, err := repos.Users.GetByID(ctx, order.UserID)
+    if err != nil {
+        return domain.Order{}, nil, err
+    }
+    if !user.EmailVerified {
+        return domain.Order{}, nil, fmt.Errorf("%w: verify your email address before placing an order", ErrForbidden)
+    }
+
+    shipTo := input.ShipTo
+    if input.ShippingAddressID != "" {
+        address, err := orderAddress(ctx, repos, user.ID, input.ShippingAddressID, domain.AddressShipping)
+        if err != nil {
+            return domain.Order{}, nil, err
+        }
+        order.ShippingAddress = &address.PostalAddress
+        if shipTo == nil {
+            shipTo = address.Location
+        }
+    }
+    if input.BillingAddressID != "" {
+        address, err := orderAddress(ctx, repos, user.ID, input.BillingAddressID, domain.AddressBilling)
+        if err != nil {
+            return domain.Order{}, nil, err
+        }
+        order.BillingAddress = &address.PostalAddress
     }
 
-    var total float64
-    updatedProducts := make([]domain.Product, 0, len(items))
+    converter := newLineConverter(s.rates, input.Currency)
+    pricing := orderPricing{repos: repos, converter: converter, userID: user.ID, now: time.Now().UTC()}
+    for i, item := range order.Items {
+        if item.SKU != "" {
+            product, err := repos.Products.GetBySKU(ctx, item.SKU)
+            if err != nil {
+                return domain.Order{}, nil, err
+            }
+            if item.ProductID != "" && item.ProductID != product.ID {
+                return domain.Order{}, nil, fmt.Errorf("%w: sku %s is not a variant of product %s", ErrValidation, item.SKU, item.ProductID)
+            }
+            item.ProductID = product.ID
+            order.Items[i].ProductID = product.ID
+        }
+        product, allocations, err := holdStock(ctx, repos, s.strategy, AllocationRequest{
+            ProductID: item.ProductID,
+            SKU:       item.SKU,
+            Quantity:  item.Quantity,
+            ShipTo:    shipTo,
+        })
+        if err != nil {
+            return domain.Order{}, nil, err
+        }
+        order.Allocations = append(order.Allocations, allocations...)
 
-    for _, item := range items {
-        product, err := s.products.GetByID(ctx, item.ProductID)
+        unitPrice, err := converter.convert(ctx, product.PriceOf(item.SKU))
         if err != nil {
-            return domain.Order{}, err
+            return domain.Order{}, nil, err
+        }
+        subtotal, err := unitPrice.Mul(int64(item.Quantity))
+        if err != nil {
+            return domain.Order{}, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        if i == 0 {
+            pricing.subtotal = domain.NewMoney(0, subtotal.Currency)
         }
-        if product.Stock < item.Quantity {
-            return domain.Order{}, fmt.Errorf("%w: insufficient stock for product %s", ErrValidation, product.ID)
+        if pricing.subtotal, err = pricing.subtotal.Add(subtotal); err != nil {
+            return domain.Order{}, nil, fmt.Errorf("%w: %w", ErrValidation, err)
         }
+        pricing.lines = append(pricing.lines, pricedLine{productID: product.ID, quantity: item.Quantity, unitPrice: unitPrice, subtotal: subtotal})
+    }
+
+    pricing.shipping = domain.NewMoney(0, pricing.subtotal.Currency)
+    if s.shipping != nil {
+        if pricing.shipping, err = converter.convert(ctx, *s.shipping); err != nil {
+            return domain.Order{}, nil, err
+        }
+        order.Shipping = &pricing.shipping
+    }
+    result, err := pricing.applyPromotions(ctx, input.CouponCodes)
+    if err != nil {
+        return domain.Order{}, nil, err
+    }
+    if err := priceOrder(&order, pricing, result); err != nil {
+        return domain.Order{}, nil, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+
+    order.ExchangeRates = converter.usedRates()
+    order.CreatedAt = pricing.now
+    order.Status = domain.OrderStatusPending
+    order.StatusHistory = []domain.OrderStatusChange{{To: domain.OrderStatusPending, At: order.CreatedAt}}
+
+    if err := repos.Orders.Create(ctx, order); err != nil {
+        return domain.Order{}, nil, err
+    }
+    if err := recordHolds(ctx, repos, order, order.CreatedAt.Add(s.holdTTL)); err != nil {
+        return domain.Order{}, nil, err
+    }
+    for _, applied := range order.Promotions {
+        if err := repos.Promotions.Redeem(ctx, domain.PromotionRedemption{
+            PromotionID: applied.PromotionID,
+            UserID:      order.UserID,
+            OrderID:     order.ID,
+            CreatedAt:   order.CreatedAt,
+        }); err != nil {
+            return domain.Order{}, nil, err
+        }
+    }
+
+    if order.Total.IsZero() {
+        // Promotions made the order free, so there is nothing to pay.
+        if err := order.TransitionTo(domain.OrderStatusPaid, order.CreatedAt); err != nil {
+            return domain.Order{}, nil, fmt.Errorf("%w: %w", ErrInvalidTransition, err)
+        }
+        if err := commitReservations(ctx, repos, order.ID, order.CreatedAt); err != nil {
+            return domain.Order{}, nil, err
+        }
+        if err := repos.Orders.Update(ctx, order); err != nil {
+            return domain.Order{}, nil, err
+        }
+        return order, nil, nil
+    }
+
+    if input.PaymentCurrency == "" && input.PaymentMethod == "" && !s.payments.Enabled() {
+        return order, nil, nil
+    }
+    invoice, err := s.payments.draftInvoice(ctx, order, input.PaymentMethod, input.PaymentCurrency)
+    if err != nil {
+        return domain.Order{}, nil, err
+    }
+    if invoice.Method == domain.PaymentMethodLightning {
+        return order, &invoice, nil
+    }
+    if err := s.payments.issueOnChainInvoice(ctx, repos, &invoice); err != nil {
+        return domain.Order{}, nil, err
+    }
+    order.InvoiceID = invoice.ID
+    if err := repos.Orders.Update(ctx, order); err != nil {
+        return domain.Order{}, nil, err
+    }
+    return order, nil, nil
+}
+
+// priceOrder records the line prices, the discounts in result and the
+// resulting totals on order.
+func priceOrder(order *domain.Order, pricing orderPricing, result pricingResult) error {
+    currency := pricing.subtotal.Currency
+    for i, line := range pricing.lines {
+        item := &order.Items[i]
+        item.UnitPrice = &line.unitPrice
+        item.Subtotal = &line.subtotal
+        item.Discounts = result.lineDiscounts[i]
+        total := line.subtotal
+        for _, discount := range item.Discounts {
+            var err error
+            if total, err = total.Sub(discount.Amount); err != nil {
+                return err
+            }
+        }
+        item.Total = &total
+    }
+
+    subtotal := pricing.subtotal
+    discount := domain.NewMoney(result.total, currency)
+    order.Subtotal = &subtotal
+    order.Discount = &discount
+    order.Promotions = result.applied
 
-        product.Stock -= item.Quantity
-        total += product.Price * float64(item.Quantity)
-        updatedProducts = append(updatedProducts, product)
+    total, err := subtotal.Sub(discount)
+    if err != nil {
+        return err
     }
+    if order.Total, err = total.Add(pricing.shipping); err != nil {
+        return err
+    }
+    return nil
+}
 
-    for _, product := range updatedProducts {
-        if err := s.products.Update(ctx, product); err != nil {
-            return domain.Order{}, err
+// orderAddress loads the user's address id for use as an order's address of
+// the given type.
+func orderAddress(ctx context.Context, repos repository.Repositories, userID, id string, addressType domain.AddressType) (domain.Address, error) {
+    field := string(addressType) + "_address_id"
+    address, err := userAddress(ctx, repos.Addresses, userID, id)
+    if errors.Is(err, repository.ErrNotFound) {
+        return domain.Address{}, fmt.Errorf("%w: %s: no such address", ErrValidation, field)
+    }
+    if err != nil {
+        return domain.Address{}, err
+    }
+    if address.Type != addressType {
+        return domain.Address{}, fmt.Errorf("%w: %s must name a %s address", ErrValidation, field, addressType)
+    }
+    return address, nil
+}
+
+// TransitionOrder moves an order to the requested status, enforcing the order lifecycle.
+func (s *OrderService) TransitionOrder(ctx context.Context, id string, status domain.OrderStatus) (domain.Order, error) {
+    if !status.Valid() {
+        return domain.Order{}, fmt.Errorf("%w: unknown order status %q", ErrValidation, status)
+    }
+    if status == domain.OrderStatusCancelled {
+        return domain.Order{}, fmt.Errorf("%w: use the cancel action so stock is restored", ErrValidation)
+    }
+
+    var order domain.Order
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        order, err = repos.Orders.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        now := time.Now().UTC()
+        if err := order.TransitionTo(status, now); err != nil {
+            return fmt.Errorf("%w: %w", ErrInvalidTransition, err)
+        }
+        if status == domain.OrderStatusPaid {
+            // Payment turns the order's stock holds into a permanent decrement.
+            if err := commitReservations(ctx, repos, order.ID, now); err != nil {
+                return err
+            }
+            // The order was paid some other way, so its invoice must not
+            // take, or flag for refund, a payment arriving later.
+            if err := voidInvoice(ctx, repos, order.ID); err != nil {
+                return err
+            }
         }
+        return repos.Orders.Update(ctx, order)
+    })
+    if err != nil {
+        return domain.Order{}, err
     }
 
-    order.Total = total
-    order.CreatedAt = time.Now().UTC()
+    return order, nil
+}
 
-    if err := s.orders.Create(ctx, order); err != nil {
+// voidInvoice closes the pending invoice of an order, if it has one, so the
+// payment watcher and Lightning settlements leave it alone.
+func voidInvoice(ctx context.Context, repos repository.Repositories, orderID string) error {
+    invoice, err := repos.Invoices.GetByOrderID(ctx, orderID)
+    if errors.Is(err, repository.ErrNotFound) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if invoice.Status != domain.InvoiceStatusPending {
+        return nil
+    }
+    invoice.Status = domain.InvoiceStatusVoid
+    return repos.Invoices.Update(ctx, invoice)
+}
+
+// CancelOrder cancels an order that has not shipped yet and returns its
+// quantities to stock: unpaid holds are released and paid units restocked.
+// Restocking and the status change commit together.
+func (s *OrderService) CancelOrder(ctx context.Context, id, cancelledBy, reason string) (domain.Order, error) {
+    if cancelledBy == "" {
+        return domain.Order{}, fmt.Errorf("%w: cancelled_by is required", ErrValidation)
+    }
+    if reason == "" {
+        return domain.Order{}, fmt.Errorf("%w: reason is required", ErrValidation)
+    }
+
+    var order domain.Order
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        order, err = repos.Orders.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        // Customers may cancel their own orders; staff cancel on their behalf.
+        if err := authorizeOwner(ctx, "order", order.UserID, domain.PermissionOrdersManage); err != nil {
+            return err
+        }
+
+        return cancelOrder(ctx, repos, &order, cancelledBy, reason, time.Now().UTC())
+    })
+    if err != nil {
         return domain.Order{}, err
     }
 
     return order, nil
 }
 
-// GetOrder retrieves an order by ID.
+// cancelOrder cancels order and returns its quantities to stock, recording
+// by as the actor and reason. The coupons used on the order can be used
+// again.
+func cancelOrder(ctx context.Context, repos repository.Repositories, order *domain.Order, by, reason string, now time.Time) error {
+    if err := order.TransitionTo(domain.OrderStatusCancelled, now); err != nil {
+        return fmt.Errorf("%w: %w", ErrInvalidTransition, err)
+    }
+    order.Cancellation = &domain.OrderCancellation{By: by, Reason: reason, At: now}
+    if err := repos.Promotions.ReleaseOrder(ctx, order.ID); err != nil {
+        return err
+    }
+
+    released, err := releaseReservations(ctx, repos, order.ID, by, now)
+    if err != nil {
+        return err
+    }
+    if released {
+        return repos.Orders.Update(ctx, *order)
+    }
+
+    // Orders placed before reservations took their stock outright.
+    for _, item := range order.Items {
+        product, err := repos.Products.GetByID(ctx, item.ProductID)
+        if errors.Is(err, repository.ErrNotFound) {
+            // The product was removed from the catalog; there is nothing to restock.
+            continue
+        }
+        if err != nil {
+            return err
+        }
+        if product.CheckSKU(item.SKU) != nil {
+            // The product was split into variants since the order; there
+            // is no stock left to return the units to.
+            continue
+        }
+        if err := product.AdjustStock(item.SKU, item.Quantity, 0); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        if err := repos.Products.Update(ctx, product); err != nil {
+            return err
+        }
+        if err := recordMovement(ctx, repos, domain.StockMovement{
+            ProductID:   product.ID,
+            SKU:         item.SKU,
+            Quantity:    item.Quantity,
+            Reason:      domain.StockMovementCancel,
+            ReferenceID: order.ID,
+            Actor:       by,
+            CreatedAt:   now,
+        }); err != nil {
+            return err
+        }
+    }
+
+    return repos.Orders.Update(ctx, *order)
+}
+
+// GetInvoice returns the payment invoice issued for an order. Only the
+// order's owner and staff who can read orders may see it.
+func (s *OrderService) GetInvoice(ctx context.Context, orderID string) (domain.Invoice, error) {
+    if _, err := s.GetOrder(ctx, orderID); err != nil {
+        return domain.Invoice{}, err
+    }
+    return s.payments.GetInvoiceForOrder(ctx, orderID)
+}
+
+// SettleLightningPayment records a settled Lightning payment reported by the
+// node as its preimage, marking the matching invoice and its order paid.
+func (s *OrderService) SettleLightningPayment(ctx context.Context, preimage string) (domain.Invoice, error) {
+    var invoice domain.Invoice
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        invoice, err = s.payments.settleLightningPayment(ctx, repos, preimage, time.Now().UTC())
+        return err
+    })
+    if err != nil {
+        return domain.Invoice{}, err
+    }
+
+    return invoice, nil
+}
+
+// GetOrder retrieves an order by ID. Only the order's owner and staff who can
+// read orders may see it.
 func (s *OrderService) GetOrder(ctx context.Context, id string) (domain.Order, error) {
-    return s.orders.GetByID(ctx, id)
+    order, err := s.orders.GetByID(ctx, id)
+    if err != nil {
+        return domain.Order{}, err
+    }
+    if err := authorizeOwner(ctx, "order", order.UserID, domain.PermissionOrdersRead); err != nil {
+        return domain.Order{}, err
+    }
+    return order, nil
+}
+
+// ListOrders returns a page of orders. Users who cannot read every order
+// only see their own.
+func (s *OrderService) ListOrders(ctx context.Context, input ListOrdersInput) (repository.Page[domain.Order], error) {
+    if err := Authorize(ctx, domain.PermissionOrdersRead); err != nil {
+        user, ok := UserFromContext(ctx)
+        if !ok {
+            return repository.Page[domain.Order]{}, err
+        }
+        if input.UserID != "" && input.UserID != user.ID {
+            return repository.Page[domain.Order]{}, fmt.Errorf("%w: cannot list another user's orders", ErrForbidden)
+        }
+        input.UserID = user.ID
+    }
+
+    query, err := input.query()
+    if err != nil {
+        return repository.Page[domain.Order]{}, err
+    }
+    return s.orders.List(ctx, query)
 }
 
-// ListOrders returns all orders.
-func (s *OrderService) ListOrders(ctx context.Context) ([]domain.Order, error) {
-    return s.orders.List(ctx)
+func sortedRates(rates map[string]domain.ExchangeRate) []domain.ExchangeRate {
+    if len(rates) == 0 {
+        return nil
+    }
+    out := make([]domain.ExchangeRate, 0, len(rates))
+    for _, rate := range rates {
+        out = append(out, rate)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].From < out[j].From })
+    return out
 }
@@ -0,0 +1,238 @@
+package service
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/google/uuid"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/payment"
+    "cryptotrade/internal/repository"
+)
+
+// lightningCurrency is the only coin Lightning invoices are denominated in.
+const lightningCurrency = "BTC"
+
+// PaymentService issues crypto payment invoices for orders.
+type PaymentService struct {
+    invoices  repository.InvoiceRepository
+    rates     ExchangeRateProvider
+    derivers  map[string]payment.AddressDeriver
+    lightning payment.LightningNode
+    ttl       time.Duration
+}
+
+// NewPaymentService creates a PaymentService that accepts on-chain payment in
+// the coins of the supplied derivers and, when lightning is non-nil, Lightning
+// payments. Invoices expire ttl after they are issued.
+func NewPaymentService(invoices repository.InvoiceRepository, rates ExchangeRateProvider, ttl time.Duration, lightning payment.LightningNode, derivers ...payment.AddressDeriver) *PaymentService {
+    byCurrency := make(map[string]payment.AddressDeriver, len(derivers))
+    for _, d := range derivers {
+        byCurrency[d.Currency()] = d
+    }
+    return &PaymentService{invoices: invoices, rates: rates, derivers: byCurrency, lightning: lightning, ttl: ttl}
+}
+
+// Enabled reports whether at least one payment method is configured.
+func (s *PaymentService) Enabled() bool {
+    return len(s.derivers) > 0 || s.lightning != nil
+}
+
+// Currencies returns the coins invoices can be issued in, sorted.
+func (s *PaymentService) Currencies() []string {
+    currencies := make([]string, 0, len(s.derivers))
+    for currency := range s.derivers {
+        currencies = append(currencies, currency)
+    }
+    sort.Strings(currencies)
+    return currencies
+}
+
+// GetInvoiceForOrder returns the invoice issued for an order. A pending
+// invoice past its expiry is reported as expired.
+func (s *PaymentService) GetInvoiceForOrder(ctx context.Context, orderID string) (domain.Invoice, error) {
+    invoice, err := s.invoices.GetByOrderID(ctx, orderID)
+    if err != nil {
+        return domain.Invoice{}, err
+    }
+    if invoice.Expired(time.Now()) {
+        invoice.Status = domain.InvoiceStatusExpired
+    }
+    return invoice, nil
+}
+
+// resolveMethod picks the payment method for a new invoice, preferring
+// on-chain payment when none is requested.
+func (s *PaymentService) resolveMethod(requested domain.PaymentMethod) (domain.PaymentMethod, error) {
+    switch {
+    case requested == "" && len(s.derivers) == 0 && s.lightning != nil:
+        return domain.PaymentMethodLightning, nil
+    case requested == "":
+        return domain.PaymentMethodOnChain, nil
+    case !requested.Valid():
+        return "", fmt.Errorf("%w: unknown payment method %q", ErrValidation, requested)
+    case requested == domain.PaymentMethodLightning && s.lightning == nil:
+        return "", fmt.Errorf("%w: lightning payments are not accepted", ErrValidation)
+    }
+    return requested, nil
+}
+
+// resolveCurrency picks the coin for a new on-chain invoice, defaulting to the
+// first configured coin when none is requested.
+func (s *PaymentService) resolveCurrency(requested string) (string, error) {
+    if requested == "" {
+        currencies := s.Currencies()
+        if len(currencies) == 0 {
+            return "", errors.New("no payment currencies configured")
+        }
+        return currencies[0], nil
+    }
+    if _, ok := s.derivers[requested]; !ok {
+        return "", fmt.Errorf("%w: payments in %q are not accepted", ErrValidation, requested)
+    }
+    return requested, nil
+}
+
+// draftInvoice prepares an unsaved invoice for order, checking the payment
+// method and coin and converting the total into the coin. On-chain invoices
+// are completed by issueOnChainInvoice in the order's transaction; Lightning
+// invoices by requestLightningPayment once the order has committed, since it
+// calls out to the node.
+func (s *PaymentService) draftInvoice(ctx context.Context, order domain.Order, method domain.PaymentMethod, currency string) (domain.Invoice, error) {
+    method, err := s.resolveMethod(method)
+    if err != nil {
+        return domain.Invoice{}, err
+    }
+    if method == domain.PaymentMethodLightning {
+        if currency != "" && currency != lightningCurrency {
+            return domain.Invoice{}, fmt.Errorf("%w: lightning invoices are paid in %s", ErrValidation, lightningCurrency)
+        }
+        currency = lightningCurrency
+    } else if currency, err = s.resolveCurrency(currency); err != nil {
+        return domain.Invoice{}, err
+    }
+
+    invoice := domain.Invoice{
+        ID:        uuid.NewString(),
+        OrderID:   order.ID,
+        Method:    method,
+        Amount:    order.Total,
+        Status:    domain.InvoiceStatusPending,
+        CreatedAt: order.CreatedAt,
+        ExpiresAt: order.CreatedAt.Add(s.ttl),
+    }
+
+    if order.Total.Currency != currency {
+        rate, err := s.rates.Rate(ctx, order.Total.Currency, currency)
+        if errors.Is(err, ErrRateUnavailable) {
+            return domain.Invoice{}, fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        if err != nil {
+            return domain.Invoice{}, err
+        }
+        // Round up so rounding never leaves the merchant short.
+        if invoice.Amount, err = rate.Convert(order.Total, domain.RoundUp); err != nil {
+            return domain.Invoice{}, err
+        }
+        invoice.Rate = &rate
+    }
+    invoice.Received = domain.NewMoney(0, invoice.Amount.Currency)
+    return invoice, nil
+}
+
+// issueOnChainInvoice assigns a drafted on-chain invoice its deposit address
+// and records it using repos, so it commits or rolls back with the caller's
+// transaction.
+func (s *PaymentService) issueOnChainInvoice(ctx context.Context, repos repository.Repositories, invoice *domain.Invoice) error {
+    if err := s.deriveDepositAddress(ctx, repos, invoice); err != nil {
+        return err
+    }
+    return repos.Invoices.Create(ctx, *invoice)
+}
+
+// deriveDepositAddress assigns the next unused deposit address to an on-chain invoice.
+func (s *PaymentService) deriveDepositAddress(ctx context.Context, repos repository.Repositories, invoice *domain.Invoice) error {
+    currency := invoice.Amount.Currency
+    index, err := repos.Invoices.NextDerivationIndex(ctx, currency)
+    if err != nil {
+        return err
+    }
+    addr, err := s.derivers[currency].DeriveAddress(index)
+    if err != nil {
+        return fmt.Errorf("derive %s deposit address: %w", currency, err)
+    }
+    invoice.Address = addr.Address
+    invoice.DerivationPath = addr.Path
+    invoice.DerivationIndex = index
+    return nil
+}
+
+// requestLightningPayment asks the node for a BOLT11 invoice and checks that
+// what came back asks for the right amount before handing it to the customer.
+func (s *PaymentService) requestLightningPayment(ctx context.Context, invoice *domain.Invoice) error {
+    // Satoshis are the BTC minor unit; Lightning amounts are millisatoshis.
+    amountMsat, err := invoice.Amount.Mul(1000)
+    if err != nil {
+        return fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+
+    request, err := s.lightning.CreateInvoice(ctx, amountMsat.Amount, "Order "+invoice.OrderID, s.ttl)
+    if err != nil {
+        return fmt.Errorf("create lightning invoice: %w", err)
+    }
+    decoded, err := payment.DecodeBOLT11(request)
+    if err != nil {
+        return fmt.Errorf("lightning node returned an invalid invoice: %w", err)
+    }
+    if err := decoded.Validate(amountMsat.Amount, time.Now()); err != nil {
+        return fmt.Errorf("lightning node returned an unusable invoice: %w", err)
+    }
+
+    invoice.PaymentRequest = request
+    invoice.PaymentHash = decoded.PaymentHashHex()
+    invoice.ExpiresAt = decoded.ExpiresAt()
+    return nil
+}
+
+// settleLightningPayment marks the Lightning invoice whose payment hash
+// matches preimage as paid, along with its order. A preimage is only learned
+// by paying the invoice, so it is proof of payment on its own.
+func (s *PaymentService) settleLightningPayment(ctx context.Context, repos repository.Repositories, preimageHex string, now time.Time) (domain.Invoice, error) {
+    preimage, err := hex.DecodeString(preimageHex)
+    if err != nil || len(preimage) != sha256.Size {
+        return domain.Invoice{}, fmt.Errorf("%w: preimage must be 32 hex-encoded bytes", ErrValidation)
+    }
+    hash := sha256.Sum256(preimage)
+
+    invoice, err := repos.Invoices.GetByPaymentHash(ctx, hex.EncodeToString(hash[:]))
+    if err != nil {
+        return domain.Invoice{}, err
+    }
+    if invoice.Status == domain.InvoiceStatusPaid {
+        // Nodes may report a settlement more than once.
+        return invoice, nil
+    }
+    if invoice.Status != domain.InvoiceStatusPending {
+        return domain.Invoice{}, fmt.Errorf("%w: invoice is %s", ErrInvalidTransition, invoice.Status)
+    }
+
+    invoice.Status = domain.InvoiceStatusPaid
+    invoice.Received = invoice.Amount
+    invoice.PaidAt = &now
+    invoice.Payments = append(invoice.Payments, domain.InvoicePayment{
+        TxHash: invoice.PaymentHash, Amount: invoice.Amount, SeenAt: now,
+    })
+    if err := markOrderPaid(ctx, repos, invoice, now); err != nil {
+        return domain.Invoice{}, err
+    }
+    if err := repos.Invoices.Update(ctx, invoice); err != nil {
+        return domain.Invoice{}, err
+    }
+    return invoice, nil
+}
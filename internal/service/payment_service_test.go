@@ -0,0 +1,152 @@
+package service
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/payment"
+    "cryptotrade/internal/repository"
+)
+
+// failingLightningNode is a LightningNode whose node cannot be reached.
+type failingLightningNode struct{}
+
+func (failingLightningNode) CreateInvoice(context.Context, int64, string, time.Duration) (string, error) {
+    return "", errors.New("connection refused")
+}
+
+func TestLightningOrderSettles(t *testing.T) {
+    ctx := context.Background()
+    f := newPaymentFixture(t, nil)
+
+    order, err := f.orders.CreateOrder(ctx, CreateOrderInput{
+        UserID:        f.userID,
+        Items:         []domain.OrderItem{{ProductID: f.productID, Quantity: 2}},
+        PaymentMethod: domain.PaymentMethodLightning,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if order.InvoiceID == "" {
+        t.Fatal("the order was placed without its invoice")
+    }
+    invoice := f.invoice(t, order.InvoiceID)
+    if invoice.Method != domain.PaymentMethodLightning || invoice.PaymentRequest == "" || invoice.Address != "" {
+        t.Fatalf("invoice %+v, want a Lightning payment request", invoice)
+    }
+    decoded, err := payment.DecodeBOLT11(invoice.PaymentRequest)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if decoded.AmountMsat != 200_000_000 || decoded.PaymentHashHex() != invoice.PaymentHash || !decoded.ExpiresAt().Equal(invoice.ExpiresAt) {
+        t.Errorf("payment request for %d msat, hash %s, expiring %s; invoice wants 200000000 msat, %s, %s",
+            decoded.AmountMsat, decoded.PaymentHashHex(), decoded.ExpiresAt(), invoice.PaymentHash, invoice.ExpiresAt)
+    }
+
+    // Reports that do not prove the payment are refused.
+    if _, err := f.orders.SettleLightningPayment(ctx, "beef"); !errors.Is(err, ErrValidation) {
+        t.Errorf("short preimage: got %v, want ErrValidation", err)
+    }
+    unknown := sha256.Sum256([]byte("not the preimage"))
+    if _, err := f.orders.SettleLightningPayment(ctx, hex.EncodeToString(unknown[:])); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("wrong preimage: got %v, want ErrNotFound", err)
+    }
+
+    preimage, err := f.node.Pay(ctx, invoice.PaymentRequest)
+    if err != nil {
+        t.Fatal(err)
+    }
+    settled, err := f.orders.SettleLightningPayment(ctx, hex.EncodeToString(preimage))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if settled.Status != domain.InvoiceStatusPaid || settled.Received != settled.Amount {
+        t.Errorf("settled invoice is %s with %v received, want paid in full", settled.Status, settled.Received)
+    }
+    if status := f.order(t, order.ID).Status; status != domain.OrderStatusPaid {
+        t.Errorf("order is %s, want paid", status)
+    }
+    if product := f.product(t); product.Stock != 8 || product.Reserved != 0 {
+        t.Errorf("stock %d reserved %d, want 8 and 0", product.Stock, product.Reserved)
+    }
+
+    // Nodes may report a settlement twice.
+    if again, err := f.orders.SettleLightningPayment(ctx, strings.ToUpper(hex.EncodeToString(preimage))); err != nil || again.ID != invoice.ID {
+        t.Errorf("repeated settlement = %s, %v; want the same invoice", again.ID, err)
+    }
+}
+
+func TestLightningOrderRefusesOtherCoins(t *testing.T) {
+    f := newPaymentFixture(t, nil)
+    _, err := f.orders.CreateOrder(context.Background(), CreateOrderInput{
+        UserID:          f.userID,
+        Items:           []domain.OrderItem{{ProductID: f.productID, Quantity: 1}},
+        PaymentMethod:   domain.PaymentMethodLightning,
+        PaymentCurrency: "ETH",
+    })
+    if !errors.Is(err, ErrValidation) {
+        t.Errorf("got %v, want ErrValidation", err)
+    }
+    if product := f.product(t); product.Reserved != 0 {
+        t.Errorf("a refused order left %d units reserved", product.Reserved)
+    }
+}
+
+func TestLightningNodeFailureCancelsOrder(t *testing.T) {
+    ctx := context.Background()
+    f := newPaymentFixture(t, failingLightningNode{})
+
+    _, err := f.orders.CreateOrder(ctx, CreateOrderInput{
+        UserID:        f.userID,
+        Items:         []domain.OrderItem{{ProductID: f.productID, Quantity: 2}},
+        PaymentMethod: domain.PaymentMethodLightning,
+    })
+    if err == nil || !strings.Contains(err.Error(), "connection refused") {
+        t.Fatalf("got %v, want the node's error", err)
+    }
+
+    page, err := f.repos.Orders.List(ctx, repository.OrderQuery{UserID: f.userID})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(page.Items) != 1 {
+        t.Fatalf("%d orders, want the one placed", len(page.Items))
+    }
+    order := page.Items[0]
+    if order.Status != domain.OrderStatusCancelled || order.Cancellation == nil || order.Cancellation.By != systemActor || order.InvoiceID != "" {
+        t.Errorf("order is %s, cancellation %+v, invoice %q; want cancelled by the system without an invoice",
+            order.Status, order.Cancellation, order.InvoiceID)
+    }
+    if product := f.product(t); product.Stock != 10 || product.Reserved != 0 {
+        t.Errorf("stock %d reserved %d, want the hold released: 10 and 0", product.Stock, product.Reserved)
+    }
+}
+
+func TestLightningCheckoutFailureRestoresCart(t *testing.T) {
+    ctx := context.Background()
+    f := newPaymentFixture(t, failingLightningNode{})
+    cart := domain.Cart{UserID: f.userID, Items: []domain.CartItem{{ProductID: f.productID, Quantity: 3}}, UpdatedAt: time.Now()}
+    if err := f.repos.Carts.Save(ctx, cart); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := f.carts.Checkout(ctx, f.userID, CheckoutInput{PaymentMethod: domain.PaymentMethodLightning}); err == nil {
+        t.Fatal("checkout succeeded without a Lightning invoice")
+    }
+    restored, err := f.repos.Carts.Get(ctx, f.userID)
+    if err != nil {
+        t.Fatalf("cart after a failed checkout: %v", err)
+    }
+    if len(restored.Items) != 1 || restored.Items[0] != cart.Items[0] {
+        t.Errorf("cart items = %v, want %v", restored.Items, cart.Items)
+    }
+    if product := f.product(t); product.Reserved != 0 {
+        t.Errorf("%d units still reserved", product.Reserved)
+    }
+}
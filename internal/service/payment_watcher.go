@@ -0,0 +1,268 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/payment"
+    "cryptotrade/internal/repository"
+)
+
+// PaymentWatcher polls chain clients for transactions to pending on-chain
+// invoice addresses and settles invoices once payments reach their coin's
+// confirmation threshold, marking the order paid.
+//
+// A transaction first seen before the invoice expires counts toward it even
+// if its confirmations arrive later. Funds arriving only after expiry leave
+// the invoice "late" for manual review instead of settling it at a stale rate.
+// Transactions seen before the invoice was created, or already claimed by
+// another invoice, belong to an earlier payment to the same address and are
+// ignored.
+type PaymentWatcher struct {
+    invoices      repository.InvoiceRepository
+    tx            repository.TxManager
+    clients       map[string]payment.ChainClient
+    confirmations map[string]int
+    interval      time.Duration
+    now           func() time.Time
+}
+
+// NewPaymentWatcher creates a watcher that polls every interval. confirmations
+// maps a coin to the confirmations a payment needs; coins missing from it need one.
+func NewPaymentWatcher(invoices repository.InvoiceRepository, tx repository.TxManager, interval time.Duration, confirmations map[string]int, clients ...payment.ChainClient) *PaymentWatcher {
+    byCurrency := make(map[string]payment.ChainClient, len(clients))
+    for _, c := range clients {
+        byCurrency[c.Currency()] = c
+    }
+    return &PaymentWatcher{
+        invoices:      invoices,
+        tx:            tx,
+        clients:       byCurrency,
+        confirmations: confirmations,
+        interval:      interval,
+        now:           func() time.Time { return time.Now().UTC() },
+    }
+}
+
+// Run polls until ctx is cancelled.
+func (w *PaymentWatcher) Run(ctx context.Context) {
+    ticker := time.NewTicker(w.interval)
+    defer ticker.Stop()
+
+    for {
+        if err := w.Poll(ctx); err != nil && ctx.Err() == nil {
+            log.Printf("payment watcher: %v", err)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// Poll performs a single sweep over all pending invoices.
+func (w *PaymentWatcher) Poll(ctx context.Context) error {
+    pending, err := w.invoices.ListPending(ctx)
+    if err != nil {
+        return fmt.Errorf("list pending invoices: %w", err)
+    }
+
+    var errs []error
+    for _, invoice := range pending {
+        if invoice.Method != domain.PaymentMethodOnChain {
+            continue
+        }
+        client, ok := w.clients[invoice.Amount.Currency]
+        if !ok {
+            continue
+        }
+        txs, err := client.TransactionsTo(ctx, invoice.Address)
+        if err != nil {
+            errs = append(errs, fmt.Errorf("invoice %s: %w", invoice.ID, err))
+            continue
+        }
+        if err := w.settle(ctx, invoice.ID, txs); err != nil {
+            errs = append(errs, fmt.Errorf("invoice %s: %w", invoice.ID, err))
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// settle applies the observed transactions to an invoice and, when it is
+// fully paid, moves the order to paid in the same transaction.
+func (w *PaymentWatcher) settle(ctx context.Context, invoiceID string, txs []payment.Transaction) error {
+    return w.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        invoice, err := repos.Invoices.GetByID(ctx, invoiceID)
+        if err != nil {
+            return err
+        }
+        if invoice.Status != domain.InvoiceStatusPending {
+            return nil
+        }
+
+        txs, err = unclaimedTransactions(ctx, repos.Invoices, invoice, txs)
+        if err != nil {
+            return err
+        }
+        invoice.Payments = mergePayments(invoice.Payments, txs)
+
+        now := w.now()
+        status, received, err := w.evaluate(invoice, now)
+        if err != nil {
+            return err
+        }
+        invoice.Status = status
+        invoice.Received = received
+
+        if status == domain.InvoiceStatusPaid || status == domain.InvoiceStatusOverpaid {
+            invoice.PaidAt = &now
+            if err := markOrderPaid(ctx, repos, invoice, now); err != nil {
+                return err
+            }
+        }
+        return repos.Invoices.Update(ctx, invoice)
+    })
+}
+
+// evaluate derives the invoice status and confirmed on-time total at now.
+func (w *PaymentWatcher) evaluate(invoice domain.Invoice, now time.Time) (domain.InvoiceStatus, domain.Money, error) {
+    threshold := w.confirmations[invoice.Amount.Currency]
+    if threshold < 1 {
+        threshold = 1
+    }
+
+    received := domain.NewMoney(0, invoice.Amount.Currency)
+    var awaitingConfirmations, late bool
+    for _, p := range invoice.Payments {
+        if !p.SeenAt.Before(invoice.ExpiresAt) {
+            late = true
+            continue
+        }
+        if p.Confirmations < threshold {
+            awaitingConfirmations = true
+            continue
+        }
+        var err error
+        if received, err = received.Add(p.Amount); err != nil {
+            return "", domain.Money{}, err
+        }
+    }
+
+    switch {
+    case received.Amount > invoice.Amount.Amount:
+        return domain.InvoiceStatusOverpaid, received, nil
+    case received.Amount == invoice.Amount.Amount:
+        return domain.InvoiceStatusPaid, received, nil
+    case now.Before(invoice.ExpiresAt), awaitingConfirmations:
+        // Still payable, or on-time funds are waiting on confirmations.
+        return domain.InvoiceStatusPending, received, nil
+    case late:
+        return domain.InvoiceStatusLate, received, nil
+    case received.IsPositive():
+        return domain.InvoiceStatusUnderpaid, received, nil
+    default:
+        return domain.InvoiceStatusExpired, received, nil
+    }
+}
+
+// markOrderPaid moves the paid invoice's order to paid and commits its stock
+// holds. An order that can no longer be paid (for example one cancelled while
+// the payment was in flight, or whose expired holds were sold elsewhere) keeps
+// its status and is marked with a refund due instead, so staff can find and
+// refund it.
+func markOrderPaid(ctx context.Context, repos repository.Repositories, invoice domain.Invoice, at time.Time) error {
+    order, err := repos.Orders.GetByID(ctx, invoice.OrderID)
+    if err != nil {
+        return err
+    }
+    if !order.Status.CanTransitionTo(domain.OrderStatusPaid) {
+        return markRefundDue(ctx, repos, order, invoice, fmt.Sprintf("the order was %s when the payment settled", order.Status), at)
+    }
+    if err := commitReservations(ctx, repos, order.ID, at); err != nil {
+        if errors.Is(err, ErrValidation) {
+            // The holds lapsed and the stock has since been sold elsewhere.
+            return markRefundDue(ctx, repos, order, invoice, strings.TrimPrefix(err.Error(), ErrValidation.Error()+": "), at)
+        }
+        return err
+    }
+    if err := order.TransitionTo(domain.OrderStatusPaid, at); err != nil {
+        return err
+    }
+    return repos.Orders.Update(ctx, order)
+}
+
+// markRefundDue records on order that the payment for invoice has to be
+// refunded.
+func markRefundDue(ctx context.Context, repos repository.Repositories, order domain.Order, invoice domain.Invoice, reason string, at time.Time) error {
+    log.Printf("payments: order %s was paid but cannot be fulfilled, refund due: %s", order.ID, reason)
+    order.RefundDue = &domain.OrderRefundDue{InvoiceID: invoice.ID, Reason: reason, At: at}
+    return repos.Orders.Update(ctx, order)
+}
+
+// blockTimeSkew is how far before an invoice's creation a transaction may
+// appear to have been seen and still pay it. A transaction first observed
+// once mined is dated by its block, whose timestamp the miner sets and which
+// Bitcoin lets trail the time it was mined by an hour or more; the clocks of
+// the server and the chain may also disagree.
+const blockTimeSkew = 2 * time.Hour
+
+// unclaimedTransactions drops the transactions that cannot pay invoice: those
+// seen well before it was created and those another invoice has claimed.
+func unclaimedTransactions(ctx context.Context, invoices repository.InvoiceRepository, invoice domain.Invoice, txs []payment.Transaction) ([]payment.Transaction, error) {
+    recorded := make(map[string]bool, len(invoice.Payments))
+    for _, p := range invoice.Payments {
+        recorded[p.TxHash] = true
+    }
+
+    var out []payment.Transaction
+    for _, tx := range txs {
+        if recorded[tx.Hash] {
+            out = append(out, tx)
+            continue
+        }
+        if tx.SeenAt.Before(invoice.CreatedAt.Add(-blockTimeSkew)) {
+            continue
+        }
+        claimant, err := invoices.TransactionClaimant(ctx, invoice.Amount.Currency, tx.Hash)
+        if err == nil && claimant != invoice.ID {
+            continue
+        }
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            return nil, err
+        }
+        out = append(out, tx)
+    }
+    return out, nil
+}
+
+// mergePayments folds freshly observed transactions into the recorded ones,
+// keeping the earliest time each transaction was seen.
+func mergePayments(recorded []domain.InvoicePayment, txs []payment.Transaction) []domain.InvoicePayment {
+    index := make(map[string]int, len(recorded))
+    for i, p := range recorded {
+        index[p.TxHash] = i
+    }
+
+    for _, tx := range txs {
+        i, ok := index[tx.Hash]
+        if !ok {
+            index[tx.Hash] = len(recorded)
+            recorded = append(recorded, domain.InvoicePayment{
+                TxHash: tx.Hash, Amount: tx.Amount, Confirmations: tx.Confirmations, SeenAt: tx.SeenAt,
+            })
+            continue
+        }
+        recorded[i].Amount = tx.Amount
+        recorded[i].Confirmations = tx.Confirmations
+        if tx.SeenAt.Before(recorded[i].SeenAt) {
+            recorded[i].SeenAt = tx.SeenAt
+        }
+    }
+    return recorded
+}
@@ -0,0 +1,466 @@
+package service
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/payment"
+    "cryptotrade/internal/repository"
+    "cryptotrade/internal/repository/memory"
+)
+
+// testXPub is the account key of BIP 32 test vector 1, chain m.
+const testXPub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+
+const (
+    testPaymentTTL    = 15 * time.Minute
+    testConfirmations = 2
+)
+
+// paymentFixture wires the order, payment and inventory services to a memory
+// store, a fake Bitcoin chain and a mock Lightning node.
+type paymentFixture struct {
+    repos     repository.Repositories
+    store     *memory.Store
+    chain     *payment.FakeChain
+    node      *payment.MockLightningNode
+    orders    *OrderService
+    carts     *CartService
+    watcher   *PaymentWatcher
+    sweeper   *ReservationSweeper
+    userID    string
+    productID string
+}
+
+func newPaymentFixture(t *testing.T, lightning payment.LightningNode) *paymentFixture {
+    t.Helper()
+    ctx := context.Background()
+    store := memory.NewStore()
+    repos := store.Repositories()
+
+    deriver, err := payment.NewBitcoinDeriver(testXPub)
+    if err != nil {
+        t.Fatal(err)
+    }
+    rates, err := NewStaticRateProvider(map[string]string{"BTC/USD": "50000"}, time.Now())
+    if err != nil {
+        t.Fatal(err)
+    }
+    node, _ := lightning.(*payment.MockLightningNode)
+    if lightning == nil {
+        if node, err = payment.NewMockLightningNode(); err != nil {
+            t.Fatal(err)
+        }
+        lightning = node
+    }
+    payments := NewPaymentService(repos.Invoices, rates, testPaymentTTL, lightning, deriver)
+    orders := NewOrderService(repos.Orders, repos.Users, repos.Products, store, rates, payments, testPaymentTTL, PriorityAllocation{}, nil)
+
+    f := &paymentFixture{
+        repos:     repos,
+        store:     store,
+        chain:     payment.NewFakeChain("BTC"),
+        node:      node,
+        orders:    orders,
+        carts:     NewCartService(repos.Carts, repos.Users, repos.Products, store, rates, orders),
+        sweeper:   NewReservationSweeper(store, time.Minute, testPaymentTTL),
+        userID:    "buyer",
+        productID: "ledger",
+    }
+    f.watcher = NewPaymentWatcher(repos.Invoices, store, time.Minute, map[string]int{"BTC": testConfirmations}, f.chain)
+
+    if err := repos.Users.Create(ctx, domain.User{
+        ID: f.userID, Name: "Buyer", Email: "buyer@example.com", Role: domain.RoleCustomer, EmailVerified: true, CreatedAt: time.Now(),
+    }); err != nil {
+        t.Fatal(err)
+    }
+    if err := repos.Products.Create(ctx, domain.Product{
+        ID: f.productID, Name: "Hardware wallet", Price: domain.NewMoney(100_000, "BTC"), Stock: 10, CreatedAt: time.Now(),
+    }); err != nil {
+        t.Fatal(err)
+    }
+    return f
+}
+
+// placeOrder orders quantity units paid on-chain and returns the order and
+// its invoice.
+func (f *paymentFixture) placeOrder(t *testing.T, quantity int) (domain.Order, domain.Invoice) {
+    t.Helper()
+    ctx := context.Background()
+    order, err := f.orders.CreateOrder(ctx, CreateOrderInput{
+        UserID:        f.userID,
+        Items:         []domain.OrderItem{{ProductID: f.productID, Quantity: quantity}},
+        PaymentMethod: domain.PaymentMethodOnChain,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    invoice, err := f.repos.Invoices.GetByOrderID(ctx, order.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return order, invoice
+}
+
+// pollAt runs one watcher pass as if it were now.
+func (f *paymentFixture) pollAt(t *testing.T, now time.Time) {
+    t.Helper()
+    f.watcher.now = func() time.Time { return now }
+    if err := f.watcher.Poll(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func (f *paymentFixture) invoice(t *testing.T, id string) domain.Invoice {
+    t.Helper()
+    invoice, err := f.repos.Invoices.GetByID(context.Background(), id)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return invoice
+}
+
+func (f *paymentFixture) order(t *testing.T, id string) domain.Order {
+    t.Helper()
+    order, err := f.repos.Orders.GetByID(context.Background(), id)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return order
+}
+
+func (f *paymentFixture) product(t *testing.T) domain.Product {
+    t.Helper()
+    product, err := f.repos.Products.GetByID(context.Background(), f.productID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return product
+}
+
+func TestPaymentWatcherSettlesAfterConfirmations(t *testing.T) {
+    f := newPaymentFixture(t, nil)
+    order, invoice := f.placeOrder(t, 2)
+    if invoice.Amount != domain.NewMoney(200_000, "BTC") || invoice.Address == "" {
+        t.Fatalf("invoice for %v at %q, want 0.002 BTC at a derived address", invoice.Amount, invoice.Address)
+    }
+    at := invoice.CreatedAt.Add(time.Minute)
+
+    // Half now, half later; nothing counts until it has two confirmations.
+    f.chain.Send(invoice.Address, domain.NewMoney(100_000, "BTC"), at)
+    f.pollAt(t, at)
+    if got := f.invoice(t, invoice.ID); got.Status != domain.InvoiceStatusPending || len(got.Payments) != 1 || !got.Received.IsZero() {
+        t.Fatalf("after an unconfirmed payment: status %s, payments %d, received %v", got.Status, len(got.Payments), got.Received)
+    }
+
+    f.chain.Mine(testConfirmations)
+    f.chain.Send(invoice.Address, domain.NewMoney(100_000, "BTC"), at.Add(time.Minute))
+    f.pollAt(t, at.Add(2*time.Minute))
+    if got := f.invoice(t, invoice.ID); got.Status != domain.InvoiceStatusPending || got.Received != domain.NewMoney(100_000, "BTC") {
+        t.Fatalf("after half confirmed: status %s, received %v", got.Status, got.Received)
+    }
+
+    f.chain.Mine(1)
+    f.pollAt(t, at.Add(3*time.Minute))
+    if got := f.invoice(t, invoice.ID); got.Status != domain.InvoiceStatusPending {
+        t.Fatalf("with one of two confirmations: status %s", got.Status)
+    }
+
+    f.chain.Mine(1)
+    f.pollAt(t, at.Add(4*time.Minute))
+    got := f.invoice(t, invoice.ID)
+    if got.Status != domain.InvoiceStatusPaid || got.PaidAt == nil {
+        t.Fatalf("fully confirmed: status %s, paid at %v", got.Status, got.PaidAt)
+    }
+    if status := f.order(t, order.ID).Status; status != domain.OrderStatusPaid {
+        t.Errorf("order is %s, want paid", status)
+    }
+    if product := f.product(t); product.Stock != 8 || product.Reserved != 0 {
+        t.Errorf("stock %d reserved %d, want the held units committed: 8 and 0", product.Stock, product.Reserved)
+    }
+}
+
+func TestPaymentWatcherOutcomes(t *testing.T) {
+    tests := []struct {
+        name       string
+        amount     int64
+        seenAfter  time.Duration
+        wantStatus domain.InvoiceStatus
+        wantOrder  domain.OrderStatus
+    }{
+        {"overpaid", 150_000, time.Minute, domain.InvoiceStatusOverpaid, domain.OrderStatusPaid},
+        {"seen after expiry", 100_000, testPaymentTTL + time.Minute, domain.InvoiceStatusLate, domain.OrderStatusPending},
+        {"underpaid", 50_000, time.Minute, domain.InvoiceStatusUnderpaid, domain.OrderStatusPending},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            f := newPaymentFixture(t, nil)
+            order, invoice := f.placeOrder(t, 1)
+            f.chain.Send(invoice.Address, domain.NewMoney(tt.amount, "BTC"), invoice.CreatedAt.Add(tt.seenAfter))
+            f.chain.Mine(testConfirmations)
+            f.pollAt(t, invoice.ExpiresAt.Add(time.Hour))
+
+            if got := f.invoice(t, invoice.ID); got.Status != tt.wantStatus {
+                t.Errorf("invoice is %s, want %s", got.Status, tt.wantStatus)
+            }
+            if got := f.order(t, order.ID); got.Status != tt.wantOrder {
+                t.Errorf("order is %s, want %s", got.Status, tt.wantOrder)
+            }
+        })
+    }
+}
+
+func TestPaymentWatcherIgnoresEarlierPayments(t *testing.T) {
+    f := newPaymentFixture(t, nil)
+    order, invoice := f.placeOrder(t, 1)
+
+    // A payment to the address from well before the invoice existed, such as
+    // one to a reused address, does not pay it.
+    f.chain.Send(invoice.Address, invoice.Amount, invoice.CreatedAt.Add(-blockTimeSkew-time.Minute))
+    f.chain.Mine(testConfirmations)
+    f.pollAt(t, invoice.CreatedAt.Add(time.Minute))
+
+    got := f.invoice(t, invoice.ID)
+    if got.Status != domain.InvoiceStatusPending || len(got.Payments) != 0 {
+        t.Fatalf("invoice is %s with %d payments, want pending with none", got.Status, len(got.Payments))
+    }
+    if status := f.order(t, order.ID).Status; status != domain.OrderStatusPending {
+        t.Errorf("order is %s, want pending", status)
+    }
+}
+
+func TestPaymentWatcherAllowsBlockTimeSkew(t *testing.T) {
+    f := newPaymentFixture(t, nil)
+    order, invoice := f.placeOrder(t, 1)
+
+    // The payment is first seen once mined, dated by a block timestamp that
+    // trails the invoice's creation.
+    f.chain.Send(invoice.Address, invoice.Amount, invoice.CreatedAt.Add(-40*time.Minute))
+    f.chain.Mine(testConfirmations)
+    f.pollAt(t, invoice.CreatedAt.Add(5*time.Minute))
+
+    if got := f.invoice(t, invoice.ID); got.Status != domain.InvoiceStatusPaid {
+        t.Errorf("invoice is %s with %d payments, want paid", got.Status, len(got.Payments))
+    }
+    if status := f.order(t, order.ID).Status; status != domain.OrderStatusPaid {
+        t.Errorf("order is %s, want paid", status)
+    }
+}
+
+func TestPaymentWatcherIgnoresClaimedTransactions(t *testing.T) {
+    ctx := context.Background()
+    f := newPaymentFixture(t, nil)
+    first, firstInvoice := f.placeOrder(t, 1)
+    second, secondInvoice := f.placeOrder(t, 1)
+    if firstInvoice.Address == secondInvoice.Address {
+        t.Fatal("two invoices were given the same address")
+    }
+
+    // Point the second invoice at the first one's address, as a reused or
+    // re-derived address would.
+    secondInvoice.Address = firstInvoice.Address
+    if err := f.repos.Invoices.Update(ctx, secondInvoice); err != nil {
+        t.Fatal(err)
+    }
+
+    f.chain.Send(firstInvoice.Address, firstInvoice.Amount, secondInvoice.CreatedAt.Add(time.Minute))
+    f.chain.Mine(testConfirmations)
+    f.pollAt(t, secondInvoice.CreatedAt.Add(2*time.Minute))
+    // A second pass sees the transaction again for both invoices.
+    f.pollAt(t, secondInvoice.CreatedAt.Add(3*time.Minute))
+
+    var paid int
+    for _, pair := range []struct {
+        order   domain.Order
+        invoice domain.Invoice
+    }{{first, firstInvoice}, {second, secondInvoice}} {
+        invoice := f.invoice(t, pair.invoice.ID)
+        order := f.order(t, pair.order.ID)
+        switch invoice.Status {
+        case domain.InvoiceStatusPaid:
+            paid++
+            if order.Status != domain.OrderStatusPaid {
+                t.Errorf("order %s is %s though its invoice is paid", order.ID, order.Status)
+            }
+        case domain.InvoiceStatusPending:
+            if len(invoice.Payments) != 0 {
+                t.Errorf("invoice %s recorded a transaction another invoice claimed", invoice.ID)
+            }
+        default:
+            t.Errorf("invoice %s is %s", invoice.ID, invoice.Status)
+        }
+    }
+    if paid != 1 {
+        t.Errorf("%d invoices were paid by one transaction, want 1", paid)
+    }
+}
+
+func TestMarkingOrderPaidVoidsInvoice(t *testing.T) {
+    ctx := context.Background()
+    f := newPaymentFixture(t, nil)
+    order, invoice := f.placeOrder(t, 1)
+
+    // Staff take the payment some other way and mark the order paid.
+    if _, err := f.orders.TransitionOrder(ctx, order.ID, domain.OrderStatusPaid); err != nil {
+        t.Fatal(err)
+    }
+    if got := f.invoice(t, invoice.ID); got.Status != domain.InvoiceStatusVoid {
+        t.Fatalf("invoice is %s, want void", got.Status)
+    }
+
+    // The customer pays the invoice as well; it is no longer watched.
+    f.chain.Send(invoice.Address, invoice.Amount, invoice.CreatedAt.Add(time.Minute))
+    f.chain.Mine(testConfirmations)
+    f.pollAt(t, invoice.CreatedAt.Add(2*time.Minute))
+
+    if got := f.invoice(t, invoice.ID); got.Status != domain.InvoiceStatusVoid || len(got.Payments) != 0 {
+        t.Errorf("invoice is %s with %d payments, want void with none", got.Status, len(got.Payments))
+    }
+    got := f.order(t, order.ID)
+    if got.Status != domain.OrderStatusPaid || got.RefundDue != nil {
+        t.Errorf("order is %s with refund due %+v, want paid without a refund", got.Status, got.RefundDue)
+    }
+    if product := f.product(t); product.Stock != 9 || product.Reserved != 0 {
+        t.Errorf("stock %d reserved %d, want 9 and 0", product.Stock, product.Reserved)
+    }
+}
+
+func TestPaymentWatcherRefundDueForCancelledOrder(t *testing.T) {
+    ctx := context.Background()
+    f := newPaymentFixture(t, nil)
+    order, invoice := f.placeOrder(t, 1)
+
+    f.chain.Send(invoice.Address, invoice.Amount, invoice.CreatedAt.Add(time.Minute))
+    err := f.store.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        order, err := repos.Orders.GetByID(ctx, order.ID)
+        if err != nil {
+            return err
+        }
+        return cancelOrder(ctx, repos, &order, "user:buyer", "changed my mind", time.Now().UTC())
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    f.chain.Mine(testConfirmations)
+    f.pollAt(t, invoice.CreatedAt.Add(2*time.Minute))
+
+    if got := f.invoice(t, invoice.ID); got.Status != domain.InvoiceStatusPaid {
+        t.Errorf("invoice is %s, want paid", got.Status)
+    }
+    got := f.order(t, order.ID)
+    if got.Status != domain.OrderStatusCancelled {
+        t.Errorf("order is %s, want it to stay cancelled", got.Status)
+    }
+    if got.RefundDue == nil || got.RefundDue.InvoiceID != invoice.ID {
+        t.Fatalf("refund due = %+v, want one for invoice %s", got.RefundDue, invoice.ID)
+    }
+
+    page, err := f.repos.Orders.List(ctx, repository.OrderQuery{RefundDue: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(page.Items) != 1 || page.Items[0].ID != order.ID {
+        t.Errorf("refund due listing = %v, want only order %s", page.Items, order.ID)
+    }
+}
+
+func TestReservationSweeper(t *testing.T) {
+    ctx := context.Background()
+    f := newPaymentFixture(t, nil)
+
+    waiting, waitingInvoice := f.placeOrder(t, 2)
+    unpaid, unpaidInvoice := f.placeOrder(t, 3)
+    if product := f.product(t); product.Reserved != 5 {
+        t.Fatalf("reserved %d, want 5", product.Reserved)
+    }
+
+    // One order's payment is seen in time but is still gathering
+    // confirmations when its holds lapse.
+    f.chain.Send(waitingInvoice.Address, waitingInvoice.Amount, waitingInvoice.CreatedAt.Add(time.Minute))
+    f.pollAt(t, waitingInvoice.CreatedAt.Add(2*time.Minute))
+
+    sweepAt := unpaidInvoice.ExpiresAt.Add(time.Minute)
+    f.sweeper.now = func() time.Time { return sweepAt }
+    released, err := f.sweeper.Sweep(ctx)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if released != 1 {
+        t.Errorf("released %d holds, want the unpaid order's 1", released)
+    }
+
+    got := f.order(t, unpaid.ID)
+    if got.Status != domain.OrderStatusCancelled || got.Cancellation == nil || got.Cancellation.By != systemActor || got.Cancellation.Reason != expiredHoldReason {
+        t.Errorf("unpaid order is %s with cancellation %+v, want cancelled by the system", got.Status, got.Cancellation)
+    }
+    holds, err := f.repos.Reservations.ListByOrder(ctx, waiting.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, hold := range holds {
+        if hold.Status != domain.ReservationStatusHeld || !hold.ExpiresAt.Equal(sweepAt.Add(testPaymentTTL)) {
+            t.Errorf("awaited hold is %s until %s, want held until %s", hold.Status, hold.ExpiresAt, sweepAt.Add(testPaymentTTL))
+        }
+    }
+    if product := f.product(t); product.Stock != 10 || product.Reserved != 2 {
+        t.Errorf("stock %d reserved %d, want 10 and 2", product.Stock, product.Reserved)
+    }
+
+    // The awaited payment confirms after the invoice expired and still pays
+    // the order from its extended holds.
+    f.chain.Mine(testConfirmations)
+    f.pollAt(t, sweepAt.Add(time.Minute))
+    if status := f.order(t, waiting.ID).Status; status != domain.OrderStatusPaid {
+        t.Errorf("awaited order is %s, want paid", status)
+    }
+    if product := f.product(t); product.Stock != 8 || product.Reserved != 0 {
+        t.Errorf("stock %d reserved %d, want 8 and 0", product.Stock, product.Reserved)
+    }
+
+    // Nothing is left to sweep.
+    f.sweeper.now = func() time.Time { return sweepAt.Add(time.Hour) }
+    if released, err := f.sweeper.Sweep(ctx); err != nil || released != 0 {
+        t.Errorf("second sweep released %d, %v; want nothing", released, err)
+    }
+}
+
+func TestReservationSweeperSkipsFailingOrders(t *testing.T) {
+    ctx := context.Background()
+    f := newPaymentFixture(t, nil)
+
+    broken, _ := f.placeOrder(t, 1)
+    unpaid, invoice := f.placeOrder(t, 3)
+    // A hold allocated from a warehouse level that no longer exists cannot
+    // be released.
+    holds, err := f.repos.Reservations.ListByOrder(ctx, broken.ID)
+    if err != nil || len(holds) != 1 {
+        t.Fatalf("holds = %v, %v; want one", holds, err)
+    }
+    holds[0].WarehouseID = "closed"
+    if err := f.repos.Reservations.Update(ctx, holds[0]); err != nil {
+        t.Fatal(err)
+    }
+
+    f.sweeper.now = func() time.Time { return invoice.ExpiresAt.Add(time.Minute) }
+    for sweep, want := range []int{1, 0} {
+        released, err := f.sweeper.Sweep(ctx)
+        if err != nil {
+            t.Fatalf("sweep %d: %v", sweep+1, err)
+        }
+        if released != want {
+            t.Errorf("sweep %d released %d holds, want %d", sweep+1, released, want)
+        }
+    }
+
+    if status := f.order(t, unpaid.ID).Status; status != domain.OrderStatusCancelled {
+        t.Errorf("unpaid order is %s, want cancelled despite the failing one", status)
+    }
+    if status := f.order(t, broken.ID).Status; status != domain.OrderStatusPending {
+        t.Errorf("failing order is %s, want it left pending for the next sweep", status)
+    }
+    if product := f.product(t); product.Reserved != 1 {
+        t.Errorf("reserved %d, want only the failing order's 1", product.Reserved)
+    }
+}
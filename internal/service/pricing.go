@@ -0,0 +1,450 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math/big"
+    "slices"
+    "sort"
+    "time"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// promotionOrder is the order in which promotion types are applied; each
+// discounts what the ones before it left of the lines.
+var promotionOrder = []domain.PromotionType{
+    domain.PromotionBuyXGetY,
+    domain.PromotionPercentage,
+    domain.PromotionFixedAmount,
+    domain.PromotionFreeShipping,
+}
+
+// pricedLine is an order line in the order's currency.
+type pricedLine struct {
+    productID string
+    quantity  int
+    unitPrice domain.Money
+    subtotal  domain.Money
+}
+
+// orderPricing applies promotions to an order being placed. Every amount is
+// in the order's currency.
+type orderPricing struct {
+    repos     repository.Repositories
+    converter *lineConverter
+    userID    string
+    now       time.Time
+    lines     []pricedLine
+    subtotal  domain.Money
+    shipping  domain.Money
+
+    // categories holds the taxonomy and productCategories the categories of
+    // each line's product; both are loaded the first time a promotion
+    // targets categories.
+    categories        []domain.Category
+    productCategories map[string][]string
+}
+
+// candidate is a promotion that can apply to the order being priced.
+type candidate struct {
+    promotion domain.Promotion
+    // eligible marks the lines the promotion covers.
+    eligible []bool
+    // amountOff is the promotion's AmountOff in the order's currency.
+    amountOff domain.Money
+}
+
+// pricingResult is what a set of promotions takes off an order.
+type pricingResult struct {
+    applied []domain.AppliedPromotion
+    // lineDiscounts holds the discounts of each line.
+    lineDiscounts [][]domain.Discount
+    total         int64
+}
+
+// applyPromotions chooses the promotions for the order and works out what
+// each takes off. Every coupon in codes must apply, or the order is refused;
+// promotions without a code are added wherever the stacking rules allow,
+// picking whichever combination saves the customer the most.
+func (p *orderPricing) applyPromotions(ctx context.Context, codes []string) (pricingResult, error) {
+    var coupons []candidate
+    seen := make(map[string]bool)
+    for _, code := range codes {
+        code = domain.NormalizeCouponCode(code)
+        if code == "" || seen[code] {
+            continue
+        }
+        seen[code] = true
+
+        promotion, err := p.repos.Promotions.GetByCode(ctx, code)
+        if errors.Is(err, repository.ErrNotFound) {
+            return pricingResult{}, fmt.Errorf("%w: coupon %s does not exist", ErrValidation, code)
+        }
+        if err != nil {
+            return pricingResult{}, err
+        }
+        c, reason, err := p.candidate(ctx, promotion)
+        if err != nil {
+            return pricingResult{}, err
+        }
+        if reason != "" {
+            return pricingResult{}, fmt.Errorf("%w: coupon %s %s", ErrValidation, code, reason)
+        }
+        coupons = append(coupons, c)
+    }
+    for _, c := range coupons {
+        if !c.promotion.Stackable && len(coupons) > 1 {
+            return pricingResult{}, fmt.Errorf("%w: coupon %s cannot be combined with other coupons", ErrValidation, c.promotion.Code)
+        }
+    }
+
+    all, err := p.repos.Promotions.List(ctx)
+    if err != nil {
+        return pricingResult{}, err
+    }
+    var stackable, exclusive []candidate
+    for _, promotion := range all {
+        if promotion.Code != "" {
+            continue
+        }
+        c, reason, err := p.candidate(ctx, promotion)
+        if err != nil {
+            return pricingResult{}, err
+        }
+        if reason != "" {
+            continue
+        }
+        if promotion.Stackable {
+            stackable = append(stackable, c)
+        } else {
+            exclusive = append(exclusive, c)
+        }
+    }
+
+    // A coupon that does not stack is used on its own. Otherwise the coupons
+    // combine with every stackable automatic promotion, and an order without
+    // coupons may instead get the best automatic promotion that does not stack.
+    var options [][]candidate
+    switch {
+    case len(coupons) == 1 && !coupons[0].promotion.Stackable:
+        options = append(options, coupons)
+    default:
+        options = append(options, slices.Concat(coupons, stackable))
+        if len(coupons) == 0 {
+            for _, c := range exclusive {
+                options = append(options, []candidate{c})
+            }
+        }
+    }
+
+    var best pricingResult
+    for i, option := range options {
+        result, err := p.evaluate(option)
+        if err != nil {
+            return pricingResult{}, err
+        }
+        if i == 0 || result.total > best.total {
+            best = result
+        }
+    }
+    return best, nil
+}
+
+// candidate checks whether promotion can apply to the order. When it cannot,
+// the returned reason completes a sentence such as "coupon X has expired".
+func (p *orderPricing) candidate(ctx context.Context, promotion domain.Promotion) (candidate, string, error) {
+    switch {
+    case !promotion.Active:
+        return candidate{}, "is no longer available", nil
+    case promotion.StartsAt != nil && p.now.Before(*promotion.StartsAt):
+        return candidate{}, "is not valid yet", nil
+    case promotion.EndsAt != nil && !p.now.Before(*promotion.EndsAt):
+        return candidate{}, "has expired", nil
+    case promotion.UsageLimit > 0 && promotion.Redemptions >= promotion.UsageLimit:
+        return candidate{}, "has been fully redeemed", nil
+    }
+    if promotion.PerUserLimit > 0 {
+        used, err := p.repos.Promotions.CountUserRedemptions(ctx, promotion.ID, p.userID)
+        if err != nil {
+            return candidate{}, "", err
+        }
+        if used >= promotion.PerUserLimit {
+            return candidate{}, "has already been used as often as allowed", nil
+        }
+    }
+
+    if promotion.MinOrderValue != nil {
+        minimum, err := p.converter.convert(ctx, *promotion.MinOrderValue)
+        if errors.Is(err, ErrRateUnavailable) {
+            return candidate{}, fmt.Sprintf("cannot be used for orders in %s", p.subtotal.Currency), nil
+        }
+        if err != nil {
+            return candidate{}, "", err
+        }
+        if p.subtotal.Amount < minimum.Amount {
+            return candidate{}, fmt.Sprintf("needs an order of at least %s", promotion.MinOrderValue), nil
+        }
+    }
+
+    c := candidate{promotion: promotion}
+    if promotion.AmountOff != nil {
+        amountOff, err := p.converter.convert(ctx, *promotion.AmountOff)
+        if errors.Is(err, ErrRateUnavailable) {
+            return candidate{}, fmt.Sprintf("cannot be used for orders in %s", p.subtotal.Currency), nil
+        }
+        if err != nil {
+            return candidate{}, "", err
+        }
+        c.amountOff = amountOff
+    }
+
+    eligible, err := p.eligibleLines(ctx, promotion)
+    if err != nil {
+        return candidate{}, "", err
+    }
+    units := 0
+    for i, ok := range eligible {
+        if ok {
+            units += p.lines[i].quantity
+        }
+    }
+    if units == 0 {
+        return candidate{}, "does not apply to any item in the order", nil
+    }
+    if promotion.Type == domain.PromotionBuyXGetY && units < promotion.BuyQuantity+promotion.GetQuantity {
+        return candidate{}, fmt.Sprintf("needs at least %d eligible items", promotion.BuyQuantity+promotion.GetQuantity), nil
+    }
+    c.eligible = eligible
+    return c, "", nil
+}
+
+// eligibleLines marks the lines whose product the promotion covers.
+func (p *orderPricing) eligibleLines(ctx context.Context, promotion domain.Promotion) ([]bool, error) {
+    eligible := make([]bool, len(p.lines))
+    if !promotion.Targeted() {
+        for i := range eligible {
+            eligible[i] = true
+        }
+        return eligible, nil
+    }
+
+    var targeted map[string]bool
+    if len(promotion.CategoryIDs) > 0 {
+        if p.categories == nil {
+            categories, err := p.repos.Categories.List(ctx)
+            if err != nil {
+                return nil, err
+            }
+            p.categories = categories
+            p.productCategories = make(map[string][]string)
+        }
+        targeted = make(map[string]bool)
+        for _, id := range promotion.CategoryIDs {
+            for _, descendant := range descendants(p.categories, id) {
+                targeted[descendant] = true
+            }
+        }
+    }
+
+    for i, line := range p.lines {
+        if slices.Contains(promotion.ProductIDs, line.productID) {
+            eligible[i] = true
+            continue
+        }
+        if targeted == nil {
+            continue
+        }
+        categoryIDs, ok := p.productCategories[line.productID]
+        if !ok {
+            categories, err := p.repos.Categories.ListByProduct(ctx, line.productID)
+            if err != nil {
+                return nil, err
+            }
+            categoryIDs = make([]string, 0, len(categories))
+            for _, category := range categories {
+                categoryIDs = append(categoryIDs, category.ID)
+            }
+            p.productCategories[line.productID] = categoryIDs
+        }
+        for _, id := range categoryIDs {
+            if targeted[id] {
+                eligible[i] = true
+                break
+            }
+        }
+    }
+    return eligible, nil
+}
+
+// evaluate works out what a set of promotions takes off the order. The
+// promotions are applied by type in promotionOrder, oldest first within a
+// type, and no line or shipping fee is discounted below zero. Promotions
+// that end up taking nothing off are left out.
+func (p *orderPricing) evaluate(set []candidate) (pricingResult, error) {
+    set = slices.Clone(set)
+    sort.SliceStable(set, func(i, j int) bool {
+        a, b := set[i].promotion, set[j].promotion
+        if a.Type != b.Type {
+            return slices.Index(promotionOrder, a.Type) < slices.Index(promotionOrder, b.Type)
+        }
+        if !a.CreatedAt.Equal(b.CreatedAt) {
+            return a.CreatedAt.Before(b.CreatedAt)
+        }
+        return a.ID < b.ID
+    })
+
+    remaining := make([]int64, len(p.lines))
+    for i, line := range p.lines {
+        remaining[i] = line.subtotal.Amount
+    }
+    shipping := p.shipping.Amount
+    result := pricingResult{lineDiscounts: make([][]domain.Discount, len(p.lines))}
+
+    for _, c := range set {
+        var (
+            amounts []int64
+            waived  int64
+            err     error
+        )
+        switch c.promotion.Type {
+        case domain.PromotionPercentage:
+            amounts, err = p.percentOff(remaining, c.eligible, c.promotion.PercentOff)
+        case domain.PromotionFixedAmount:
+            amounts = amountOff(remaining, c.eligible, c.amountOff.Amount)
+        case domain.PromotionBuyXGetY:
+            amounts = p.freeUnits(remaining, c.eligible, c.promotion.BuyQuantity, c.promotion.GetQuantity)
+        case domain.PromotionFreeShipping:
+            waived, shipping = shipping, 0
+        }
+        if err != nil {
+            return pricingResult{}, fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+
+        total := waived
+        for i, amount := range amounts {
+            if amount == 0 {
+                continue
+            }
+            remaining[i] -= amount
+            total += amount
+            result.lineDiscounts[i] = append(result.lineDiscounts[i], domain.Discount{
+                PromotionID: c.promotion.ID,
+                Code:        c.promotion.Code,
+                Amount:      domain.NewMoney(amount, p.subtotal.Currency),
+            })
+        }
+        if total == 0 {
+            continue
+        }
+        result.total += total
+        result.applied = append(result.applied, domain.AppliedPromotion{
+            PromotionID: c.promotion.ID,
+            Code:        c.promotion.Code,
+            Name:        c.promotion.Name,
+            Type:        c.promotion.Type,
+            Amount:      domain.NewMoney(total, p.subtotal.Currency),
+        })
+    }
+    return result, nil
+}
+
+// percentOff takes percent of what is left of each eligible line, rounding
+// each line's discount to the nearest minor unit.
+func (p *orderPricing) percentOff(remaining []int64, eligible []bool, percent int) ([]int64, error) {
+    rate := big.NewRat(int64(percent), 100)
+    amounts := make([]int64, len(remaining))
+    for i, left := range remaining {
+        if !eligible[i] {
+            continue
+        }
+        discount, err := domain.NewMoney(left, p.subtotal.Currency).MulRat(rate, domain.RoundHalfEven)
+        if err != nil {
+            return nil, err
+        }
+        amounts[i] = discount.Amount
+    }
+    return amounts, nil
+}
+
+// amountOff spreads amount over the eligible lines in proportion to what is
+// left of them, taking at most all of it. Minor units that do not divide
+// evenly go to the lines with the largest remainders, so the shares add up
+// to exactly the amount taken.
+func amountOff(remaining []int64, eligible []bool, amount int64) []int64 {
+    base := new(big.Int)
+    for i, left := range remaining {
+        if eligible[i] {
+            base.Add(base, big.NewInt(left))
+        }
+    }
+    amounts := make([]int64, len(remaining))
+    if base.Sign() == 0 {
+        return amounts
+    }
+    if base.Cmp(big.NewInt(amount)) <= 0 {
+        for i, left := range remaining {
+            if eligible[i] {
+                amounts[i] = left
+            }
+        }
+        return amounts
+    }
+
+    type share struct {
+        line      int
+        remainder *big.Int
+    }
+    var shares []share
+    leftover := amount
+    for i, left := range remaining {
+        if !eligible[i] || left == 0 {
+            continue
+        }
+        quotient, remainder := new(big.Int).QuoRem(new(big.Int).Mul(big.NewInt(amount), big.NewInt(left)), base, new(big.Int))
+        amounts[i] = quotient.Int64()
+        leftover -= amounts[i]
+        shares = append(shares, share{line: i, remainder: remainder})
+    }
+    sort.SliceStable(shares, func(i, j int) bool { return shares[i].remainder.Cmp(shares[j].remainder) > 0 })
+    for i := 0; leftover > 0; i++ {
+        amounts[shares[i].line]++
+        leftover--
+    }
+    return amounts
+}
+
+// freeUnits gives away get units for every buy+get eligible units in the
+// order, choosing the cheapest units, and returns what that takes off each line.
+func (p *orderPricing) freeUnits(remaining []int64, eligible []bool, buy, get int) []int64 {
+    var lines []int
+    units := 0
+    for i, line := range p.lines {
+        if eligible[i] {
+            lines = append(lines, i)
+            units += line.quantity
+        }
+    }
+    sort.SliceStable(lines, func(i, j int) bool {
+        return p.lines[lines[i]].unitPrice.Amount < p.lines[lines[j]].unitPrice.Amount
+    })
+
+    amounts := make([]int64, len(remaining))
+    free := units / (buy + get) * get
+    for _, i := range lines {
+        if free == 0 {
+            break
+        }
+        n := min(free, p.lines[i].quantity)
+        free -= n
+        discount, err := p.lines[i].unitPrice.Mul(int64(n))
+        if err != nil || discount.Amount > remaining[i] {
+            // The units cannot be worth more than the whole line.
+            discount.Amount = remaining[i]
+        }
+        amounts[i] = discount.Amount
+    }
+    return amounts
+}
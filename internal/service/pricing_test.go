@@ -0,0 +1,311 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "slices"
+    "strings"
+    "testing"
+    "time"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository/memory"
+)
+
+var pricingEpoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestPricing prices an order for two ledgers at 1000 and a cable at 500,
+// shipped for 300, against the promotions and redemptions given. Promotions
+// are created a minute apart in the order listed.
+func newTestPricing(t *testing.T, promotions []domain.Promotion, redemptions []domain.PromotionRedemption) *orderPricing {
+    t.Helper()
+    ctx := context.Background()
+    repos := memory.NewStore().Repositories()
+    for i, promotion := range promotions {
+        promotion.CreatedAt = pricingEpoch.Add(time.Duration(i) * time.Minute)
+        if err := repos.Promotions.Create(ctx, promotion); err != nil {
+            t.Fatal(err)
+        }
+    }
+    for _, redemption := range redemptions {
+        if err := repos.Promotions.Redeem(ctx, redemption); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return &orderPricing{
+        repos:     repos,
+        converter: newLineConverter(nil, "BTC"),
+        userID:    "buyer",
+        now:       pricingEpoch.Add(24 * time.Hour),
+        lines: []pricedLine{
+            {productID: "ledger", quantity: 2, unitPrice: domain.NewMoney(1000, "BTC"), subtotal: domain.NewMoney(2000, "BTC")},
+            {productID: "cable", quantity: 1, unitPrice: domain.NewMoney(500, "BTC"), subtotal: domain.NewMoney(500, "BTC")},
+        },
+        subtotal: domain.NewMoney(2500, "BTC"),
+        shipping: domain.NewMoney(300, "BTC"),
+    }
+}
+
+func testPromotion(id string, typ domain.PromotionType, stackable bool, set func(*domain.Promotion)) domain.Promotion {
+    promotion := domain.Promotion{ID: id, Name: id, Type: typ, Stackable: stackable, Active: true}
+    if set != nil {
+        set(&promotion)
+    }
+    return promotion
+}
+
+func percentOff(id string, percent int, stackable bool) domain.Promotion {
+    return testPromotion(id, domain.PromotionPercentage, stackable, func(p *domain.Promotion) { p.PercentOff = percent })
+}
+
+func fixedOff(id string, amount int64, stackable bool) domain.Promotion {
+    return testPromotion(id, domain.PromotionFixedAmount, stackable, func(p *domain.Promotion) {
+        off := domain.NewMoney(amount, "BTC")
+        p.AmountOff = &off
+    })
+}
+
+func freeShipping(id string, stackable bool) domain.Promotion {
+    return testPromotion(id, domain.PromotionFreeShipping, stackable, nil)
+}
+
+func coupon(code string, promotion domain.Promotion) domain.Promotion {
+    promotion.Code = code
+    return promotion
+}
+
+// describe lists what each applied promotion and each line's discounts took
+// off as "promotion=amount".
+func describe(result pricingResult) (applied []string, lines [][]string) {
+    for _, a := range result.applied {
+        applied = append(applied, fmt.Sprintf("%s=%d", a.PromotionID, a.Amount.Amount))
+    }
+    for _, discounts := range result.lineDiscounts {
+        var line []string
+        for _, d := range discounts {
+            line = append(line, fmt.Sprintf("%s=%d", d.PromotionID, d.Amount.Amount))
+        }
+        lines = append(lines, line)
+    }
+    return applied, lines
+}
+
+func TestApplyPromotions(t *testing.T) {
+    tests := []struct {
+        name        string
+        promotions  []domain.Promotion
+        redemptions []domain.PromotionRedemption
+        codes       []string
+        wantErr     string
+        wantApplied []string
+        wantLines   [][]string
+        wantTotal   int64
+    }{
+        {
+            name: "stackable promotions apply by type, not age",
+            // The fixed amount is older but comes off what the percentage
+            // leaves: 100 split 1800:450.
+            promotions:  []domain.Promotion{fixedOff("fixed", 100, true), percentOff("pct", 10, true)},
+            wantApplied: []string{"pct=250", "fixed=100"},
+            wantLines:   [][]string{{"pct=200", "fixed=80"}, {"pct=50", "fixed=20"}},
+            wantTotal:   350,
+        },
+        {
+            name:        "percentages compound",
+            promotions:  []domain.Promotion{percentOff("first", 10, true), percentOff("second", 10, true)},
+            wantApplied: []string{"first=250", "second=225"},
+            wantLines:   [][]string{{"first=200", "second=180"}, {"first=50", "second=45"}},
+            wantTotal:   475,
+        },
+        {
+            name: "buy x get y comes before percentages",
+            promotions: []domain.Promotion{
+                percentOff("pct", 10, true),
+                testPromotion("bogo", domain.PromotionBuyXGetY, true, func(p *domain.Promotion) { p.BuyQuantity, p.GetQuantity = 1, 1 }),
+            },
+            // The cable is the cheapest unit and goes free, leaving nothing
+            // for the percentage to take off it.
+            wantApplied: []string{"bogo=500", "pct=200"},
+            wantLines:   [][]string{{"pct=200"}, {"bogo=500"}},
+            wantTotal:   700,
+        },
+        {
+            name:        "no line goes below zero",
+            promotions:  []domain.Promotion{fixedOff("huge", 5000, true)},
+            wantApplied: []string{"huge=2500"},
+            wantLines:   [][]string{{"huge=2000"}, {"huge=500"}},
+            wantTotal:   2500,
+        },
+        {
+            name: "best promotion that does not stack wins",
+            // Free shipping alone saves 300 and the fixed amount 300, but
+            // 20% saves 500 and cannot be combined with free shipping.
+            promotions:  []domain.Promotion{freeShipping("ship", true), percentOff("twenty", 20, false), fixedOff("fixed", 300, false)},
+            wantApplied: []string{"twenty=500"},
+            wantLines:   [][]string{{"twenty=400"}, {"twenty=100"}},
+            wantTotal:   500,
+        },
+        {
+            name:        "stackable promotions win when they save more",
+            promotions:  []domain.Promotion{freeShipping("ship", true), fixedOff("fixed", 100, true), percentOff("five", 5, false)},
+            wantApplied: []string{"fixed=100", "ship=300"},
+            wantLines:   [][]string{{"fixed=80"}, {"fixed=20"}},
+            wantTotal:   400,
+        },
+        {
+            name:        "coupon that does not stack is used alone",
+            promotions:  []domain.Promotion{freeShipping("ship", true), percentOff("twenty", 20, false), coupon("SAVE5", percentOff("save5", 5, false))},
+            codes:       []string{" save5 "},
+            wantApplied: []string{"save5=125"},
+            wantLines:   [][]string{{"save5=100"}, {"save5=25"}},
+            wantTotal:   125,
+        },
+        {
+            name:        "stackable coupon joins stackable promotions only",
+            promotions:  []domain.Promotion{freeShipping("ship", true), percentOff("twenty", 20, false), coupon("TENOFF", fixedOff("tenoff", 100, true))},
+            codes:       []string{"TENOFF", "tenoff"},
+            wantApplied: []string{"tenoff=100", "ship=300"},
+            wantLines:   [][]string{{"tenoff=80"}, {"tenoff=20"}},
+            wantTotal:   400,
+        },
+        {
+            name:       "coupons that do not stack cannot be combined",
+            promotions: []domain.Promotion{coupon("A", percentOff("a", 5, true)), coupon("B", percentOff("b", 5, false))},
+            codes:      []string{"A", "B"},
+            wantErr:    "coupon B cannot be combined",
+        },
+        {
+            name:    "unknown coupon",
+            codes:   []string{"NOPE"},
+            wantErr: "coupon NOPE does not exist",
+        },
+        {
+            name: "coupon below its minimum order",
+            promotions: []domain.Promotion{coupon("BIG", testPromotion("big", domain.PromotionFreeShipping, true, func(p *domain.Promotion) {
+                minimum := domain.NewMoney(3000, "BTC")
+                p.MinOrderValue = &minimum
+            }))},
+            codes:   []string{"BIG"},
+            wantErr: "needs an order of at least",
+        },
+        {
+            name: "fully redeemed coupon",
+            promotions: []domain.Promotion{coupon("GONE", testPromotion("gone", domain.PromotionFreeShipping, true, func(p *domain.Promotion) {
+                p.UsageLimit = 2
+            }))},
+            redemptions: []domain.PromotionRedemption{
+                {PromotionID: "gone", UserID: "someone else", OrderID: "earlier"},
+                {PromotionID: "gone", UserID: "another", OrderID: "later"},
+            },
+            codes:   []string{"GONE"},
+            wantErr: "has been fully redeemed",
+        },
+        {
+            name: "coupon used as often as the customer may",
+            promotions: []domain.Promotion{coupon("ONCE", testPromotion("once", domain.PromotionFreeShipping, true, func(p *domain.Promotion) {
+                p.PerUserLimit = 1
+            }))},
+            redemptions: []domain.PromotionRedemption{{PromotionID: "once", UserID: "buyer", OrderID: "earlier"}},
+            codes:       []string{"ONCE"},
+            wantErr:     "has already been used",
+        },
+        {
+            name: "other customers' redemptions do not count",
+            promotions: []domain.Promotion{coupon("ONCE", testPromotion("once", domain.PromotionFreeShipping, true, func(p *domain.Promotion) {
+                p.PerUserLimit = 1
+            }))},
+            redemptions: []domain.PromotionRedemption{{PromotionID: "once", UserID: "someone else", OrderID: "earlier"}},
+            codes:       []string{"ONCE"},
+            wantApplied: []string{"once=300"},
+            wantLines:   [][]string{nil, nil},
+            wantTotal:   300,
+        },
+        {
+            name: "exhausted promotions without a code are skipped",
+            promotions: []domain.Promotion{
+                testPromotion("gone", domain.PromotionFreeShipping, true, func(p *domain.Promotion) { p.UsageLimit = 1 }),
+                testPromotion("inactive", domain.PromotionFreeShipping, true, func(p *domain.Promotion) { p.Active = false }),
+            },
+            redemptions: []domain.PromotionRedemption{{PromotionID: "gone", UserID: "someone else", OrderID: "earlier"}},
+            wantLines:   [][]string{nil, nil},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            p := newTestPricing(t, tt.promotions, tt.redemptions)
+            result, err := p.applyPromotions(context.Background(), tt.codes)
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Fatalf("applyPromotions error = %v, want one mentioning %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            applied, lines := describe(result)
+            if !slices.Equal(applied, tt.wantApplied) {
+                t.Errorf("applied %v, want %v", applied, tt.wantApplied)
+            }
+            if fmt.Sprint(lines) != fmt.Sprint(tt.wantLines) {
+                t.Errorf("line discounts %v, want %v", lines, tt.wantLines)
+            }
+            if result.total != tt.wantTotal {
+                t.Errorf("total %d, want %d", result.total, tt.wantTotal)
+            }
+        })
+    }
+}
+
+func TestAmountOff(t *testing.T) {
+    tests := []struct {
+        name      string
+        remaining []int64
+        eligible  []bool
+        amount    int64
+        want      []int64
+    }{
+        {"divides evenly", []int64{1800, 450}, []bool{true, true}, 100, []int64{80, 20}},
+        {"largest remainder gets the extra unit", []int64{100, 200, 300}, []bool{true, true, true}, 100, []int64{17, 33, 50}},
+        {"ties go to the earlier line", []int64{10, 10, 10}, []bool{true, true, true}, 10, []int64{4, 3, 3}},
+        {"ineligible lines are left alone", []int64{100, 200, 300}, []bool{true, false, true}, 50, []int64{13, 0, 37}},
+        {"at most everything eligible", []int64{100, 200, 300}, []bool{true, false, true}, 1000, []int64{100, 0, 300}},
+        {"nothing left", []int64{0, 0}, []bool{true, true}, 10, []int64{0, 0}},
+    }
+    for _, tt := range tests {
+        got := amountOff(tt.remaining, tt.eligible, tt.amount)
+        if !slices.Equal(got, tt.want) {
+            t.Errorf("%s: amountOff(%v, %v, %d) = %v, want %v", tt.name, tt.remaining, tt.eligible, tt.amount, got, tt.want)
+        }
+    }
+}
+
+func TestFreeUnits(t *testing.T) {
+    line := func(quantity int, unitPrice int64) pricedLine {
+        return pricedLine{quantity: quantity, unitPrice: domain.NewMoney(unitPrice, "BTC"), subtotal: domain.NewMoney(int64(quantity)*unitPrice, "BTC")}
+    }
+    // Two units at 500, one at 200 and one at 300.
+    p := &orderPricing{lines: []pricedLine{line(2, 500), line(1, 200), line(1, 300)}}
+    full := []int64{1000, 200, 300}
+    all := []bool{true, true, true}
+
+    tests := []struct {
+        name      string
+        remaining []int64
+        eligible  []bool
+        buy, get  int
+        want      []int64
+    }{
+        {"one set", full, all, 2, 1, []int64{0, 200, 0}},
+        {"cheapest units first", full, all, 1, 1, []int64{0, 200, 300}},
+        {"free units span lines", full, all, 1, 3, []int64{500, 200, 300}},
+        {"only eligible units count", full, []bool{true, false, true}, 1, 1, []int64{0, 0, 300}},
+        {"no more than what is left of a line", []int64{1000, 150, 300}, all, 2, 1, []int64{0, 150, 0}},
+    }
+    for _, tt := range tests {
+        got := p.freeUnits(tt.remaining, tt.eligible, tt.buy, tt.get)
+        if !slices.Equal(got, tt.want) {
+            t.Errorf("%s: freeUnits = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
@@ -2,7 +2,11 @@ package service
 
 import (
     "context"
+    "encoding/json"
+    "errors"
     "fmt"
+    "strings"
+    "time"
 
     "github.com/google/uuid"
 
@@ -12,61 +16,174 @@ import (
 
 // ProductService contains the business logic for products.
 type ProductService struct {
-    repo repository.ProductRepository
+    repo   repository.ProductRepository
+    tx     repository.TxManager
+    rates  ExchangeRateProvider
+    search SearchIndex
 }
 
-// NewProductService creates a new ProductService.
-func NewProductService(repo repository.ProductRepository) *ProductService {
-    return &ProductService{repo: repo}
+// NewProductService creates a new ProductService. Product changes are
+// mirrored into search once they are committed.
+func NewProductService(repo repository.ProductRepository, tx repository.TxManager, rates ExchangeRateProvider, search SearchIndex) *ProductService {
+    return &ProductService{repo: repo, tx: tx, rates: rates, search: search}
 }
 
-// CreateProduct persists a new product.
+// QuotedProduct is a product together with its price expressed in a requested currency.
+type QuotedProduct struct {
+    domain.Product
+    Quote domain.Money        `json:"quote"`
+    Rate  domain.ExchangeRate `json:"rate"`
+}
+
+// ProductSearchResult is a product matching a search, with its relevance
+// score and highlighted excerpts of the matching fields.
+type ProductSearchResult struct {
+    Product    domain.Product    `json:"product"`
+    Score      float64           `json:"score"`
+    Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// MarshalJSON encodes the product fields alongside the quote. It is needed
+// because the embedded product's own MarshalJSON would otherwise be promoted
+// and drop the quote.
+func (q QuotedProduct) MarshalJSON() ([]byte, error) {
+    encoded, err := json.Marshal(q.Product)
+    if err != nil {
+        return nil, err
+    }
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(encoded, &fields); err != nil {
+        return nil, err
+    }
+    if fields["quote"], err = json.Marshal(q.Quote); err != nil {
+        return nil, err
+    }
+    if fields["rate"], err = json.Marshal(q.Rate); err != nil {
+        return nil, err
+    }
+    return json.Marshal(fields)
+}
+
+// CreateProduct persists a new product. Its initial stock, or that of each
+// of its variants, is recorded in the ledger as a restock.
 func (s *ProductService) CreateProduct(ctx context.Context, input domain.Product) (domain.Product, error) {
+    now := time.Now().UTC()
     product := domain.Product{
         ID:          uuid.NewString(),
         Name:        input.Name,
         Description: input.Description,
         Price:       input.Price,
         Stock:       input.Stock,
+        Options:     input.Options,
+        Variants:    make([]domain.Variant, len(input.Variants)),
+        CreatedAt:   now,
+    }
+    for i, variant := range input.Variants {
+        variant.Reserved = 0
+        product.Variants[i] = variant
+    }
+    if product.HasVariants() {
+        if product.Stock != 0 {
+            return domain.Product{}, fmt.Errorf("%w: stock is set on each variant of a product sold as variants", ErrValidation)
+        }
+        product.Stock = totalVariantStock(product.Variants)
     }
 
     if err := product.Validate(); err != nil {
         return domain.Product{}, fmt.Errorf("%w: %w", ErrValidation, err)
     }
 
-    if err := s.repo.Create(ctx, product); err != nil {
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        if err := repos.Products.Create(ctx, product); err != nil {
+            return err
+        }
+        opening := []domain.Variant{{Stock: product.Stock}}
+        if product.HasVariants() {
+            opening = product.Variants
+        }
+        for _, variant := range opening {
+            if variant.Stock == 0 {
+                continue
+            }
+            if err := recordMovement(ctx, repos, domain.StockMovement{
+                ProductID:   product.ID,
+                SKU:         variant.SKU,
+                Quantity:    variant.Stock,
+                Reason:      domain.StockMovementRestock,
+                ReferenceID: product.ID,
+                Actor:       systemActor,
+                CreatedAt:   now,
+            }); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
         return domain.Product{}, err
     }
 
+    if err := s.search.Index(ctx, product); err != nil {
+        return domain.Product{}, fmt.Errorf("index product %s: %w", product.ID, err)
+    }
     return product, nil
 }
 
-// UpdateProduct updates an existing product by ID.
+// UpdateProduct updates an existing product's details by ID, replacing its
+// options and variants. Stock is left untouched; it changes only through
+// StockService.AdjustStock so that every change reaches the ledger. Variants
+// keep their stock by SKU, new variants start without stock, and a variant
+// can only be removed once it has none. The read and write share a
+// transaction so concurrent stock changes are not overwritten.
 func (s *ProductService) UpdateProduct(ctx context.Context, id string, input domain.Product) (domain.Product, error) {
-    product, err := s.repo.GetByID(ctx, id)
-    if err != nil {
-        return domain.Product{}, err
-    }
+    var product domain.Product
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        product, err = repos.Products.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
 
-    product.Name = input.Name
-    product.Description = input.Description
-    product.Price = input.Price
-    product.Stock = input.Stock
-
-    if err := product.Validate(); err != nil {
-        return domain.Product{}, fmt.Errorf("%w: %w", ErrValidation, err)
-    }
+        variants, err := mergeVariants(product, input.Variants)
+        if err != nil {
+            return err
+        }
+        product.Name = input.Name
+        product.Description = input.Description
+        product.Price = input.Price
+        product.Options = input.Options
+        product.Variants = variants
 
-    if err := s.repo.Update(ctx, product); err != nil {
+        if err := product.Validate(); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        return repos.Products.Update(ctx, product)
+    })
+    if err != nil {
         return domain.Product{}, err
     }
 
+    if err := s.search.Index(ctx, product); err != nil {
+        return domain.Product{}, fmt.Errorf("index product %s: %w", product.ID, err)
+    }
     return product, nil
 }
 
-// DeleteProduct removes a product by ID.
+// DeleteProduct removes a product by ID, along with its category assignments.
 func (s *ProductService) DeleteProduct(ctx context.Context, id string) error {
-    return s.repo.Delete(ctx, id)
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        if err := repos.Categories.SetProductCategories(ctx, id, nil); err != nil {
+            return err
+        }
+        return repos.Products.Delete(ctx, id)
+    })
+    if err != nil {
+        return err
+    }
+    if err := s.search.Remove(ctx, id); err != nil {
+        return fmt.Errorf("remove product %s from search: %w", id, err)
+    }
+    return nil
 }
 
 // GetProduct returns a product by ID.
@@ -74,7 +191,131 @@ func (s *ProductService) GetProduct(ctx context.Context, id string) (domain.Prod
     return s.repo.GetByID(ctx, id)
 }
 
-// ListProducts returns all products.
-func (s *ProductService) ListProducts(ctx context.Context) ([]domain.Product, error) {
-    return s.repo.List(ctx)
+// ListProducts returns a page of products.
+func (s *ProductService) ListProducts(ctx context.Context, input ListProductsInput) (repository.Page[domain.Product], error) {
+    query, err := input.query()
+    if err != nil {
+        return repository.Page[domain.Product]{}, err
+    }
+    return s.repo.List(ctx, query)
+}
+
+// SearchProducts returns up to limit products matching text, most relevant
+// first. A zero limit means repository.DefaultLimit.
+func (s *ProductService) SearchProducts(ctx context.Context, text string, limit int) (repository.Page[ProductSearchResult], error) {
+    if strings.TrimSpace(text) == "" {
+        return repository.Page[ProductSearchResult]{}, fmt.Errorf("%w: q is required", ErrValidation)
+    }
+    if limit < 0 || limit > repository.MaxLimit {
+        return repository.Page[ProductSearchResult]{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrValidation, repository.MaxLimit)
+    }
+    if limit == 0 {
+        limit = repository.DefaultLimit
+    }
+
+    hits, err := s.search.Search(ctx, text, limit)
+    if err != nil {
+        return repository.Page[ProductSearchResult]{}, err
+    }
+    results := repository.Page[ProductSearchResult]{Items: make([]ProductSearchResult, 0, len(hits))}
+    for _, hit := range hits {
+        product, err := s.repo.GetByID(ctx, hit.ProductID)
+        if errors.Is(err, repository.ErrNotFound) {
+            // Deleted since the search ran.
+            continue
+        }
+        if err != nil {
+            return repository.Page[ProductSearchResult]{}, err
+        }
+        results.Items = append(results.Items, ProductSearchResult{Product: product, Score: hit.Score, Highlights: hit.Highlights})
+    }
+    return results, nil
+}
+
+// ReindexProducts loads every product into the search index. It is run at
+// startup, when the catalog is held in a persistent store the in-process
+// index has not seen.
+func (s *ProductService) ReindexProducts(ctx context.Context) error {
+    query := repository.ProductQuery{ListOptions: repository.ListOptions{Limit: repository.MaxLimit}}
+    for {
+        page, err := s.repo.List(ctx, query)
+        if err != nil {
+            return err
+        }
+        for _, product := range page.Items {
+            if err := s.search.Index(ctx, product); err != nil {
+                return fmt.Errorf("index product %s: %w", product.ID, err)
+            }
+        }
+        if page.NextCursor == "" {
+            return nil
+        }
+        query.Cursor = page.NextCursor
+    }
+}
+
+// QuoteProduct returns a product priced in currency.
+func (s *ProductService) QuoteProduct(ctx context.Context, id, currency string) (QuotedProduct, error) {
+    product, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        return QuotedProduct{}, err
+    }
+    return s.quoteProduct(ctx, product, currency)
+}
+
+// QuoteProducts returns a page of products priced in currency.
+func (s *ProductService) QuoteProducts(ctx context.Context, currency string, input ListProductsInput) (repository.Page[QuotedProduct], error) {
+    products, err := s.ListProducts(ctx, input)
+    if err != nil {
+        return repository.Page[QuotedProduct]{}, err
+    }
+
+    quoted := repository.Page[QuotedProduct]{Items: make([]QuotedProduct, 0, len(products.Items)), NextCursor: products.NextCursor}
+    for _, product := range products.Items {
+        q, err := s.quoteProduct(ctx, product, currency)
+        if err != nil {
+            return repository.Page[QuotedProduct]{}, err
+        }
+        quoted.Items = append(quoted.Items, q)
+    }
+    return quoted, nil
+}
+
+func (s *ProductService) quoteProduct(ctx context.Context, product domain.Product, currency string) (QuotedProduct, error) {
+    price, rate, err := quote(ctx, s.rates, product.Price, currency)
+    if err != nil {
+        return QuotedProduct{}, err
+    }
+    return QuotedProduct{Product: product, Quote: price, Rate: rate}, nil
+}
+
+// mergeVariants returns the variants a product is updated to, carrying over
+// the stock of variants that keep their SKU.
+func mergeVariants(current domain.Product, updated []domain.Variant) ([]domain.Variant, error) {
+    if len(updated) > 0 && !current.HasVariants() && current.Stock != 0 {
+        return nil, fmt.Errorf("%w: product %s has stock of its own; adjust it to zero before adding variants", ErrValidation, current.ID)
+    }
+
+    kept := make(map[string]bool, len(updated))
+    variants := make([]domain.Variant, len(updated))
+    for i, variant := range updated {
+        existing, _ := current.Variant(variant.SKU)
+        variant.Stock, variant.Reserved = existing.Stock, existing.Reserved
+        variants[i] = variant
+        kept[variant.SKU] = true
+    }
+    for _, variant := range current.Variants {
+        if !kept[variant.SKU] && variant.Stock != 0 {
+            return nil, fmt.Errorf("%w: variant %s still has stock; adjust it to zero before removing it", ErrValidation, variant.SKU)
+        }
+    }
+    return variants, nil
+}
+
+func totalVariantStock(variants []domain.Variant) int {
+    total := 0
+    for _, variant := range variants {
+        total += variant.Stock
+    }
+    return total
 }
@@ -0,0 +1,157 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "slices"
+    "time"
+
+    "github.com/google/uuid"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// PromotionService manages promotions and coupons. Orders apply them through
+// OrderService.
+type PromotionService struct {
+    promotions repository.PromotionRepository
+    tx         repository.TxManager
+}
+
+// NewPromotionService creates a new PromotionService.
+func NewPromotionService(promotionRepo repository.PromotionRepository, tx repository.TxManager) *PromotionService {
+    return &PromotionService{promotions: promotionRepo, tx: tx}
+}
+
+// CreatePromotion validates and stores a new promotion.
+func (s *PromotionService) CreatePromotion(ctx context.Context, input domain.Promotion) (domain.Promotion, error) {
+    now := time.Now().UTC()
+    promotion := normalizePromotion(input)
+    promotion.ID = uuid.NewString()
+    promotion.Redemptions = 0
+    promotion.CreatedAt = now
+    promotion.UpdatedAt = now
+    if err := promotion.Validate(); err != nil {
+        return domain.Promotion{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        if err := checkPromotionTargets(ctx, repos, promotion); err != nil {
+            return err
+        }
+        return savePromotion(ctx, repos.Promotions.Create, promotion)
+    })
+    if err != nil {
+        return domain.Promotion{}, err
+    }
+
+    return promotion, nil
+}
+
+// UpdatePromotion replaces a promotion's settings. Orders that already used
+// it keep the discount they were given.
+func (s *PromotionService) UpdatePromotion(ctx context.Context, id string, input domain.Promotion) (domain.Promotion, error) {
+    var promotion domain.Promotion
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        existing, err := repos.Promotions.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+
+        promotion = normalizePromotion(input)
+        promotion.ID = existing.ID
+        promotion.Redemptions = existing.Redemptions
+        promotion.CreatedAt = existing.CreatedAt
+        promotion.UpdatedAt = time.Now().UTC()
+        if err := promotion.Validate(); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        if err := checkPromotionTargets(ctx, repos, promotion); err != nil {
+            return err
+        }
+        return savePromotion(ctx, repos.Promotions.Update, promotion)
+    })
+    if err != nil {
+        return domain.Promotion{}, err
+    }
+
+    return promotion, nil
+}
+
+// DeletePromotion removes a promotion that has not been redeemed. Redeemed
+// promotions are kept for the orders that used them and can be deactivated
+// instead.
+func (s *PromotionService) DeletePromotion(ctx context.Context, id string) error {
+    return s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        promotion, err := repos.Promotions.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        if promotion.Redemptions > 0 {
+            return fmt.Errorf("%w: promotion has been redeemed; deactivate it instead", repository.ErrConflict)
+        }
+        return repos.Promotions.Delete(ctx, id)
+    })
+}
+
+// GetPromotion retrieves a promotion by ID.
+func (s *PromotionService) GetPromotion(ctx context.Context, id string) (domain.Promotion, error) {
+    return s.promotions.GetByID(ctx, id)
+}
+
+// ListPromotions returns every promotion, newest first.
+func (s *PromotionService) ListPromotions(ctx context.Context) ([]domain.Promotion, error) {
+    return s.promotions.List(ctx)
+}
+
+// normalizePromotion puts the code in the form it is looked up in, drops
+// duplicate targets and stores times in UTC.
+func normalizePromotion(p domain.Promotion) domain.Promotion {
+    p.Code = domain.NormalizeCouponCode(p.Code)
+    p.ProductIDs = slices.Compact(slices.Sorted(slices.Values(p.ProductIDs)))
+    p.CategoryIDs = slices.Compact(slices.Sorted(slices.Values(p.CategoryIDs)))
+    if p.StartsAt != nil {
+        startsAt := p.StartsAt.UTC()
+        p.StartsAt = &startsAt
+    }
+    if p.EndsAt != nil {
+        endsAt := p.EndsAt.UTC()
+        p.EndsAt = &endsAt
+    }
+    return p
+}
+
+// checkPromotionTargets ensures the products and categories a promotion
+// targets exist.
+func checkPromotionTargets(ctx context.Context, repos repository.Repositories, promotion domain.Promotion) error {
+    for _, id := range promotion.ProductIDs {
+        _, err := repos.Products.GetByID(ctx, id)
+        if errors.Is(err, repository.ErrNotFound) {
+            return fmt.Errorf("%w: product_ids: no such product %s", ErrValidation, id)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    for _, id := range promotion.CategoryIDs {
+        _, err := repos.Categories.GetByID(ctx, id)
+        if errors.Is(err, repository.ErrNotFound) {
+            return fmt.Errorf("%w: category_ids: no such category %s", ErrValidation, id)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// savePromotion stores promotion with save, reporting a taken coupon code.
+func savePromotion(ctx context.Context, save func(context.Context, domain.Promotion) error, promotion domain.Promotion) error {
+    err := save(ctx, promotion)
+    if errors.Is(err, repository.ErrConflict) {
+        return fmt.Errorf("%w: coupon code %s is already in use", repository.ErrConflict, promotion.Code)
+    }
+    return err
+}
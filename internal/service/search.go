@@ -0,0 +1,345 @@
+package service
+
+import (
+    "context"
+    "html"
+    "math"
+    "sort"
+    "strings"
+    "sync"
+    "unicode/utf8"
+
+    "cryptotrade/internal/domain"
+)
+
+// SearchIndex provides full-text search over the product catalog.
+// ProductService keeps it in step with product changes.
+type SearchIndex interface {
+    // Index adds a product, or replaces the indexed copy of it.
+    Index(ctx context.Context, product domain.Product) error
+    // Remove drops a product from the index; removing an unknown product is
+    // not an error.
+    Remove(ctx context.Context, productID string) error
+    // Search returns up to limit matches for text, most relevant first.
+    Search(ctx context.Context, text string, limit int) ([]SearchHit, error)
+}
+
+// SearchHit is a product matching a search.
+type SearchHit struct {
+    ProductID string
+    Score     float64
+    // Highlights maps a field name to an HTML-escaped excerpt of it with the
+    // matched words wrapped in <mark> tags. Fields without a match are omitted.
+    Highlights map[string]string
+}
+
+// BM25 term frequency saturation and length normalisation.
+const (
+    bm25K1 = 1.2
+    bm25B  = 0.75
+)
+
+// searchFields lists the indexed product fields with their ranking boosts;
+// a match in the name counts for more than one in the description.
+var searchFields = []struct {
+    name  string
+    boost float64
+    text  func(domain.Product) string
+}{
+    {"name", 2, func(p domain.Product) string { return p.Name }},
+    {"description", 1, func(p domain.Product) string { return p.Description }},
+}
+
+// Weights applied to query words that only match an indexed word by prefix
+// or within the typo budget, so exact matches rank first.
+const (
+    prefixMatchWeight = 0.7
+    typoMatchWeight   = 0.5
+    // minPrefixLength keeps one-letter query words from matching every
+    // word that starts with them.
+    minPrefixLength = 2
+)
+
+// snippetWords is the length, in words, of description excerpts.
+const snippetWords = 24
+
+// InvertedIndex is an in-process SearchIndex. It maps each stemmed word to
+// the products containing it and ranks matches with BM25 across the name
+// and description.
+type InvertedIndex struct {
+    mu   sync.RWMutex
+    docs map[string]*searchDoc
+    // postings maps a stem to the IDs of the products containing it.
+    postings map[string]map[string]struct{}
+    // words counts the indexed products containing each surface word; prefix
+    // and typo matching run against these words rather than stems.
+    words map[string]int
+    // fieldLength totals each field's length in words across products.
+    fieldLength []int
+}
+
+type searchDoc struct {
+    fields []searchField
+}
+
+type searchField struct {
+    text   string
+    tokens []searchToken
+    freq   map[string]int
+}
+
+// NewInvertedIndex creates an empty index.
+func NewInvertedIndex() *InvertedIndex {
+    return &InvertedIndex{
+        docs:        make(map[string]*searchDoc),
+        postings:    make(map[string]map[string]struct{}),
+        words:       make(map[string]int),
+        fieldLength: make([]int, len(searchFields)),
+    }
+}
+
+func (x *InvertedIndex) Index(_ context.Context, product domain.Product) error {
+    doc := &searchDoc{fields: make([]searchField, len(searchFields))}
+    for i, f := range searchFields {
+        text := f.text(product)
+        field := searchField{text: text, tokens: tokenize(text), freq: make(map[string]int)}
+        for _, token := range field.tokens {
+            field.freq[token.stem]++
+        }
+        doc.fields[i] = field
+    }
+
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    x.remove(product.ID)
+    x.docs[product.ID] = doc
+    for stem := range doc.stems() {
+        if x.postings[stem] == nil {
+            x.postings[stem] = make(map[string]struct{})
+        }
+        x.postings[stem][product.ID] = struct{}{}
+    }
+    for word := range doc.words() {
+        x.words[word]++
+    }
+    for i, field := range doc.fields {
+        x.fieldLength[i] += len(field.tokens)
+    }
+    return nil
+}
+
+func (x *InvertedIndex) Remove(_ context.Context, productID string) error {
+    x.mu.Lock()
+    defer x.mu.Unlock()
+    x.remove(productID)
+    return nil
+}
+
+func (x *InvertedIndex) remove(productID string) {
+    doc, ok := x.docs[productID]
+    if !ok {
+        return
+    }
+    delete(x.docs, productID)
+    for stem := range doc.stems() {
+        delete(x.postings[stem], productID)
+        if len(x.postings[stem]) == 0 {
+            delete(x.postings, stem)
+        }
+    }
+    for word := range doc.words() {
+        if x.words[word]--; x.words[word] == 0 {
+            delete(x.words, word)
+        }
+    }
+    for i, field := range doc.fields {
+        x.fieldLength[i] -= len(field.tokens)
+    }
+}
+
+// Search scores every product containing a word that matches the query.
+// Each query word contributes the score of its best match in the product:
+// the same stem, an indexed word it is a prefix of, or one within its typo
+// budget, in decreasing order of weight.
+func (x *InvertedIndex) Search(_ context.Context, text string, limit int) ([]SearchHit, error) {
+    x.mu.RLock()
+    defer x.mu.RUnlock()
+
+    var expansions []map[string]float64
+    for _, token := range tokenize(text) {
+        if matches := x.expand(token); len(matches) > 0 {
+            expansions = append(expansions, matches)
+        }
+    }
+
+    candidates := make(map[string]struct{})
+    for _, matches := range expansions {
+        for stem := range matches {
+            for id := range x.postings[stem] {
+                candidates[id] = struct{}{}
+            }
+        }
+    }
+
+    type scored struct {
+        id      string
+        score   float64
+        matched map[string]bool
+    }
+    ranked := make([]scored, 0, len(candidates))
+    for id := range candidates {
+        doc := x.docs[id]
+        hit := scored{id: id, matched: make(map[string]bool)}
+        for _, matches := range expansions {
+            best := 0.0
+            for stem, weight := range matches {
+                if s := weight * x.bm25(doc, stem); s > 0 {
+                    hit.matched[stem] = true
+                    best = math.Max(best, s)
+                }
+            }
+            hit.score += best
+        }
+        ranked = append(ranked, hit)
+    }
+    sort.Slice(ranked, func(i, j int) bool {
+        if ranked[i].score != ranked[j].score {
+            return ranked[i].score > ranked[j].score
+        }
+        return ranked[i].id < ranked[j].id
+    })
+    if len(ranked) > limit {
+        ranked = ranked[:limit]
+    }
+
+    hits := make([]SearchHit, len(ranked))
+    for i, r := range ranked {
+        hits[i] = SearchHit{ProductID: r.id, Score: r.score, Highlights: x.docs[r.id].highlight(r.matched)}
+    }
+    return hits, nil
+}
+
+// expand returns the indexed stems a query word matches, with their weights.
+func (x *InvertedIndex) expand(token searchToken) map[string]float64 {
+    matches := make(map[string]float64)
+    if _, ok := x.postings[token.stem]; ok {
+        matches[token.stem] = 1
+    }
+    length := utf8.RuneCountInString(token.word)
+    budget := typoBudget(token.word)
+    for word := range x.words {
+        weight := 0.0
+        switch {
+        case length >= minPrefixLength && strings.HasPrefix(word, token.word):
+            weight = prefixMatchWeight
+        case budget > 0 && abs(utf8.RuneCountInString(word)-length) <= budget && editDistance(word, token.word) <= budget:
+            weight = typoMatchWeight
+        default:
+            continue
+        }
+        if s := stem(word); weight > matches[s] {
+            matches[s] = weight
+        }
+    }
+    return matches
+}
+
+// bm25 scores one stem against a product, summing the boosted score of
+// each field it appears in.
+func (x *InvertedIndex) bm25(doc *searchDoc, stem string) float64 {
+    n := float64(len(x.docs))
+    df := float64(len(x.postings[stem]))
+    idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+    score := 0.0
+    for i, field := range doc.fields {
+        tf := float64(field.freq[stem])
+        if tf == 0 {
+            continue
+        }
+        avg := float64(x.fieldLength[i]) / n
+        norm := 1 - bm25B + bm25B*float64(len(field.tokens))/avg
+        score += searchFields[i].boost * idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+    }
+    return score
+}
+
+func (d *searchDoc) stems() map[string]struct{} {
+    stems := make(map[string]struct{})
+    for _, field := range d.fields {
+        for stem := range field.freq {
+            stems[stem] = struct{}{}
+        }
+    }
+    return stems
+}
+
+func (d *searchDoc) words() map[string]struct{} {
+    words := make(map[string]struct{})
+    for _, field := range d.fields {
+        for _, token := range field.tokens {
+            words[token.word] = struct{}{}
+        }
+    }
+    return words
+}
+
+// highlight marks the words whose stems matched in each field. Names are
+// returned whole; descriptions are cut to an excerpt around the first match.
+func (d *searchDoc) highlight(matched map[string]bool) map[string]string {
+    highlights := make(map[string]string)
+    for i, field := range d.fields {
+        first := -1
+        for j, token := range field.tokens {
+            if matched[token.stem] {
+                first = j
+                break
+            }
+        }
+        if first < 0 {
+            continue
+        }
+
+        from, to := 0, len(field.tokens)
+        if searchFields[i].name != "name" {
+            from = max(0, first-snippetWords/4)
+            to = min(len(field.tokens), from+snippetWords)
+        }
+        start, end := 0, len(field.text)
+        if from > 0 {
+            start = field.tokens[from].start
+        }
+        if to < len(field.tokens) {
+            end = field.tokens[to-1].end
+        }
+
+        var b strings.Builder
+        if start > 0 {
+            b.WriteString("…")
+        }
+        pos := start
+        for _, token := range field.tokens[from:to] {
+            if !matched[token.stem] {
+                continue
+            }
+            b.WriteString(html.EscapeString(field.text[pos:token.start]))
+            b.WriteString("<mark>")
+            b.WriteString(html.EscapeString(field.text[token.start:token.end]))
+            b.WriteString("</mark>")
+            pos = token.end
+        }
+        b.WriteString(html.EscapeString(field.text[pos:end]))
+        if end < len(field.text) {
+            b.WriteString("…")
+        }
+        highlights[searchFields[i].name] = b.String()
+    }
+    return highlights
+}
+
+func abs(n int) int {
+    if n < 0 {
+        return -n
+    }
+    return n
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+    "context"
+    "slices"
+    "testing"
+
+    "cryptotrade/internal/domain"
+)
+
+func TestStem(t *testing.T) {
+    // Examples from Porter's "An algorithm for suffix stripping" (1980),
+    // run through the whole algorithm.
+    tests := []struct{ word, want string }{
+        {"caresses", "caress"},
+        {"ponies", "poni"},
+        {"ties", "ti"},
+        {"caress", "caress"},
+        {"cats", "cat"},
+        {"feed", "feed"},
+        {"agreed", "agre"},
+        {"plastered", "plaster"},
+        {"bled", "bled"},
+        {"motoring", "motor"},
+        {"sing", "sing"},
+        {"conflated", "conflat"},
+        {"troubled", "troubl"},
+        {"sized", "size"},
+        {"hopping", "hop"},
+        {"tanned", "tan"},
+        {"falling", "fall"},
+        {"hissing", "hiss"},
+        {"fizzed", "fizz"},
+        {"failing", "fail"},
+        {"filing", "file"},
+        {"happy", "happi"},
+        {"sky", "sky"},
+        {"relational", "relat"},
+        {"conditional", "condit"},
+        {"rational", "ration"},
+        {"digitizer", "digit"},
+        {"vietnamization", "vietnam"},
+        {"predication", "predic"},
+        {"operator", "oper"},
+        {"feudalism", "feudal"},
+        {"decisiveness", "decis"},
+        {"hopefulness", "hope"},
+        {"callousness", "callous"},
+        {"formaliti", "formal"},
+        {"sensitiviti", "sensit"},
+        {"sensibiliti", "sensibl"},
+        {"triplicate", "triplic"},
+        {"formative", "form"},
+        {"formalize", "formal"},
+        {"electrical", "electr"},
+        {"hopeful", "hope"},
+        {"goodness", "good"},
+        {"revival", "reviv"},
+        {"allowance", "allow"},
+        {"inference", "infer"},
+        {"airliner", "airlin"},
+        {"adjustable", "adjust"},
+        {"defensible", "defens"},
+        {"replacement", "replac"},
+        {"adoption", "adopt"},
+        {"communism", "commun"},
+        {"effective", "effect"},
+        {"bowdlerize", "bowdler"},
+        {"probate", "probat"},
+        {"rate", "rate"},
+        {"cease", "ceas"},
+        {"controlling", "control"},
+        {"generalizations", "gener"},
+        {"running", "run"},
+        {"runs", "run"},
+        // Short, non-ASCII and alphanumeric words are left alone.
+        {"is", "is"},
+        {"cafés", "cafés"},
+        {"x100s", "x100s"},
+    }
+    for _, tt := range tests {
+        if got := stem(tt.word); got != tt.want {
+            t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+        }
+    }
+}
+
+func TestEditDistance(t *testing.T) {
+    tests := []struct {
+        a, b string
+        want int
+    }{
+        {"", "", 0},
+        {"wallet", "wallet", 0},
+        {"wallet", "", 6},
+        {"", "wallet", 6},
+        {"wallet", "wallat", 1},
+        {"wallet", "walet", 1},
+        {"wallet", "walllet", 1},
+        {"wallet", "walelt", 1},
+        {"kitten", "sitting", 3},
+        {"ca", "ac", 1},
+        // Optimal string alignment edits no substring twice, so this is 3
+        // rather than the unrestricted Damerau distance of 2.
+        {"ca", "abc", 3},
+        {"café", "cafe", 1},
+        {"naïve", "naive", 1},
+    }
+    for _, tt := range tests {
+        if got := editDistance(tt.a, tt.b); got != tt.want {
+            t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+        }
+        if got := editDistance(tt.b, tt.a); got != tt.want {
+            t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+        }
+    }
+}
+
+func TestInvertedIndexRanking(t *testing.T) {
+    tests := []struct {
+        name     string
+        products []domain.Product
+        query    string
+        want     []string
+    }{
+        {
+            name: "name outranks description",
+            products: []domain.Product{
+                {ID: "desc", Name: "Ledger device", Description: "A wallet for coins"},
+                {ID: "name", Name: "Hardware wallet", Description: "Secure storage"},
+            },
+            query: "wallet",
+            want:  []string{"name", "desc"},
+        },
+        {
+            name: "shorter field outranks longer",
+            products: []domain.Product{
+                {ID: "long", Name: "Wallet black leather slim"},
+                {ID: "short", Name: "Wallet"},
+            },
+            query: "wallet",
+            want:  []string{"short", "long"},
+        },
+        {
+            name: "more occurrences outrank fewer",
+            products: []domain.Product{
+                {ID: "once", Name: "Case", Description: "wallet steel cover"},
+                {ID: "twice", Name: "Case", Description: "wallet steel wallet"},
+            },
+            query: "wallet",
+            want:  []string{"twice", "once"},
+        },
+        {
+            name: "rare word outranks common",
+            products: []domain.Product{
+                {ID: "common1", Name: "Wallet"},
+                {ID: "common2", Name: "Wallet"},
+                {ID: "rare", Name: "Titanium"},
+            },
+            query: "wallet titanium",
+            want:  []string{"rare", "common1", "common2"},
+        },
+        {
+            name: "exact outranks prefix outranks typo",
+            products: []domain.Product{
+                {ID: "typo", Name: "Wallat"},
+                {ID: "prefix", Name: "Walletholder"},
+                {ID: "exact", Name: "Wallet"},
+            },
+            query: "wallet",
+            want:  []string{"exact", "prefix", "typo"},
+        },
+        {
+            name: "stems match",
+            products: []domain.Product{
+                {ID: "runner", Name: "Running shoes"},
+                {ID: "other", Name: "Hiking boots"},
+            },
+            query: "runs",
+            want:  []string{"runner"},
+        },
+        {
+            name: "every query word counts",
+            products: []domain.Product{
+                {ID: "one", Name: "Steel case"},
+                {ID: "both", Name: "Steel wallet"},
+            },
+            query: "steel wallet",
+            want:  []string{"both", "one"},
+        },
+        {
+            name: "stop words and short typos match nothing",
+            products: []domain.Product{
+                {ID: "p", Name: "The pen"},
+            },
+            query: "the pin",
+            want:  nil,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := context.Background()
+            index := NewInvertedIndex()
+            for _, p := range tt.products {
+                if err := index.Index(ctx, p); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            hits, err := index.Search(ctx, tt.query, 10)
+            if err != nil {
+                t.Fatal(err)
+            }
+            var got []string
+            for _, hit := range hits {
+                got = append(got, hit.ProductID)
+            }
+            if !slices.Equal(got, tt.want) {
+                t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestInvertedIndexUpdates(t *testing.T) {
+    ctx := context.Background()
+    index := NewInvertedIndex()
+    for _, p := range []domain.Product{
+        {ID: "a", Name: "Steel wallet"},
+        {ID: "b", Name: "Leather wallet"},
+        {ID: "c", Name: "Paper wallet"},
+    } {
+        if err := index.Index(ctx, p); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    search := func(query string, limit int) []string {
+        t.Helper()
+        hits, err := index.Search(ctx, query, limit)
+        if err != nil {
+            t.Fatal(err)
+        }
+        var ids []string
+        for _, hit := range hits {
+            ids = append(ids, hit.ProductID)
+        }
+        return ids
+    }
+
+    // Equal scores fall back to ID order, and limit cuts the tail.
+    if got := search("wallet", 2); !slices.Equal(got, []string{"a", "b"}) {
+        t.Errorf("limited search = %v, want [a b]", got)
+    }
+
+    if err := index.Remove(ctx, "a"); err != nil {
+        t.Fatal(err)
+    }
+    if got := search("steel", 10); len(got) != 0 {
+        t.Errorf("removed product still found: %v", got)
+    }
+
+    if err := index.Index(ctx, domain.Product{ID: "b", Name: "Steel card"}); err != nil {
+        t.Fatal(err)
+    }
+    if got := search("steel", 10); !slices.Equal(got, []string{"b"}) {
+        t.Errorf("reindexed product: search = %v, want [b]", got)
+    }
+    if got := search("leather", 10); len(got) != 0 {
+        t.Errorf("replaced text still found: %v", got)
+    }
+}
+
+func TestInvertedIndexHighlights(t *testing.T) {
+    ctx := context.Background()
+    index := NewInvertedIndex()
+    product := domain.Product{ID: "p", Name: "Hardware Wallet <v2>", Description: "Keeps wallets offline."}
+    if err := index.Index(ctx, product); err != nil {
+        t.Fatal(err)
+    }
+    hits, err := index.Search(ctx, "wallet", 10)
+    if err != nil || len(hits) != 1 {
+        t.Fatalf("Search = %v, %v; want one hit", hits, err)
+    }
+    want := map[string]string{
+        "name":        "Hardware <mark>Wallet</mark> &lt;v2&gt;",
+        "description": "Keeps <mark>wallets</mark> offline.",
+    }
+    for field, text := range want {
+        if got := hits[0].Highlights[field]; got != text {
+            t.Errorf("highlight of %s = %q, want %q", field, got, text)
+        }
+    }
+}
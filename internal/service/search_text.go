@@ -0,0 +1,291 @@
+package service
+
+import (
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// searchToken is a word found in indexed or queried text. Start and End are
+// byte offsets into the original text so that matches can be highlighted.
+type searchToken struct {
+    word  string
+    stem  string
+    start int
+    end   int
+}
+
+// stopWords are too common to help ranking and are neither indexed nor
+// searched for.
+var stopWords = map[string]bool{
+    "a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+    "for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+    "the": true, "to": true, "with": true,
+}
+
+// tokenize splits text into lower-cased words of letters and digits, skipping
+// stop words, and stems each one.
+func tokenize(text string) []searchToken {
+    var tokens []searchToken
+    start := -1
+    flush := func(end int) {
+        if start < 0 {
+            return
+        }
+        word := strings.ToLower(text[start:end])
+        if !stopWords[word] {
+            tokens = append(tokens, searchToken{word: word, stem: stem(word), start: start, end: end})
+        }
+        start = -1
+    }
+    for i, r := range text {
+        if unicode.IsLetter(r) || unicode.IsDigit(r) {
+            if start < 0 {
+                start = i
+            }
+            continue
+        }
+        flush(i)
+    }
+    flush(len(text))
+    return tokens
+}
+
+// editDistance returns the optimal string alignment distance between a and
+// b: the number of single-rune insertions, deletions, substitutions and
+// adjacent transpositions that turn one into the other.
+func editDistance(a, b string) int {
+    ra, rb := []rune(a), []rune(b)
+    prev2 := make([]int, len(rb)+1)
+    prev := make([]int, len(rb)+1)
+    curr := make([]int, len(rb)+1)
+    for j := range prev {
+        prev[j] = j
+    }
+    for i := 1; i <= len(ra); i++ {
+        curr[0] = i
+        for j := 1; j <= len(rb); j++ {
+            cost := 1
+            if ra[i-1] == rb[j-1] {
+                cost = 0
+            }
+            curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+            if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+                curr[j] = min(curr[j], prev2[j-2]+1)
+            }
+        }
+        prev2, prev, curr = prev, curr, prev2
+    }
+    return prev[len(rb)]
+}
+
+// typoBudget is the number of edits tolerated when matching word: none for
+// short words, where a single edit usually yields a different word.
+func typoBudget(word string) int {
+    switch n := utf8.RuneCountInString(word); {
+    case n < 4:
+        return 0
+    case n < 8:
+        return 1
+    default:
+        return 2
+    }
+}
+
+// stem reduces an English word to its stem with the Porter algorithm, so
+// that "running", "runs" and "run" index alike. Words that are not plain
+// lower-case ASCII, such as model numbers, are returned unchanged.
+func stem(word string) string {
+    if len(word) <= 2 {
+        return word
+    }
+    for i := 0; i < len(word); i++ {
+        if word[i] < 'a' || word[i] > 'z' {
+            return word
+        }
+    }
+    w := []byte(word)
+    w = stemStep1a(w)
+    w = stemStep1b(w)
+    w = stemStep1c(w)
+    w = replaceSuffix(w, step2Suffixes, func(s []byte) bool { return measure(s) > 0 })
+    w = replaceSuffix(w, step3Suffixes, func(s []byte) bool { return measure(s) > 0 })
+    w = stemStep4(w)
+    w = stemStep5(w)
+    return string(w)
+}
+
+var step2Suffixes = [][2]string{
+    {"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"}, {"izer", "ize"},
+    {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"},
+    {"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"},
+    {"fulness", "ful"}, {"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+    {"logi", "log"},
+}
+
+var step3Suffixes = [][2]string{
+    {"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"}, {"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+var step4Suffixes = []string{
+    "al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+    "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+func isConsonant(w []byte, i int) bool {
+    switch w[i] {
+    case 'a', 'e', 'i', 'o', 'u':
+        return false
+    case 'y':
+        return i == 0 || !isConsonant(w, i-1)
+    default:
+        return true
+    }
+}
+
+// measure counts the vowel-consonant sequences in w, the m of the Porter paper.
+func measure(w []byte) int {
+    n, i := 0, 0
+    for i < len(w) && isConsonant(w, i) {
+        i++
+    }
+    for i < len(w) {
+        for i < len(w) && !isConsonant(w, i) {
+            i++
+        }
+        if i == len(w) {
+            break
+        }
+        for i < len(w) && isConsonant(w, i) {
+            i++
+        }
+        n++
+    }
+    return n
+}
+
+func hasVowel(w []byte) bool {
+    for i := range w {
+        if !isConsonant(w, i) {
+            return true
+        }
+    }
+    return false
+}
+
+func endsDoubleConsonant(w []byte) bool {
+    n := len(w)
+    return n >= 2 && w[n-1] == w[n-2] && isConsonant(w, n-1)
+}
+
+// endsCVC reports whether w ends consonant-vowel-consonant where the final
+// consonant is not w, x or y, as in "hop" but not "snow".
+func endsCVC(w []byte) bool {
+    n := len(w)
+    if n < 3 || !isConsonant(w, n-3) || isConsonant(w, n-2) || !isConsonant(w, n-1) {
+        return false
+    }
+    return w[n-1] != 'w' && w[n-1] != 'x' && w[n-1] != 'y'
+}
+
+func hasSuffix(w []byte, suffix string) bool {
+    return len(w) >= len(suffix) && string(w[len(w)-len(suffix):]) == suffix
+}
+
+// replaceSuffix swaps the longest matching suffix for its replacement when
+// the remaining stem satisfies cond.
+func replaceSuffix(w []byte, rules [][2]string, cond func([]byte) bool) []byte {
+    best := -1
+    for i, rule := range rules {
+        if hasSuffix(w, rule[0]) && (best < 0 || len(rule[0]) > len(rules[best][0])) {
+            best = i
+        }
+    }
+    if best < 0 {
+        return w
+    }
+    s := w[:len(w)-len(rules[best][0])]
+    if !cond(s) {
+        return w
+    }
+    return append(s[:len(s):len(s)], rules[best][1]...)
+}
+
+func stemStep1a(w []byte) []byte {
+    switch {
+    case hasSuffix(w, "sses"), hasSuffix(w, "ies"):
+        return w[:len(w)-2]
+    case hasSuffix(w, "ss"):
+        return w
+    case hasSuffix(w, "s"):
+        return w[:len(w)-1]
+    }
+    return w
+}
+
+func stemStep1b(w []byte) []byte {
+    if hasSuffix(w, "eed") {
+        if measure(w[:len(w)-3]) > 0 {
+            return w[:len(w)-1]
+        }
+        return w
+    }
+    var s []byte
+    switch {
+    case hasSuffix(w, "ed") && hasVowel(w[:len(w)-2]):
+        s = w[:len(w)-2]
+    case hasSuffix(w, "ing") && hasVowel(w[:len(w)-3]):
+        s = w[:len(w)-3]
+    default:
+        return w
+    }
+    switch {
+    case hasSuffix(s, "at"), hasSuffix(s, "bl"), hasSuffix(s, "iz"):
+        return append(s[:len(s):len(s)], 'e')
+    case endsDoubleConsonant(s) && !hasSuffix(s, "l") && !hasSuffix(s, "s") && !hasSuffix(s, "z"):
+        return s[:len(s)-1]
+    case measure(s) == 1 && endsCVC(s):
+        return append(s[:len(s):len(s)], 'e')
+    }
+    return s
+}
+
+func stemStep1c(w []byte) []byte {
+    if hasSuffix(w, "y") && hasVowel(w[:len(w)-1]) {
+        return append(w[:len(w)-1:len(w)-1], 'i')
+    }
+    return w
+}
+
+func stemStep4(w []byte) []byte {
+    best := ""
+    for _, suffix := range step4Suffixes {
+        if hasSuffix(w, suffix) && len(suffix) > len(best) {
+            best = suffix
+        }
+    }
+    if best == "" {
+        return w
+    }
+    s := w[:len(w)-len(best)]
+    if measure(s) <= 1 {
+        return w
+    }
+    if best == "ion" && !hasSuffix(s, "s") && !hasSuffix(s, "t") {
+        return w
+    }
+    return s
+}
+
+func stemStep5(w []byte) []byte {
+    if hasSuffix(w, "e") {
+        s := w[:len(w)-1]
+        if m := measure(s); m > 1 || (m == 1 && !endsCVC(s)) {
+            w = s
+        }
+    }
+    if measure(w) > 1 && endsDoubleConsonant(w) && hasSuffix(w, "l") {
+        w = w[:len(w)-1]
+    }
+    return w
+}
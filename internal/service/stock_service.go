@@ -0,0 +1,288 @@
+package service
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/google/uuid"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// StockService records explicit stock adjustments and answers questions
+// from the stock ledger.
+type StockService struct {
+    products  repository.ProductRepository
+    movements repository.StockMovementRepository
+    tx        repository.TxManager
+}
+
+// NewStockService creates a new StockService.
+func NewStockService(productRepo repository.ProductRepository, movementRepo repository.StockMovementRepository, tx repository.TxManager) *StockService {
+    return &StockService{products: productRepo, movements: movementRepo, tx: tx}
+}
+
+// StockAdjustmentInput describes a manual change to physical stock. Exactly
+// one of Delta and Stock is set.
+type StockAdjustmentInput struct {
+    ProductID string
+    // SKU selects the variant to adjust and is required for products sold
+    // as variants.
+    SKU string
+    // WarehouseID selects the warehouse to adjust; when empty the change
+    // applies to the unassigned stock of the product or variant.
+    WarehouseID string
+    // Delta is a signed change in units.
+    Delta *int
+    // Stock is the new quantity on hand at the warehouse or, without a
+    // warehouse, the new total of the variant or product, as after a stock
+    // count.
+    Stock *int
+    // Reason is adjustment, restock or return; empty means adjustment.
+    Reason      domain.StockMovementReason
+    ReferenceID string
+    Actor       string
+}
+
+// StockLedgerEntry is a stock movement with the stock after it. The two legs
+// of a transfer net to zero in Balance but each moves LocationBalance.
+type StockLedgerEntry struct {
+    domain.StockMovement
+    // Balance is the product's total stock after the movement.
+    Balance int `json:"balance"`
+    // LocationBalance is the stock of the movement's variant on hand at its
+    // warehouse, or unassigned, after the movement.
+    LocationBalance int `json:"location_balance"`
+}
+
+// StockBalance compares the stock recorded at one location with the total
+// derived from the ledger.
+type StockBalance struct {
+    // WarehouseID is empty for unassigned stock and for the product total.
+    WarehouseID string `json:"warehouse_id,omitempty"`
+    Recorded    int    `json:"recorded"`
+    Ledger      int    `json:"ledger"`
+    Difference  int    `json:"difference"`
+}
+
+// StockReconciliation checks a product's stock against its ledger.
+type StockReconciliation struct {
+    ProductID string `json:"product_id"`
+    // Consistent is true when every location matches the ledger.
+    Consistent bool           `json:"consistent"`
+    Total      StockBalance   `json:"total"`
+    Locations  []StockBalance `json:"locations"`
+}
+
+// AdjustStock applies a manual stock change and records it in the ledger.
+// Units held for open orders cannot be adjusted away.
+func (s *StockService) AdjustStock(ctx context.Context, input StockAdjustmentInput) (StockLedgerEntry, error) {
+    if input.Reason == "" {
+        input.Reason = domain.StockMovementAdjustment
+    }
+    switch input.Reason {
+    case domain.StockMovementAdjustment, domain.StockMovementRestock, domain.StockMovementReturn:
+    default:
+        if input.Reason.Valid() {
+            return StockLedgerEntry{}, fmt.Errorf("%w: %s movements are recorded automatically", ErrValidation, input.Reason)
+        }
+        return StockLedgerEntry{}, fmt.Errorf("%w: unknown stock movement reason %q", ErrValidation, input.Reason)
+    }
+    if input.Actor == "" {
+        return StockLedgerEntry{}, fmt.Errorf("%w: actor is required", ErrValidation)
+    }
+    if (input.Delta == nil) == (input.Stock == nil) {
+        return StockLedgerEntry{}, fmt.Errorf("%w: exactly one of delta and stock is required", ErrValidation)
+    }
+    if input.Stock != nil && *input.Stock < 0 {
+        return StockLedgerEntry{}, fmt.Errorf("%w: stock cannot be negative", ErrValidation)
+    }
+
+    var entry StockLedgerEntry
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        product, err := repos.Products.GetByID(ctx, input.ProductID)
+        if err != nil {
+            return err
+        }
+        if err := product.CheckSKU(input.SKU); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+
+        var level domain.StockLevel
+        current := product.Stock
+        if variant, ok := product.Variant(input.SKU); ok {
+            current = variant.Stock
+        }
+        if input.WarehouseID != "" {
+            if _, err := repos.Warehouses.GetByID(ctx, input.WarehouseID); err != nil {
+                return err
+            }
+            if level, err = loadLevel(ctx, repos, input.WarehouseID, product.ID, input.SKU); err != nil {
+                return err
+            }
+            current = level.OnHand
+        }
+
+        var delta int
+        if input.Delta != nil {
+            delta = *input.Delta
+        } else {
+            delta = *input.Stock - current
+        }
+        if delta == 0 {
+            return fmt.Errorf("%w: adjustment does not change stock", ErrValidation)
+        }
+        if delta < 0 && input.Reason != domain.StockMovementAdjustment {
+            return fmt.Errorf("%w: a %s must add stock", ErrValidation, input.Reason)
+        }
+
+        available, err := sourceAvailable(ctx, repos, product, input.SKU, input.WarehouseID)
+        if err != nil {
+            return err
+        }
+        if input.SKU != "" {
+            available = min(available, product.AvailableOf(input.SKU))
+        }
+        if available+delta < 0 {
+            return fmt.Errorf("%w: only %d units of %s can be removed; the rest are reserved for open orders", ErrValidation, available, describeItem(product.ID, input.SKU))
+        }
+
+        if err := product.AdjustStock(input.SKU, delta, 0); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        if err := product.Validate(); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        locationBalance := level.OnHand + delta
+        if input.WarehouseID != "" {
+            level.OnHand = locationBalance
+            if err := repos.StockLevels.Save(ctx, level); err != nil {
+                return err
+            }
+        } else {
+            levels, err := repos.StockLevels.ListByProduct(ctx, product.ID)
+            if err != nil {
+                return err
+            }
+            locationBalance = unassignedStockOf(product, input.SKU, levels).OnHand
+        }
+        if err := repos.Products.Update(ctx, product); err != nil {
+            return err
+        }
+
+        movement := domain.StockMovement{
+            ID:          uuid.NewString(),
+            ProductID:   product.ID,
+            SKU:         input.SKU,
+            WarehouseID: input.WarehouseID,
+            Quantity:    delta,
+            Reason:      input.Reason,
+            ReferenceID: input.ReferenceID,
+            Actor:       input.Actor,
+            CreatedAt:   time.Now().UTC(),
+        }
+        if err := recordMovement(ctx, repos, movement); err != nil {
+            return err
+        }
+        entry = StockLedgerEntry{StockMovement: movement, Balance: product.Stock, LocationBalance: locationBalance}
+        return nil
+    })
+    if err != nil {
+        return StockLedgerEntry{}, err
+    }
+
+    return entry, nil
+}
+
+// ListStockMovements returns a product's ledger, oldest first, with the
+// running stock balance after each movement.
+func (s *StockService) ListStockMovements(ctx context.Context, productID string) ([]StockLedgerEntry, error) {
+    if _, err := s.products.GetByID(ctx, productID); err != nil {
+        return nil, err
+    }
+    movements, err := s.movements.ListByProduct(ctx, productID)
+    if err != nil {
+        return nil, err
+    }
+
+    type location struct{ warehouseID, sku string }
+    entries := make([]StockLedgerEntry, 0, len(movements))
+    balance := 0
+    locations := make(map[location]int)
+    for _, movement := range movements {
+        balance += movement.Quantity
+        at := location{movement.WarehouseID, movement.SKU}
+        locations[at] += movement.Quantity
+        entries = append(entries, StockLedgerEntry{
+            StockMovement:   movement,
+            Balance:         balance,
+            LocationBalance: locations[at],
+        })
+    }
+    return entries, nil
+}
+
+// ReconcileStock compares a product's stock, in total and at each location,
+// with the totals derived from its ledger.
+func (s *StockService) ReconcileStock(ctx context.Context, productID string) (StockReconciliation, error) {
+    var reconciliation StockReconciliation
+    // Reading inside a transaction gives a snapshot no stock change can split.
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        product, err := repos.Products.GetByID(ctx, productID)
+        if err != nil {
+            return err
+        }
+        levels, err := repos.StockLevels.ListByProduct(ctx, productID)
+        if err != nil {
+            return err
+        }
+        movements, err := repos.StockMovements.ListByProduct(ctx, productID)
+        if err != nil {
+            return err
+        }
+
+        recorded := map[string]int{"": unassignedStock(product, levels).OnHand}
+        for _, level := range levels {
+            recorded[level.WarehouseID] += level.OnHand
+        }
+        ledger := make(map[string]int)
+        total := 0
+        for _, movement := range movements {
+            ledger[movement.WarehouseID] += movement.Quantity
+            total += movement.Quantity
+            if _, ok := recorded[movement.WarehouseID]; !ok {
+                recorded[movement.WarehouseID] = 0
+            }
+        }
+
+        reconciliation = StockReconciliation{
+            ProductID: product.ID,
+            Total:     newStockBalance("", product.Stock, total),
+            Locations: make([]StockBalance, 0, len(recorded)),
+        }
+        reconciliation.Consistent = reconciliation.Total.Difference == 0
+        for warehouseID, onHand := range recorded {
+            balance := newStockBalance(warehouseID, onHand, ledger[warehouseID])
+            if balance.Difference != 0 {
+                reconciliation.Consistent = false
+            }
+            reconciliation.Locations = append(reconciliation.Locations, balance)
+        }
+        sort.Slice(reconciliation.Locations, func(i, j int) bool {
+            return reconciliation.Locations[i].WarehouseID < reconciliation.Locations[j].WarehouseID
+        })
+        return nil
+    })
+    if err != nil {
+        return StockReconciliation{}, err
+    }
+
+    return reconciliation, nil
+}
+
+func newStockBalance(warehouseID string, recorded, ledger int) StockBalance {
+    return StockBalance{WarehouseID: warehouseID, Recorded: recorded, Ledger: ledger, Difference: recorded - ledger}
+}
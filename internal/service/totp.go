@@ -0,0 +1,192 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "slices"
+    "time"
+
+    "cryptotrade/internal/auth"
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+const (
+    // totpIssuer labels the account in authenticator apps.
+    totpIssuer = "Cryptotrade"
+    // recoveryCodeCount is how many recovery codes a user is given at a time.
+    recoveryCodeCount = 10
+)
+
+// TOTPEnrollment is a pending TOTP enrollment. The secret is shown for users
+// who type it in; most scan ProvisioningURI as a QR code instead.
+type TOTPEnrollment struct {
+    Secret          string `json:"secret"`
+    ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// EnrollTOTP starts a TOTP enrollment with a fresh secret. It takes effect
+// once ConfirmTOTP accepts a first code; enrolling again before that
+// replaces the secret.
+func (s *UserService) EnrollTOTP(ctx context.Context, id string) (TOTPEnrollment, error) {
+    var enrollment TOTPEnrollment
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        user, err := repos.Users.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        if user.TOTPEnabled {
+            return fmt.Errorf("%w: two-factor authentication is already enabled", repository.ErrConflict)
+        }
+
+        secret, err := auth.NewTOTPSecret()
+        if err != nil {
+            return err
+        }
+        user.TOTPSecret = secret
+        if err := repos.Users.Update(ctx, user); err != nil {
+            return err
+        }
+        enrollment = TOTPEnrollment{
+            Secret:          secret,
+            ProvisioningURI: auth.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+        }
+        return nil
+    })
+    if err != nil {
+        return TOTPEnrollment{}, err
+    }
+    return enrollment, nil
+}
+
+// ConfirmTOTP enables TOTP once the user proves their authenticator works
+// by entering a code from it, and returns the user's recovery codes. They
+// are shown this once.
+func (s *UserService) ConfirmTOTP(ctx context.Context, id, code string) ([]string, error) {
+    var codes []string
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        user, err := repos.Users.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        if user.TOTPEnabled {
+            return fmt.Errorf("%w: two-factor authentication is already enabled", repository.ErrConflict)
+        }
+        if user.TOTPSecret == "" {
+            return fmt.Errorf("%w: no enrollment is pending", repository.ErrConflict)
+        }
+        if code == "" {
+            return fmt.Errorf("%w: code is required", ErrValidation)
+        }
+        if err := checkSecondFactor(&user, code, "", time.Now()); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+
+        user.TOTPEnabled = true
+        if codes, err = resetRecoveryCodes(&user); err != nil {
+            return err
+        }
+        return repos.Users.Update(ctx, user)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return codes, nil
+}
+
+// DisableTOTP turns TOTP off after checking a current code or a recovery code.
+func (s *UserService) DisableTOTP(ctx context.Context, id, code, recoveryCode string) error {
+    return s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        user, err := repos.Users.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        if !user.TOTPEnabled {
+            return fmt.Errorf("%w: two-factor authentication is not enabled", repository.ErrConflict)
+        }
+        if err := checkSecondFactor(&user, code, recoveryCode, time.Now()); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+
+        user.TOTPEnabled = false
+        user.TOTPSecret = ""
+        user.TOTPLastStep = 0
+        user.RecoveryCodeHashes = nil
+        return repos.Users.Update(ctx, user)
+    })
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a current code, for users who have used up or lost them.
+func (s *UserService) RegenerateRecoveryCodes(ctx context.Context, id, code string) ([]string, error) {
+    var codes []string
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        user, err := repos.Users.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        if !user.TOTPEnabled {
+            return fmt.Errorf("%w: two-factor authentication is not enabled", repository.ErrConflict)
+        }
+        if code == "" {
+            return fmt.Errorf("%w: code is required", ErrValidation)
+        }
+        if err := checkSecondFactor(&user, code, "", time.Now()); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+
+        if codes, err = resetRecoveryCodes(&user); err != nil {
+            return err
+        }
+        return repos.Users.Update(ctx, user)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return codes, nil
+}
+
+// checkSecondFactor verifies a TOTP code or an unused recovery code for user
+// and records its use on user, which the caller must store in the same
+// transaction so that neither can be used twice.
+func checkSecondFactor(user *domain.User, code, recoveryCode string, now time.Time) error {
+    switch {
+    case code != "" && recoveryCode != "":
+        return errors.New("give either a code or a recovery code, not both")
+    case code != "":
+        step, ok, err := auth.MatchTOTP(user.TOTPSecret, code, now)
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return errors.New("invalid code")
+        }
+        if step <= user.TOTPLastStep {
+            return errors.New("code has already been used; wait for the next one")
+        }
+        user.TOTPLastStep = step
+    case recoveryCode != "":
+        i := slices.Index(user.RecoveryCodeHashes, auth.HashRecoveryCode(recoveryCode))
+        if i < 0 {
+            return errors.New("invalid recovery code")
+        }
+        user.RecoveryCodeHashes = slices.Delete(user.RecoveryCodeHashes, i, i+1)
+    default:
+        return errors.New("a code or a recovery code is required")
+    }
+    return nil
+}
+
+// resetRecoveryCodes gives user a fresh set of recovery codes and returns them.
+func resetRecoveryCodes(user *domain.User) ([]string, error) {
+    codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+    if err != nil {
+        return nil, err
+    }
+    user.RecoveryCodeHashes = make([]string, len(codes))
+    for i, code := range codes {
+        user.RecoveryCodeHashes[i] = auth.HashRecoveryCode(code)
+    }
+    return codes, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "cryptotrade/internal/auth"
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository/memory"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func totpCodeAt(t *testing.T, at time.Time) string {
+    t.Helper()
+    code, err := auth.TOTPCode(testTOTPSecret, auth.TOTPStep(at))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return code
+}
+
+func TestCheckSecondFactorReplayGuard(t *testing.T) {
+    now := time.Unix(1_700_000_000, 0)
+    step := auth.TOTPStep(now)
+    user := domain.User{
+        TOTPEnabled:        true,
+        TOTPSecret:         testTOTPSecret,
+        RecoveryCodeHashes: []string{auth.HashRecoveryCode("AAAA-BBBB-CCCC-DDDD")},
+    }
+
+    steps := []struct {
+        name         string
+        code         string
+        recoveryCode string
+        wantErr      bool
+        wantLastStep int64
+    }{
+        {"previous step", totpCodeAt(t, now.Add(-30*time.Second)), "", false, step - 1},
+        {"same code again", totpCodeAt(t, now.Add(-30*time.Second)), "", true, step - 1},
+        {"current step", totpCodeAt(t, now), "", false, step},
+        {"same code again", totpCodeAt(t, now), "", true, step},
+        {"older step after a newer one", totpCodeAt(t, now.Add(-30*time.Second)), "", true, step},
+        {"wrong code", "000000", "", true, step},
+        {"code and recovery code", totpCodeAt(t, now.Add(30*time.Second)), "AAAA-BBBB-CCCC-DDDD", true, step},
+        {"neither", "", "", true, step},
+        {"recovery code", "", "aaaa-bbbb-cccc-dddd", false, step},
+        {"recovery code again", "", "AAAA-BBBB-CCCC-DDDD", true, step},
+        {"next step", totpCodeAt(t, now.Add(30*time.Second)), "", false, step + 1},
+    }
+    for _, tt := range steps {
+        err := checkSecondFactor(&user, tt.code, tt.recoveryCode, now)
+        if (err != nil) != tt.wantErr {
+            t.Fatalf("%s: checkSecondFactor error = %v, want error %t", tt.name, err, tt.wantErr)
+        }
+        if user.TOTPLastStep != tt.wantLastStep {
+            t.Fatalf("%s: TOTPLastStep = %d, want %d", tt.name, user.TOTPLastStep, tt.wantLastStep)
+        }
+    }
+    if len(user.RecoveryCodeHashes) != 0 {
+        t.Errorf("used recovery code was kept: %v", user.RecoveryCodeHashes)
+    }
+}
+
+func TestCompleteLoginLocksSecondFactor(t *testing.T) {
+    ctx := context.Background()
+    store := memory.NewStore()
+    repos := store.Repositories()
+    hash, err := auth.HashPassword("password123")
+    if err != nil {
+        t.Fatal(err)
+    }
+    user := domain.User{
+        ID:           "u1",
+        Name:         "Ada",
+        Email:        "ada@example.com",
+        Role:         domain.RoleCustomer,
+        PasswordHash: hash,
+        TOTPEnabled:  true,
+        TOTPSecret:   testTOTPSecret,
+        CreatedAt:    time.Now(),
+    }
+    if err := repos.Users.Create(ctx, user); err != nil {
+        t.Fatal(err)
+    }
+    svc := NewAuthService(repos.Users, repos.RefreshTokens, store, auth.NewSigner([]byte("test key")), time.Minute, time.Hour)
+
+    challenge := func() string {
+        t.Helper()
+        result, err := svc.Login(ctx, user.Email, "password123")
+        if err != nil || result.Challenge == nil {
+            t.Fatalf("Login = %+v, %v; want a challenge", result, err)
+        }
+        return result.Challenge.MFAToken
+    }
+
+    // Wrong codes count against the user whichever challenge they are
+    // entered against.
+    for i := 0; i < maxMFAFailures; i++ {
+        if _, err := svc.CompleteLogin(ctx, challenge(), "000000", ""); !errors.Is(err, ErrUnauthenticated) {
+            t.Fatalf("wrong code %d: got %v, want ErrUnauthenticated", i+1, err)
+        }
+    }
+    _, err = svc.CompleteLogin(ctx, challenge(), totpCodeAt(t, time.Now()), "")
+    if !errors.Is(err, ErrUnauthenticated) || !strings.Contains(err.Error(), "too many wrong codes") {
+        t.Fatalf("right code while locked: got %v, want a lockout", err)
+    }
+
+    stored, err := repos.Users.GetByID(ctx, user.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if stored.MFALockedUntil == nil {
+        t.Fatal("lockout was not stored on the user")
+    }
+    past := time.Now().Add(-time.Second)
+    stored.MFALockedUntil = &past
+    stored.MFAFailures = maxMFAFailures - 1
+    if err := repos.Users.Update(ctx, stored); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := svc.CompleteLogin(ctx, challenge(), totpCodeAt(t, time.Now()), ""); err != nil {
+        t.Fatalf("right code after the lockout: %v", err)
+    }
+    stored, err = repos.Users.GetByID(ctx, user.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if stored.MFAFailures != 0 || stored.MFALockedUntil != nil {
+        t.Errorf("a successful login left failures %d, lockout %v", stored.MFAFailures, stored.MFALockedUntil)
+    }
+}
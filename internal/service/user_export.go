@@ -0,0 +1,89 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "time"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// UserExport is everything stored about a user, for data portability
+// requests. Credentials are left out.
+type UserExport struct {
+    ExportedAt time.Time        `json:"exported_at"`
+    Profile    domain.User      `json:"profile"`
+    Addresses  []domain.Address `json:"addresses"`
+    Orders     []domain.Order   `json:"orders"`
+    Invoices   []domain.Invoice `json:"invoices"`
+    // Cart is nil when the user has no saved cart.
+    Cart *domain.Cart `json:"cart"`
+}
+
+// ExportUser collects everything stored about a user. Users may export
+// their own data; exporting anyone else's needs the users:read permission.
+// The export is read in one transaction so that it is consistent.
+func (s *UserService) ExportUser(ctx context.Context, id string) (UserExport, error) {
+    if err := authorizeOwner(ctx, "account", id, domain.PermissionUsersRead); err != nil {
+        return UserExport{}, err
+    }
+
+    export := UserExport{
+        ExportedAt: time.Now().UTC(),
+        Orders:     []domain.Order{},
+        Invoices:   []domain.Invoice{},
+    }
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        export.Profile, err = repos.Users.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+
+        if export.Addresses, err = repos.Addresses.ListByUser(ctx, id); err != nil {
+            return err
+        }
+
+        query := repository.OrderQuery{
+            ListOptions: repository.ListOptions{Limit: repository.MaxLimit},
+            UserID:      id,
+        }
+        for {
+            page, err := repos.Orders.List(ctx, query)
+            if err != nil {
+                return err
+            }
+            export.Orders = append(export.Orders, page.Items...)
+            if page.NextCursor == "" {
+                break
+            }
+            query.Cursor = page.NextCursor
+        }
+
+        for _, order := range export.Orders {
+            invoice, err := repos.Invoices.GetByOrderID(ctx, order.ID)
+            if errors.Is(err, repository.ErrNotFound) {
+                continue
+            }
+            if err != nil {
+                return err
+            }
+            export.Invoices = append(export.Invoices, invoice)
+        }
+
+        cart, err := repos.Carts.Get(ctx, id)
+        if errors.Is(err, repository.ErrNotFound) {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        export.Cart = &cart
+        return nil
+    })
+    if err != nil {
+        return UserExport{}, err
+    }
+    return export, nil
+}
@@ -2,35 +2,64 @@ package service
 
 import (
     "context"
+    "errors"
     "fmt"
+    "log"
+    "time"
+    "unicode/utf8"
 
     "github.com/google/uuid"
 
+    "cryptotrade/internal/auth"
     "cryptotrade/internal/domain"
+    "cryptotrade/internal/mail"
     "cryptotrade/internal/repository"
 )
 
 // UserService contains the business logic for users.
 type UserService struct {
     repo repository.UserRepository
+    tx   repository.TxManager
+    // signer signs the verification and password reset tokens that mailer
+    // delivers as links into the storefront at publicURL.
+    signer    *auth.Signer
+    mailer    mail.Mailer
+    publicURL string
+    // adminEmail names the account that is made an admin once its email is
+    // verified, so that a new deployment has someone who can hand out the
+    // other roles.
+    adminEmail string
 }
 
-// NewUserService creates a new UserService.
-func NewUserService(repo repository.UserRepository) *UserService {
-    return &UserService{repo: repo}
+// NewUserService creates a new UserService. The user who verifies
+// adminEmail, if any, becomes an admin.
+func NewUserService(repo repository.UserRepository, tx repository.TxManager, signer *auth.Signer, mailer mail.Mailer, publicURL, adminEmail string) *UserService {
+    return &UserService{
+        repo:       repo,
+        tx:         tx,
+        signer:     signer,
+        mailer:     mailer,
+        publicURL:  publicURL,
+        adminEmail: adminEmail,
+    }
 }
 
-// CreateUser registers a new user.
-func (s *UserService) CreateUser(ctx context.Context, input domain.User) (domain.User, error) {
+// CreateUser registers a new customer who logs in with password, and mails
+// them a link to verify their email.
+func (s *UserService) CreateUser(ctx context.Context, input domain.User, password string) (domain.User, error) {
     user := domain.User{
-        ID:    uuid.NewString(),
-        Name:  input.Name,
-        Email: input.Email,
+        ID:        uuid.NewString(),
+        Name:      input.Name,
+        Email:     input.Email,
+        Role:      domain.RoleCustomer,
+        CreatedAt: time.Now().UTC(),
     }
-
     if err := user.Validate(); err != nil {
         return domain.User{}, fmt.Errorf("%w: %w", ErrValidation, err)
     }
+    if err := validatePassword(password); err != nil {
+        return domain.User{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
 
     if _, err := s.repo.GetByEmail(ctx, user.Email); err == nil {
         return domain.User{}, repository.ErrConflict
@@ -38,19 +67,227 @@ func (s *UserService) CreateUser(ctx context.Context, input domain.User) (domain
         return domain.User{}, err
     }
 
+    hash, err := auth.HashPassword(password)
+    if err != nil {
+        return domain.User{}, err
+    }
+    user.PasswordHash = hash
+
     if err := s.repo.Create(ctx, user); err != nil {
         return domain.User{}, err
     }
 
+    // The user can ask for another link, so a mail failure does not undo
+    // the registration.
+    if err := s.sendAccountToken(ctx, user, verifyEmailToken); err != nil {
+        log.Printf("users: send verification email to user %s: %v", user.ID, err)
+    }
     return user, nil
 }
 
-// GetUser returns a user by ID.
+// GetUser returns a user by ID. Users may read their own profile; reading
+// anyone else's needs the users:read permission.
 func (s *UserService) GetUser(ctx context.Context, id string) (domain.User, error) {
+    if err := authorizeOwner(ctx, "account", id, domain.PermissionUsersRead); err != nil {
+        return domain.User{}, err
+    }
     return s.repo.GetByID(ctx, id)
 }
 
-// ListUsers returns all registered users.
-func (s *UserService) ListUsers(ctx context.Context) ([]domain.User, error) {
-    return s.repo.List(ctx)
+// UpdateUserInput holds the profile fields to change; nil fields are left
+// as they are.
+type UpdateUserInput struct {
+    Name  *string
+    Email *string
+}
+
+// UpdateUser changes a user's profile. Users may update their own profile;
+// updating anyone else's needs the users:manage permission. A new email has
+// to be verified again.
+func (s *UserService) UpdateUser(ctx context.Context, id string, input UpdateUserInput) (domain.User, error) {
+    if err := authorizeOwner(ctx, "account", id, domain.PermissionUsersManage); err != nil {
+        return domain.User{}, err
+    }
+
+    var (
+        user         domain.User
+        emailChanged bool
+    )
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        var err error
+        user, err = repos.Users.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        if user.DeletedAt != nil {
+            return errAccountDeleted
+        }
+
+        previousEmail := user.Email
+        if input.Name != nil {
+            user.Name = *input.Name
+        }
+        if input.Email != nil {
+            user.Email = *input.Email
+        }
+        if err := user.Validate(); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+
+        if user.Email != previousEmail {
+            if _, err := repos.Users.GetByEmail(ctx, user.Email); err == nil {
+                return fmt.Errorf("%w: email is already registered", repository.ErrConflict)
+            } else if !errors.Is(err, repository.ErrNotFound) {
+                return err
+            }
+            emailChanged = true
+            user.EmailVerified = false
+        }
+        return repos.Users.Update(ctx, user)
+    })
+    if err != nil {
+        return domain.User{}, err
+    }
+
+    if emailChanged {
+        if err := s.sendAccountToken(ctx, user, verifyEmailToken); err != nil {
+            log.Printf("users: send verification email to user %s: %v", user.ID, err)
+        }
+    }
+    return user, nil
+}
+
+// DeleteUser deletes a user's account. The user record is kept so that the
+// user's orders still refer to it, but everything that identifies the user
+// is erased, the cart and address book are dropped and all sessions are
+// revoked. Orders keep their address snapshots. Users may
+// delete their own account; deleting anyone else's needs the users:manage
+// permission. Deleting an account twice is not an error.
+func (s *UserService) DeleteUser(ctx context.Context, id string) error {
+    if err := authorizeOwner(ctx, "account", id, domain.PermissionUsersManage); err != nil {
+        return err
+    }
+
+    return s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        user, err := repos.Users.GetByID(ctx, id)
+        if err != nil {
+            return err
+        }
+        if user.DeletedAt != nil {
+            return nil
+        }
+        // Otherwise an admin could delete the last admin account.
+        if user.Role == domain.RoleAdmin {
+            return fmt.Errorf("%w: an admin account cannot be deleted; change its role first", ErrForbidden)
+        }
+
+        now := time.Now().UTC()
+        if err := repos.Users.Update(ctx, anonymize(user, now)); err != nil {
+            return err
+        }
+        if err := repos.Carts.Delete(ctx, id); err != nil {
+            return err
+        }
+        addresses, err := repos.Addresses.ListByUser(ctx, id)
+        if err != nil {
+            return err
+        }
+        for _, address := range addresses {
+            if err := repos.Addresses.Delete(ctx, address.ID); err != nil {
+                return err
+            }
+        }
+        return repos.RefreshTokens.RevokeUser(ctx, id, now)
+    })
+}
+
+// errAccountDeleted rejects changes to a deleted account.
+var errAccountDeleted = fmt.Errorf("%w: account has been deleted", repository.ErrConflict)
+
+// anonymize strips a user of personal data and credentials. The email is
+// replaced by a unique address on a reserved domain, which keeps the record
+// valid and frees the original address for a new registration.
+func anonymize(user domain.User, at time.Time) domain.User {
+    return domain.User{
+        ID:        user.ID,
+        Name:      "Deleted user",
+        Email:     fmt.Sprintf("deleted-%s@deleted.invalid", user.ID),
+        Role:      domain.RoleCustomer,
+        CreatedAt: user.CreatedAt,
+        DeletedAt: &at,
+    }
+}
+
+// SetRole changes a user's role. Users cannot change their own role, so an
+// admin cannot lock the last admin out by accident.
+func (s *UserService) SetRole(ctx context.Context, id string, role domain.Role) (domain.User, error) {
+    actor, err := authorize(ctx, domain.PermissionUsersManage)
+    if err != nil {
+        return domain.User{}, err
+    }
+    if !role.Valid() {
+        return domain.User{}, fmt.Errorf("%w: unknown role %q", ErrValidation, role)
+    }
+    if actor.ID == id {
+        return domain.User{}, fmt.Errorf("%w: cannot change your own role", ErrForbidden)
+    }
+
+    if err := s.repo.SetRole(ctx, id, role); err != nil {
+        return domain.User{}, err
+    }
+    return s.repo.GetByID(ctx, id)
+}
+
+// EnsureAdmin promotes the admin account, if it is registered and its email
+// verified, to the admin role. It runs at startup so that the account
+// configured for an existing deployment gets its role without registering
+// again.
+func (s *UserService) EnsureAdmin(ctx context.Context) error {
+    if s.adminEmail == "" {
+        return nil
+    }
+    user, err := s.repo.GetByEmail(ctx, s.adminEmail)
+    if errors.Is(err, repository.ErrNotFound) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if !s.isAdmin(user) || user.Role == domain.RoleAdmin {
+        return nil
+    }
+    return s.repo.SetRole(ctx, user.ID, domain.RoleAdmin)
+}
+
+// isAdmin reports whether user has proved they own the admin email. Until
+// they have, anyone could claim the address by registering with it or
+// changing their email to it.
+func (s *UserService) isAdmin(user domain.User) bool {
+    return s.adminEmail != "" && user.Email == s.adminEmail && user.EmailVerified && user.DeletedAt == nil
+}
+
+// ListUsers returns a page of registered users.
+func (s *UserService) ListUsers(ctx context.Context, input ListUsersInput) (repository.Page[domain.User], error) {
+    query, err := input.query()
+    if err != nil {
+        return repository.Page[domain.User]{}, err
+    }
+    return s.repo.List(ctx, query)
+}
+
+// Password length bounds. The upper bound keeps a single login request from
+// making the key derivation hash an arbitrarily large input.
+const (
+    minPasswordLength = 8
+    maxPasswordLength = 1024
+)
+
+func validatePassword(password string) error {
+    switch n := utf8.RuneCountInString(password); {
+    case n < minPasswordLength:
+        return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+    case len(password) > maxPasswordLength:
+        return fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+    }
+    return nil
 }
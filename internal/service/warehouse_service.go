@@ -0,0 +1,247 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/google/uuid"
+
+    "cryptotrade/internal/domain"
+    "cryptotrade/internal/repository"
+)
+
+// WarehouseService contains the business logic for warehouses and the stock
+// they hold. A product's Stock is the total across warehouses plus any units
+// not yet assigned to one.
+type WarehouseService struct {
+    warehouses repository.WarehouseRepository
+    levels     repository.StockLevelRepository
+    products   repository.ProductRepository
+    tx         repository.TxManager
+}
+
+// NewWarehouseService creates a new WarehouseService.
+func NewWarehouseService(warehouseRepo repository.WarehouseRepository, levelRepo repository.StockLevelRepository, productRepo repository.ProductRepository, tx repository.TxManager) *WarehouseService {
+    return &WarehouseService{warehouses: warehouseRepo, levels: levelRepo, products: productRepo, tx: tx}
+}
+
+// ProductStock breaks a product's stock down by warehouse, with a level for
+// each variant a warehouse holds.
+type ProductStock struct {
+    ProductID string              `json:"product_id"`
+    Stock     int                 `json:"stock"`
+    Reserved  int                 `json:"reserved"`
+    Available int                 `json:"available"`
+    Levels    []domain.StockLevel `json:"levels"`
+    // Unassigned is the stock not held at any warehouse.
+    Unassigned domain.StockLevel `json:"unassigned"`
+}
+
+// TransferInput describes stock moved between warehouses. An empty warehouse
+// ID stands for the product's unassigned stock, so transfers also place
+// unassigned units into a warehouse or take them back out.
+type TransferInput struct {
+    ProductID string
+    // SKU selects the variant to move and is required for products sold as
+    // variants.
+    SKU             string
+    FromWarehouseID string
+    ToWarehouseID   string
+    Quantity        int
+    // Actor is recorded on the ledger entries for the transfer.
+    Actor string
+}
+
+// CreateWarehouse persists a new warehouse.
+func (s *WarehouseService) CreateWarehouse(ctx context.Context, input domain.Warehouse) (domain.Warehouse, error) {
+    warehouse := domain.Warehouse{
+        ID:        uuid.NewString(),
+        Name:      input.Name,
+        Location:  input.Location,
+        Priority:  input.Priority,
+        CreatedAt: time.Now().UTC(),
+    }
+
+    if err := warehouse.Validate(); err != nil {
+        return domain.Warehouse{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+
+    if err := s.warehouses.Create(ctx, warehouse); err != nil {
+        return domain.Warehouse{}, err
+    }
+
+    return warehouse, nil
+}
+
+// UpdateWarehouse updates an existing warehouse by ID.
+func (s *WarehouseService) UpdateWarehouse(ctx context.Context, id string, input domain.Warehouse) (domain.Warehouse, error) {
+    warehouse, err := s.warehouses.GetByID(ctx, id)
+    if err != nil {
+        return domain.Warehouse{}, err
+    }
+
+    warehouse.Name = input.Name
+    warehouse.Location = input.Location
+    warehouse.Priority = input.Priority
+
+    if err := warehouse.Validate(); err != nil {
+        return domain.Warehouse{}, fmt.Errorf("%w: %w", ErrValidation, err)
+    }
+
+    if err := s.warehouses.Update(ctx, warehouse); err != nil {
+        return domain.Warehouse{}, err
+    }
+
+    return warehouse, nil
+}
+
+// GetWarehouse returns a warehouse by ID.
+func (s *WarehouseService) GetWarehouse(ctx context.Context, id string) (domain.Warehouse, error) {
+    return s.warehouses.GetByID(ctx, id)
+}
+
+// ListWarehouses returns all warehouses in priority order.
+func (s *WarehouseService) ListWarehouses(ctx context.Context) ([]domain.Warehouse, error) {
+    return s.warehouses.List(ctx)
+}
+
+// ListWarehouseStock returns the stock levels held at a warehouse.
+func (s *WarehouseService) ListWarehouseStock(ctx context.Context, warehouseID string) ([]domain.StockLevel, error) {
+    if _, err := s.warehouses.GetByID(ctx, warehouseID); err != nil {
+        return nil, err
+    }
+    return s.levels.ListByWarehouse(ctx, warehouseID)
+}
+
+// GetProductStock returns a product's stock broken down by warehouse.
+func (s *WarehouseService) GetProductStock(ctx context.Context, productID string) (ProductStock, error) {
+    product, err := s.products.GetByID(ctx, productID)
+    if err != nil {
+        return ProductStock{}, err
+    }
+    levels, err := s.levels.ListByProduct(ctx, productID)
+    if err != nil {
+        return ProductStock{}, err
+    }
+    return productStock(product, levels), nil
+}
+
+// Transfer moves available units of a product, or of one of its variants,
+// between warehouses. Units held
+// for open orders stay where they were allocated. The product's total stock
+// is unchanged; the ledger records the units leaving one location and
+// arriving at the other.
+func (s *WarehouseService) Transfer(ctx context.Context, input TransferInput) (ProductStock, error) {
+    if input.Quantity <= 0 {
+        return ProductStock{}, fmt.Errorf("%w: quantity must be positive", ErrValidation)
+    }
+    if input.FromWarehouseID == input.ToWarehouseID {
+        return ProductStock{}, fmt.Errorf("%w: source and destination must differ", ErrValidation)
+    }
+    if input.Actor == "" {
+        return ProductStock{}, fmt.Errorf("%w: actor is required", ErrValidation)
+    }
+
+    var stock ProductStock
+    err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+        product, err := repos.Products.GetByID(ctx, input.ProductID)
+        if err != nil {
+            return err
+        }
+        if err := product.CheckSKU(input.SKU); err != nil {
+            return fmt.Errorf("%w: %w", ErrValidation, err)
+        }
+        for _, id := range []string{input.FromWarehouseID, input.ToWarehouseID} {
+            if id == "" {
+                continue
+            }
+            if _, err := repos.Warehouses.GetByID(ctx, id); err != nil {
+                return err
+            }
+        }
+
+        available, err := sourceAvailable(ctx, repos, product, input.SKU, input.FromWarehouseID)
+        if err != nil {
+            return err
+        }
+        if available < input.Quantity {
+            return fmt.Errorf("%w: only %d of %s available to transfer", ErrValidation, available, describeItem(product.ID, input.SKU))
+        }
+
+        transferID := uuid.NewString()
+        now := time.Now().UTC()
+        for _, leg := range []struct {
+            warehouseID string
+            quantity    int
+        }{
+            {input.FromWarehouseID, -input.Quantity},
+            {input.ToWarehouseID, input.Quantity},
+        } {
+            if err := moveStock(ctx, repos, leg.warehouseID, product.ID, input.SKU, leg.quantity); err != nil {
+                return err
+            }
+            if err := recordMovement(ctx, repos, domain.StockMovement{
+                ProductID:   product.ID,
+                SKU:         input.SKU,
+                WarehouseID: leg.warehouseID,
+                Quantity:    leg.quantity,
+                Reason:      domain.StockMovementTransfer,
+                ReferenceID: transferID,
+                Actor:       input.Actor,
+                CreatedAt:   now,
+            }); err != nil {
+                return err
+            }
+        }
+
+        levels, err := repos.StockLevels.ListByProduct(ctx, product.ID)
+        if err != nil {
+            return err
+        }
+        stock = productStock(product, levels)
+        return nil
+    })
+    if err != nil {
+        return ProductStock{}, err
+    }
+
+    return stock, nil
+}
+
+// moveStock changes the quantity on hand of a product, or of its variant sku,
+// at a warehouse by delta. Unassigned stock is whatever the levels do not
+// account for, so it needs no write of its own.
+func moveStock(ctx context.Context, repos repository.Repositories, warehouseID, productID, sku string, delta int) error {
+    if warehouseID == "" {
+        return nil
+    }
+    level, err := loadLevel(ctx, repos, warehouseID, productID, sku)
+    if err != nil {
+        return err
+    }
+    level.OnHand += delta
+    return repos.StockLevels.Save(ctx, level)
+}
+
+// loadLevel returns the level of a product, or of its variant sku, at a
+// warehouse, or an empty one if the warehouse has never stocked it.
+func loadLevel(ctx context.Context, repos repository.Repositories, warehouseID, productID, sku string) (domain.StockLevel, error) {
+    level, err := repos.StockLevels.Get(ctx, warehouseID, productID, sku)
+    if errors.Is(err, repository.ErrNotFound) {
+        return domain.StockLevel{WarehouseID: warehouseID, ProductID: productID, SKU: sku}, nil
+    }
+    return level, err
+}
+
+func productStock(product domain.Product, levels []domain.StockLevel) ProductStock {
+    return ProductStock{
+        ProductID:  product.ID,
+        Stock:      product.Stock,
+        Reserved:   product.Reserved,
+        Available:  product.Available(),
+        Levels:     levels,
+        Unassigned: unassignedStock(product, levels),
+    }
+}
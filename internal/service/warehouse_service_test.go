@@ -0,0 +1,173 @@
+package service
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "cryptotrade/internal/domain"
+)
+
+// variantStockFixture stocks a product sold in two colours at two
+// warehouses: the preferred one holds only black units, the other only
+// orange ones.
+type variantStockFixture struct {
+    *paymentFixture
+    warehouses *WarehouseService
+    stock      *StockService
+    north      domain.Warehouse
+    south      domain.Warehouse
+}
+
+const variantProductID = "wallet"
+
+func newVariantStockFixture(t *testing.T) *variantStockFixture {
+    t.Helper()
+    ctx := context.Background()
+    f := &variantStockFixture{paymentFixture: newPaymentFixture(t, nil)}
+    f.warehouses = NewWarehouseService(f.repos.Warehouses, f.repos.StockLevels, f.repos.Products, f.store)
+    f.stock = NewStockService(f.repos.Products, f.repos.StockMovements, f.store)
+
+    price := domain.NewMoney(100_000, "BTC")
+    if err := f.repos.Products.Create(ctx, domain.Product{
+        ID:      variantProductID,
+        Name:    "Wallet",
+        Price:   price,
+        Stock:   10,
+        Options: []domain.ProductOption{{Name: "colour", Values: []string{"black", "orange"}}},
+        Variants: []domain.Variant{
+            {SKU: "BLK", Options: map[string]string{"colour": "black"}, Price: price, Stock: 5},
+            {SKU: "ORG", Options: map[string]string{"colour": "orange"}, Price: price, Stock: 5},
+        },
+        CreatedAt: time.Now(),
+    }); err != nil {
+        t.Fatal(err)
+    }
+
+    var err error
+    if f.north, err = f.warehouses.CreateWarehouse(ctx, domain.Warehouse{Name: "North", Priority: 0}); err != nil {
+        t.Fatal(err)
+    }
+    if f.south, err = f.warehouses.CreateWarehouse(ctx, domain.Warehouse{Name: "South", Priority: 1}); err != nil {
+        t.Fatal(err)
+    }
+    f.transfer(t, "BLK", "", f.north.ID, 5)
+    f.transfer(t, "ORG", "", f.south.ID, 5)
+    return f
+}
+
+func (f *variantStockFixture) transfer(t *testing.T, sku, from, to string, quantity int) {
+    t.Helper()
+    if _, err := f.warehouses.Transfer(context.Background(), TransferInput{
+        ProductID: variantProductID, SKU: sku, FromWarehouseID: from, ToWarehouseID: to, Quantity: quantity, Actor: "user:staff",
+    }); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func (f *variantStockFixture) level(t *testing.T, warehouseID, sku string) domain.StockLevel {
+    t.Helper()
+    level, err := f.repos.StockLevels.Get(context.Background(), warehouseID, variantProductID, sku)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return level
+}
+
+func TestAllocationFollowsVariant(t *testing.T) {
+    ctx := context.Background()
+    f := newVariantStockFixture(t)
+
+    order, err := f.orders.CreateOrder(ctx, CreateOrderInput{
+        UserID:        f.userID,
+        Items:         []domain.OrderItem{{SKU: "ORG", Quantity: 3}},
+        PaymentMethod: domain.PaymentMethodOnChain,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    // North comes first by priority but holds no orange units.
+    want := domain.Allocation{ProductID: variantProductID, SKU: "ORG", WarehouseID: f.south.ID, Quantity: 3}
+    if len(order.Allocations) != 1 || order.Allocations[0] != want {
+        t.Fatalf("allocations = %+v, want %+v", order.Allocations, want)
+    }
+    if level := f.level(t, f.south.ID, "ORG"); level.Reserved != 3 {
+        t.Errorf("south holds %d orange units for the order, want 3", level.Reserved)
+    }
+    if level := f.level(t, f.north.ID, "BLK"); level.Reserved != 0 {
+        t.Errorf("north holds %d black units for an orange order", level.Reserved)
+    }
+
+    // The remaining orange units are all at South; an order for more than
+    // that cannot be covered by black units elsewhere.
+    _, err = f.orders.CreateOrder(ctx, CreateOrderInput{
+        UserID:        f.userID,
+        Items:         []domain.OrderItem{{SKU: "ORG", Quantity: 3}},
+        PaymentMethod: domain.PaymentMethodOnChain,
+    })
+    if !errors.Is(err, ErrValidation) {
+        t.Errorf("ordering more orange units than are left: got %v, want ErrValidation", err)
+    }
+}
+
+func TestTransferMovesOneVariant(t *testing.T) {
+    ctx := context.Background()
+    f := newVariantStockFixture(t)
+
+    // North holds black units only, so it has no orange ones to give.
+    _, err := f.warehouses.Transfer(ctx, TransferInput{
+        ProductID: variantProductID, SKU: "ORG", FromWarehouseID: f.north.ID, ToWarehouseID: f.south.ID, Quantity: 1, Actor: "user:staff",
+    })
+    if !errors.Is(err, ErrValidation) {
+        t.Fatalf("transferring a variant the warehouse does not hold: got %v, want ErrValidation", err)
+    }
+    _, err = f.warehouses.Transfer(ctx, TransferInput{
+        ProductID: variantProductID, FromWarehouseID: f.north.ID, ToWarehouseID: f.south.ID, Quantity: 1, Actor: "user:staff",
+    })
+    if !errors.Is(err, ErrValidation) {
+        t.Fatalf("transfer without a sku: got %v, want ErrValidation", err)
+    }
+
+    f.transfer(t, "BLK", f.north.ID, f.south.ID, 2)
+    if level := f.level(t, f.north.ID, "BLK"); level.OnHand != 3 {
+        t.Errorf("north holds %d black units, want 3", level.OnHand)
+    }
+    if level := f.level(t, f.south.ID, "BLK"); level.OnHand != 2 {
+        t.Errorf("south holds %d black units, want 2", level.OnHand)
+    }
+    if level := f.level(t, f.south.ID, "ORG"); level.OnHand != 5 {
+        t.Errorf("south holds %d orange units, want them untouched at 5", level.OnHand)
+    }
+}
+
+func TestAdjustStockAtWarehouseChangesOneVariant(t *testing.T) {
+    ctx := context.Background()
+    f := newVariantStockFixture(t)
+
+    counted := 2
+    entry, err := f.stock.AdjustStock(ctx, StockAdjustmentInput{
+        ProductID: variantProductID, SKU: "ORG", WarehouseID: f.south.ID, Stock: &counted, Actor: "user:staff",
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if entry.Quantity != -3 || entry.LocationBalance != 2 || entry.Balance != 7 {
+        t.Errorf("entry moved %d to a location balance of %d and a total of %d, want -3, 2 and 7", entry.Quantity, entry.LocationBalance, entry.Balance)
+    }
+    if level := f.level(t, f.south.ID, "ORG"); level.OnHand != 2 {
+        t.Errorf("south holds %d orange units, want 2", level.OnHand)
+    }
+
+    // North holds no orange units to remove.
+    delta := -1
+    _, err = f.stock.AdjustStock(ctx, StockAdjustmentInput{
+        ProductID: variantProductID, SKU: "ORG", WarehouseID: f.north.ID, Delta: &delta, Actor: "user:staff",
+    })
+    if !errors.Is(err, ErrValidation) {
+        t.Errorf("removing a variant the warehouse does not hold: got %v, want ErrValidation", err)
+    }
+    if level := f.level(t, f.north.ID, "BLK"); level.OnHand != 5 {
+        t.Errorf("north holds %d black units, want them untouched at 5", level.OnHand)
+    }
+}
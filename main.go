@@ -2,17 +2,26 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	"cryptotrade/internal/auth"
 	"cryptotrade/internal/config"
+	"cryptotrade/internal/domain"
 	"cryptotrade/internal/handler"
+	"cryptotrade/internal/mail"
+	"cryptotrade/internal/payment"
+	"cryptotrade/internal/repository"
 	"cryptotrade/internal/repository/memory"
+	"cryptotrade/internal/repository/sqlite"
 	"cryptotrade/internal/router"
 	"cryptotrade/internal/service"
 )
@@ -20,19 +29,49 @@ import (
 func main() {
 	cfg := config.Load()
 
-	productRepo := memory.NewProductRepository()
-	userRepo := memory.NewUserRepository()
-	orderRepo := memory.NewOrderRepository()
+	repos, txManager, closeStore := openStore(cfg)
+	defer closeStore()
 
-	productService := service.NewProductService(productRepo)
-	userService := service.NewUserService(userRepo)
-	orderService := service.NewOrderService(orderRepo, userRepo, productRepo)
+	rates := openRateProvider(cfg)
+	signer := openTokenSigner(cfg)
+
+	productService := service.NewProductService(repos.Products, txManager, rates, service.NewInvertedIndex())
+	if err := productService.ReindexProducts(context.Background()); err != nil {
+		log.Fatalf("build search index: %v", err)
+	}
+	userService := service.NewUserService(repos.Users, txManager, signer, openMailer(cfg), cfg.PublicURL, cfg.AdminEmail)
+	if err := userService.EnsureAdmin(context.Background()); err != nil {
+		log.Fatalf("promote ADMIN_EMAIL: %v", err)
+	}
+	chainClients := openChainClients(cfg)
+	paymentService := service.NewPaymentService(repos.Invoices, rates, cfg.InvoiceTTL, openLightningNode(cfg), openAddressDerivers(cfg, chainClients)...)
+	orderService := service.NewOrderService(repos.Orders, repos.Users, repos.Products, txManager, rates, paymentService, cfg.ReservationTTL, openAllocationStrategy(cfg), openShippingFee(cfg))
+	cartService := service.NewCartService(repos.Carts, repos.Users, repos.Products, txManager, rates, orderService)
+	warehouseService := service.NewWarehouseService(repos.Warehouses, repos.StockLevels, repos.Products, txManager)
+	stockService := service.NewStockService(repos.Products, repos.StockMovements, txManager)
+	categoryService := service.NewCategoryService(repos.Categories, repos.Products, txManager)
+	apiKeyService := service.NewAPIKeyService(repos.APIKeys)
+	addressService := service.NewAddressService(repos.Addresses, txManager)
+	promotionService := service.NewPromotionService(repos.Promotions, txManager)
+	authService := service.NewAuthService(repos.Users, repos.RefreshTokens, txManager, signer, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
 
 	productHandler := handler.NewProductHandler(productService)
 	userHandler := handler.NewUserHandler(userService)
 	orderHandler := handler.NewOrderHandler(orderService)
+	cartHandler := handler.NewCartHandler(cartService)
+	warehouseHandler := handler.NewWarehouseHandler(warehouseService)
+	stockHandler := handler.NewStockHandler(stockService)
+	categoryHandler := handler.NewCategoryHandler(categoryService)
+	authHandler := handler.NewAuthHandler(authService, apiKeyService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	addressHandler := handler.NewAddressHandler(addressService)
+	promotionHandler := handler.NewPromotionHandler(promotionService)
 
-	engine := router.SetupRouter(cfg, productHandler, userHandler, orderHandler)
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	watcherDone := startPaymentWatcher(workersCtx, cfg, repos, txManager, chainClients)
+	sweeperDone := startReservationSweeper(workersCtx, cfg, txManager)
+
+	engine := router.SetupRouter(cfg, productHandler, userHandler, orderHandler, cartHandler, warehouseHandler, stockHandler, categoryHandler, authHandler, apiKeyHandler, addressHandler, promotionHandler)
 
 	srv := &http.Server{
 		Addr:         cfg.ServerPort,
@@ -60,4 +99,246 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("graceful shutdown failed: %v", err)
 	}
+
+	stopWorkers()
+	<-watcherDone
+	<-sweeperDone
+}
+
+// openStore builds the repositories and transaction manager for the configured
+// storage driver. The returned function releases any resources held by the backend.
+func openStore(cfg config.Config) (repository.Repositories, repository.TxManager, func()) {
+	switch cfg.StorageDriver {
+	case "memory":
+		store := memory.NewStore()
+		return store.Repositories(), store, func() {}
+	case "sqlite":
+		store, err := sqlite.Open(cfg.SQLitePath)
+		if err != nil {
+			log.Fatalf("open sqlite store: %v", err)
+		}
+		log.Printf("using sqlite store at %s", cfg.SQLitePath)
+		closeStore := func() {
+			if err := store.Close(); err != nil {
+				log.Printf("close sqlite store: %v", err)
+			}
+		}
+		return store.Repositories(), store, closeStore
+	default:
+		log.Fatalf("unknown storage driver %q", cfg.StorageDriver)
+		return repository.Repositories{}, nil, nil
+	}
+}
+
+// devExchangeRates seeds the static rate provider when no rate file is configured.
+// The figures are illustrative only.
+var devExchangeRates = map[string]string{
+	"BTC/USD":  "65000",
+	"ETH/USD":  "3200",
+	"USDT/USD": "1",
+	"EUR/USD":  "1.08",
+	"GBP/USD":  "1.27",
+	"USD/JPY":  "150",
+}
+
+// openRateProvider selects the exchange rate source for the configured
+// environment. The illustrative development rates are refused in production,
+// where they would price real orders.
+func openRateProvider(cfg config.Config) service.ExchangeRateProvider {
+	if cfg.ExchangeRatesFile != "" {
+		provider, err := service.NewFileRateProvider(cfg.ExchangeRatesFile)
+		if err != nil {
+			log.Fatalf("load exchange rates: %v", err)
+		}
+		log.Printf("using exchange rates from %s", cfg.ExchangeRatesFile)
+		return provider
+	}
+	if cfg.Environment == "production" {
+		log.Fatal("EXCHANGE_RATES_FILE must be set in production")
+	}
+
+	provider, err := service.NewStaticRateProvider(devExchangeRates, time.Now())
+	if err != nil {
+		log.Fatalf("load exchange rates: %v", err)
+	}
+	log.Println("EXCHANGE_RATES_FILE not set; using illustrative development rates")
+	return provider
+}
+
+// openAddressDerivers builds a deposit address deriver for every coin with a
+// configured xpub. The memory store forgets which addresses it handed out
+// when the server restarts and would give them out again, so on-chain
+// invoices need a persistent store. Each coin also needs one of clients to
+// watch for payments, or its invoices could never settle.
+func openAddressDerivers(cfg config.Config, clients []payment.ChainClient) []payment.AddressDeriver {
+	if cfg.StorageDriver == "memory" && (cfg.BitcoinXPub != "" || cfg.EthereumXPub != "") {
+		log.Fatal("BTC_XPUB and ETH_XPUB need STORAGE_DRIVER=sqlite so that deposit addresses are never reused")
+	}
+
+	var derivers []payment.AddressDeriver
+	if cfg.BitcoinXPub != "" {
+		d, err := payment.NewBitcoinDeriver(cfg.BitcoinXPub)
+		if err != nil {
+			log.Fatalf("load BTC_XPUB: %v", err)
+		}
+		derivers = append(derivers, d)
+	}
+	if cfg.EthereumXPub != "" {
+		d, err := payment.NewEthereumDeriver(cfg.EthereumXPub)
+		if err != nil {
+			log.Fatalf("load ETH_XPUB: %v", err)
+		}
+		derivers = append(derivers, d)
+	}
+	if len(derivers) == 0 {
+		log.Println("no payment xpubs configured; orders will be created without invoices")
+	}
+	for _, d := range derivers {
+		watched := slices.ContainsFunc(clients, func(c payment.ChainClient) bool { return c.Currency() == d.Currency() })
+		if !watched {
+			log.Fatalf("%s_XPUB is set but no %s chain client is configured to watch for payments", d.Currency(), d.Currency())
+		}
+	}
+	return derivers
+}
+
+// openLightningNode returns the configured Lightning node, or nil when
+// Lightning payments are disabled.
+func openLightningNode(cfg config.Config) payment.LightningNode {
+	switch cfg.LightningNode {
+	case "":
+		return nil
+	case "mock":
+		if cfg.Environment == "production" {
+			log.Fatal("LIGHTNING_NODE=mock is not allowed in production; its logged preimages let anyone settle orders")
+		}
+		node, err := payment.NewMockLightningNode()
+		if err != nil {
+			log.Fatalf("start mock lightning node: %v", err)
+		}
+		node.LogPreimages = true
+		log.Println("using mock lightning node; settle invoices with the logged preimages")
+		return node
+	default:
+		log.Fatalf("unknown lightning node %q", cfg.LightningNode)
+		return nil
+	}
+}
+
+// openTokenSigner builds the signer for session tokens. Outside production a
+// missing JWT_SECRET is replaced by a random key, which invalidates every
+// session when the server restarts.
+func openTokenSigner(cfg config.Config) *auth.Signer {
+	if cfg.JWTSecret != "" {
+		return auth.NewSigner([]byte(cfg.JWTSecret))
+	}
+	if cfg.Environment == "production" {
+		log.Fatal("JWT_SECRET must be set in production")
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("generate token signing key: %v", err)
+	}
+	log.Println("JWT_SECRET not set; using a random signing key, sessions will not survive a restart")
+	return auth.NewSigner(key)
+}
+
+// openMailer selects how emails are sent. Without an SMTP relay they are
+// written to MAIL_DROP_DIR, which is refused in production since nobody
+// would receive them.
+func openMailer(cfg config.Config) mail.Mailer {
+	if cfg.SMTPHost != "" {
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	}
+	if cfg.Environment == "production" {
+		log.Fatal("SMTP_HOST must be set in production")
+	}
+
+	mailer, err := mail.NewFileMailer(cfg.MailDropDir, cfg.MailFrom)
+	if err != nil {
+		log.Fatalf("open mail drop: %v", err)
+	}
+	log.Printf("SMTP_HOST not set; writing emails to %s", cfg.MailDropDir)
+	return mailer
+}
+
+// openAllocationStrategy selects how order items are allocated to warehouses.
+func openAllocationStrategy(cfg config.Config) service.AllocationStrategy {
+	strategy, err := service.NewAllocationStrategy(cfg.AllocationStrategy)
+	if err != nil {
+		log.Fatalf("load ALLOCATION_STRATEGY: %v", err)
+	}
+	return strategy
+}
+
+// openShippingFee parses the flat shipping fee, or returns nil when orders ship free.
+func openShippingFee(cfg config.Config) *domain.Money {
+	if cfg.ShippingFee == "" {
+		return nil
+	}
+	amount, currency, _ := strings.Cut(strings.TrimSpace(cfg.ShippingFee), " ")
+	fee, err := domain.ParseMoney(amount, strings.TrimSpace(currency))
+	if err != nil {
+		log.Fatalf("load SHIPPING_FEE: %v", err)
+	}
+	if fee.IsNegative() {
+		log.Fatal("load SHIPPING_FEE: the fee cannot be negative")
+	}
+	return &fee
+}
+
+// openChainClients builds a client for every coin with a configured chain
+// source: Esplora for BTC and an Etherscan-compatible API for ETH.
+func openChainClients(cfg config.Config) []payment.ChainClient {
+	var clients []payment.ChainClient
+	if cfg.BitcoinEsploraURL != "" {
+		clients = append(clients, payment.NewEsploraClient(cfg.BitcoinEsploraURL))
+	}
+	if cfg.EthereumExplorerURL != "" {
+		client, err := payment.NewEtherscanClient(cfg.EthereumExplorerURL, cfg.EthereumExplorerAPIKey)
+		if err != nil {
+			log.Fatalf("load ETH_EXPLORER_URL: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// startPaymentWatcher runs the blockchain payment watcher in the background
+// when a chain client is configured. The returned channel closes once the
+// watcher has stopped after ctx is cancelled.
+func startPaymentWatcher(ctx context.Context, cfg config.Config, repos repository.Repositories, txManager repository.TxManager, clients []payment.ChainClient) <-chan struct{} {
+	done := make(chan struct{})
+
+	if len(clients) == 0 {
+		close(done)
+		return done
+	}
+
+	confirmations := map[string]int{
+		"BTC": cfg.BitcoinConfirmations,
+		"ETH": cfg.EthereumConfirmations,
+	}
+	watcher := service.NewPaymentWatcher(repos.Invoices, txManager, cfg.PaymentPollInterval, confirmations, clients...)
+
+	go func() {
+		defer close(done)
+		log.Printf("payment watcher polling every %s", cfg.PaymentPollInterval)
+		watcher.Run(ctx)
+	}()
+	return done
+}
+
+// startReservationSweeper releases expired stock holds in the background. The
+// returned channel closes once the sweeper has stopped after ctx is cancelled.
+func startReservationSweeper(ctx context.Context, cfg config.Config, txManager repository.TxManager) <-chan struct{} {
+	done := make(chan struct{})
+	sweeper := service.NewReservationSweeper(txManager, cfg.ReservationSweepInterval, cfg.ReservationTTL)
+
+	go func() {
+		defer close(done)
+		sweeper.Run(ctx)
+	}()
+	return done
 }